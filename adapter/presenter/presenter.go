@@ -3,6 +3,7 @@ package presenter
 import (
 	"time"
 
+	"go-feedmaker/entity"
 	"go-feedmaker/interactor"
 )
 
@@ -10,14 +11,86 @@ type (
 	Presenter struct{}
 
 	generationOut struct {
-		ID            string  `json:"id"`
-		Type          string  `json:"type"`
-		Progress      uint    `json:"progress"`
-		DataFetched   bool    `json:"data_fetched"`
-		FilesUploaded uint    `json:"files_uploaded"`
-		IsCanceled    bool    `json:"is_canceled"`
-		StartTime     string  `json:"start_time"`
-		EndTime       *string `json:"end_time"`
+		ID            string            `json:"id"`
+		Type          string            `json:"type"`
+		Status        string            `json:"status"`
+		Progress      uint              `json:"progress"`
+		DataFetched   bool              `json:"data_fetched"`
+		FilesUploaded uint              `json:"files_uploaded"`
+		IsCanceled    bool              `json:"is_canceled"`
+		StartTime     string            `json:"start_time"`
+		EndTime       *string           `json:"end_time"`
+		RecordsCount  uint              `json:"records_count"`
+		Error         string            `json:"error,omitempty"`
+		Parameters    map[string]string `json:"parameters,omitempty"`
+		Stages        []*stageOut       `json:"stages,omitempty"`
+		Manifest      *manifestOut      `json:"manifest,omitempty"`
+		Destinations  []*destinationOut `json:"destinations,omitempty"`
+	}
+
+	generationsPageOut struct {
+		Generations []*generationOut `json:"generations"`
+		NextCursor  string           `json:"next_cursor,omitempty"`
+	}
+
+	stageOut struct {
+		Name      string  `json:"name"`
+		StartTime *string `json:"start_time"`
+		EndTime   *string `json:"end_time"`
+	}
+
+	destinationOut struct {
+		Name          string `json:"name"`
+		State         string `json:"state"`
+		FilesUploaded uint   `json:"files_uploaded"`
+		Error         string `json:"error,omitempty"`
+	}
+
+	manifestOut struct {
+		CreatedTime string         `json:"created_time"`
+		Records     uint           `json:"records"`
+		Files       []*fileInfoOut `json:"files"`
+	}
+
+	fileInfoOut struct {
+		Name      string `json:"name"`
+		Partition string `json:"partition,omitempty"`
+		Size      int64  `json:"size"`
+		Records   uint   `json:"records"`
+		SHA256    string `json:"sha256"`
+	}
+
+	filePreviewOut struct {
+		Name string     `json:"name"`
+		Rows [][]string `json:"rows"`
+	}
+
+	feedPreviewOut struct {
+		Type           string               `json:"type"`
+		DryRun         bool                 `json:"dry_run"`
+		RecordsCount   uint                 `json:"records_count"`
+		Records        uint                 `json:"records"`
+		InvalidRecords uint                 `json:"invalid_records"`
+		Rejected       []*rejectedRecordOut `json:"rejected,omitempty"`
+		Files          []*previewFileOut    `json:"files"`
+	}
+
+	rejectedRecordOut struct {
+		Record []string `json:"record"`
+		Error  string   `json:"error"`
+	}
+
+	previewFileOut struct {
+		*fileInfoOut
+		Content string `json:"content,omitempty"`
+	}
+
+	logEntryOut struct {
+		Time    string                 `json:"time"`
+		Level   string                 `json:"level"`
+		Message string                 `json:"message"`
+		Error   string                 `json:"error,omitempty"`
+		Fields  map[string]interface{} `json:"fields,omitempty"`
 	}
 )
 
@@ -26,12 +99,70 @@ func (p *Presenter) PresentGenerationTypes(out []string) interface{} {
 }
 
 func (p *Presenter) PresentListGenerations(generations *interactor.ListGenerationsOut) interface{} {
-	generationsOut := make([]*generationOut, len(*generations))
-	for i, generation := range *generations {
+	generationsOut := make([]*generationOut, len(generations.Generations))
+	for i, generation := range generations.Generations {
 		generationOut := makeGenerationOut(generation)
 		generationsOut[i] = generationOut
 	}
-	return generationsOut
+	return &generationsPageOut{
+		Generations: generationsOut,
+		NextCursor:  generations.NextCursor,
+	}
+}
+
+func (p *Presenter) PresentGeneration(generation *interactor.GenerationsOut) interface{} {
+	return makeGenerationOut(generation)
+}
+
+func (p *Presenter) PresentGenerationFiles(files []*entity.FileInfo) interface{} {
+	out := make([]*fileInfoOut, len(files))
+	for i, file := range files {
+		out[i] = makeFileInfoOut(file)
+	}
+	return out
+}
+
+func (p *Presenter) PresentFilePreview(preview *interactor.FilePreviewOut) interface{} {
+	return &filePreviewOut{
+		Name: preview.Name,
+		Rows: preview.Rows,
+	}
+}
+
+func (p *Presenter) PresentFeedPreview(preview *entity.FeedPreview) interface{} {
+	out := &feedPreviewOut{
+		Type:           preview.Type,
+		DryRun:         preview.DryRun,
+		RecordsCount:   preview.RecordsCount,
+		Records:        preview.Records,
+		InvalidRecords: preview.InvalidRecords,
+		Files:          make([]*previewFileOut, len(preview.Files)),
+	}
+	for _, rejected := range preview.Rejected {
+		out.Rejected = append(out.Rejected, &rejectedRecordOut{
+			Record: rejected.Record,
+			Error:  rejected.Error,
+		})
+	}
+	for i, file := range preview.Files {
+		out.Files[i] = &previewFileOut{
+			fileInfoOut: makeFileInfoOut(file.FileInfo),
+			Content:     file.Content,
+		}
+	}
+	return out
+}
+
+func (p *Presenter) PresentGenerationLogs(entries []*entity.LogEntry) interface{} {
+	out := make([]*logEntryOut, len(entries))
+	for i, entry := range entries {
+		out[i] = makeLogEntryOut(entry)
+	}
+	return out
+}
+
+func (p *Presenter) PresentLogEntry(entry *entity.LogEntry) interface{} {
+	return makeLogEntryOut(entry)
 }
 
 func (p *Presenter) PresentErr(err error) error {
@@ -42,19 +173,78 @@ func makeGenerationOut(generation *interactor.GenerationsOut) *generationOut {
 	generationOut := &generationOut{
 		ID:            generation.ID,
 		Type:          generation.Type,
+		Status:        string((*entity.Generation)(generation).Status()),
 		Progress:      generation.Progress,
 		DataFetched:   generation.DataFetched,
 		FilesUploaded: generation.FilesUploaded,
 		IsCanceled:    generation.IsCanceled,
 		StartTime:     formatTime(generation.StartTime),
+		RecordsCount:  generation.RecordsCount,
+		Error:         generation.Error,
+		Parameters:    generation.Parameters,
+	}
+	generationOut.EndTime = formatOptionalTime(generation.EndTime)
+	for _, stage := range generation.Stages {
+		generationOut.Stages = append(generationOut.Stages, &stageOut{
+			Name:      stage.Name,
+			StartTime: formatOptionalTime(stage.StartTime),
+			EndTime:   formatOptionalTime(stage.EndTime),
+		})
+	}
+	if generation.Manifest != nil {
+		generationOut.Manifest = makeManifestOut(generation.Manifest)
 	}
-	if !generation.EndTime.IsZero() {
-		endTime := formatTime(generation.EndTime)
-		generationOut.EndTime = &endTime
+	for _, destination := range generation.Destinations {
+		generationOut.Destinations = append(generationOut.Destinations, &destinationOut{
+			Name:          destination.Name,
+			State:         string(destination.State),
+			FilesUploaded: destination.FilesUploaded,
+			Error:         destination.Error,
+		})
 	}
 	return generationOut
 }
 
+func makeManifestOut(manifest *entity.Manifest) *manifestOut {
+	out := &manifestOut{
+		CreatedTime: formatTime(manifest.CreatedTime),
+		Files:       make([]*fileInfoOut, len(manifest.Files)),
+	}
+	for i, file := range manifest.Files {
+		out.Records += file.Records
+		out.Files[i] = makeFileInfoOut(file)
+	}
+	return out
+}
+
+func makeFileInfoOut(file *entity.FileInfo) *fileInfoOut {
+	return &fileInfoOut{
+		Name:      file.Name,
+		Partition: file.Partition,
+		Size:      file.Size,
+		Records:   file.Records,
+		SHA256:    file.SHA256,
+	}
+}
+
+func makeLogEntryOut(entry *entity.LogEntry) *logEntryOut {
+	return &logEntryOut{
+		Time:    formatTime(entry.Time),
+		Level:   entry.Level,
+		Message: entry.Message,
+		Error:   entry.Error,
+		Fields:  entry.Fields,
+	}
+}
+
 func formatTime(t time.Time) string {
 	return t.UTC().Format(time.RFC3339)
 }
+
+func formatOptionalTime(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	formatted := formatTime(t)
+	return &formatted
+}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"go-feedmaker/entity"
+	"go-feedmaker/interactor"
+)
+
+type (
+	// Archive keeps a copy of the files every generation uploaded, in a
+	// directory per generation, until the generation is purged.
+	Archive struct {
+		dir string
+	}
+
+	GenerationArchive struct {
+		dir            string
+		generationType string
+	}
+
+	// archivingUploader copies every file into the archive before handing it
+	// to the uploader. A file that can't be archived is still uploaded.
+	archivingUploader struct {
+		interactor.Uploader
+		archive   *GenerationArchive
+		inStream  <-chan io.ReadCloser
+		outStream chan io.ReadCloser
+	}
+)
+
+func NewArchive(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Archive{dir: dir}, nil
+}
+
+func (a *Archive) Generation(generation *entity.Generation) *GenerationArchive {
+	return &GenerationArchive{
+		dir:            filepath.Join(a.dir, generation.ID),
+		generationType: generation.Type,
+	}
+}
+
+// Files lists the archived files by their name relative to the generation
+// directory.
+func (g *GenerationArchive) Files() ([]*entity.FileInfo, error) {
+	files := make([]*entity.FileInfo, 0)
+	err := filepath.Walk(g.dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(g.dir, name)
+		if err != nil {
+			return err
+		}
+		relative = filepath.ToSlash(relative)
+		files = append(files, &entity.FileInfo{
+			Name:      relative,
+			Size:      info.Size(),
+			Partition: path.Dir(relative),
+		})
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return files, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.Partition == "." {
+			file.Partition = ""
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+// Open opens an archived file; names can't point outside the generation
+// directory.
+func (g *GenerationArchive) Open(name string) (*os.File, error) {
+	file, err := os.Open(g.path(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", entity.ErrFileNotFound, name)
+	} else if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, fmt.Errorf("%w: %s", entity.ErrFileNotFound, name)
+	}
+	return file, nil
+}
+
+// Preview reads the first rows of an archived file written in dialect.
+func (g *GenerationArchive) Preview(name string, rows uint, dialect CsvDialect) ([][]string, error) {
+	file, err := g.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	buffered := bufio.NewReader(file)
+	if prefix, _ := buffered.Peek(len(utf8BOM)); string(prefix) == utf8BOM {
+		buffered.Discard(len(utf8BOM))
+	}
+	reader := csv.NewReader(buffered)
+	if dialect.Delimiter != 0 {
+		reader.Comma = dialect.Delimiter
+	}
+	reader.FieldsPerRecord = -1
+	records := make([][]string, 0, rows)
+	for uint(len(records)) < rows {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (g *GenerationArchive) Remove() error {
+	return os.RemoveAll(g.dir)
+}
+
+func (g *GenerationArchive) path(name string) string {
+	return filepath.Join(g.dir, filepath.FromSlash(path.Clean("/"+name)))
+}
+
+// store copies a spooled file into the archive and rewinds it for the
+// upload.
+func (g *GenerationArchive) store(name string, file io.ReadCloser) error {
+	data, ok := file.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	if !ok {
+		return fmt.Errorf("%s isn't spooled", name)
+	}
+	size, err := data.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := data.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	target := g.path(name)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	archived, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(archived, io.NewSectionReader(data, 0, size)); err != nil {
+		archived.Close()
+		return err
+	}
+	return archived.Close()
+}
+
+func newArchivingUploader(
+	archive *GenerationArchive,
+	inStream <-chan io.ReadCloser,
+	createUploader func(inStream <-chan io.ReadCloser) interactor.Uploader,
+) *archivingUploader {
+	outStream := make(chan io.ReadCloser)
+	return &archivingUploader{
+		Uploader:  createUploader(outStream),
+		archive:   archive,
+		inStream:  inStream,
+		outStream: outStream,
+	}
+}
+
+// UploadFiles replaces what an earlier run of the generation archived.
+func (u *archivingUploader) UploadFiles(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := u.archive.Remove(); err != nil {
+		ctxLog(ctx).Error().Err(err).Msgf("Cannot clear archive %s", u.archive.dir)
+	}
+	go u.archiveFiles(ctx)
+	return u.Uploader.UploadFiles(ctx)
+}
+
+func (u *archivingUploader) archiveFiles(ctx context.Context) {
+	defer close(u.outStream)
+	namer := newFileNamer(u.archive.generationType)
+	for file := range u.inStream {
+		name, _ := namer.next(partitionOf(file))
+		if err := u.archive.store(name, file); err != nil {
+			ctxLog(ctx).Error().Err(err).Msgf("Cannot archive %s", name)
+		}
+		select {
+		case u.outStream <- file:
+		case <-ctx.Done():
+			file.Close()
+			return
+		}
+	}
+}
+
+// OnDestinationsUpdated reports the destinations of the wrapped uploader,
+// if it has several.
+func (u *archivingUploader) OnDestinationsUpdated(callback func(destinations []*entity.DestinationStatus)) {
+	if reporter, ok := u.Uploader.(interactor.DestinationsReporter); ok {
+		reporter.OnDestinationsUpdated(callback)
+	}
+}
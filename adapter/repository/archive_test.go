@@ -0,0 +1,221 @@
+package repository_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-feedmaker/adapter/repository"
+	"go-feedmaker/entity"
+	"go-feedmaker/interactor"
+)
+
+type (
+	archivedFile struct {
+		*os.File
+		partition string
+	}
+
+	// readingUploader reads every file it gets, like a real uploader would.
+	readingUploader struct {
+		inStream <-chan io.ReadCloser
+		contents []string
+		err      error
+	}
+)
+
+func (f *archivedFile) Partition() string {
+	return f.partition
+}
+
+func (u *readingUploader) UploadFiles(ctx context.Context) error {
+	for file := range u.inStream {
+		content, err := ioutil.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		u.contents = append(u.contents, string(content))
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return u.err
+}
+
+func (u *readingUploader) OnUpload(func(uint)) {}
+
+func (u *readingUploader) OnManifestUploaded(func(*entity.Manifest)) {}
+
+func newArchivedFile(t *testing.T, partition, content string) io.ReadCloser {
+	file, err := ioutil.TempFile(t.TempDir(), "spooled")
+	require.NoError(t, err)
+	_, err = file.WriteString(content)
+	require.NoError(t, err)
+	_, err = file.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	return &archivedFile{File: file, partition: partition}
+}
+
+func TestArchivingUploader_UploadFiles(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := repository.NewArchive(dir)
+	require.NoError(t, err)
+	generation := &entity.Generation{ID: "42", Type: "test"}
+	generationArchive := archive.Generation(generation)
+	// Files of an earlier run are replaced.
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, generation.ID), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, generation.ID, "test_2.csv"), nil, 0644))
+
+	inStream := make(chan io.ReadCloser, 3)
+	inStream <- newArchivedFile(t, "", "id,country\n1,DE\n")
+	inStream <- newArchivedFile(t, "DE", "id\n1\n")
+	inStream <- newArchivedFile(t, "", "id,country\n2,AT\n")
+	close(inStream)
+	inner := new(readingUploader)
+	uploader := repository.NewArchivingUploader(generationArchive, inStream, func(inStream <-chan io.ReadCloser) interactor.Uploader {
+		inner.inStream = inStream
+		return inner
+	})
+
+	require.NoError(t, uploader.UploadFiles(context.Background()))
+
+	assert.Equal(t, []string{"id,country\n1,DE\n", "id\n1\n", "id,country\n2,AT\n"}, inner.contents)
+	files, err := generationArchive.Files()
+	require.NoError(t, err)
+	assert.Equal(t, []*entity.FileInfo{
+		{Name: "DE/test_0.csv", Partition: "DE", Size: 5},
+		{Name: "test_0.csv", Size: 16},
+		{Name: "test_1.csv", Size: 16},
+	}, files)
+	file, err := generationArchive.Open("test_1.csv")
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(file)
+	require.NoError(t, err)
+	assert.NoError(t, file.Close())
+	assert.Equal(t, "id,country\n2,AT\n", string(content))
+}
+
+func TestGenerationArchive_Open(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := repository.NewArchive(dir)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "secret.csv"), nil, 0644))
+	generationArchive := archive.Generation(&entity.Generation{ID: "42", Type: "test"})
+	inStream := make(chan io.ReadCloser, 1)
+	inStream <- newArchivedFile(t, "DE", "id\n1\n")
+	close(inStream)
+	uploader := repository.NewArchivingUploader(generationArchive, inStream, func(inStream <-chan io.ReadCloser) interactor.Uploader {
+		return &readingUploader{inStream: inStream}
+	})
+	require.NoError(t, uploader.UploadFiles(context.Background()))
+
+	testCases := []struct {
+		name    string
+		file    string
+		wantErr error
+	}{
+		{
+			name: "succeed",
+			file: "DE/test_0.csv",
+		},
+		{
+			name:    "missing file",
+			file:    "test_0.csv",
+			wantErr: entity.ErrFileNotFound,
+		},
+		{
+			name:    "directory",
+			file:    "DE",
+			wantErr: entity.ErrFileNotFound,
+		},
+		{
+			name:    "outside of generation",
+			file:    "../secret.csv",
+			wantErr: entity.ErrFileNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, gotErr := generationArchive.Open(tc.file)
+
+			assert.ErrorIs(t, gotErr, tc.wantErr)
+			if tc.wantErr == nil {
+				assert.NoError(t, file.Close())
+			}
+		})
+	}
+}
+
+func TestGenerationArchive_Preview(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		dialect repository.CsvDialect
+		rows    uint
+		want    [][]string
+	}{
+		{
+			name:    "first rows",
+			content: "id,name\n1,\"a,b\"\n2,c\n3,d\n",
+			rows:    3,
+			want:    [][]string{{"id", "name"}, {"1", "a,b"}, {"2", "c"}},
+		},
+		{
+			name:    "shorter file",
+			content: "id,name\n1,a\n",
+			rows:    10,
+			want:    [][]string{{"id", "name"}, {"1", "a"}},
+		},
+		{
+			name:    "dialect",
+			content: "\xEF\xBB\xBFid;name\r\n1;a\r\n",
+			dialect: repository.CsvDialect{Delimiter: ';', WithBOM: true, UseCRLF: true},
+			rows:    10,
+			want:    [][]string{{"id", "name"}, {"1", "a"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			archive, err := repository.NewArchive(t.TempDir())
+			require.NoError(t, err)
+			generationArchive := archive.Generation(&entity.Generation{ID: "42", Type: "test"})
+			inStream := make(chan io.ReadCloser, 1)
+			inStream <- newArchivedFile(t, "", tc.content)
+			close(inStream)
+			uploader := repository.NewArchivingUploader(generationArchive, inStream, func(inStream <-chan io.ReadCloser) interactor.Uploader {
+				return &readingUploader{inStream: inStream}
+			})
+			require.NoError(t, uploader.UploadFiles(context.Background()))
+
+			got, gotErr := generationArchive.Preview("test_0.csv", tc.rows, tc.dialect)
+
+			assert.NoError(t, gotErr)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGenerationArchive_Remove(t *testing.T) {
+	archive, err := repository.NewArchive(t.TempDir())
+	require.NoError(t, err)
+	generationArchive := archive.Generation(&entity.Generation{ID: "42", Type: "test"})
+	inStream := make(chan io.ReadCloser, 1)
+	inStream <- newArchivedFile(t, "", "id\n")
+	close(inStream)
+	uploader := repository.NewArchivingUploader(generationArchive, inStream, func(inStream <-chan io.ReadCloser) interactor.Uploader {
+		return &readingUploader{inStream: inStream}
+	})
+	require.NoError(t, uploader.UploadFiles(context.Background()))
+
+	assert.NoError(t, generationArchive.Remove())
+
+	files, err := generationArchive.Files()
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
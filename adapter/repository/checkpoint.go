@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+	"io"
+)
+
+type (
+	// checkpointFormatter streams the files a failed generation kept in the
+	// spool instead of formatting fetched data again.
+	checkpointFormatter struct {
+		files     []*csvFile
+		outStream chan<- io.ReadCloser
+	}
+)
+
+func (f *checkpointFormatter) FormatFiles(ctx context.Context) error {
+	for i, file := range f.files {
+		select {
+		case f.outStream <- file:
+		case <-ctx.Done():
+			closeFiles(f.files[i:])
+			return ctx.Err()
+		}
+	}
+	return nil
+}
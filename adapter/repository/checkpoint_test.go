@@ -0,0 +1,174 @@
+package repository_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/inhies/go-bytesize"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-feedmaker/adapter/repository"
+	"go-feedmaker/adapter/repository/mocks"
+	"go-feedmaker/entity"
+	"go-feedmaker/interactor"
+)
+
+type checkpointFile struct {
+	partition string
+	content   string
+}
+
+func readFormattedFiles(t *testing.T, formatter interactor.FileFormatter, outStream chan io.ReadCloser) ([]checkpointFile, error) {
+	var gotErr error
+	go func() {
+		defer close(outStream)
+		gotErr = formatter.FormatFiles(context.Background())
+	}()
+	var files []checkpointFile
+	for file := range outStream {
+		partitioned, ok := file.(repository.PartitionedFile)
+		require.True(t, ok)
+		content, err := ioutil.ReadAll(file)
+		assert.NoError(t, err)
+		assert.NoError(t, file.Close())
+		files = append(files, checkpointFile{partition: partitioned.Partition(), content: string(content)})
+	}
+	return files, gotErr
+}
+
+func TestDefaultFactory_CreateCheckpointFormatter(t *testing.T) {
+	testCases := []struct {
+		name          string
+		checkpoints   bool
+		formatted     bool
+		filesUploaded uint
+		wantErr       error
+	}{
+		{
+			name:        "kept files streamed again",
+			checkpoints: true,
+			formatted:   true,
+		},
+		{
+			name:          "uploaded files still streamed",
+			checkpoints:   true,
+			formatted:     true,
+			filesUploaded: 2,
+		},
+		{
+			name:        "checkpoints disabled",
+			formatted:   true,
+			wantErr:     entity.ErrNoCheckpoint,
+			checkpoints: false,
+		},
+		{
+			name:        "formatting not finished",
+			checkpoints: true,
+			wantErr:     entity.ErrNoCheckpoint,
+		},
+		{
+			name:          "more files uploaded than kept",
+			checkpoints:   true,
+			formatted:     true,
+			filesUploaded: 4,
+			wantErr:       entity.ErrNoCheckpoint,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spool, err := repository.NewSpool(t.TempDir(), bytesize.MB)
+			require.NoError(t, err)
+			factory, err := repository.NewDefaultFactory(&repository.FeedConfig{
+				FileSizeLimit: 64,
+				FileLineLimit: 2,
+				PartitionBy:   "country",
+				Spool:         spool,
+				Checkpoints:   tc.checkpoints,
+			}, nil, nil, nil, "test")
+			require.NoError(t, err)
+			generation := &entity.Generation{ID: "42", Type: "test"}
+			records := [][]string{{"id", "country"}, {"1", "DE"}, {"2", "AT"}, {"3", "DE"}}
+			if !tc.formatted {
+				records = append(records, []string{strings.Repeat("4", 64), "AT"})
+			}
+			inStream := make(chan []string, len(records))
+			for _, record := range records {
+				inStream <- record
+			}
+			close(inStream)
+			outStream := make(chan io.ReadCloser)
+			wantFiles, formatErr := readFormattedFiles(t, factory.CreateFileFormatter(generation, inStream, outStream), outStream)
+			require.Equal(t, tc.formatted, formatErr == nil)
+			checkpointer := factory.(interactor.Checkpointer)
+			require.NoError(t, checkpointer.CleanupFailed(generation))
+			generation.FilesUploaded = tc.filesUploaded
+
+			outStream = make(chan io.ReadCloser)
+			formatter, gotErr := checkpointer.CreateCheckpointFormatter(generation, outStream)
+
+			assert.ErrorIs(t, gotErr, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			gotFiles, err := readFormattedFiles(t, formatter, outStream)
+			assert.NoError(t, err)
+			assert.Equal(t, wantFiles, gotFiles)
+			assert.NotZero(t, spool.Used())
+			assert.NoError(t, factory.Cleanup(generation))
+			assert.Zero(t, spool.Used())
+		})
+	}
+}
+
+func TestDefaultFactory_RemoveStaging(t *testing.T) {
+	testCases := []struct {
+		name       string
+		setupMocks func(ftp *mocks.FtpGateway)
+		wantErr    error
+	}{
+		{
+			name: "kept staging dir removed",
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", "test.tmp-42").Return(true, nil)
+				ftp.On("RemoveDir", "test.tmp-42").Return(nil).Once()
+			},
+		},
+		{
+			name: "nothing kept",
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", "test.tmp-42").Return(false, nil)
+			},
+		},
+		{
+			name: "remove error",
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", "test.tmp-42").Return(true, nil)
+				ftp.On("RemoveDir", "test.tmp-42").Return(defaultErr)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ftp := new(mocks.FtpGateway)
+			tc.setupMocks(ftp)
+			spool, err := repository.NewSpool(t.TempDir(), bytesize.MB)
+			require.NoError(t, err)
+			factory, err := repository.NewDefaultFactory(&repository.FeedConfig{
+				Spool:       spool,
+				Checkpoints: true,
+			}, nil, ftp, nil, "test")
+			require.NoError(t, err)
+			generation := &entity.Generation{ID: "42", Type: "test"}
+
+			gotErr := factory.(interactor.Checkpointer).RemoveStaging(generation)
+
+			assert.ErrorIs(t, gotErr, tc.wantErr)
+			ftp.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+type (
+	quotingCsvWriter struct {
+		delimiter rune
+		useCRLF   bool
+		w         *bufio.Writer
+		err       error
+	}
+)
+
+func newQuotingCsvWriter(w io.Writer, delimiter rune, useCRLF bool) *quotingCsvWriter {
+	if delimiter == 0 {
+		delimiter = ','
+	}
+	return &quotingCsvWriter{
+		delimiter: delimiter,
+		useCRLF:   useCRLF,
+		w:         bufio.NewWriter(w),
+	}
+}
+
+func (q *quotingCsvWriter) Write(record []string) error {
+	if q.err != nil {
+		return q.err
+	}
+	for i, field := range record {
+		if i > 0 {
+			if _, q.err = q.w.WriteRune(q.delimiter); q.err != nil {
+				return q.err
+			}
+		}
+		if _, q.err = q.w.WriteString(q.quote(field)); q.err != nil {
+			return q.err
+		}
+	}
+	_, q.err = q.w.WriteString(q.lineEnding())
+	return q.err
+}
+
+func (q *quotingCsvWriter) Flush() {
+	if q.err == nil {
+		q.err = q.w.Flush()
+	}
+}
+
+func (q *quotingCsvWriter) Error() error {
+	return q.err
+}
+
+func (q *quotingCsvWriter) quote(field string) string {
+	field = strings.ReplaceAll(field, `"`, `""`)
+	if q.useCRLF {
+		field = strings.ReplaceAll(field, "\r\n", "\n")
+		field = strings.ReplaceAll(field, "\n", "\r\n")
+	}
+	return `"` + field + `"`
+}
+
+func (q *quotingCsvWriter) lineEnding() string {
+	if q.useCRLF {
+		return "\r\n"
+	}
+	return "\n"
+}
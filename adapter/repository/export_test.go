@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"io"
+
+	"go-feedmaker/interactor"
+)
+
+func (g *GenerationSpool) CreateFile() (io.ReadWriteCloser, error) {
+	return g.createFile()
+}
+
+func NewArchivingUploader(
+	archive *GenerationArchive,
+	inStream <-chan io.ReadCloser,
+	createUploader func(inStream <-chan io.ReadCloser) interactor.Uploader,
+) interactor.Uploader {
+	return newArchivingUploader(archive, inStream, createUploader)
+}
+
+func NewPreviewUploader(generationType string, inStream <-chan io.ReadCloser, withContent bool) interactor.PreviewUploader {
+	return newPreviewUploader(generationType, inStream, withContent)
+}
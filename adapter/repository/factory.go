@@ -1,27 +1,74 @@
 package repository
 
 import (
+	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"text/template"
 
 	"github.com/inhies/go-bytesize"
 
+	"go-feedmaker/entity"
 	"go-feedmaker/interactor"
 )
 
 type (
 	defaultFactory struct {
-		fileSizeLimit  bytesize.ByteSize
-		fileLineLimit  uint
-		generationType string
-		countQuery     string
-		selectQuery    string
-		sqlGateway     SqlGateway
-		ftpGateway     FtpGateway
+		fileSizeLimit     bytesize.ByteSize
+		fileLineLimit     uint
+		csvDialect        CsvDialect
+		partitionBy       string
+		generationType    string
+		countQuery        string
+		selectQuery       string
+		sqlGateway        SqlGateway
+		ftpGateway        FtpGateway
+		keyPrefix         *template.Template
+		spool             *Spool
+		destinations      []*Destination
+		destinationPolicy DestinationPolicy
+		versions          VersionStore
+		keepVersions      uint
+		checkpoints       bool
+		archive           *Archive
 	}
 )
 
-func (d *defaultFactory) CreateFileFormatter(inStream <-chan []string, outStream chan<- io.ReadCloser) interactor.FileFormatter {
-	return NewCsvFormatter(inStream, outStream, d.fileSizeLimit, d.fileLineLimit)
+func (d *defaultFactory) CreateFileFormatter(
+	generation *entity.Generation,
+	inStream <-chan []string,
+	outStream chan<- io.ReadCloser,
+) interactor.FileFormatter {
+	return NewCsvFormatter(
+		inStream,
+		outStream,
+		d.fileSizeLimit,
+		d.fileLineLimit,
+		d.csvDialect,
+		d.partitionBy,
+		d.generationSpool(generation),
+	)
+}
+
+// CreateCheckpointFormatter streams the files kept by a failed run of
+// generation.
+func (d *defaultFactory) CreateCheckpointFormatter(
+	generation *entity.Generation,
+	outStream chan<- io.ReadCloser,
+) (interactor.FileFormatter, error) {
+	if !d.checkpoints {
+		return nil, entity.ErrNoCheckpoint
+	}
+	files, err := d.generationSpool(generation).openCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	if generation.FilesUploaded > uint(len(files)) {
+		closeFiles(files)
+		return nil, entity.ErrNoCheckpoint
+	}
+	return &checkpointFormatter{files: files, outStream: outStream}, nil
 }
 
 func (d *defaultFactory) CreateDataFetcher(outStream chan<- []string) interactor.DataFetcher {
@@ -33,24 +80,216 @@ func (d *defaultFactory) CreateDataFetcher(outStream chan<- []string) interactor
 	}
 }
 
-func (d *defaultFactory) CreateUploader(inStream <-chan io.ReadCloser) interactor.Uploader {
-	return NewFtpUploader(d.ftpGateway, d.generationType, inStream)
+func (d *defaultFactory) CreateUploader(generation *entity.Generation, inStream <-chan io.ReadCloser) interactor.Uploader {
+	return d.createArchivingUploader(generation, inStream, false)
+}
+
+// CreateResumedUploader continues the failed upload of generation after the
+// files it already uploaded; its staging directories are kept when it fails
+// again.
+func (d *defaultFactory) CreateResumedUploader(generation *entity.Generation, inStream <-chan io.ReadCloser) interactor.Uploader {
+	return d.createArchivingUploader(generation, inStream, true)
+}
+
+func (d *defaultFactory) createArchivingUploader(
+	generation *entity.Generation,
+	inStream <-chan io.ReadCloser,
+	resume bool,
+) interactor.Uploader {
+	if d.archive != nil {
+		return newArchivingUploader(d.archive.Generation(generation), inStream, func(inStream <-chan io.ReadCloser) interactor.Uploader {
+			return d.createUploader(generation, inStream, resume)
+		})
+	}
+	return d.createUploader(generation, inStream, resume)
+}
+
+// CreatePreviewDataFetcher fetches the first rows records of the feed
+// without counting them first.
+func (d *defaultFactory) CreatePreviewDataFetcher(outStream chan<- []string, rows uint) interactor.DataFetcher {
+	return &SqlDataFetcher{
+		OutStream:   outStream,
+		SelectQuery: d.selectQuery,
+		Db:          d.sqlGateway,
+		Limit:       rows,
+	}
+}
+
+// CreatePreviewUploader names and describes the files of a preview the way
+// the uploader would, without sending them anywhere.
+func (d *defaultFactory) CreatePreviewUploader(
+	generation *entity.Generation,
+	inStream <-chan io.ReadCloser,
+	withContent bool,
+) interactor.PreviewUploader {
+	return newPreviewUploader(generation.Type, inStream, withContent)
+}
+
+func (d *defaultFactory) createUploader(generation *entity.Generation, inStream <-chan io.ReadCloser, resume bool) interactor.Uploader {
+	if len(d.destinations) == 0 {
+		uploader := NewFtpUploader(d.ftpGateway, generation, inStream)
+		uploader.KeepVersions(d.versions, DefaultDestination, d.keepVersions)
+		if resume {
+			uploader.Resume(generation.FilesUploaded)
+		}
+		return uploader
+	}
+	uploader := NewFanOutUploader(
+		d.destinations,
+		d.destinationPolicy,
+		inStream,
+		func(destination *Destination, inStream <-chan io.ReadCloser) interactor.Uploader {
+			return d.createDestinationUploader(destination, generation, inStream, resume)
+		},
+	)
+	if resume {
+		uploader.Resume(generation.FilesUploaded)
+	}
+	return uploader
+}
+
+func (d *defaultFactory) createDestinationUploader(
+	destination *Destination,
+	generation *entity.Generation,
+	inStream <-chan io.ReadCloser,
+	resume bool,
+) interactor.Uploader {
+	if destination.ObjectStorage != nil {
+		uploader := NewObjectStorageUploader(destination.ObjectStorage, d.keyPrefix, generation, inStream)
+		uploader.KeepVersions(d.versions, destination.Name, d.keepVersions)
+		if resume {
+			uploader.Resume(generation.FilesUploaded)
+		}
+		return uploader
+	}
+	uploader := NewFtpUploader(destination.FtpGateway, generation, inStream)
+	uploader.KeepVersions(d.versions, destination.Name, d.keepVersions)
+	if resume {
+		uploader.Resume(generation.FilesUploaded)
+	}
+	return uploader
+}
+
+// Parameters describes the feed settings without queries and credentials.
+func (d *defaultFactory) Parameters() map[string]string {
+	parameters := map[string]string{
+		"size_limit":   d.fileSizeLimit.String(),
+		"line_limit":   strconv.FormatUint(uint64(d.fileLineLimit), 10),
+		"checkpoints":  strconv.FormatBool(d.checkpoints),
+		"destinations": DefaultDestination,
+	}
+	if d.partitionBy != "" {
+		parameters["partition_by"] = d.partitionBy
+	}
+	if len(d.destinations) > 0 {
+		names := make([]string, len(d.destinations))
+		for i, destination := range d.destinations {
+			names[i] = destination.Name
+		}
+		parameters["destinations"] = strings.Join(names, ",")
+		parameters["destination_policy"] = string(d.destinationPolicy)
+	}
+	if d.keepVersions > 0 {
+		parameters["keep_versions"] = strconv.FormatUint(uint64(d.keepVersions), 10)
+	}
+	return parameters
+}
+
+func (d *defaultFactory) Cleanup(generation *entity.Generation) error {
+	return d.spool.Generation(generation.ID).Remove()
+}
+
+// CleanupFailed keeps the spool of a failed generation when every file was
+// formatted, so its upload can be retried.
+func (d *defaultFactory) CleanupFailed(generation *entity.Generation) error {
+	if d.checkpoints && d.generationSpool(generation).HasCheckpoint() {
+		return nil
+	}
+	return d.Cleanup(generation)
+}
+
+// RemoveStaging removes the staging directories resumed uploads of
+// generation kept on the destinations.
+func (d *defaultFactory) RemoveStaging(generation *entity.Generation) error {
+	if len(d.destinations) == 0 {
+		return removeStagingDir(d.ftpGateway, generation)
+	}
+	for _, destination := range d.destinations {
+		if destination.FtpGateway == nil {
+			continue
+		}
+		if err := removeStagingDir(destination.FtpGateway, generation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListArchivedFiles lists the files the last run of generation uploaded.
+func (d *defaultFactory) ListArchivedFiles(generation *entity.Generation) ([]*entity.FileInfo, error) {
+	if d.archive == nil {
+		return []*entity.FileInfo{}, nil
+	}
+	return d.archive.Generation(generation).Files()
+}
+
+func (d *defaultFactory) OpenArchivedFile(generation *entity.Generation, name string) (interactor.ArchivedFile, error) {
+	if d.archive == nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrFileNotFound, name)
+	}
+	file, err := d.archive.Generation(generation).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// PreviewArchivedFile reads the first rows of an archived file.
+func (d *defaultFactory) PreviewArchivedFile(generation *entity.Generation, name string, rows uint) ([][]string, error) {
+	if d.archive == nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrFileNotFound, name)
+	}
+	return d.archive.Generation(generation).Preview(name, rows, d.csvDialect)
+}
+
+func (d *defaultFactory) RemoveArchive(generation *entity.Generation) error {
+	if d.archive == nil {
+		return nil
+	}
+	return d.archive.Generation(generation).Remove()
+}
+
+func (d *defaultFactory) generationSpool(generation *entity.Generation) *GenerationSpool {
+	spool := d.spool.Generation(generation.ID)
+	spool.keep = d.checkpoints
+	return spool
 }
 
 func NewDefaultFactory(
 	config *FeedConfig,
 	sqlGateway SqlGateway,
 	ftpGateway FtpGateway,
+	versions VersionStore,
 	generationType string,
 ) (interactor.FeedFactory, error) {
 	return &defaultFactory{
-		generationType: generationType,
-		fileSizeLimit:  config.FileSizeLimit,
-		fileLineLimit:  config.FileLineLimit,
-		countQuery:     config.CountQuery,
-		selectQuery:    config.SelectQuery,
-		sqlGateway:     sqlGateway,
-		ftpGateway:     ftpGateway,
+		generationType:    generationType,
+		fileSizeLimit:     config.FileSizeLimit,
+		fileLineLimit:     config.FileLineLimit,
+		csvDialect:        config.CsvDialect,
+		partitionBy:       config.PartitionBy,
+		countQuery:        config.CountQuery,
+		selectQuery:       config.SelectQuery,
+		sqlGateway:        sqlGateway,
+		ftpGateway:        ftpGateway,
+		keyPrefix:         config.KeyPrefix,
+		spool:             config.Spool,
+		destinations:      config.Destinations,
+		destinationPolicy: config.DestinationPolicy,
+		versions:          versions,
+		keepVersions:      config.KeepVersions,
+		checkpoints:       config.Checkpoints,
+		archive:           config.Archive,
 	}, nil
 }
 
@@ -0,0 +1,405 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+
+	"go-feedmaker/entity"
+	"go-feedmaker/interactor"
+)
+
+// DestinationPolicy decides how failures of single destinations affect
+// the generation.
+type DestinationPolicy string
+
+const (
+	// DestinationPolicyAll fails the generation as soon as any destination fails.
+	DestinationPolicyAll DestinationPolicy = "all"
+	// DestinationPolicyAny fails the generation only when every destination failed.
+	DestinationPolicyAny DestinationPolicy = "any"
+)
+
+// DefaultDestination names the FTP gateway of the repo, used by feeds without
+// destinations.
+const DefaultDestination = "ftp"
+
+var (
+	ErrInvalidDestinationPolicy = errors.New("invalid destination policy")
+	ErrAllDestinationsFailed    = errors.New("all destinations failed")
+)
+
+type (
+	Destination struct {
+		Name          string
+		FtpGateway    FtpGateway
+		ObjectStorage ObjectStorage
+	}
+
+	createUploaderFunc func(destination *Destination, inStream <-chan io.ReadCloser) interactor.Uploader
+
+	fanOutUploader struct {
+		destinations          []*Destination
+		policy                DestinationPolicy
+		inStream              <-chan io.ReadCloser
+		createUploader        createUploaderFunc
+		mu                    sync.Mutex
+		statuses              []*entity.DestinationStatus
+		failedNum             int
+		firstErr              error
+		uploadedFilesNum      uint
+		manifestUploaded      bool
+		onUpload              func(uploadedFilesNum uint)
+		onManifestUploaded    func(manifest *entity.Manifest)
+		onDestinationsUpdated func(destinations []*entity.DestinationStatus)
+	}
+
+	fanOutTarget struct {
+		index    int
+		stream   chan io.ReadCloser
+		done     chan struct{}
+		sent     []*sharedFileView
+		uploader interactor.Uploader
+	}
+
+	// sharedFile lets every destination read the same spooled file through
+	// its own view and closes the file once the last view is closed.
+	sharedFile struct {
+		file io.ReadCloser
+		data io.ReaderAt
+		size int64
+		refs int32
+	}
+
+	sharedFileView struct {
+		*io.SectionReader
+		shared    *sharedFile
+		partition string
+		records   uint
+		closed    int32
+	}
+)
+
+func ParseDestinationPolicy(policy string) (DestinationPolicy, error) {
+	switch DestinationPolicy(policy) {
+	case "", DestinationPolicyAll:
+		return DestinationPolicyAll, nil
+	case DestinationPolicyAny:
+		return DestinationPolicyAny, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidDestinationPolicy, policy)
+	}
+}
+
+func NewFanOutUploader(
+	destinations []*Destination,
+	policy DestinationPolicy,
+	inStream <-chan io.ReadCloser,
+	createUploader createUploaderFunc,
+) *fanOutUploader {
+	statuses := make([]*entity.DestinationStatus, len(destinations))
+	for i, destination := range destinations {
+		statuses[i] = &entity.DestinationStatus{Name: destination.Name, State: entity.DestinationUploading}
+	}
+	return &fanOutUploader{
+		destinations:          destinations,
+		policy:                policy,
+		inStream:              inStream,
+		createUploader:        createUploader,
+		statuses:              statuses,
+		onUpload:              func(uploadedFilesNum uint) {},
+		onManifestUploaded:    func(manifest *entity.Manifest) {},
+		onDestinationsUpdated: func(destinations []*entity.DestinationStatus) {},
+	}
+}
+
+func (u *fanOutUploader) UploadFiles(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	u.notifyDestinations()
+
+	targets := make([]*fanOutTarget, len(u.destinations))
+	for i, destination := range u.destinations {
+		target := &fanOutTarget{
+			index:  i,
+			stream: make(chan io.ReadCloser),
+			done:   make(chan struct{}),
+		}
+		target.uploader = u.createUploader(destination, target.stream)
+		target.uploader.OnUpload(u.onTargetUpload(i))
+		target.uploader.OnManifestUploaded(u.onTargetManifestUploaded)
+		targets[i] = target
+		go u.runTarget(ctx, target, cancel)
+	}
+
+	err := u.distribute(ctx, targets)
+	for _, target := range targets {
+		close(target.stream)
+	}
+	for _, target := range targets {
+		<-target.done
+		// Uploaders leave the file they failed on open; closing views twice
+		// is a no-op.
+		for _, view := range target.sent {
+			view.Close()
+		}
+	}
+	if resultErr := u.result(); resultErr != nil {
+		return resultErr
+	}
+	return err
+}
+
+func (u *fanOutUploader) runTarget(ctx context.Context, target *fanOutTarget, cancel context.CancelFunc) {
+	defer close(target.done)
+	err := target.uploader.UploadFiles(ctx)
+	if err == nil {
+		u.setState(target.index, entity.DestinationSucceeded, nil)
+		return
+	}
+	u.setState(target.index, entity.DestinationFailed, err)
+	ctxLog(ctx).Error().Err(err).Msgf("Upload to destination %s failed", u.destinations[target.index].Name)
+	u.mu.Lock()
+	if u.failedNum == 0 {
+		u.firstErr = err
+	}
+	u.failedNum++
+	allFailed := u.failedNum == len(u.destinations)
+	u.mu.Unlock()
+	if u.policy == DestinationPolicyAll || allFailed {
+		cancel()
+	}
+	// Drain the stream, so that files sent after the failure are released.
+	for file := range target.stream {
+		file.Close()
+	}
+}
+
+func (u *fanOutUploader) distribute(ctx context.Context, targets []*fanOutTarget) error {
+	for {
+		select {
+		case file, isOpen := <-u.inStream:
+			if !isOpen {
+				return nil
+			}
+			shared, err := newSharedFile(file, len(targets))
+			if err != nil {
+				return err
+			}
+			for _, target := range targets {
+				view := shared.view()
+				select {
+				case target.stream <- view:
+					target.sent = append(target.sent, view)
+				case <-target.done:
+					view.Close()
+				case <-ctx.Done():
+					view.Close()
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// result applies the policy once every destination has finished.
+func (u *fanOutUploader) result() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	switch {
+	case u.failedNum == 0:
+		return nil
+	case u.policy == DestinationPolicyAll:
+		return u.firstErr
+	case u.failedNum == len(u.destinations):
+		return fmt.Errorf("%w: %v", ErrAllDestinationsFailed, u.firstErr)
+	default:
+		return nil
+	}
+}
+
+// onTargetUpload reports a file as uploaded once every destination that is
+// still working has uploaded it.
+func (u *fanOutUploader) onTargetUpload(index int) func(uint) {
+	return func(uploadedFilesNum uint) {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		u.statuses[index].FilesUploaded = uploadedFilesNum
+		u.onDestinationsUpdated(u.copyStatuses())
+		u.reportProgress()
+	}
+}
+
+func (u *fanOutUploader) reportProgress() {
+	var minUploaded uint
+	found := false
+	for _, status := range u.statuses {
+		if status.State == entity.DestinationFailed {
+			continue
+		}
+		if !found || status.FilesUploaded < minUploaded {
+			minUploaded = status.FilesUploaded
+			found = true
+		}
+	}
+	for found && u.uploadedFilesNum < minUploaded {
+		u.uploadedFilesNum++
+		u.onUpload(u.uploadedFilesNum)
+	}
+}
+
+func (u *fanOutUploader) onTargetManifestUploaded(manifest *entity.Manifest) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.manifestUploaded {
+		return
+	}
+	u.manifestUploaded = true
+	u.onManifestUploaded(manifest)
+}
+
+func (u *fanOutUploader) setState(index int, state entity.DestinationState, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.statuses[index].State = state
+	if err != nil {
+		u.statuses[index].Error = err.Error()
+	}
+	u.onDestinationsUpdated(u.copyStatuses())
+	u.reportProgress()
+}
+
+func (u *fanOutUploader) notifyDestinations() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.onDestinationsUpdated(u.copyStatuses())
+}
+
+func (u *fanOutUploader) copyStatuses() []*entity.DestinationStatus {
+	statuses := make([]*entity.DestinationStatus, len(u.statuses))
+	for i, status := range u.statuses {
+		statusCopy := *status
+		statuses[i] = &statusCopy
+	}
+	return statuses
+}
+
+// Resume continues counting after the files a failed run uploaded; the
+// uploaders of destinations are resumed by the factory.
+func (u *fanOutUploader) Resume(uploaded uint) {
+	u.uploadedFilesNum = uploaded
+	for _, status := range u.statuses {
+		status.FilesUploaded = uploaded
+	}
+}
+
+func (u *fanOutUploader) OnUpload(callback func(uploadedFilesNum uint)) {
+	u.onUpload = callback
+}
+
+func (u *fanOutUploader) OnManifestUploaded(callback func(manifest *entity.Manifest)) {
+	u.onManifestUploaded = callback
+}
+
+func (u *fanOutUploader) OnDestinationsUpdated(callback func(destinations []*entity.DestinationStatus)) {
+	u.onDestinationsUpdated = callback
+}
+
+// newSharedFile uses random access of spooled files; any other reader is
+// read into memory first.
+func newSharedFile(file io.ReadCloser, refs int) (*sharedFile, error) {
+	shared := &sharedFile{file: file, refs: int32(refs)}
+	if data, ok := file.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		size, err := data.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		shared.data = data
+		shared.size = size
+		return shared, nil
+	}
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	shared.data = bytes.NewReader(content)
+	shared.size = int64(len(content))
+	return shared, nil
+}
+
+func (s *sharedFile) view() *sharedFileView {
+	view := &sharedFileView{
+		SectionReader: io.NewSectionReader(s.data, 0, s.size),
+		shared:        s,
+		partition:     partitionOf(s.file),
+	}
+	if counter, ok := s.file.(RecordsCounter); ok {
+		view.records = counter.Records()
+	}
+	return view
+}
+
+func (v *sharedFileView) Partition() string {
+	return v.partition
+}
+
+func (v *sharedFileView) Records() uint {
+	return v.records
+}
+
+func (v *sharedFileView) Close() error {
+	if !atomic.CompareAndSwapInt32(&v.closed, 0, 1) {
+		return nil
+	}
+	if atomic.AddInt32(&v.shared.refs, -1) == 0 {
+		return v.shared.file.Close()
+	}
+	return nil
+}
+
+type destinationStatusJSON struct {
+	Name          string `json:"name"`
+	State         string `json:"state"`
+	FilesUploaded uint   `json:"files_uploaded"`
+	Error         string `json:"error,omitempty"`
+}
+
+func marshalDestinations(destinations []*entity.DestinationStatus) ([]byte, error) {
+	out := make([]*destinationStatusJSON, len(destinations))
+	for i, destination := range destinations {
+		out[i] = &destinationStatusJSON{
+			Name:          destination.Name,
+			State:         string(destination.State),
+			FilesUploaded: destination.FilesUploaded,
+			Error:         destination.Error,
+		}
+	}
+	return json.Marshal(out)
+}
+
+func unmarshalDestinations(data []byte) ([]*entity.DestinationStatus, error) {
+	in := make([]*destinationStatusJSON, 0)
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+	destinations := make([]*entity.DestinationStatus, len(in))
+	for i, destination := range in {
+		destinations[i] = &entity.DestinationStatus{
+			Name:          destination.Name,
+			State:         entity.DestinationState(destination.State),
+			FilesUploaded: destination.FilesUploaded,
+			Error:         destination.Error,
+		}
+	}
+	return destinations, nil
+}
@@ -0,0 +1,201 @@
+package repository_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"go-feedmaker/adapter/repository"
+	"go-feedmaker/adapter/repository/mocks"
+	"go-feedmaker/entity"
+	"go-feedmaker/interactor"
+)
+
+type closeCountingReader struct {
+	*strings.Reader
+	closed int32
+}
+
+func (r *closeCountingReader) Close() error {
+	atomic.AddInt32(&r.closed, 1)
+	return nil
+}
+
+func TestFanOutUploader_UploadFiles(t *testing.T) {
+	testCases := []struct {
+		name         string
+		policy       repository.DestinationPolicy
+		setupMocks   func(first, second *mocks.ObjectStorage)
+		wantErr      error
+		wantUploaded uint
+		wantStates   []entity.DestinationState
+	}{
+		{
+			name:   "all destinations succeed",
+			policy: repository.DestinationPolicyAll,
+			setupMocks: func(first, second *mocks.ObjectStorage) {
+				first.On("PutObject", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				second.On("PutObject", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			wantUploaded: 2,
+			wantStates:   []entity.DestinationState{entity.DestinationSucceeded, entity.DestinationSucceeded},
+		},
+		{
+			name:   "policy all fails with first destination error",
+			policy: repository.DestinationPolicyAll,
+			setupMocks: func(first, second *mocks.ObjectStorage) {
+				first.On("PutObject", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+				second.On("PutObject", mock.Anything, "test/test_0.csv", mock.Anything, mock.Anything, mock.Anything).Return(defaultErr)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name:   "policy any continues with working destinations",
+			policy: repository.DestinationPolicyAny,
+			setupMocks: func(first, second *mocks.ObjectStorage) {
+				first.On("PutObject", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				second.On("PutObject", mock.Anything, "test/test_0.csv", mock.Anything, mock.Anything, mock.Anything).Return(defaultErr)
+			},
+			wantUploaded: 2,
+			wantStates:   []entity.DestinationState{entity.DestinationSucceeded, entity.DestinationFailed},
+		},
+		{
+			name:   "policy any fails when all destinations failed",
+			policy: repository.DestinationPolicyAny,
+			setupMocks: func(first, second *mocks.ObjectStorage) {
+				first.On("PutObject", mock.Anything, "test/test_0.csv", mock.Anything, mock.Anything, mock.Anything).Return(defaultErr)
+				second.On("PutObject", mock.Anything, "test/test_0.csv", mock.Anything, mock.Anything, mock.Anything).Return(defaultErr)
+			},
+			wantErr:    repository.ErrAllDestinationsFailed,
+			wantStates: []entity.DestinationState{entity.DestinationFailed, entity.DestinationFailed},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			first, second := new(mocks.ObjectStorage), new(mocks.ObjectStorage)
+			tc.setupMocks(first, second)
+			files := []*closeCountingReader{
+				{Reader: strings.NewReader("a")},
+				{Reader: strings.NewReader("b")},
+			}
+			inStream := make(chan io.ReadCloser, len(files))
+			for _, file := range files {
+				inStream <- file
+			}
+			close(inStream)
+			keyPrefix, err := repository.ParseKeyPrefix("")
+			require.NoError(t, err)
+			generation := &entity.Generation{ID: "42", Type: "test", StartTime: time.Unix(100, 0)}
+			destinations := []*repository.Destination{
+				{Name: "first", ObjectStorage: first},
+				{Name: "second", ObjectStorage: second},
+			}
+			uploader := repository.NewFanOutUploader(destinations, tc.policy, inStream,
+				func(destination *repository.Destination, inStream <-chan io.ReadCloser) interactor.Uploader {
+					return repository.NewObjectStorageUploader(destination.ObjectStorage, keyPrefix, generation, inStream)
+				})
+			var gotUploaded uint
+			uploader.OnUpload(func(uploadedNum uint) {
+				gotUploaded = uploadedNum
+			})
+			var gotStatuses []*entity.DestinationStatus
+			uploader.OnDestinationsUpdated(func(statuses []*entity.DestinationStatus) {
+				gotStatuses = statuses
+			})
+
+			gotErr := uploader.UploadFiles(context.Background())
+
+			assert.ErrorIs(t, gotErr, tc.wantErr)
+			if tc.wantErr == nil {
+				assert.Equal(t, tc.wantUploaded, gotUploaded)
+			}
+			if tc.wantStates != nil {
+				require.Len(t, gotStatuses, len(tc.wantStates))
+				for i, state := range tc.wantStates {
+					assert.Equal(t, state, gotStatuses[i].State)
+				}
+			}
+			// Files left in the stream after cancellation are removed with
+			// the spool, but none may be closed twice.
+			for _, file := range files {
+				if tc.wantErr == nil {
+					assert.Equal(t, int32(1), atomic.LoadInt32(&file.closed))
+				} else {
+					assert.LessOrEqual(t, atomic.LoadInt32(&file.closed), int32(1))
+				}
+			}
+			first.AssertExpectations(t)
+			second.AssertExpectations(t)
+		})
+	}
+}
+
+func TestFanOutUploader_UploadFiles_SameContent(t *testing.T) {
+	var mu sync.Mutex
+	got := make(map[string]map[string]string)
+	storageFor := func(name string) *mocks.ObjectStorage {
+		got[name] = make(map[string]string)
+		storage := new(mocks.ObjectStorage)
+		storage.On("PutObject", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				data, err := ioutil.ReadAll(args.Get(2).(io.Reader))
+				require.NoError(t, err)
+				mu.Lock()
+				defer mu.Unlock()
+				got[name][args.String(1)] = string(data)
+			}).
+			Return(nil)
+		return storage
+	}
+	destinations := []*repository.Destination{
+		{Name: "first", ObjectStorage: storageFor("first")},
+		{Name: "second", ObjectStorage: storageFor("second")},
+	}
+	inStream := make(chan io.ReadCloser, 2)
+	inStream <- newPartitionedReader("DE")
+	inStream <- ioutil.NopCloser(strings.NewReader("not seekable"))
+	close(inStream)
+	keyPrefix, err := repository.ParseKeyPrefix("")
+	require.NoError(t, err)
+	generation := &entity.Generation{ID: "42", Type: "test", StartTime: time.Unix(100, 0)}
+	uploader := repository.NewFanOutUploader(destinations, repository.DestinationPolicyAll, inStream,
+		func(destination *repository.Destination, inStream <-chan io.ReadCloser) interactor.Uploader {
+			return repository.NewObjectStorageUploader(destination.ObjectStorage, keyPrefix, generation, inStream)
+		})
+	manifestsNum := 0
+	uploader.OnManifestUploaded(func(manifest *entity.Manifest) {
+		manifestsNum++
+	})
+
+	require.NoError(t, uploader.UploadFiles(context.Background()))
+
+	assert.Equal(t, 1, manifestsNum)
+	for _, name := range []string{"first", "second"} {
+		assert.Equal(t, "data", got[name]["test/DE/test_0.csv"])
+		assert.Equal(t, "not seekable", got[name]["test/test_0.csv"])
+		assert.Contains(t, got[name], "test/manifest.json")
+	}
+}
+
+func TestParseDestinationPolicy(t *testing.T) {
+	for policy, want := range map[string]repository.DestinationPolicy{
+		"":    repository.DestinationPolicyAll,
+		"all": repository.DestinationPolicyAll,
+		"any": repository.DestinationPolicyAny,
+	} {
+		got, err := repository.ParseDestinationPolicy(policy)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := repository.ParseDestinationPolicy("some")
+	assert.ErrorIs(t, err, repository.ErrInvalidDestinationPolicy)
+}
@@ -2,9 +2,11 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strconv"
+	"text/template"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -20,7 +22,24 @@ type (
 		SelectQuery   string
 		FileSizeLimit bytesize.ByteSize
 		FileLineLimit uint
+		CsvDialect    CsvDialect
+		PartitionBy   string
 		SqlGateway    SqlGateway
+		KeyPrefix     *template.Template
+		Spool         *Spool
+		// Archive keeps a copy of every uploaded file when set.
+		Archive *Archive
+		// Destinations receive every file of the feed; without them files go
+		// to the FTP gateway of the repo.
+		Destinations      []*Destination
+		DestinationPolicy DestinationPolicy
+		// KeepVersions is the number of published versions kept on every
+		// destination, the current one included; 0 keeps only the current.
+		KeepVersions uint
+		// Checkpoints keeps the formatted files of a failed generation and
+		// its partial upload, so the upload can be retried from the first
+		// file that wasn't uploaded.
+		Checkpoints bool
 	}
 
 	RedisClient interface {
@@ -41,8 +60,11 @@ type (
 	}
 
 	feedRepo struct {
-		client         RedisClient
+		client RedisClient
+		// idSetName is the set generations were kept in before they were
+		// indexed by start time in indexName.
 		idSetName      string
+		indexName      string
 		cancelChanName string
 		typeConfigMap  map[string]*FeedConfig
 		ftpGateway     FtpGateway
@@ -53,6 +75,7 @@ func NewFeedRepo(config map[string]*FeedConfig, client RedisClient, ftpGateway F
 	return &feedRepo{
 		client:        client,
 		idSetName:     "generationIDs",
+		indexName:     "generationsByStartTime",
 		ftpGateway:    ftpGateway,
 		typeConfigMap: config,
 	}
@@ -63,8 +86,7 @@ func (r *feedRepo) GetFactoryByGenerationType(generationType string) (interactor
 	if !ok {
 		return nil, entity.ErrInvalidGenerationType
 	}
-
-	return NewDefaultFactory(config, config.SqlGateway, r.ftpGateway, generationType)
+	return NewDefaultFactory(config, config.SqlGateway, r.ftpGateway, r, generationType)
 }
 
 func (r *feedRepo) ListAllowedTypes() []string {
@@ -84,17 +106,25 @@ func (r *feedRepo) StoreGeneration(ctx context.Context, generation *entity.Gener
 	conn := r.client.Connection()
 	defer conn.Close()
 	conn.Send("MULTI")
-	conn.Send("SADD", r.idSetName, generation.ID)
+	conn.Send("ZADD", r.indexName, startTimeScore(generation.StartTime), generation.ID)
 	hashArgs := new(redis.Args).
 		Add(generation.ID).
 		Add("type", generation.Type).
 		Add("progress", generation.Progress).
 		Add("data_fetched", generation.DataFetched).
 		Add("files_uploaded", generation.FilesUploaded).
-		Add("start_time", generation.StartTime.Unix())
+		Add("start_time", generation.StartTime.Unix()).
+		Add("instance", generation.Instance)
 	if !generation.EndTime.IsZero() {
 		hashArgs = hashArgs.Add("end_time", generation.EndTime.Unix())
 	}
+	if len(generation.Parameters) > 0 {
+		parameters, err := json.Marshal(generation.Parameters)
+		if err != nil {
+			return err
+		}
+		hashArgs = hashArgs.Add("parameters", parameters)
+	}
 	conn.Send("HMSET", hashArgs...)
 
 	_, err := conn.Do("EXEC")
@@ -108,6 +138,9 @@ func (r *feedRepo) GetGeneration(ctx context.Context, generationID string) (*ent
 	if err != nil {
 		return nil, err
 	}
+	if len(stringMap) == 0 {
+		return nil, fmt.Errorf("%w: %s", entity.ErrGenerationNotFound, generationID)
+	}
 	stringMap["id"] = generationID
 	generation, err := makeGenerationFromRedisValues(stringMap)
 	if err != nil {
@@ -116,29 +149,13 @@ func (r *feedRepo) GetGeneration(ctx context.Context, generationID string) (*ent
 	return generation, nil
 }
 
-func (r *feedRepo) ListGenerations(ctx context.Context) ([]*entity.Generation, error) {
-	generations := make([]*entity.Generation, 0)
-	conn := r.client.Connection()
-	defer conn.Close()
-	generationIDs, err := redis.Strings(conn.Do("SMEMBERS", r.idSetName))
-	if err != nil {
-		return nil, err
-	}
-	for _, id := range generationIDs {
-		generation, err := r.GetGeneration(ctx, id)
-		if err != nil {
-			return nil, err
-		}
-		generations = append(generations, generation)
-	}
-	return generations, nil
-}
-
 func makeGenerationFromRedisValues(v map[string]string) (*entity.Generation, error) {
 	progress, _ := strconv.ParseUint(v["progress"], 10, 32)
 	filesUploaded, _ := strconv.ParseUint(v["files_uploaded"], 10, 32)
 	dataFetched, _ := strconv.ParseBool(v["data_fetched"])
 	isCanceled, _ := strconv.ParseBool(v["is_canceled"])
+	finished, _ := strconv.ParseBool(v["finished"])
+	recordsCount, _ := strconv.ParseUint(v["records_count"], 10, 32)
 
 	generation := new(entity.Generation)
 	generation.ID = v["id"]
@@ -147,6 +164,10 @@ func makeGenerationFromRedisValues(v map[string]string) (*entity.Generation, err
 	generation.DataFetched = dataFetched
 	generation.IsCanceled = isCanceled
 	generation.FilesUploaded = uint(filesUploaded)
+	generation.RecordsCount = uint(recordsCount)
+	generation.Error = v["error"]
+	generation.Finished = finished
+	generation.Instance = v["instance"]
 
 	if timestamp, ok := v["start_time"]; ok && len(timestamp) > 0 {
 		startTime, err := strconv.ParseInt(timestamp, 10, 64)
@@ -162,10 +183,76 @@ func makeGenerationFromRedisValues(v map[string]string) (*entity.Generation, err
 		}
 		generation.EndTime = time.Unix(startTime, 0)
 	}
+	if rawManifest, ok := v["manifest"]; ok && len(rawManifest) > 0 {
+		manifest, err := unmarshalManifest([]byte(rawManifest))
+		if err != nil {
+			return nil, fmt.Errorf("%s 'manifest': %w", generation.ID, err)
+		}
+		generation.Manifest = manifest
+	}
+	if rawDestinations, ok := v["destinations"]; ok && len(rawDestinations) > 0 {
+		destinations, err := unmarshalDestinations([]byte(rawDestinations))
+		if err != nil {
+			return nil, fmt.Errorf("%s 'destinations': %w", generation.ID, err)
+		}
+		generation.Destinations = destinations
+	}
+	if rawParameters, ok := v["parameters"]; ok && len(rawParameters) > 0 {
+		if err := json.Unmarshal([]byte(rawParameters), &generation.Parameters); err != nil {
+			return nil, fmt.Errorf("%s 'parameters': %w", generation.ID, err)
+		}
+	}
+	if rawStages, ok := v["stages"]; ok && len(rawStages) > 0 {
+		stages, err := unmarshalStages([]byte(rawStages))
+		if err != nil {
+			return nil, fmt.Errorf("%s 'stages': %w", generation.ID, err)
+		}
+		generation.Stages = stages
+	}
 
 	return generation, nil
 }
 
+type stageTimingJSON struct {
+	Name      string     `json:"name"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+}
+
+func marshalStages(stages []*entity.StageTiming) ([]byte, error) {
+	out := make([]*stageTimingJSON, len(stages))
+	for i, stage := range stages {
+		out[i] = &stageTimingJSON{Name: stage.Name}
+		if !stage.StartTime.IsZero() {
+			startTime := stage.StartTime.UTC()
+			out[i].StartTime = &startTime
+		}
+		if !stage.EndTime.IsZero() {
+			endTime := stage.EndTime.UTC()
+			out[i].EndTime = &endTime
+		}
+	}
+	return json.Marshal(out)
+}
+
+func unmarshalStages(data []byte) ([]*entity.StageTiming, error) {
+	in := make([]*stageTimingJSON, 0)
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+	stages := make([]*entity.StageTiming, len(in))
+	for i, stage := range in {
+		stages[i] = &entity.StageTiming{Name: stage.Name}
+		if stage.StartTime != nil {
+			stages[i].StartTime = *stage.StartTime
+		}
+		if stage.EndTime != nil {
+			stages[i].EndTime = *stage.EndTime
+		}
+	}
+	return stages, nil
+}
+
 func (r *feedRepo) UpdateGenerationState(ctx context.Context, generation *entity.Generation) error {
 	channel := "generation.updated"
 	conn := r.client.Connection()
@@ -174,10 +261,42 @@ func (r *feedRepo) UpdateGenerationState(ctx context.Context, generation *entity
 		Add("progress", generation.Progress).
 		Add("data_fetched", generation.DataFetched).
 		Add("is_canceled", generation.IsCanceled).
-		Add("files_uploaded", generation.FilesUploaded)
+		Add("files_uploaded", generation.FilesUploaded).
+		Add("records_count", generation.RecordsCount).
+		Add("error", generation.Error).
+		Add("finished", generation.Finished).
+		Add("instance", generation.Instance)
 	if !generation.EndTime.IsZero() {
 		hashArgs = hashArgs.Add("end_time", generation.EndTime.Unix())
 	}
+	if len(generation.Parameters) > 0 {
+		parameters, err := json.Marshal(generation.Parameters)
+		if err != nil {
+			return err
+		}
+		hashArgs = hashArgs.Add("parameters", parameters)
+	}
+	if len(generation.Stages) > 0 {
+		stages, err := marshalStages(generation.Stages)
+		if err != nil {
+			return err
+		}
+		hashArgs = hashArgs.Add("stages", stages)
+	}
+	if generation.Manifest != nil {
+		manifest, err := marshalManifest(generation.Manifest)
+		if err != nil {
+			return err
+		}
+		hashArgs = hashArgs.Add("manifest", manifest)
+	}
+	if len(generation.Destinations) > 0 {
+		destinations, err := marshalDestinations(generation.Destinations)
+		if err != nil {
+			return err
+		}
+		hashArgs = hashArgs.Add("destinations", destinations)
+	}
 	_, err := conn.Do("HSET", hashArgs...)
 	if err != nil {
 		return err
@@ -189,11 +308,15 @@ func (r *feedRepo) UpdateGenerationState(ctx context.Context, generation *entity
 	return nil
 }
 
+// DeleteGeneration removes the record of a generation, its log and its entry
+// in the start time index.
 func (r *feedRepo) DeleteGeneration(ctx context.Context, generationID string) error {
 	conn := r.client.Connection()
 	defer conn.Close()
-
-	_, err := conn.Do("DEL", generationID)
+	conn.Send("MULTI")
+	conn.Send("DEL", generationID, generationLogKey(generationID))
+	conn.Send("ZREM", r.indexName, generationID)
+	_, err := conn.Do("EXEC")
 	return err
 }
 
@@ -250,7 +373,10 @@ func (r *feedRepo) OnGenerationCanceled(ctx context.Context, generationID string
 	}
 }
 
-func (r *feedRepo) OnGenerationsUpdated(ctx context.Context, callback func(*entity.Generation)) error {
+// OnGenerationsUpdated calls onSubscribed once it listens to the updates
+// and then callback with every generation that changed until ctx is done.
+// The updates made while onSubscribed runs are passed after it returns.
+func (r *feedRepo) OnGenerationsUpdated(ctx context.Context, onSubscribed func() error, callback func(*entity.Generation)) error {
 	channel := "generation.updated"
 	errChan := make(chan error)
 	pubsub := r.client.PubSub()
@@ -260,6 +386,9 @@ func (r *feedRepo) OnGenerationsUpdated(ctx context.Context, callback func(*enti
 		return err
 	}
 	defer pubsub.Unsubscribe(channel)
+	if err := onSubscribed(); err != nil {
+		return err
+	}
 
 	go func() {
 		for {
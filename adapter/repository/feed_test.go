@@ -76,7 +76,7 @@ func TestFeedRepo_StoreGeneration(t *testing.T) {
 				f.client.On("Connection").Return(f.conn)
 				f.conn.On("Close").Return(nil)
 				f.conn.On("Send", "MULTI").Return(nil)
-				f.conn.On("Send", "SADD", mock.Anything, a.generation.ID).Return(nil)
+				f.conn.On("Send", "ZADD", mock.Anything, a.generation.StartTime.UnixNano()/int64(time.Millisecond), a.generation.ID).Return(nil)
 				args := new(redis.Args).
 					Add("HMSET", a.generation.ID).
 					Add(mock.Anything, a.generation.Type).
@@ -84,6 +84,7 @@ func TestFeedRepo_StoreGeneration(t *testing.T) {
 					Add(mock.Anything, a.generation.DataFetched).
 					Add(mock.Anything, a.generation.FilesUploaded).
 					Add(mock.Anything, a.generation.StartTime.Unix()).
+					Add(mock.Anything, a.generation.Instance).
 					Add(mock.Anything, a.generation.EndTime.Unix())
 				f.conn.On("Send", args...).Return(nil)
 				f.conn.On("Do", "EXEC").Return("OK", nil)
@@ -103,14 +104,15 @@ func TestFeedRepo_StoreGeneration(t *testing.T) {
 				f.client.On("Connection").Return(f.conn)
 				f.conn.On("Close").Return(nil)
 				f.conn.On("Send", "MULTI").Return(nil)
-				f.conn.On("Send", "SADD", mock.Anything, a.generation.ID).Return(nil)
+				f.conn.On("Send", "ZADD", mock.Anything, a.generation.StartTime.UnixNano()/int64(time.Millisecond), a.generation.ID).Return(nil)
 				args := new(redis.Args).
 					Add("HMSET", a.generation.ID).
 					Add(mock.Anything, a.generation.Type).
 					Add(mock.Anything, a.generation.Progress).
 					Add(mock.Anything, a.generation.DataFetched).
 					Add(mock.Anything, a.generation.FilesUploaded).
-					Add(mock.Anything, a.generation.StartTime.Unix())
+					Add(mock.Anything, a.generation.StartTime.Unix()).
+					Add(mock.Anything, a.generation.Instance)
 				f.conn.On("Send", args...).Return(nil)
 				f.conn.On("Do", "EXEC").Return("", defaultErr)
 			},
@@ -131,141 +133,68 @@ func TestFeedRepo_StoreGeneration(t *testing.T) {
 	}
 }
 
-func TestFeedRepo_ListGenerations(t *testing.T) {
-	type args struct {
-		ctx context.Context
-	}
+func TestFeedRepo_GetGeneration(t *testing.T) {
 	testCases := []struct {
 		name       string
-		args       *args
-		setupMocks func(*args, *feedFields)
-		want       []*entity.Generation
+		setupMocks func(*feedFields)
+		want       *entity.Generation
 		wantErr    error
 	}{
 		{
 			name: "succeed",
-			args: &args{ctx: context.Background()},
-			setupMocks: func(a *args, f *feedFields) {
+			setupMocks: func(f *feedFields) {
 				f.client.On("Connection").Return(f.conn)
 				f.conn.On("Close").Return(nil)
-
-				f.conn.
-					On("Do", "SMEMBERS", mock.Anything).
-					Return([]interface{}{"123", "234"}, nil)
 				f.conn.
 					On("Do", "HGETALL", "123").
 					Return([]interface{}{
-						[]byte("type"), []byte("test1"),
-						[]byte("progress"), []byte("100"),
-						[]byte("files_uploaded"), []byte("4"),
-						[]byte("data_fetched"), []byte("1"),
-						[]byte("is_canceled"), []byte("1"),
-						[]byte("start_time"), []byte(strconv.Itoa(int(time.Unix(1, 0).Unix()))),
-					}, nil)
-				f.conn.
-					On("Do", "HGETALL", "234").
-					Return([]interface{}{
-						[]byte("type"), []byte("test2"),
-						[]byte("progress"), []byte("43"),
-						[]byte("files_uploaded"), []byte("5"),
-						[]byte("data_fetched"), []byte("0"),
-						[]byte("start_time"), []byte(strconv.Itoa(int(time.Unix(11, 0).Unix()))),
-						[]byte("end_time"), []byte(strconv.Itoa(int(time.Unix(20, 0).Unix()))),
+						[]byte("type"), []byte("test"),
+						[]byte("records_count"), []byte("42"),
+						[]byte("error"), []byte("test error"),
+						[]byte("instance"), []byte("app-1"),
+						[]byte("parameters"), []byte(`{"line_limit":"100"}`),
+						[]byte("stages"), []byte(`[{"name":"fetch","start_time":"1970-01-01T00:00:01Z","end_time":"1970-01-01T00:00:02Z"},{"name":"upload","start_time":"1970-01-01T00:00:01Z"}]`),
 					}, nil)
 			},
-			want: []*entity.Generation{
-				{
-					ID: "123", Type: "test1",
-					Progress:      100,
-					FilesUploaded: 4,
-					DataFetched:   true,
-					IsCanceled:    true,
-					StartTime:     time.Unix(1, 0),
-				},
-				{
-					ID: "234", Type: "test2",
-					Progress:      43,
-					FilesUploaded: 5,
-					DataFetched:   false,
-					StartTime:     time.Unix(11, 0),
-					EndTime:       time.Unix(20, 0),
+			want: &entity.Generation{
+				ID:           "123",
+				Type:         "test",
+				RecordsCount: 42,
+				Error:        "test error",
+				Instance:     "app-1",
+				Parameters:   map[string]string{"line_limit": "100"},
+				Stages: []*entity.StageTiming{
+					{Name: "fetch", StartTime: time.Unix(1, 0).UTC(), EndTime: time.Unix(2, 0).UTC()},
+					{Name: "upload", StartTime: time.Unix(1, 0).UTC()},
 				},
 			},
 		},
 		{
-			name: "SMEMBERS error",
-			args: &args{ctx: context.Background()},
-			setupMocks: func(a *args, f *feedFields) {
-				f.client.On("Connection").Return(f.conn)
-				f.conn.On("Close").Return(nil)
-				f.conn.
-					On("Do", "SMEMBERS", mock.Anything).
-					Return(nil, defaultErr)
-			},
-			wantErr: defaultErr,
-		},
-		{
-			name: "first HGETALL error",
-			args: &args{ctx: context.Background()},
-			setupMocks: func(a *args, f *feedFields) {
+			name: "not found",
+			setupMocks: func(f *feedFields) {
 				f.client.On("Connection").Return(f.conn)
 				f.conn.On("Close").Return(nil)
-				f.conn.
-					On("Do", "SMEMBERS", mock.Anything).
-					Return([]interface{}{"123", "234"}, nil)
-				f.conn.
-					On("Do", "HGETALL", "123").
-					Return(nil, defaultErr)
+				f.conn.On("Do", "HGETALL", "123").Return([]interface{}{}, nil)
 			},
-			wantErr: defaultErr,
+			wantErr: entity.ErrGenerationNotFound,
 		},
 		{
-			name: "second HGETALL error",
-			args: &args{ctx: context.Background()},
-			setupMocks: func(a *args, f *feedFields) {
+			name: "HGETALL error",
+			setupMocks: func(f *feedFields) {
 				f.client.On("Connection").Return(f.conn)
 				f.conn.On("Close").Return(nil)
-				f.conn.
-					On("Do", "SMEMBERS", mock.Anything).
-					Return([]interface{}{"123", "234"}, nil)
-				f.conn.
-					On("Do", "HGETALL", "123").
-					Return([]interface{}{
-						[]byte("type"), []byte("test1"),
-						[]byte("start_time"), []byte(strconv.Itoa(int(time.Unix(1, 0).Unix()))),
-						[]byte("end_time"), []byte(strconv.Itoa(int(time.Unix(10, 0).Unix()))),
-					}, nil)
-				f.conn.
-					On("Do", "HGETALL", "234").Return(nil, defaultErr)
+				f.conn.On("Do", "HGETALL", "123").Return(nil, defaultErr)
 			},
 			wantErr: defaultErr,
 		},
-		{
-			name: "invalid timestamp error",
-			args: &args{ctx: context.Background()},
-			setupMocks: func(a *args, f *feedFields) {
-				f.client.On("Connection").Return(f.conn)
-				f.conn.On("Close").Return(nil)
-				f.conn.
-					On("Do", "SMEMBERS", mock.Anything).
-					Return([]interface{}{"123"}, nil)
-				f.conn.
-					On("Do", "HGETALL", "123").
-					Return([]interface{}{
-						[]byte("type"), []byte("test1"),
-						[]byte("start_time"), []byte("invalid"),
-					}, nil)
-			},
-			wantErr: entity.ErrInvalidTimestamp,
-		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			fields := defaultFeedFields()
-			tc.setupMocks(tc.args, fields)
+			tc.setupMocks(fields)
 			feedRepo := repository.NewFeedRepo(fields.config, fields.client, fields.ftp)
 
-			got, gotErr := feedRepo.ListGenerations(tc.args.ctx)
+			got, gotErr := feedRepo.GetGeneration(context.Background(), "123")
 
 			assert.ErrorIs(t, gotErr, tc.wantErr)
 			assert.Equal(t, tc.want, got)
@@ -306,6 +235,10 @@ func TestFeedRepo_UpdateGenerationState(t *testing.T) {
 					Add(mock.Anything, a.generation.DataFetched).
 					Add(mock.Anything, a.generation.IsCanceled).
 					Add(mock.Anything, a.generation.FilesUploaded).
+					Add(mock.Anything, a.generation.RecordsCount).
+					Add(mock.Anything, a.generation.Error).
+					Add(mock.Anything, a.generation.Finished).
+					Add(mock.Anything, a.generation.Instance).
 					Add(mock.Anything, a.generation.EndTime.Unix())
 				f.conn.On("Do", args...).Return("", nil)
 
@@ -333,7 +266,48 @@ func TestFeedRepo_UpdateGenerationState(t *testing.T) {
 					Add(mock.Anything, a.generation.Progress).
 					Add(mock.Anything, a.generation.DataFetched).
 					Add(mock.Anything, a.generation.IsCanceled).
-					Add(mock.Anything, a.generation.FilesUploaded)
+					Add(mock.Anything, a.generation.FilesUploaded).
+					Add(mock.Anything, a.generation.RecordsCount).
+					Add(mock.Anything, a.generation.Error).
+					Add(mock.Anything, a.generation.Finished).
+					Add(mock.Anything, a.generation.Instance)
+				f.conn.On("Do", args...).Return("", nil)
+
+				args = new(redis.Args).Add("PUBLISH", "generation.updated", a.generation.ID)
+				f.conn.On("Do", args...).Return("", nil)
+			},
+		},
+		{
+			name: "succeed with destinations",
+			args: &args{
+				ctx: context.Background(),
+				generation: &entity.Generation{
+					ID:        uuid.New().String(),
+					Type:      "test",
+					Progress:  80,
+					StartTime: time.Now(),
+					Destinations: []*entity.DestinationStatus{
+						{Name: "ftp", State: entity.DestinationSucceeded, FilesUploaded: 2},
+						{Name: "s3", State: entity.DestinationFailed, FilesUploaded: 1, Error: "test error"},
+					},
+				},
+			},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+
+				args := new(redis.Args).
+					Add("HSET", a.generation.ID).
+					Add(mock.Anything, a.generation.Progress).
+					Add(mock.Anything, a.generation.DataFetched).
+					Add(mock.Anything, a.generation.IsCanceled).
+					Add(mock.Anything, a.generation.FilesUploaded).
+					Add(mock.Anything, a.generation.RecordsCount).
+					Add(mock.Anything, a.generation.Error).
+					Add(mock.Anything, a.generation.Finished).
+					Add(mock.Anything, a.generation.Instance).
+					Add("destinations", []byte(`[{"name":"ftp","state":"succeeded","files_uploaded":2},`+
+						`{"name":"s3","state":"failed","files_uploaded":1,"error":"test error"}]`))
 				f.conn.On("Do", args...).Return("", nil)
 
 				args = new(redis.Args).Add("PUBLISH", "generation.updated", a.generation.ID)
@@ -361,6 +335,10 @@ func TestFeedRepo_UpdateGenerationState(t *testing.T) {
 					Add(mock.Anything, a.generation.DataFetched).
 					Add(mock.Anything, a.generation.IsCanceled).
 					Add(mock.Anything, a.generation.FilesUploaded).
+					Add(mock.Anything, a.generation.RecordsCount).
+					Add(mock.Anything, a.generation.Error).
+					Add(mock.Anything, a.generation.Finished).
+					Add(mock.Anything, a.generation.Instance).
 					Add(mock.Anything, a.generation.EndTime.Unix())
 				f.conn.On("Do", args...).Return("", defaultErr)
 			},
@@ -387,6 +365,10 @@ func TestFeedRepo_UpdateGenerationState(t *testing.T) {
 					Add(mock.Anything, a.generation.DataFetched).
 					Add(mock.Anything, a.generation.IsCanceled).
 					Add(mock.Anything, a.generation.FilesUploaded).
+					Add(mock.Anything, a.generation.RecordsCount).
+					Add(mock.Anything, a.generation.Error).
+					Add(mock.Anything, a.generation.Finished).
+					Add(mock.Anything, a.generation.Instance).
 					Add(mock.Anything, a.generation.EndTime.Unix())
 				f.conn.On("Do", args...).Return("", nil)
 
@@ -430,11 +412,14 @@ func TestFeedRepo_DeleteGeneration(t *testing.T) {
 			setupMocks: func(a *args, f *feedFields) {
 				f.client.On("Connection").Return(f.conn)
 				f.conn.On("Close").Return(nil)
-				f.conn.On("Do", "DEL", a.id).Return("", nil)
+				f.conn.On("Send", "MULTI").Return(nil)
+				f.conn.On("Send", "DEL", a.id, a.id+".logs").Return(nil)
+				f.conn.On("Send", "ZREM", mock.Anything, a.id).Return(nil)
+				f.conn.On("Do", "EXEC").Return([]interface{}{int64(1), int64(1)}, nil)
 			},
 		},
 		{
-			name: "DEL error",
+			name: "EXEC error",
 			args: &args{
 				ctx: context.Background(),
 				id:  uuid.New().String(),
@@ -442,7 +427,10 @@ func TestFeedRepo_DeleteGeneration(t *testing.T) {
 			setupMocks: func(a *args, f *feedFields) {
 				f.client.On("Connection").Return(f.conn)
 				f.conn.On("Close").Return(nil)
-				f.conn.On("Do", "DEL", a.id).Return("", defaultErr)
+				f.conn.On("Send", "MULTI").Return(nil)
+				f.conn.On("Send", "DEL", a.id, a.id+".logs").Return(nil)
+				f.conn.On("Send", "ZREM", mock.Anything, a.id).Return(nil)
+				f.conn.On("Do", "EXEC").Return(nil, defaultErr)
 			},
 			wantErr: defaultErr,
 		},
@@ -646,8 +634,10 @@ func TestFeedRepo_OnGenerationsUpdated(t *testing.T) {
 		name       string
 		args       *args
 		setupMocks func(a *args, f *feedFields)
-		want       *entity.Generation
-		wantErr    error
+		// subscribedErr is returned by onSubscribed.
+		subscribedErr error
+		want          *entity.Generation
+		wantErr       error
 	}{
 		{
 			name: "succeed",
@@ -692,6 +682,19 @@ func TestFeedRepo_OnGenerationsUpdated(t *testing.T) {
 			},
 			wantErr: defaultErr,
 		},
+		{
+			name: "onSubscribed error",
+			args: &args{ctx: context.Background()},
+			setupMocks: func(a *args, f *feedFields) {
+				channel := "generation.updated"
+				f.client.On("PubSub").Return(f.pubsub)
+				f.pubsub.On("Subscribe", channel).Return(nil)
+				f.pubsub.On("Unsubscribe", channel).Return(nil)
+				f.pubsub.On("Close").Return(nil)
+			},
+			subscribedErr: defaultErr,
+			wantErr:       defaultErr,
+		},
 		{
 			name: "Receive error",
 			args: &args{
@@ -762,7 +765,11 @@ func TestFeedRepo_OnGenerationsUpdated(t *testing.T) {
 				got = g
 			}
 
-			gotErr := feedRepo.OnGenerationsUpdated(tc.args.ctx, tc.args.callback)
+			onSubscribed := func() error {
+				return tc.subscribedErr
+			}
+
+			gotErr := feedRepo.OnGenerationsUpdated(tc.args.ctx, onSubscribed, tc.args.callback)
 
 			assert.Equal(t, tc.want, got)
 			assert.Equal(t, tc.wantErr, gotErr)
@@ -4,8 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"math"
-
-	"github.com/rs/zerolog/log"
 )
 
 type (
@@ -19,15 +17,21 @@ type (
 	}
 
 	SqlDataFetcher struct {
-		OutStream        chan<- []string
-		CountQuery       string
-		SelectQuery      string
-		Db               SqlGateway
+		OutStream   chan<- []string
+		CountQuery  string
+		SelectQuery string
+		Db          SqlGateway
+		// Limit stops the fetch after that many valid records, without
+		// running CountQuery; 0 fetches every record.
+		Limit            uint
 		recordsCount     uint
+		recordsSent      uint
 		recordsProceeded uint
 		progress         uint
 		onDataFetched    func()
 		onProgress       func(progress uint)
+		onRecordsCounted func(recordsCount uint)
+		onRecordInvalid  func(record []string, err error)
 		validators       []RecordValidator
 	}
 )
@@ -37,8 +41,10 @@ func (s *SqlDataFetcher) AddValidator(validator RecordValidator) {
 }
 
 func (s *SqlDataFetcher) StreamData(ctx context.Context) error {
-	if err := s.countRecords(ctx); err != nil {
-		return err
+	if s.Limit == 0 {
+		if err := s.countRecords(ctx); err != nil {
+			return err
+		}
 	}
 	rows, err := s.Db.QueryContext(ctx, s.SelectQuery)
 	if err != nil {
@@ -53,22 +59,30 @@ func (s *SqlDataFetcher) StreamData(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	s.OutStream <- cols
+	if err := s.send(ctx, cols); err != nil {
+		return err
+	}
 
 	values := make([]interface{}, len(cols))
 	for i := range cols {
 		values[i] = new(sql.RawBytes)
 	}
 
-	for rows.Next() {
+	for (s.Limit == 0 || s.recordsSent < s.Limit) && rows.Next() {
 		if err := rows.Scan(values...); err != nil {
 			return err
 		}
 		record := rawBytesToString(values)
 		if err := s.validate(record); err != nil {
-			log.Error().Err(err).Msgf("invalid record: %s", record)
+			ctxLog(ctx).Error().Err(err).Msgf("invalid record: %s", record)
+			if s.onRecordInvalid != nil {
+				s.onRecordInvalid(record, err)
+			}
 		} else {
-			s.OutStream <- record
+			if err := s.send(ctx, record); err != nil {
+				return err
+			}
+			s.recordsSent++
 		}
 		s.recordsProceeded++
 		s.updateProgress()
@@ -76,6 +90,15 @@ func (s *SqlDataFetcher) StreamData(ctx context.Context) error {
 	return rows.Err()
 }
 
+func (s *SqlDataFetcher) send(ctx context.Context, record []string) error {
+	select {
+	case s.OutStream <- record:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (s *SqlDataFetcher) countRecords(ctx context.Context) error {
 	row := s.Db.QueryRowContext(ctx, s.CountQuery)
 	if row.Err() != nil {
@@ -84,6 +107,9 @@ func (s *SqlDataFetcher) countRecords(ctx context.Context) error {
 	if err := row.Scan(&s.recordsCount); err != nil {
 		return err
 	}
+	if s.onRecordsCounted != nil {
+		s.onRecordsCounted(s.recordsCount)
+	}
 	return nil
 }
 
@@ -97,6 +123,9 @@ func (s *SqlDataFetcher) validate(record []string) error {
 }
 
 func (s *SqlDataFetcher) updateProgress() {
+	if s.recordsCount == 0 {
+		return
+	}
 	progress := uint(math.Round(float64(s.recordsProceeded) / float64(s.recordsCount) * 100))
 	if progress > s.progress {
 		s.progress = progress
@@ -121,3 +150,11 @@ func (s *SqlDataFetcher) OnDataFetched(callback func()) {
 func (s *SqlDataFetcher) OnProgress(callback func(progress uint)) {
 	s.onProgress = callback
 }
+
+func (s *SqlDataFetcher) OnRecordsCounted(callback func(recordsCount uint)) {
+	s.onRecordsCounted = callback
+}
+
+func (s *SqlDataFetcher) OnRecordInvalid(callback func(record []string, err error)) {
+	s.onRecordInvalid = callback
+}
@@ -10,8 +10,10 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 
 	"go-feedmaker/adapter/repository"
+	"go-feedmaker/adapter/repository/mocks"
 	helper "go-feedmaker/infrastructure/testing"
 )
 
@@ -163,3 +165,79 @@ func csvRecordToSqlValues(record []string) []driver.Value {
 	}
 	return values
 }
+
+func TestSqlDataFetcher_OnRecordInvalid(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	countQuery := "SELECT Count(*) FROM Marketing.dbo.records;"
+	selectQuery := "SELECT * FROM Marketing.dbo.records;"
+	sqlMock.ExpectQuery(regexp.QuoteMeta(countQuery)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(selectQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1").AddRow("invalid").AddRow("3"))
+	validator := new(mocks.RecordValidator)
+	validator.On("Validate", []string{"invalid"}).Return(defaultErr)
+	validator.On("Validate", mock.Anything).Return(nil)
+
+	recordStream := make(chan []string, 10)
+	sqlFetcher := repository.SqlDataFetcher{
+		Db:          db,
+		OutStream:   recordStream,
+		SelectQuery: selectQuery,
+		CountQuery:  countQuery,
+	}
+	sqlFetcher.AddValidator(validator)
+	var rejected [][]string
+	sqlFetcher.OnRecordInvalid(func(record []string, err error) {
+		assert.Equal(t, defaultErr, err)
+		rejected = append(rejected, record)
+	})
+
+	assert.NoError(t, sqlFetcher.StreamData(context.Background()))
+	close(recordStream)
+
+	var got [][]string
+	for record := range recordStream {
+		got = append(got, record)
+	}
+	assert.Equal(t, [][]string{{"id"}, {"1"}, {"3"}}, got)
+	assert.Equal(t, [][]string{{"invalid"}}, rejected)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestSqlDataFetcher_StreamData_Limit(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	selectQuery := "SELECT * FROM Marketing.dbo.records;"
+	// The count query isn't run and the rows after the limit aren't read.
+	sqlMock.ExpectQuery(regexp.QuoteMeta(selectQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1").AddRow("invalid").AddRow("3").AddRow("4")).
+		RowsWillBeClosed()
+	validator := new(mocks.RecordValidator)
+	validator.On("Validate", []string{"invalid"}).Return(defaultErr)
+	validator.On("Validate", mock.Anything).Return(nil)
+
+	recordStream := make(chan []string, 10)
+	sqlFetcher := repository.SqlDataFetcher{
+		Db:          db,
+		OutStream:   recordStream,
+		SelectQuery: selectQuery,
+		Limit:       2,
+	}
+	sqlFetcher.AddValidator(validator)
+	var counted bool
+	sqlFetcher.OnRecordsCounted(func(uint) { counted = true })
+
+	assert.NoError(t, sqlFetcher.StreamData(context.Background()))
+	close(recordStream)
+
+	var got [][]string
+	for record := range recordStream {
+		got = append(got, record)
+	}
+	assert.Equal(t, [][]string{{"id"}, {"1"}, {"3"}}, got)
+	assert.False(t, counted)
+	validator.AssertNotCalled(t, "Validate", []string{"4"})
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -1,71 +1,133 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"errors"
 	"io"
-	"os"
-	"path"
+	"regexp"
+	"sort"
 
-	"github.com/google/uuid"
 	"github.com/inhies/go-bytesize"
 )
 
 var (
 	ErrSingleRecordOverflowsLimits = errors.New("limits are too strict: single record overflows them")
+	ErrPartitionColumnNotFound     = errors.New("partition column not found in header")
 )
 
-type CsvFormatter struct {
-	inStream         <-chan []string
-	outStream        chan<- io.ReadCloser
-	recordsProcessed uint
-	csvWriter        *csv.Writer
-	limitWriter      *LimitWriter
-	currentFile      *os.File
-	sizeLimit        bytesize.ByteSize
-	lineLimit        uint
-}
+const (
+	utf8BOM            = "\xEF\xBB\xBF"
+	emptyPartitionName = "_"
+	// partitionHashSeparator can't occur in a safe value, so a sanitized
+	// name never equals one kept as is.
+	partitionHashSeparator = "~"
+)
+
+var (
+	unsafePartitionChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+)
+
+type (
+	CsvDialect struct {
+		Delimiter    rune
+		QuoteAll     bool
+		UseCRLF      bool
+		WithBOM      bool
+		RepeatHeader bool
+	}
+
+	PartitionedFile interface {
+		io.ReadCloser
+		Partition() string
+	}
+
+	csvRecordWriter interface {
+		Write(record []string) error
+		Flush()
+		Error() error
+	}
+
+	csvFile struct {
+		*spoolFile
+		partition string
+		records   uint
+	}
+
+	csvPartition struct {
+		name          string
+		file          *csvFile
+		limitWriter   *LimitWriter
+		recordsInFile uint
+	}
+
+	CsvFormatter struct {
+		inStream         <-chan []string
+		outStream        chan<- io.ReadCloser
+		recordsProcessed uint
+		header           []string
+		recordBuf        *bytes.Buffer
+		csvWriter        csvRecordWriter
+		partitions       map[string]*csvPartition
+		partitionColumn  string
+		partitionIdx     int
+		spool            *GenerationSpool
+		sizeLimit        bytesize.ByteSize
+		lineLimit        uint
+		dialect          CsvDialect
+	}
+)
 
 func NewCsvFormatter(
 	inStream <-chan []string,
 	outStream chan<- io.ReadCloser,
 	sizeLimit bytesize.ByteSize,
 	lineLimit uint,
+	dialect CsvDialect,
+	partitionColumn string,
+	spool *GenerationSpool,
 ) *CsvFormatter {
+	recordBuf := new(bytes.Buffer)
 	return &CsvFormatter{
-		inStream:  inStream,
-		outStream: outStream,
-		sizeLimit: sizeLimit,
-		lineLimit: lineLimit,
+		inStream:        inStream,
+		outStream:       outStream,
+		sizeLimit:       sizeLimit,
+		lineLimit:       lineLimit,
+		dialect:         dialect,
+		partitionColumn: partitionColumn,
+		spool:           spool,
+		partitions:      make(map[string]*csvPartition),
+		recordBuf:       recordBuf,
+		csvWriter:       newCsvRecordWriter(recordBuf, dialect),
 	}
 }
 
-func (f *CsvFormatter) FormatFiles(ctx context.Context) error {
-	if err := f.createCsvWriter(); err != nil {
-		return err
+// FormatFiles writes the records to files and sends them to the out stream;
+// the files it didn't send are closed when it fails or is canceled.
+func (f *CsvFormatter) FormatFiles(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			f.closePartitions()
+		}
+	}()
+	if !f.isPartitioned() {
+		if _, err := f.openPartition(""); err != nil {
+			return err
+		}
 	}
 	for {
 		select {
 		case record, isOpen := <-f.inStream:
 			if !isOpen {
-				return f.sendCsvFileToStream()
-			}
-			err := f.writeRecordToCsv(record)
-			if err == ErrLinesOverflow || err == ErrSizeOverflow {
-				if f.limitWriter.LinesWritten() == 0 {
-					return ErrSingleRecordOverflowsLimits
-				}
-				if err := f.sendCsvFileToStream(); err != nil {
+				if err := f.sendAllPartitionsToStream(ctx); err != nil {
 					return err
 				}
-				if err := f.createCsvWriter(); err != nil {
-					return err
-				}
-				if err := f.writeRecordToCsv(record); err != nil {
-					return err
-				}
-			} else if err != nil {
+				return f.spool.finishCheckpoint()
+			}
+			if err := f.formatRecord(ctx, record); err != nil {
 				return err
 			}
 		case <-ctx.Done():
@@ -74,35 +136,220 @@ func (f *CsvFormatter) FormatFiles(ctx context.Context) error {
 	}
 }
 
-func (f *CsvFormatter) createCsvWriter() error {
-	file, err := createTmpFile()
+func (f *CsvFormatter) isPartitioned() bool {
+	return f.partitionColumn != ""
+}
+
+func (f *CsvFormatter) formatRecord(ctx context.Context, record []string) error {
+	if f.header == nil {
+		return f.handleHeader(record)
+	}
+	partition, err := f.getPartition(record)
 	if err != nil {
 		return err
 	}
-	f.currentFile = file
-	f.limitWriter = NewLimitWriter(file, f.sizeLimit, f.lineLimit)
-	f.csvWriter = csv.NewWriter(f.limitWriter)
+	err = f.writeRecordToCsv(partition, record)
+	if err == ErrLinesOverflow || err == ErrSizeOverflow {
+		if !f.canRotate(partition) {
+			return ErrSingleRecordOverflowsLimits
+		}
+		if err := f.rotatePartitionFile(ctx, partition); err != nil {
+			return err
+		}
+		err = f.writeRecordToCsv(partition, record)
+		if err == ErrLinesOverflow || err == ErrSizeOverflow {
+			return ErrSingleRecordOverflowsLimits
+		}
+	}
+	if err != nil {
+		return err
+	}
+	partition.recordsInFile++
+	f.recordsProcessed++
 	return nil
 }
 
-func (f *CsvFormatter) sendCsvFileToStream() error {
-	if err := f.limitWriter.Flush(); err != nil {
+func (f *CsvFormatter) canRotate(partition *csvPartition) bool {
+	if partition.recordsInFile > 0 {
+		return true
+	}
+	return !f.dialect.RepeatHeader && partition.limitWriter.RecordsWritten() > 0
+}
+
+func (f *CsvFormatter) handleHeader(header []string) error {
+	f.header = header
+	if !f.isPartitioned() {
+		return f.writeHeader(f.partitions[""])
+	}
+	for idx, column := range header {
+		if column == f.partitionColumn {
+			f.partitionIdx = idx
+			return nil
+		}
+	}
+	return ErrPartitionColumnNotFound
+}
+
+func (f *CsvFormatter) getPartition(record []string) (*csvPartition, error) {
+	var name string
+	if f.isPartitioned() {
+		// A record without the column goes with the empty values.
+		var value string
+		if f.partitionIdx < len(record) {
+			value = record[f.partitionIdx]
+		}
+		name = partitionName(value)
+	}
+	if partition, ok := f.partitions[name]; ok {
+		return partition, nil
+	}
+	partition, err := f.openPartition(name)
+	if err != nil {
+		return nil, err
+	}
+	return partition, f.writeHeader(partition)
+}
+
+// partitionName keeps a value that is safe as a directory name. Other values
+// are sanitized and get a short hash of the value appended, so different
+// values never share a partition.
+func partitionName(value string) string {
+	if !isSpecialDirName(value) && !unsafePartitionChars.MatchString(value) {
+		return value
+	}
+	name := unsafePartitionChars.ReplaceAllString(value, "_")
+	if isSpecialDirName(name) {
+		name = emptyPartitionName
+	}
+	sum := sha256.Sum256([]byte(value))
+	return name + partitionHashSeparator + hex.EncodeToString(sum[:4])
+}
+
+func isSpecialDirName(name string) bool {
+	return name == "" || name == "." || name == ".."
+}
+
+func (f *CsvFormatter) openPartition(name string) (*csvPartition, error) {
+	partition := &csvPartition{name: name}
+	if err := f.createCsvFile(partition); err != nil {
+		return nil, err
+	}
+	f.partitions[name] = partition
+	return partition, nil
+}
+
+func (f *CsvFormatter) rotatePartitionFile(ctx context.Context, partition *csvPartition) error {
+	if err := f.sendCsvFileToStream(ctx, partition); err != nil {
+		return err
+	}
+	if err := f.createCsvFile(partition); err != nil {
 		return err
-	} else if _, err := f.currentFile.Seek(0, 0); err != nil {
+	}
+	if f.dialect.RepeatHeader {
+		return f.writeHeader(partition)
+	}
+	return nil
+}
+
+func (f *CsvFormatter) writeHeader(partition *csvPartition) error {
+	if f.header == nil {
+		return nil
+	}
+	err := f.writeRecordToCsv(partition, f.header)
+	if err == ErrLinesOverflow || err == ErrSizeOverflow {
+		return ErrSingleRecordOverflowsLimits
+	}
+	return err
+}
+
+func (f *CsvFormatter) createCsvFile(partition *csvPartition) error {
+	file, err := f.spool.createFile()
+	if err != nil {
 		return err
 	}
-	f.outStream <- f.currentFile
+	partition.file = &csvFile{spoolFile: file, partition: partition.name}
+	partition.recordsInFile = 0
+	partition.limitWriter = NewLimitWriter(file, f.sizeLimit, f.lineLimit)
+	if f.dialect.WithBOM {
+		if _, err := partition.limitWriter.Write([]byte(utf8BOM)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (f *CsvFormatter) writeRecordToCsv(record []string) error {
+func (f *CsvFormatter) sendAllPartitionsToStream(ctx context.Context) error {
+	names := make([]string, 0, len(f.partitions))
+	for name := range f.partitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := f.sendCsvFileToStream(ctx, f.partitions[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *CsvFormatter) sendCsvFileToStream(ctx context.Context, partition *csvPartition) error {
+	if err := partition.limitWriter.Flush(); err != nil {
+		return err
+	} else if _, err := partition.file.Seek(0, 0); err != nil {
+		return err
+	}
+	partition.file.records = partition.recordsInFile
+	if err := f.spool.addToCheckpoint(partition.file); err != nil {
+		return err
+	}
+	select {
+	case f.outStream <- partition.file:
+		// The receiver closes it from now on.
+		partition.file = nil
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closePartitions closes the files that weren't sent to the out stream.
+func (f *CsvFormatter) closePartitions() {
+	for _, partition := range f.partitions {
+		if partition.file != nil {
+			partition.file.Close()
+			partition.file = nil
+		}
+	}
+}
+
+func (f *CsvFormatter) writeRecordToCsv(partition *csvPartition, record []string) error {
+	f.recordBuf.Reset()
 	if err := f.csvWriter.Write(record); err != nil {
 		return err
 	}
 	f.csvWriter.Flush()
-	return f.csvWriter.Error()
+	if err := f.csvWriter.Error(); err != nil {
+		return err
+	}
+	return partition.limitWriter.WriteRecord(f.recordBuf.Bytes())
 }
 
-func createTmpFile() (*os.File, error) {
-	return os.Create(path.Join("/tmp", uuid.NewString()+".csv"))
+func (c *csvFile) Partition() string {
+	return c.partition
+}
+
+func (c *csvFile) Records() uint {
+	return c.records
+}
+
+func newCsvRecordWriter(w io.Writer, dialect CsvDialect) csvRecordWriter {
+	if dialect.QuoteAll {
+		return newQuotingCsvWriter(w, dialect.Delimiter, dialect.UseCRLF)
+	}
+	csvWriter := csv.NewWriter(w)
+	if dialect.Delimiter != 0 {
+		csvWriter.Comma = dialect.Delimiter
+	}
+	csvWriter.UseCRLF = dialect.UseCRLF
+	return csvWriter
 }
@@ -2,14 +2,23 @@ package repository_test
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/csv"
 	"io"
+	"io/ioutil"
+	"math/rand"
+	"path"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
+	"testing/quick"
 	"time"
 
 	"github.com/inhies/go-bytesize"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"go-feedmaker/adapter/repository"
 	helper "go-feedmaker/infrastructure/testing"
@@ -127,6 +136,9 @@ func TestCsvFormatter_FormatFiles(t *testing.T) {
 				tc.fields.outStream,
 				tc.fields.sizeLimit,
 				tc.fields.lineLimit,
+				repository.CsvDialect{},
+				"",
+				newTestSpool(t),
 			)
 
 			var wg sync.WaitGroup
@@ -169,6 +181,352 @@ func TestCsvFormatter_FormatFiles(t *testing.T) {
 	}
 }
 
+func TestCsvFormatter_FormatFiles_Dialect(t *testing.T) {
+	testCases := []struct {
+		name      string
+		dialect   repository.CsvDialect
+		sizeLimit bytesize.ByteSize
+		lineLimit uint
+		records   [][]string
+		wantFiles []string
+		wantErr   error
+	}{
+		{
+			name:      "header only in first file",
+			dialect:   repository.CsvDialect{},
+			sizeLimit: bytesize.MB,
+			lineLimit: 2,
+			records:   [][]string{{"h1", "h2"}, {"a1", "b1"}, {"a2", "b2"}},
+			wantFiles: []string{"h1,h2\na1,b1\n", "a2,b2\n"},
+		},
+		{
+			name:      "header repeated in every file",
+			dialect:   repository.CsvDialect{RepeatHeader: true},
+			sizeLimit: bytesize.MB,
+			lineLimit: 2,
+			records:   [][]string{{"h1", "h2"}, {"a1", "b1"}, {"a2", "b2"}, {"a3", "b3"}},
+			wantFiles: []string{"h1,h2\na1,b1\n", "h1,h2\na2,b2\n", "h1,h2\na3,b3\n"},
+		},
+		{
+			name:      "semicolon delimiter and crlf",
+			dialect:   repository.CsvDialect{Delimiter: ';', UseCRLF: true},
+			sizeLimit: bytesize.MB,
+			lineLimit: 10,
+			records:   [][]string{{"h1", "h2"}, {"a;1", "b1"}},
+			wantFiles: []string{"h1;h2\r\n\"a;1\";b1\r\n"},
+		},
+		{
+			name:      "quote all fields",
+			dialect:   repository.CsvDialect{QuoteAll: true},
+			sizeLimit: bytesize.MB,
+			lineLimit: 10,
+			records:   [][]string{{"h1", "h2"}, {"a\"1", ""}},
+			wantFiles: []string{"\"h1\",\"h2\"\n\"a\"\"1\",\"\"\n"},
+		},
+		{
+			name:      "bom in every file",
+			dialect:   repository.CsvDialect{WithBOM: true, RepeatHeader: true},
+			sizeLimit: 10,
+			lineLimit: 10,
+			records:   [][]string{{"h"}, {"a1"}, {"a2"}},
+			wantFiles: []string{"\xEF\xBB\xBFh\na1\n", "\xEF\xBB\xBFh\na2\n"},
+		},
+		{
+			name:      "header does not leave space for records",
+			dialect:   repository.CsvDialect{RepeatHeader: true},
+			sizeLimit: bytesize.MB,
+			lineLimit: 1,
+			records:   [][]string{{"h1", "h2"}, {"a1", "b1"}},
+			wantErr:   repository.ErrSingleRecordOverflowsLimits,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inStream := make(chan []string, len(tc.records))
+			outStream := make(chan io.ReadCloser)
+			for _, record := range tc.records {
+				inStream <- record
+			}
+			close(inStream)
+			formatter := repository.NewCsvFormatter(inStream, outStream, tc.sizeLimit, tc.lineLimit, tc.dialect, "", newTestSpool(t))
+
+			var gotErr error
+			go func() {
+				defer close(outStream)
+				gotErr = formatter.FormatFiles(context.Background())
+			}()
+
+			gotFiles := make([]string, 0)
+			for file := range outStream {
+				content, err := ioutil.ReadAll(file)
+				assert.NoError(t, err)
+				assert.NoError(t, file.Close())
+				gotFiles = append(gotFiles, string(content))
+			}
+			assert.Equal(t, tc.wantErr, gotErr)
+			if tc.wantErr == nil {
+				assert.Equal(t, tc.wantFiles, gotFiles)
+			}
+		})
+	}
+}
+
+func TestCsvFormatter_FormatFiles_Partitioned(t *testing.T) {
+	type partitionFile struct {
+		partition string
+		content   string
+	}
+	testCases := []struct {
+		name            string
+		partitionColumn string
+		dialect         repository.CsvDialect
+		lineLimit       uint
+		records         [][]string
+		wantFiles       []partitionFile
+		wantErr         error
+	}{
+		{
+			name:            "limits are applied per partition",
+			partitionColumn: "country",
+			lineLimit:       2,
+			records: [][]string{
+				{"id", "country"},
+				{"1", "DE"}, {"2", "AT"}, {"3", "DE"}, {"4", "DE"},
+			},
+			wantFiles: []partitionFile{
+				{partition: "DE", content: "id,country\n1,DE\n"},
+				{partition: "DE", content: "3,DE\n4,DE\n"},
+				{partition: "AT", content: "id,country\n2,AT\n"},
+			},
+		},
+		{
+			name:            "header repeated in partition files",
+			partitionColumn: "country",
+			dialect:         repository.CsvDialect{RepeatHeader: true},
+			lineLimit:       2,
+			records: [][]string{
+				{"id", "country"},
+				{"1", "DE"}, {"2", "DE"},
+			},
+			wantFiles: []partitionFile{
+				{partition: "DE", content: "id,country\n1,DE\n"},
+				{partition: "DE", content: "id,country\n2,DE\n"},
+			},
+		},
+		{
+			name:            "unsafe partition values are sanitized",
+			partitionColumn: "brand",
+			lineLimit:       10,
+			records: [][]string{
+				{"id", "brand"},
+				{"1", "../etc"}, {"2", ""},
+			},
+			wantFiles: []partitionFile{
+				{partition: ".._etc~f7f9121f", content: "id,brand\n1,../etc\n"},
+				{partition: "_~e3b0c442", content: "id,brand\n2,\n"},
+			},
+		},
+		{
+			name:            "sanitized partition values don't merge",
+			partitionColumn: "brand",
+			lineLimit:       10,
+			records: [][]string{
+				{"id", "brand"},
+				{"1", "D E"}, {"2", "D_E"}, {"3", "_"}, {"4", ""},
+			},
+			wantFiles: []partitionFile{
+				{partition: "D_E~f8d1ec36", content: "id,brand\n1,D E\n"},
+				{partition: "D_E", content: "id,brand\n2,D_E\n"},
+				{partition: "_", content: "id,brand\n3,_\n"},
+				{partition: "_~e3b0c442", content: "id,brand\n4,\n"},
+			},
+		},
+		{
+			name:            "records without the partition column",
+			partitionColumn: "brand",
+			lineLimit:       10,
+			records: [][]string{
+				{"id", "brand"},
+				{"1"}, {"2", ""},
+			},
+			wantFiles: []partitionFile{
+				{partition: "_~e3b0c442", content: "id,brand\n1\n2,\n"},
+			},
+		},
+		{
+			name:            "unknown partition column",
+			partitionColumn: "country",
+			lineLimit:       10,
+			records:         [][]string{{"id", "brand"}, {"1", "a"}},
+			wantErr:         repository.ErrPartitionColumnNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inStream := make(chan []string, len(tc.records))
+			outStream := make(chan io.ReadCloser)
+			for _, record := range tc.records {
+				inStream <- record
+			}
+			close(inStream)
+			formatter := repository.NewCsvFormatter(inStream, outStream, bytesize.MB, tc.lineLimit, tc.dialect, tc.partitionColumn, newTestSpool(t))
+
+			var gotErr error
+			go func() {
+				defer close(outStream)
+				gotErr = formatter.FormatFiles(context.Background())
+			}()
+
+			gotFiles := make(map[string][]string)
+			for file := range outStream {
+				partitioned, ok := file.(repository.PartitionedFile)
+				require.True(t, ok)
+				content, err := ioutil.ReadAll(file)
+				assert.NoError(t, err)
+				assert.NoError(t, file.Close())
+				gotFiles[partitioned.Partition()] = append(gotFiles[partitioned.Partition()], string(content))
+			}
+			assert.Equal(t, tc.wantErr, gotErr)
+			wantFiles := make(map[string][]string)
+			for _, file := range tc.wantFiles {
+				wantFiles[file.partition] = append(wantFiles[file.partition], file.content)
+			}
+			assert.Equal(t, wantFiles, gotFiles)
+		})
+	}
+}
+
+type csvRecords [][]string
+
+func (csvRecords) Generate(rand *rand.Rand, size int) reflect.Value {
+	alphabet := []string{"a", "b", ",", ";", "\"", "\n", "\r\n", " ", "ü"}
+	records := make(csvRecords, rand.Intn(size+1))
+	for i := range records {
+		record := make([]string, 3)
+		for j := range record {
+			var field strings.Builder
+			for k := rand.Intn(8); k > 0; k-- {
+				field.WriteString(alphabet[rand.Intn(len(alphabet))])
+			}
+			record[j] = field.String()
+		}
+		records[i] = record
+	}
+	return reflect.ValueOf(records)
+}
+
+func TestCsvFormatter_FormatFiles_Properties(t *testing.T) {
+	spool := newTestSpool(t)
+	property := func(records csvRecords, rawSizeLimit uint8, rawLineLimit uint8, useCRLF bool) bool {
+		sizeLimit, lineLimit := int(rawSizeLimit)+64, uint(rawLineLimit%10)+1
+		inStream := make(chan []string, len(records))
+		outStream := make(chan io.ReadCloser, len(records)+1)
+		for _, record := range records {
+			inStream <- record
+		}
+		close(inStream)
+		dialect := repository.CsvDialect{UseCRLF: useCRLF}
+		formatter := repository.NewCsvFormatter(inStream, outStream, bytesize.ByteSize(sizeLimit), lineLimit, dialect, "", spool)
+		if err := formatter.FormatFiles(context.Background()); err != nil {
+			return err == repository.ErrSingleRecordOverflowsLimits
+		}
+		close(outStream)
+
+		got := make([][]string, 0, len(records))
+		for file := range outStream {
+			content, err := ioutil.ReadAll(file)
+			if err != nil || file.Close() != nil || len(content) > sizeLimit {
+				return false
+			}
+			reader := csv.NewReader(bytes.NewReader(content))
+			reader.FieldsPerRecord = -1
+			fileRecords, err := reader.ReadAll()
+			if err != nil || uint(len(fileRecords)) > lineLimit {
+				return false
+			}
+			got = append(got, fileRecords...)
+		}
+		if len(got) != len(records) {
+			return false
+		}
+		for i := range records {
+			if !reflect.DeepEqual(normalizeLineEndings(records[i]), got[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	require.NoError(t, quick.Check(property, nil))
+}
+
+func normalizeLineEndings(record []string) []string {
+	normalized := make([]string, len(record))
+	for i, field := range record {
+		normalized[i] = strings.ReplaceAll(field, "\r\n", "\n")
+	}
+	return normalized
+}
+
+// The files a failed or canceled formatter didn't send are closed, so they
+// leave neither disk usage nor open descriptors behind.
+func TestCsvFormatter_FormatFiles_Cleanup(t *testing.T) {
+	testCases := []struct {
+		name    string
+		records [][]string
+		cancel  bool
+		wantErr error
+	}{
+		{
+			name:    "failed",
+			records: [][]string{{"id", "country"}, {"1", "DE"}, {strings.Repeat("2", 64), "AT"}},
+			wantErr: repository.ErrSingleRecordOverflowsLimits,
+		},
+		{
+			name:    "canceled while sending",
+			records: [][]string{{"id", "country"}, {"1", "DE"}, {"2", "AT"}},
+			cancel:  true,
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			spool, err := repository.NewSpool(dir, bytesize.MB)
+			require.NoError(t, err)
+			inStream := make(chan []string, len(tc.records))
+			for _, record := range tc.records {
+				inStream <- record
+			}
+			close(inStream)
+			// Nobody reads the files.
+			outStream := make(chan io.ReadCloser)
+			formatter := repository.NewCsvFormatter(inStream, outStream, 64, 10,
+				repository.CsvDialect{RepeatHeader: true}, "country", spool.Generation("test"))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancel {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+
+			gotErr := formatter.FormatFiles(ctx)
+
+			assert.ErrorIs(t, gotErr, tc.wantErr)
+			assert.Zero(t, spool.Used())
+			files, err := ioutil.ReadDir(path.Join(dir, "test"))
+			require.NoError(t, err)
+			assert.Empty(t, files)
+		})
+	}
+}
+
+func newTestSpool(t *testing.T) *repository.GenerationSpool {
+	spool, err := repository.NewSpool(t.TempDir(), bytesize.GB)
+	require.NoError(t, err)
+	return spool.Generation("test")
+}
+
 func produceRecords(ctx context.Context, stream chan<- []string, records []record) {
 	if len(records) == 0 {
 		return
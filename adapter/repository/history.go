@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"go-feedmaker/entity"
+)
+
+const (
+	DefaultGenerationsLimit = 50
+	MaxGenerationsLimit     = 500
+)
+
+// indexEntry is a generation in the start time index; a cursor points at
+// the last entry of a page.
+type indexEntry struct {
+	score int64
+	id    string
+}
+
+// startTimeScore is the score of a generation in the start time index.
+// Milliseconds keep the score exact in a float64.
+func startTimeScore(startTime time.Time) int64 {
+	return startTime.UnixNano() / int64(time.Millisecond)
+}
+
+func encodeCursor(entry *indexEntry) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", entry.score, entry.id)))
+}
+
+func parseCursor(cursor string) (*indexEntry, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrInvalidCursor, cursor)
+	}
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("%w: %s", entity.ErrInvalidCursor, cursor)
+	}
+	score, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrInvalidCursor, cursor)
+	}
+	return &indexEntry{score: score, id: parts[1]}, nil
+}
+
+func generationsLimit(limit uint) uint {
+	switch {
+	case limit == 0:
+		return DefaultGenerationsLimit
+	case limit > MaxGenerationsLimit:
+		return MaxGenerationsLimit
+	default:
+		return limit
+	}
+}
+
+// ListGenerations pages through the start time index, newest first. The
+// index is read in batches and the hashes of every batch are loaded in a
+// pipeline; type and status filters are applied to the loaded generations,
+// so a page may take several batches. The returned cursor is empty on the
+// last page.
+func (r *feedRepo) ListGenerations(ctx context.Context, filter *entity.GenerationFilter) ([]*entity.Generation, string, error) {
+	limit := generationsLimit(filter.Limit)
+	max, min := "+inf", "-inf"
+	if !filter.To.IsZero() {
+		max = strconv.FormatInt(startTimeScore(filter.To), 10)
+	}
+	if !filter.From.IsZero() {
+		min = strconv.FormatInt(startTimeScore(filter.From), 10)
+	}
+	var after *indexEntry
+	if filter.Cursor != "" {
+		var err error
+		if after, err = parseCursor(filter.Cursor); err != nil {
+			return nil, "", err
+		}
+		if filter.To.IsZero() || after.score < startTimeScore(filter.To) {
+			max = strconv.FormatInt(after.score, 10)
+		}
+	}
+
+	conn := r.client.Connection()
+	defer conn.Close()
+	generations := make([]*entity.Generation, 0, limit)
+	for offset := uint(0); ; offset += limit {
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		entries, err := r.readIndex(conn, max, min, offset, limit)
+		if err != nil {
+			return nil, "", err
+		}
+		batch, err := r.getGenerations(conn, entries)
+		if err != nil {
+			return nil, "", err
+		}
+		for i, entry := range entries {
+			// Generations started in the same millisecond come in reverse
+			// order of their ids; the ones up to the cursor were listed.
+			if after != nil && entry.score == after.score && entry.id >= after.id {
+				continue
+			}
+			if batch[i] == nil || !filter.Matches(batch[i]) {
+				continue
+			}
+			generations = append(generations, batch[i])
+			if uint(len(generations)) == limit {
+				return generations, encodeCursor(entry), nil
+			}
+		}
+		if uint(len(entries)) < limit {
+			return generations, "", nil
+		}
+	}
+}
+
+func (r *feedRepo) readIndex(conn redis.Conn, max, min string, offset, count uint) ([]*indexEntry, error) {
+	values, err := redis.Strings(conn.Do("ZREVRANGEBYSCORE", r.indexName, max, min,
+		"WITHSCORES", "LIMIT", offset, count))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*indexEntry, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		score, err := strconv.ParseFloat(values[i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &indexEntry{score: int64(score), id: values[i]})
+	}
+	return entries, nil
+}
+
+// getGenerations loads the hashes of entries in a pipeline. Generations
+// whose hash was removed are nil.
+func (r *feedRepo) getGenerations(conn redis.Conn, entries []*indexEntry) ([]*entity.Generation, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	for _, entry := range entries {
+		if err := conn.Send("HGETALL", entry.id); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	generations := make([]*entity.Generation, len(entries))
+	for i, entry := range entries {
+		values, err := redis.StringMap(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			continue
+		}
+		values["id"] = entry.id
+		if generations[i], err = makeGenerationFromRedisValues(values); err != nil {
+			return nil, err
+		}
+	}
+	return generations, nil
+}
+
+// IndexGenerations moves generations stored by older versions from the ID
+// set to the start time index.
+func (r *feedRepo) IndexGenerations(ctx context.Context) error {
+	conn := r.client.Connection()
+	defer conn.Close()
+	ids, err := redis.Strings(conn.Do("SMEMBERS", r.idSetName))
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	for _, id := range ids {
+		if err := conn.Send("HGET", id, "start_time"); err != nil {
+			return err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	args := new(redis.Args).Add(r.indexName)
+	for _, id := range ids {
+		startTime, err := redis.Int64(conn.Receive())
+		if err == redis.ErrNil {
+			continue
+		} else if err != nil {
+			return err
+		}
+		args = args.Add(startTimeScore(time.Unix(startTime, 0)), id)
+	}
+	if len(args) > 1 {
+		if _, err := conn.Do("ZADD", args...); err != nil {
+			return err
+		}
+	}
+	_, err = conn.Do("DEL", r.idSetName)
+	return err
+}
@@ -0,0 +1,389 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-feedmaker/adapter/repository"
+	"go-feedmaker/entity"
+)
+
+func indexCursor(score int64, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", score, id)))
+}
+
+// indexReply builds a ZREVRANGEBYSCORE WITHSCORES reply from id, score pairs.
+func indexReply(pairs ...interface{}) []interface{} {
+	reply := make([]interface{}, 0, len(pairs))
+	for i := 0; i+1 < len(pairs); i += 2 {
+		reply = append(reply, []byte(pairs[i].(string)), []byte(strconv.Itoa(pairs[i+1].(int))))
+	}
+	return reply
+}
+
+// expectGenerations expects a pipelined HGETALL for every id, replied with
+// hashes in order.
+func expectGenerations(f *feedFields, ids []string, hashes ...[]interface{}) {
+	for _, id := range ids {
+		f.conn.On("Send", "HGETALL", id).Return(nil).Once()
+	}
+	f.conn.On("Flush").Return(nil).Once()
+	for _, hash := range hashes {
+		f.conn.On("Receive").Return(hash, nil).Once()
+	}
+}
+
+func TestFeedRepo_ListGenerations(t *testing.T) {
+	type args struct {
+		ctx    context.Context
+		filter *entity.GenerationFilter
+	}
+	hash123 := []interface{}{
+		[]byte("type"), []byte("test1"),
+		[]byte("progress"), []byte("100"),
+		[]byte("files_uploaded"), []byte("4"),
+		[]byte("data_fetched"), []byte("1"),
+		[]byte("is_canceled"), []byte("1"),
+		[]byte("start_time"), []byte(strconv.Itoa(int(time.Unix(1, 0).Unix()))),
+	}
+	hash234 := []interface{}{
+		[]byte("type"), []byte("test2"),
+		[]byte("progress"), []byte("43"),
+		[]byte("files_uploaded"), []byte("5"),
+		[]byte("data_fetched"), []byte("0"),
+		[]byte("start_time"), []byte(strconv.Itoa(int(time.Unix(11, 0).Unix()))),
+		[]byte("end_time"), []byte(strconv.Itoa(int(time.Unix(20, 0).Unix()))),
+	}
+	generation123 := &entity.Generation{
+		ID: "123", Type: "test1",
+		Progress:      100,
+		FilesUploaded: 4,
+		DataFetched:   true,
+		IsCanceled:    true,
+		StartTime:     time.Unix(1, 0),
+	}
+	generation234 := &entity.Generation{
+		ID: "234", Type: "test2",
+		Progress:      43,
+		FilesUploaded: 5,
+		DataFetched:   false,
+		StartTime:     time.Unix(11, 0),
+		EndTime:       time.Unix(20, 0),
+	}
+	// Interrupted before it fetched the data, it looks running.
+	hash345 := []interface{}{
+		[]byte("type"), []byte("test1"),
+		[]byte("progress"), []byte("10"),
+		[]byte("start_time"), []byte(strconv.Itoa(int(time.Unix(12, 0).Unix()))),
+	}
+	generation345 := &entity.Generation{
+		ID: "345", Type: "test1",
+		Progress:  10,
+		StartTime: time.Unix(12, 0),
+	}
+	// Every stage ended, but the outcome isn't stored yet.
+	stages := `[{"name":"fetch","start_time":"1970-01-01T00:00:13Z","end_time":"1970-01-01T00:00:14Z"}]`
+	hash456 := []interface{}{
+		[]byte("type"), []byte("test1"),
+		[]byte("start_time"), []byte(strconv.Itoa(int(time.Unix(13, 0).Unix()))),
+		[]byte("stages"), []byte(stages),
+	}
+	generation456 := &entity.Generation{
+		ID: "456", Type: "test1",
+		StartTime: time.Unix(13, 0),
+		Stages: []*entity.StageTiming{
+			{Name: "fetch", StartTime: time.Unix(13, 0).UTC(), EndTime: time.Unix(14, 0).UTC()},
+		},
+	}
+	hash567 := append([]interface{}{[]byte("finished"), []byte("1")}, hash456...)
+	testCases := []struct {
+		name       string
+		args       *args
+		setupMocks func(*args, *feedFields)
+		want       []*entity.Generation
+		wantCursor string
+		wantErr    error
+	}{
+		{
+			name: "full page",
+			args: &args{ctx: context.Background(), filter: &entity.GenerationFilter{Limit: 2}},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "+inf", "-inf", "WITHSCORES", "LIMIT", uint(0), uint(2)).
+					Return(indexReply("234", 11000, "123", 1000), nil)
+				expectGenerations(f, []string{"234", "123"}, hash234, hash123)
+			},
+			want:       []*entity.Generation{generation234, generation123},
+			wantCursor: indexCursor(1000, "123"),
+		},
+		{
+			name: "last page",
+			args: &args{ctx: context.Background(), filter: &entity.GenerationFilter{}},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "+inf", "-inf", "WITHSCORES", "LIMIT",
+						uint(0), uint(repository.DefaultGenerationsLimit)).
+					Return(indexReply("234", 11000, "123", 1000), nil)
+				expectGenerations(f, []string{"234", "123"}, hash234, hash123)
+			},
+			want: []*entity.Generation{generation234, generation123},
+		},
+		{
+			name: "filtered and deleted generations skipped across batches",
+			args: &args{ctx: context.Background(), filter: &entity.GenerationFilter{Types: []string{"test1"}, Limit: 2}},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "+inf", "-inf", "WITHSCORES", "LIMIT", uint(0), uint(2)).
+					Return(indexReply("234", 11000, "345", 5000), nil)
+				expectGenerations(f, []string{"234", "345"}, hash234, []interface{}{})
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "+inf", "-inf", "WITHSCORES", "LIMIT", uint(2), uint(2)).
+					Return(indexReply("123", 1000), nil)
+				expectGenerations(f, []string{"123"}, hash123)
+			},
+			want: []*entity.Generation{generation123},
+		},
+		{
+			name: "legacy generations finished by progress or end time",
+			args: &args{ctx: context.Background(), filter: &entity.GenerationFilter{
+				Statuses: []entity.GenerationStatus{entity.GenerationRunning},
+			}},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "+inf", "-inf", "WITHSCORES", "LIMIT",
+						uint(0), uint(repository.DefaultGenerationsLimit)).
+					Return(indexReply("345", 12000, "234", 11000), nil)
+				expectGenerations(f, []string{"345", "234"}, hash345, hash234)
+			},
+			want: []*entity.Generation{generation345},
+		},
+		{
+			name: "running until the outcome is stored",
+			args: &args{ctx: context.Background(), filter: &entity.GenerationFilter{
+				Statuses: []entity.GenerationStatus{entity.GenerationRunning},
+			}},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "+inf", "-inf", "WITHSCORES", "LIMIT",
+						uint(0), uint(repository.DefaultGenerationsLimit)).
+					Return(indexReply("567", 13000, "456", 13000), nil)
+				expectGenerations(f, []string{"567", "456"}, hash567, hash456)
+			},
+			want: []*entity.Generation{generation456},
+		},
+		{
+			name: "cursor skips listed generations with the same start time",
+			args: &args{ctx: context.Background(), filter: &entity.GenerationFilter{
+				Cursor: indexCursor(11000, "345"),
+				Limit:  3,
+			}},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "11000", "-inf", "WITHSCORES", "LIMIT", uint(0), uint(3)).
+					Return(indexReply("345", 11000, "234", 11000, "123", 1000), nil)
+				expectGenerations(f, []string{"345", "234", "123"},
+					[]interface{}{[]byte("type"), []byte("test3")}, hash234, hash123)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "11000", "-inf", "WITHSCORES", "LIMIT", uint(3), uint(3)).
+					Return(indexReply(), nil)
+			},
+			want: []*entity.Generation{generation234, generation123},
+		},
+		{
+			name: "start time bounds",
+			args: &args{ctx: context.Background(), filter: &entity.GenerationFilter{
+				From:  time.Unix(1, 0),
+				To:    time.Unix(11, 0),
+				Limit: 2,
+			}},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "11000", "1000", "WITHSCORES", "LIMIT", uint(0), uint(2)).
+					Return(indexReply(), nil)
+			},
+			want: []*entity.Generation{},
+		},
+		{
+			name:       "invalid cursor",
+			args:       &args{ctx: context.Background(), filter: &entity.GenerationFilter{Cursor: "foo"}},
+			setupMocks: func(a *args, f *feedFields) {},
+			wantErr:    entity.ErrInvalidCursor,
+		},
+		{
+			name: "ZREVRANGEBYSCORE error",
+			args: &args{ctx: context.Background(), filter: &entity.GenerationFilter{}},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "+inf", "-inf", "WITHSCORES", "LIMIT", mock.Anything, mock.Anything).
+					Return(nil, defaultErr)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "HGETALL error",
+			args: &args{ctx: context.Background(), filter: &entity.GenerationFilter{}},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "+inf", "-inf", "WITHSCORES", "LIMIT", mock.Anything, mock.Anything).
+					Return(indexReply("234", 11000, "123", 1000), nil)
+				f.conn.On("Send", "HGETALL", mock.Anything).Return(nil)
+				f.conn.On("Flush").Return(nil)
+				f.conn.On("Receive").Return(hash234, nil).Once()
+				f.conn.On("Receive").Return(nil, defaultErr).Once()
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "invalid timestamp error",
+			args: &args{ctx: context.Background(), filter: &entity.GenerationFilter{}},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "+inf", "-inf", "WITHSCORES", "LIMIT", mock.Anything, mock.Anything).
+					Return(indexReply("123", 1000), nil)
+				expectGenerations(f, []string{"123"}, []interface{}{
+					[]byte("type"), []byte("test1"),
+					[]byte("start_time"), []byte("invalid"),
+				})
+			},
+			wantErr: entity.ErrInvalidTimestamp,
+		},
+		{
+			name: "succeed with manifest and destinations",
+			args: &args{ctx: context.Background(), filter: &entity.GenerationFilter{}},
+			setupMocks: func(a *args, f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.
+					On("Do", "ZREVRANGEBYSCORE", mock.Anything, "+inf", "-inf", "WITHSCORES", "LIMIT", mock.Anything, mock.Anything).
+					Return(indexReply("123", 0), nil)
+				expectGenerations(f, []string{"123"}, []interface{}{
+					[]byte("type"), []byte("test1"),
+					[]byte("manifest"), []byte(`{"generation_id":"123","type":"test1","files":[{"name":"test1_0.csv","size":10,"records":2,"sha256":"abc"}]}`),
+					[]byte("destinations"), []byte(`[{"name":"ftp","state":"failed","files_uploaded":1,"error":"test error"}]`),
+				})
+			},
+			want: []*entity.Generation{
+				{
+					ID: "123", Type: "test1",
+					Manifest: &entity.Manifest{
+						GenerationID: "123",
+						Type:         "test1",
+						Files: []*entity.FileInfo{
+							{Name: "test1_0.csv", Size: 10, Records: 2, SHA256: "abc"},
+						},
+					},
+					Destinations: []*entity.DestinationStatus{
+						{Name: "ftp", State: entity.DestinationFailed, FilesUploaded: 1, Error: "test error"},
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := defaultFeedFields()
+			tc.setupMocks(tc.args, fields)
+			feedRepo := repository.NewFeedRepo(fields.config, fields.client, fields.ftp)
+
+			got, gotCursor, gotErr := feedRepo.ListGenerations(tc.args.ctx, tc.args.filter)
+
+			assert.ErrorIs(t, gotErr, tc.wantErr)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantCursor, gotCursor)
+			fields.assertExpectations(t)
+		})
+	}
+}
+
+func TestFeedRepo_IndexGenerations(t *testing.T) {
+	testCases := []struct {
+		name       string
+		setupMocks func(*feedFields)
+		wantErr    error
+	}{
+		{
+			name: "legacy generations indexed",
+			setupMocks: func(f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.On("Do", "SMEMBERS", mock.Anything).Return([]interface{}{[]byte("123"), []byte("234")}, nil)
+				f.conn.On("Send", "HGET", "123", "start_time").Return(nil)
+				f.conn.On("Send", "HGET", "234", "start_time").Return(nil)
+				f.conn.On("Flush").Return(nil)
+				f.conn.On("Receive").Return([]byte("1"), nil).Once()
+				f.conn.On("Receive").Return(nil, nil).Once()
+				f.conn.On("Do", "ZADD", mock.Anything, int64(1000), "123").Return(int64(1), nil)
+				f.conn.On("Do", "DEL", mock.Anything).Return(int64(1), nil)
+			},
+		},
+		{
+			name: "nothing to index",
+			setupMocks: func(f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.On("Do", "SMEMBERS", mock.Anything).Return([]interface{}{}, nil)
+			},
+		},
+		{
+			name: "SMEMBERS error",
+			setupMocks: func(f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.On("Do", "SMEMBERS", mock.Anything).Return(nil, defaultErr)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "ZADD error",
+			setupMocks: func(f *feedFields) {
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.conn.On("Do", "SMEMBERS", mock.Anything).Return([]interface{}{[]byte("123")}, nil)
+				f.conn.On("Send", "HGET", "123", "start_time").Return(nil)
+				f.conn.On("Flush").Return(nil)
+				f.conn.On("Receive").Return([]byte("1"), nil)
+				f.conn.On("Do", "ZADD", mock.Anything, int64(1000), "123").Return(nil, defaultErr)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := defaultFeedFields()
+			tc.setupMocks(fields)
+			feedRepo := repository.NewFeedRepo(fields.config, fields.client, fields.ftp)
+
+			gotErr := feedRepo.IndexGenerations(context.Background())
+
+			assert.ErrorIs(t, gotErr, tc.wantErr)
+			fields.assertExpectations(t)
+		})
+	}
+}
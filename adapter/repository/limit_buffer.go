@@ -15,35 +15,49 @@ var (
 
 type (
 	LimitWriter struct {
-		lineLimit    uint
-		sizeLimit    bytesize.ByteSize
-		linesWritten int
-		bytesWritten int
-		w            *bufio.Writer
+		lineLimit      uint
+		sizeLimit      bytesize.ByteSize
+		recordsWritten uint
+		sink           *countingWriter
+		w              *bufio.Writer
+	}
+
+	countingWriter struct {
+		w io.Writer
+		n int64
 	}
 )
 
 func NewLimitWriter(w io.Writer, sizeLimit bytesize.ByteSize, lineLimit uint) *LimitWriter {
+	sink := &countingWriter{w: w}
 	return &LimitWriter{
 		lineLimit: lineLimit,
 		sizeLimit: sizeLimit,
-		w:         bufio.NewWriter(w),
+		sink:      sink,
+		w:         bufio.NewWriter(sink),
 	}
 }
 
-func (l *LimitWriter) Write(data []byte) (int, error) {
+// WriteRecord writes a single encoded record entirely or not at all.
+func (l *LimitWriter) WriteRecord(record []byte) error {
 	if l.willOverflowLines() {
-		return 0, ErrLinesOverflow
-	} else if l.willOverflowSize(len(data)) {
-		return 0, ErrSizeOverflow
+		return ErrLinesOverflow
+	} else if l.willOverflowSize(len(record)) {
+		return ErrSizeOverflow
 	}
-	if n, err := l.w.Write(data); err != nil {
-		return 0, err
-	} else {
-		l.bytesWritten += n
-		l.linesWritten += 1
-		return n, nil
+	if _, err := l.w.Write(record); err != nil {
+		return err
 	}
+	l.recordsWritten++
+	return nil
+}
+
+// Write writes data which is not a record, e.g. a byte order mark.
+func (l *LimitWriter) Write(data []byte) (int, error) {
+	if l.willOverflowSize(len(data)) {
+		return 0, ErrSizeOverflow
+	}
+	return l.w.Write(data)
 }
 
 func (l *LimitWriter) Flush() error {
@@ -52,19 +66,29 @@ func (l *LimitWriter) Flush() error {
 
 func (l *LimitWriter) Reset(w io.Writer) {
 	l.Flush()
-	l.w.Reset(w)
-	l.linesWritten = 0
-	l.bytesWritten = 0
+	l.sink = &countingWriter{w: w}
+	l.w.Reset(l.sink)
+	l.recordsWritten = 0
+}
+
+func (l *LimitWriter) RecordsWritten() uint {
+	return l.recordsWritten
 }
 
-func (l *LimitWriter) LinesWritten() int {
-	return l.linesWritten
+func (l *LimitWriter) BytesWritten() int64 {
+	return l.sink.n + int64(l.w.Buffered())
 }
 
 func (l *LimitWriter) willOverflowLines() bool {
-	return l.linesWritten+1 > int(l.lineLimit)
+	return l.recordsWritten+1 > l.lineLimit
 }
 
 func (l *LimitWriter) willOverflowSize(dataLen int) bool {
-	return dataLen+l.bytesWritten > int(l.sizeLimit)
+	return l.BytesWritten()+int64(dataLen) > int64(l.sizeLimit)
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	n, err := c.w.Write(data)
+	c.n += int64(n)
+	return n, err
 }
@@ -3,21 +3,24 @@ package repository_test
 import (
 	"bytes"
 	"io/ioutil"
+	"math/rand"
+	"reflect"
 	"testing"
+	"testing/quick"
 
 	"github.com/inhies/go-bytesize"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"go-feedmaker/adapter/repository"
 )
 
-func TestLimitBuffer_Write(t *testing.T) {
+func TestLimitBuffer_WriteRecord(t *testing.T) {
 	testCases := []struct {
 		name      string
 		sizeLimit bytesize.ByteSize
 		lineLimit uint
 		data      []byte
-		wantN     int
 		wantErr   error
 	}{
 		{
@@ -25,7 +28,12 @@ func TestLimitBuffer_Write(t *testing.T) {
 			sizeLimit: 11,
 			lineLimit: 1,
 			data:      []byte("hello world"),
-			wantN:     11,
+		},
+		{
+			name:      "multiline record counts as one",
+			sizeLimit: bytesize.MB,
+			lineLimit: 1,
+			data:      []byte("\"hello\nworld\"\n"),
 		},
 		{
 			name:      "size overflow",
@@ -47,17 +55,81 @@ func TestLimitBuffer_Write(t *testing.T) {
 			buffer := new(bytes.Buffer)
 			limitWriter := repository.NewLimitWriter(buffer, testCase.sizeLimit, testCase.lineLimit)
 
-			gotN, gotErr := limitWriter.Write(testCase.data)
+			gotErr := limitWriter.WriteRecord(testCase.data)
 			assert.NoError(t, limitWriter.Flush())
 
-			assert.Equal(t, testCase.wantN, gotN)
 			assert.Equal(t, testCase.wantErr, gotErr)
 
 			if testCase.wantErr == nil {
 				got, err := ioutil.ReadAll(buffer)
 				assert.NoError(t, err)
 				assert.Equal(t, testCase.data, got)
+				assert.Equal(t, uint(1), limitWriter.RecordsWritten())
+			} else {
+				assert.Zero(t, buffer.Len())
+				assert.Zero(t, limitWriter.RecordsWritten())
 			}
 		})
 	}
 }
+
+func TestLimitBuffer_Write(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	limitWriter := repository.NewLimitWriter(buffer, 4, 1)
+
+	n, err := limitWriter.Write([]byte("\xEF\xBB\xBF"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Zero(t, limitWriter.RecordsWritten())
+
+	assert.Equal(t, repository.ErrSizeOverflow, limitWriter.WriteRecord([]byte("ab")))
+	assert.NoError(t, limitWriter.WriteRecord([]byte("a")))
+	assert.NoError(t, limitWriter.Flush())
+	assert.Equal(t, "\xEF\xBB\xBFa", buffer.String())
+}
+
+type recordSizes []uint16
+
+func (recordSizes) Generate(rand *rand.Rand, size int) reflect.Value {
+	sizes := make(recordSizes, rand.Intn(size+1))
+	for i := range sizes {
+		sizes[i] = uint16(rand.Intn(5000))
+	}
+	return reflect.ValueOf(sizes)
+}
+
+func TestLimitBuffer_WriteRecord_Properties(t *testing.T) {
+	property := func(sizes recordSizes, sizeLimit uint16, lineLimit uint8) bool {
+		sink := new(bytes.Buffer)
+		limitWriter := repository.NewLimitWriter(sink, bytesize.ByteSize(sizeLimit), uint(lineLimit))
+		var wantBytes int64
+		var wantRecords uint
+		for _, size := range sizes {
+			err := limitWriter.WriteRecord(bytes.Repeat([]byte("x"), int(size)))
+			switch {
+			case wantRecords+1 > uint(lineLimit):
+				if err != repository.ErrLinesOverflow {
+					return false
+				}
+			case wantBytes+int64(size) > int64(sizeLimit):
+				if err != repository.ErrSizeOverflow {
+					return false
+				}
+			default:
+				if err != nil {
+					return false
+				}
+				wantBytes += int64(size)
+				wantRecords++
+			}
+			if limitWriter.BytesWritten() != wantBytes || limitWriter.RecordsWritten() != wantRecords {
+				return false
+			}
+		}
+		if err := limitWriter.Flush(); err != nil {
+			return false
+		}
+		return int64(sink.Len()) == wantBytes && wantBytes <= int64(sizeLimit)
+	}
+	require.NoError(t, quick.Check(property, nil))
+}
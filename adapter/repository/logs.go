@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"go-feedmaker/entity"
+)
+
+type (
+	// LogWriter keeps the log lines tagged with a generation in a capped
+	// Redis list per generation and publishes them to its followers. Lines
+	// are stored in the background and dropped while Redis falls behind, so
+	// logging never waits for it.
+	LogWriter struct {
+		client   RedisClient
+		maxLines uint
+		lines    chan *logLine
+		stop     chan struct{}
+		done     chan struct{}
+	}
+
+	logLine struct {
+		generationID string
+		data         []byte
+	}
+)
+
+// logBufferSize is how many lines LogWriter holds before dropping them.
+const logBufferSize = 1024
+
+func NewLogWriter(client RedisClient, maxLines uint) *LogWriter {
+	return &LogWriter{
+		client:   client,
+		maxLines: maxLines,
+		lines:    make(chan *logLine, logBufferSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (w *LogWriter) Start() {
+	go w.run()
+}
+
+// Stop stores the lines written so far.
+func (w *LogWriter) Stop() {
+	close(w.stop)
+	<-w.done
+}
+
+// Write takes a JSON log line; lines without a generation are ignored.
+func (w *LogWriter) Write(p []byte) (int, error) {
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return len(p), nil
+	}
+	generationID, _ := fields[entity.LogGenerationID].(string)
+	if generationID == "" {
+		return len(p), nil
+	}
+	line := &logLine{
+		generationID: generationID,
+		data:         append([]byte(nil), bytes.TrimSpace(p)...),
+	}
+	select {
+	case w.lines <- line:
+	default:
+	}
+	return len(p), nil
+}
+
+func (w *LogWriter) run() {
+	defer close(w.done)
+	for {
+		select {
+		case line := <-w.lines:
+			w.store(line)
+		case <-w.stop:
+			for {
+				select {
+				case line := <-w.lines:
+					w.store(line)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *LogWriter) store(line *logLine) {
+	key := generationLogKey(line.generationID)
+	conn := w.client.Connection()
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("RPUSH", key, line.data)
+	conn.Send("LTRIM", key, -int64(w.maxLines), -1)
+	conn.Send("PUBLISH", key, line.data)
+	if _, err := conn.Do("EXEC"); err != nil {
+		log.Error().Err(err).Msgf("Cannot keep log line of generation %s", line.generationID)
+	}
+}
+
+// ListGenerationLogs returns the log lines kept for a generation, oldest
+// first.
+func (r *feedRepo) ListGenerationLogs(ctx context.Context, generationID string) ([]*entity.LogEntry, error) {
+	conn := r.client.Connection()
+	defer conn.Close()
+	lines, err := redis.ByteSlices(conn.Do("LRANGE", generationLogKey(generationID), 0, -1))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*entity.LogEntry, len(lines))
+	for i, line := range lines {
+		if entries[i], err = unmarshalLogEntry(line); err != nil {
+			return nil, fmt.Errorf("%s log: %w", generationID, err)
+		}
+	}
+	return entries, nil
+}
+
+// FollowGenerationLogs calls callback with the kept log lines of a
+// generation and then with every new one until ctx is done. A line logged
+// while following starts may be passed twice.
+func (r *feedRepo) FollowGenerationLogs(ctx context.Context, generationID string, callback func(*entity.LogEntry)) error {
+	channel := generationLogKey(generationID)
+	// Buffered, so the receiver doesn't block on the error closing the
+	// pubsub makes once the client is gone.
+	errChan := make(chan error, 1)
+	pubsub := r.client.PubSub()
+	defer pubsub.Close()
+
+	if err := pubsub.Subscribe(channel); err != nil {
+		return err
+	}
+	defer pubsub.Unsubscribe(channel)
+	entries, err := r.ListGenerationLogs(ctx, generationID)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		callback(entry)
+	}
+
+	go func() {
+		for {
+			switch v := pubsub.Receive().(type) {
+			case error:
+				errChan <- v
+				return
+			case redis.Message:
+				if v.Channel == channel {
+					if entry, err := unmarshalLogEntry(v.Data); err != nil {
+						log.Error().Err(err).Msgf("Cannot read log line of generation %s", generationID)
+					} else {
+						callback(entry)
+					}
+				}
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second * 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := pubsub.Ping(""); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errChan:
+			return err
+		}
+	}
+}
+
+func generationLogKey(generationID string) string {
+	return fmt.Sprintf("%s.logs", generationID)
+}
+
+func unmarshalLogEntry(data []byte) (*entity.LogEntry, error) {
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	entry := new(entity.LogEntry)
+	for key, value := range fields {
+		switch key {
+		case zerolog.TimestampFieldName:
+			if timestamp, ok := value.(string); ok {
+				entry.Time, _ = time.Parse(zerolog.TimeFieldFormat, timestamp)
+			}
+		case zerolog.LevelFieldName:
+			entry.Level = fmt.Sprint(value)
+		case zerolog.MessageFieldName:
+			entry.Message = fmt.Sprint(value)
+		case zerolog.ErrorFieldName:
+			entry.Error = fmt.Sprint(value)
+		case entity.LogGenerationID, entity.LogGenerationType:
+		default:
+			if entry.Fields == nil {
+				entry.Fields = make(map[string]interface{})
+			}
+			entry.Fields[key] = value
+		}
+	}
+	return entry, nil
+}
+
+// ctxLog returns the logger of the generation ctx belongs to, so lines are
+// kept with its log, or the global logger.
+func ctxLog(ctx context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return &log.Logger
+}
@@ -0,0 +1,214 @@
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+
+	"go-feedmaker/adapter/repository"
+	"go-feedmaker/entity"
+	helper "go-feedmaker/infrastructure/testing"
+)
+
+const (
+	logLine      = `{"level":"info","generation_id":"42","generation_type":"test","records":3,"time":"2021-03-04T05:06:07Z","message":"Fetched"}`
+	errorLogLine = `{"level":"error","generation_id":"42","error":"test error","time":"2021-03-04T05:06:08Z","message":"Cannot upload"}`
+)
+
+var (
+	logEntry = &entity.LogEntry{
+		Time:    time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		Level:   "info",
+		Message: "Fetched",
+		Fields:  map[string]interface{}{"records": float64(3)},
+	}
+	errorLogEntry = &entity.LogEntry{
+		Time:    time.Date(2021, 3, 4, 5, 6, 8, 0, time.UTC),
+		Level:   "error",
+		Message: "Cannot upload",
+		Error:   "test error",
+	}
+)
+
+func TestLogWriter_Write(t *testing.T) {
+	fields := defaultFeedFields()
+	fields.client.On("Connection").Return(fields.conn)
+	fields.conn.On("Close").Return(nil)
+	fields.conn.On("Send", "MULTI").Return(nil)
+	fields.conn.On("Send", "RPUSH", "42.logs", []byte(logLine)).Return(nil).Once()
+	fields.conn.On("Send", "LTRIM", "42.logs", int64(-100), -1).Return(nil).Once()
+	fields.conn.On("Send", "PUBLISH", "42.logs", []byte(logLine)).Return(nil).Once()
+	fields.conn.On("Do", "EXEC").Return([]interface{}{int64(1), "OK", int64(0)}, nil).Once()
+	writer := repository.NewLogWriter(fields.client, 100)
+	writer.Start()
+
+	for _, line := range []string{
+		logLine + "\n",
+		`{"level":"info","message":"Started server"}` + "\n",
+		"not json\n",
+	} {
+		n, err := writer.Write([]byte(line))
+		assert.NoError(t, err)
+		assert.Equal(t, len(line), n)
+	}
+	writer.Stop()
+
+	fields.assertExpectations(t)
+}
+
+func TestFeedRepo_ListGenerationLogs(t *testing.T) {
+	testCases := []struct {
+		name       string
+		setupMocks func(f *feedFields)
+		want       []*entity.LogEntry
+		wantErr    bool
+	}{
+		{
+			name: "succeed",
+			setupMocks: func(f *feedFields) {
+				f.conn.On("Do", "LRANGE", "42.logs", 0, -1).
+					Return([]interface{}{[]byte(logLine), []byte(errorLogLine)}, nil)
+			},
+			want: []*entity.LogEntry{logEntry, errorLogEntry},
+		},
+		{
+			name: "no lines",
+			setupMocks: func(f *feedFields) {
+				f.conn.On("Do", "LRANGE", "42.logs", 0, -1).Return([]interface{}{}, nil)
+			},
+			want: []*entity.LogEntry{},
+		},
+		{
+			name: "LRANGE error",
+			setupMocks: func(f *feedFields) {
+				f.conn.On("Do", "LRANGE", "42.logs", 0, -1).Return(nil, defaultErr)
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid line",
+			setupMocks: func(f *feedFields) {
+				f.conn.On("Do", "LRANGE", "42.logs", 0, -1).
+					Return([]interface{}{[]byte("not json")}, nil)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := defaultFeedFields()
+			fields.client.On("Connection").Return(fields.conn)
+			fields.conn.On("Close").Return(nil)
+			tc.setupMocks(fields)
+			feedRepo := repository.NewFeedRepo(fields.config, fields.client, fields.ftp)
+
+			got, gotErr := feedRepo.ListGenerationLogs(context.Background(), "42")
+
+			if tc.wantErr {
+				assert.Error(t, gotErr)
+			} else {
+				assert.NoError(t, gotErr)
+				assert.Equal(t, tc.want, got)
+			}
+			fields.assertExpectations(t)
+		})
+	}
+}
+
+func TestFeedRepo_FollowGenerationLogs(t *testing.T) {
+	channel := "42.logs"
+	testCases := []struct {
+		name       string
+		ctx        context.Context
+		setupMocks func(f *feedFields)
+		want       []*entity.LogEntry
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			ctx:  helper.TimeoutCtx(t, context.Background(), 50*time.Millisecond),
+			setupMocks: func(f *feedFields) {
+				f.client.On("PubSub").Return(f.pubsub)
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.pubsub.On("Subscribe", channel).Return(nil)
+				f.conn.On("Do", "LRANGE", channel, 0, -1).Return([]interface{}{[]byte(logLine)}, nil)
+
+				f.pubsub.On("Receive").Return(redis.Message{Channel: channel, Data: []byte(errorLogLine)}).Once()
+				f.pubsub.On("Receive").Return(redis.Subscription{Kind: "subscribe", Channel: channel}).After(time.Second).Maybe()
+
+				f.pubsub.On("Unsubscribe", channel).Return(nil)
+				f.pubsub.On("Close").Return(nil)
+			},
+			want:    []*entity.LogEntry{logEntry, errorLogEntry},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "Subscribe error",
+			ctx:  context.Background(),
+			setupMocks: func(f *feedFields) {
+				f.client.On("PubSub").Return(f.pubsub)
+				f.pubsub.On("Subscribe", channel).Return(defaultErr)
+				f.pubsub.On("Close").Return(nil)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "LRANGE error",
+			ctx:  context.Background(),
+			setupMocks: func(f *feedFields) {
+				f.client.On("PubSub").Return(f.pubsub)
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.pubsub.On("Subscribe", channel).Return(nil)
+				f.conn.On("Do", "LRANGE", channel, 0, -1).Return(nil, defaultErr)
+				f.pubsub.On("Unsubscribe", channel).Return(nil)
+				f.pubsub.On("Close").Return(nil)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "Receive error",
+			ctx:  context.Background(),
+			setupMocks: func(f *feedFields) {
+				f.client.On("PubSub").Return(f.pubsub)
+				f.client.On("Connection").Return(f.conn)
+				f.conn.On("Close").Return(nil)
+				f.pubsub.On("Subscribe", channel).Return(nil)
+				f.conn.On("Do", "LRANGE", channel, 0, -1).Return([]interface{}{}, nil)
+
+				f.pubsub.On("Receive").Return(defaultErr)
+
+				f.pubsub.On("Unsubscribe", channel).Return(nil)
+				f.pubsub.On("Close").Return(nil)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := defaultFeedFields()
+			tc.setupMocks(fields)
+			feedRepo := repository.NewFeedRepo(fields.config, fields.client, fields.ftp)
+			var mu sync.Mutex
+			var got []*entity.LogEntry
+			callback := func(entry *entity.LogEntry) {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, entry)
+			}
+
+			gotErr := feedRepo.FollowGenerationLogs(tc.ctx, "42", callback)
+
+			assert.Equal(t, tc.wantErr, gotErr)
+			mu.Lock()
+			assert.Equal(t, tc.want, got)
+			mu.Unlock()
+			fields.assertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"go-feedmaker/entity"
+)
+
+const (
+	ManifestFilename = "manifest.json"
+)
+
+var (
+	ErrNotSeekable = errors.New("file is not seekable")
+)
+
+type (
+	RecordsCounter interface {
+		Records() uint
+	}
+
+	manifestJSON struct {
+		GenerationID string          `json:"generation_id"`
+		Type         string          `json:"type"`
+		StartTime    time.Time       `json:"start_time"`
+		CreatedTime  time.Time       `json:"created_time"`
+		Files        []*fileInfoJSON `json:"files"`
+	}
+
+	fileInfoJSON struct {
+		Name         string    `json:"name"`
+		Partition    string    `json:"partition,omitempty"`
+		Size         int64     `json:"size"`
+		Records      uint      `json:"records"`
+		SHA256       string    `json:"sha256"`
+		UploadedTime time.Time `json:"uploaded_time"`
+	}
+
+	checksumReader struct {
+		r    io.Reader
+		hash hash.Hash
+		size int64
+	}
+)
+
+func newChecksumReader(r io.Reader) *checksumReader {
+	return &checksumReader{
+		r:    r,
+		hash: sha256.New(),
+	}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	c.size += int64(n)
+	return n, err
+}
+
+// ResumeAt continues reading at offset when the underlying reader is
+// seekable, hashing the skipped part again so the checksum stays complete.
+// It lets a gateway resume or retry a failed upload.
+func (c *checksumReader) ResumeAt(offset int64) error {
+	seeker, ok := c.r.(io.Seeker)
+	if !ok {
+		return ErrNotSeekable
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	c.hash.Reset()
+	c.size = 0
+	_, err := io.CopyN(ioutil.Discard, c, offset)
+	return err
+}
+
+func (c *checksumReader) Size() int64 {
+	return c.size
+}
+
+func (c *checksumReader) SHA256() string {
+	return hex.EncodeToString(c.hash.Sum(nil))
+}
+
+func marshalManifest(manifest *entity.Manifest) ([]byte, error) {
+	out := &manifestJSON{
+		GenerationID: manifest.GenerationID,
+		Type:         manifest.Type,
+		StartTime:    manifest.StartTime.UTC(),
+		CreatedTime:  manifest.CreatedTime.UTC(),
+		Files:        make([]*fileInfoJSON, len(manifest.Files)),
+	}
+	for i, file := range manifest.Files {
+		out.Files[i] = &fileInfoJSON{
+			Name:         file.Name,
+			Partition:    file.Partition,
+			Size:         file.Size,
+			Records:      file.Records,
+			SHA256:       file.SHA256,
+			UploadedTime: file.UploadedTime.UTC(),
+		}
+	}
+	return json.Marshal(out)
+}
+
+func unmarshalManifest(data []byte) (*entity.Manifest, error) {
+	in := new(manifestJSON)
+	if err := json.Unmarshal(data, in); err != nil {
+		return nil, err
+	}
+	manifest := &entity.Manifest{
+		GenerationID: in.GenerationID,
+		Type:         in.Type,
+		StartTime:    in.StartTime,
+		CreatedTime:  in.CreatedTime,
+		Files:        make([]*entity.FileInfo, len(in.Files)),
+	}
+	for i, file := range in.Files {
+		manifest.Files[i] = &entity.FileInfo{
+			Name:         file.Name,
+			Partition:    file.Partition,
+			Size:         file.Size,
+			Records:      file.Records,
+			SHA256:       file.SHA256,
+			UploadedTime: file.UploadedTime,
+		}
+	}
+	return manifest, nil
+}
@@ -70,6 +70,41 @@ func (_m *FtpGateway) RemoveDir(dir string) error {
 	return r0
 }
 
+// DirExists provides a mock function with given fields: dir
+func (_m *FtpGateway) DirExists(dir string) (bool, error) {
+	ret := _m.Called(dir)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(dir)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(dir)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Rename provides a mock function with given fields: from, to
+func (_m *FtpGateway) Rename(from string, to string) error {
+	ret := _m.Called(from, to)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(from, to)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Upload provides a mock function with given fields: ctx, path, r
 func (_m *FtpGateway) Upload(ctx context.Context, path string, r io.Reader) error {
 	ret := _m.Called(ctx, path, r)
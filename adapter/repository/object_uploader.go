@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"strings"
+	"text/template"
+
+	"go-feedmaker/entity"
+)
+
+type (
+	ObjectStorage interface {
+		// PutObject stores r under key, size is negative when it is unknown.
+		PutObject(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+		// RemoveObjects removes every object under prefix.
+		RemoveObjects(ctx context.Context, prefix string) error
+	}
+
+	objectStorageUploader struct {
+		storage            ObjectStorage
+		generation         *entity.Generation
+		keyPrefix          *template.Template
+		inStream           <-chan io.ReadCloser
+		uploadedFilesNum   uint
+		namer              *fileNamer
+		uploadedFiles      []*entity.FileInfo
+		versions           *versioning
+		uploaded           uint
+		onUpload           func(uploadedFilesNum uint)
+		onManifestUploaded func(manifest *entity.Manifest)
+	}
+)
+
+// DefaultKeyPrefix puts every generation under the key of its type, the same
+// layout the FTP uploader produces.
+const DefaultKeyPrefix = "{{.Type}}"
+
+var contentTypes = map[string]string{
+	".csv":  "text/csv; charset=utf-8",
+	".json": "application/json",
+}
+
+// ParseKeyPrefix parses a key prefix template, executed with the generation
+// as data, e.g. "feeds/{{.Type}}/{{.StartTime.Format \"2006-01-02\"}}/{{.ID}}".
+func ParseKeyPrefix(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultKeyPrefix
+	}
+	return template.New("key_prefix").Option("missingkey=error").Parse(text)
+}
+
+func NewObjectStorageUploader(
+	storage ObjectStorage,
+	keyPrefix *template.Template,
+	generation *entity.Generation,
+	inStream <-chan io.ReadCloser,
+) *objectStorageUploader {
+	return &objectStorageUploader{
+		storage:            storage,
+		generation:         generation,
+		keyPrefix:          keyPrefix,
+		inStream:           inStream,
+		namer:              newFileNamer(generation.Type),
+		uploadedFiles:      make([]*entity.FileInfo, 0),
+		onUpload:           func(uploadedFilesNum uint) {},
+		onManifestUploaded: func(manifest *entity.Manifest) {},
+	}
+}
+
+func (u *objectStorageUploader) UploadFiles(ctx context.Context) error {
+	prefix, err := u.makeKeyPrefix()
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case file, isOpen := <-u.inStream:
+			if !isOpen {
+				return u.uploadManifest(ctx, prefix)
+			}
+
+			if u.uploadedFilesNum < u.uploaded {
+				fileInfo, err := skipFile(u.namer, file)
+				if err != nil {
+					return err
+				}
+				u.uploadedFiles = append(u.uploadedFiles, fileInfo)
+				u.uploadedFilesNum++
+				continue
+			}
+
+			filename, _ := u.namer.next(partitionOf(file))
+			checksum := newChecksumReader(file)
+			key := path.Join(prefix, filename)
+			if err := u.storage.PutObject(ctx, key, checksum, -1, contentTypeOf(filename)); err != nil {
+				return err
+			}
+			u.uploadedFiles = append(u.uploadedFiles, makeFileInfo(filename, file, checksum))
+
+			u.uploadedFilesNum++
+			u.onUpload(u.uploadedFilesNum)
+			if err := file.Close(); err != nil {
+				ctxLog(ctx).Error().Err(err).Msgf("Cannot close file after uploading")
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (u *objectStorageUploader) uploadManifest(ctx context.Context, prefix string) error {
+	manifest := newManifest(u.generation, u.uploadedFiles)
+	data, err := marshalManifest(manifest)
+	if err != nil {
+		return err
+	}
+	key := path.Join(prefix, ManifestFilename)
+	if err := u.storage.PutObject(ctx, key, bytes.NewReader(data), int64(len(data)), contentTypeOf(key)); err != nil {
+		return err
+	}
+	if u.versions != nil {
+		if err := u.publish(ctx, prefix); err != nil {
+			return err
+		}
+	}
+	u.onManifestUploaded(manifest)
+	return nil
+}
+
+// publish points the current pointer of the feed to prefix and removes the
+// versions beyond the kept ones.
+func (u *objectStorageUploader) publish(ctx context.Context, prefix string) error {
+	versions, err := u.versions.store.Versions(ctx, u.generation.Type, u.versions.destination)
+	if err != nil {
+		return err
+	}
+	version := &FeedVersion{GenerationID: u.generation.ID, Location: prefix}
+	if err := putCurrentPointer(ctx, u.storage, u.generation.Type, version); err != nil {
+		return err
+	}
+	kept, removed := u.versions.add(versions, version)
+	for _, version := range removed {
+		if err := u.storage.RemoveObjects(ctx, version.Location); err != nil {
+			ctxLog(ctx).Error().Err(err).Msgf("Cannot remove version %s of %s", version.GenerationID, u.generation.Type)
+		}
+	}
+	return u.versions.store.SetVersions(ctx, u.generation.Type, u.versions.destination, kept)
+}
+
+func (u *objectStorageUploader) makeKeyPrefix() (string, error) {
+	buf := new(strings.Builder)
+	if err := u.keyPrefix.Execute(buf, u.generation); err != nil {
+		return "", err
+	}
+	prefix := strings.Trim(buf.String(), "/")
+	// Every version needs its own prefix.
+	if u.versions != nil && !strings.Contains(prefix, u.generation.ID) {
+		prefix = path.Join(prefix, u.generation.ID)
+	}
+	return prefix, nil
+}
+
+// Resume continues a failed upload: objects stay after a failure, so the
+// first uploaded files are only added to the manifest.
+func (u *objectStorageUploader) Resume(uploaded uint) {
+	u.uploaded = uploaded
+}
+
+// KeepVersions keeps the last keep published versions under their own
+// prefixes and points to the current one with CurrentPointerFilename.
+func (u *objectStorageUploader) KeepVersions(store VersionStore, destination string, keep uint) {
+	u.versions = newVersioning(store, destination, keep)
+}
+
+func (u *objectStorageUploader) OnUpload(callback func(uploadedFilesNum uint)) {
+	u.onUpload = callback
+}
+
+func (u *objectStorageUploader) OnManifestUploaded(callback func(manifest *entity.Manifest)) {
+	u.onManifestUploaded = callback
+}
+
+func contentTypeOf(filename string) string {
+	if contentType, ok := contentTypes[path.Ext(filename)]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
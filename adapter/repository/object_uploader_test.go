@@ -0,0 +1,160 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"go-feedmaker/adapter/repository"
+	"go-feedmaker/adapter/repository/mocks"
+	"go-feedmaker/entity"
+)
+
+func TestObjectStorageUploader_UploadFiles(t *testing.T) {
+	const csvType = "text/csv; charset=utf-8"
+	testCases := []struct {
+		name       string
+		keyPrefix  string
+		files      []io.ReadCloser
+		setupMocks func(storage *mocks.ObjectStorage)
+		wantErr    error
+	}{
+		{
+			name: "default prefix",
+			files: []io.ReadCloser{
+				ioutil.NopCloser(strings.NewReader("a")),
+				ioutil.NopCloser(strings.NewReader("b")),
+			},
+			setupMocks: func(storage *mocks.ObjectStorage) {
+				storage.On("PutObject", mock.Anything, "test/test_0.csv", mock.Anything, int64(-1), csvType).Return(nil).Once()
+				storage.On("PutObject", mock.Anything, "test/test_1.csv", mock.Anything, int64(-1), csvType).Return(nil).Once()
+				storage.On("PutObject", mock.Anything, "test/manifest.json", mock.Anything, mock.Anything, "application/json").
+					Return(nil).Once()
+			},
+		},
+		{
+			name:      "prefix template with partitions",
+			keyPrefix: `/feeds/{{.Type}}/{{.StartTime.UTC.Format "2006-01-02"}}/{{.ID}}/`,
+			files: []io.ReadCloser{
+				newPartitionedReader("DE"),
+				newPartitionedReader("AT"),
+				newPartitionedReader("DE"),
+			},
+			setupMocks: func(storage *mocks.ObjectStorage) {
+				prefix := "feeds/test/1970-01-01/42/"
+				storage.On("PutObject", mock.Anything, prefix+"DE/test_0.csv", mock.Anything, int64(-1), csvType).Return(nil).Once()
+				storage.On("PutObject", mock.Anything, prefix+"AT/test_0.csv", mock.Anything, int64(-1), csvType).Return(nil).Once()
+				storage.On("PutObject", mock.Anything, prefix+"DE/test_1.csv", mock.Anything, int64(-1), csvType).Return(nil).Once()
+				storage.On("PutObject", mock.Anything, prefix+"manifest.json", mock.Anything, mock.Anything, "application/json").
+					Return(nil).Once()
+			},
+		},
+		{
+			name:       "prefix template error",
+			keyPrefix:  "{{.Unknown}}",
+			files:      []io.ReadCloser{ioutil.NopCloser(strings.NewReader("a"))},
+			setupMocks: func(storage *mocks.ObjectStorage) {},
+			wantErr:    assert.AnError,
+		},
+		{
+			name:  "upload error",
+			files: []io.ReadCloser{ioutil.NopCloser(strings.NewReader("a"))},
+			setupMocks: func(storage *mocks.ObjectStorage) {
+				storage.On("PutObject", mock.Anything, "test/test_0.csv", mock.Anything, int64(-1), csvType).Return(defaultErr)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name:  "manifest upload error",
+			files: []io.ReadCloser{ioutil.NopCloser(strings.NewReader("a"))},
+			setupMocks: func(storage *mocks.ObjectStorage) {
+				storage.On("PutObject", mock.Anything, "test/test_0.csv", mock.Anything, int64(-1), csvType).Return(nil)
+				storage.On("PutObject", mock.Anything, "test/manifest.json", mock.Anything, mock.Anything, "application/json").
+					Return(defaultErr)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := new(mocks.ObjectStorage)
+			tc.setupMocks(storage)
+			inStream := make(chan io.ReadCloser, len(tc.files))
+			for _, file := range tc.files {
+				inStream <- file
+			}
+			close(inStream)
+			keyPrefix, err := repository.ParseKeyPrefix(tc.keyPrefix)
+			require.NoError(t, err)
+			generation := &entity.Generation{ID: "42", Type: "test", StartTime: time.Unix(100, 0)}
+			uploader := repository.NewObjectStorageUploader(storage, keyPrefix, generation, inStream)
+			var gotUploaded uint
+			uploader.OnUpload(func(uploadedNum uint) {
+				gotUploaded = uploadedNum
+			})
+
+			gotErr := uploader.UploadFiles(context.Background())
+
+			if tc.wantErr == assert.AnError {
+				assert.Error(t, gotErr)
+			} else {
+				assert.Equal(t, tc.wantErr, gotErr)
+			}
+			if tc.wantErr == nil {
+				assert.Equal(t, uint(len(tc.files)), gotUploaded)
+			}
+			storage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestObjectStorageUploader_UploadFiles_Manifest(t *testing.T) {
+	storage := new(mocks.ObjectStorage)
+	var gotContent []byte
+	var gotManifestJSON map[string]interface{}
+	storage.On("PutObject", mock.Anything, "test/DE/test_0.csv", mock.Anything, int64(-1), mock.Anything).
+		Run(func(args mock.Arguments) {
+			var err error
+			gotContent, err = ioutil.ReadAll(args.Get(2).(io.Reader))
+			require.NoError(t, err)
+		}).
+		Return(nil)
+	storage.On("PutObject", mock.Anything, "test/manifest.json", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			data, err := ioutil.ReadAll(args.Get(2).(io.Reader))
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(data)), args.Get(3))
+			require.NoError(t, json.Unmarshal(data, &gotManifestJSON))
+		}).
+		Return(nil)
+	inStream := make(chan io.ReadCloser, 1)
+	inStream <- newPartitionedReader("DE")
+	close(inStream)
+	keyPrefix, err := repository.ParseKeyPrefix("")
+	require.NoError(t, err)
+	generation := &entity.Generation{ID: "42", Type: "test", StartTime: time.Unix(100, 0)}
+	uploader := repository.NewObjectStorageUploader(storage, keyPrefix, generation, inStream)
+	var gotManifest *entity.Manifest
+	uploader.OnManifestUploaded(func(manifest *entity.Manifest) {
+		gotManifest = manifest
+	})
+
+	require.NoError(t, uploader.UploadFiles(context.Background()))
+
+	assert.Equal(t, []byte("data"), gotContent)
+	require.NotNil(t, gotManifest)
+	require.Len(t, gotManifest.Files, 1)
+	assert.Equal(t, "DE/test_0.csv", gotManifest.Files[0].Name)
+	assert.Equal(t, int64(4), gotManifest.Files[0].Size)
+	assert.Equal(t, "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7", gotManifest.Files[0].SHA256)
+	assert.Equal(t, "42", gotManifestJSON["generation_id"])
+	assert.Len(t, gotManifestJSON["files"], 1)
+}
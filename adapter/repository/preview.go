@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"go-feedmaker/entity"
+)
+
+type (
+	// previewUploader describes the files it reads instead of uploading them.
+	previewUploader struct {
+		inStream    <-chan io.ReadCloser
+		namer       *fileNamer
+		withContent bool
+		files       []*entity.PreviewFile
+	}
+)
+
+func newPreviewUploader(generationType string, inStream <-chan io.ReadCloser, withContent bool) *previewUploader {
+	return &previewUploader{
+		inStream:    inStream,
+		namer:       newFileNamer(generationType),
+		withContent: withContent,
+		files:       make([]*entity.PreviewFile, 0),
+	}
+}
+
+func (u *previewUploader) UploadFiles(ctx context.Context) error {
+	for {
+		select {
+		case file, isOpen := <-u.inStream:
+			if !isOpen {
+				return nil
+			}
+			if err := u.describeFile(file); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (u *previewUploader) describeFile(file io.ReadCloser) error {
+	filename, _ := u.namer.next(partitionOf(file))
+	var content bytes.Buffer
+	var w io.Writer = ioutil.Discard
+	if u.withContent {
+		w = &content
+	}
+	checksum := newChecksumReader(file)
+	if _, err := io.Copy(w, checksum); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	info := makeFileInfo(filename, file, checksum)
+	// Nothing was uploaded.
+	info.UploadedTime = time.Time{}
+	u.files = append(u.files, &entity.PreviewFile{FileInfo: info, Content: content.String()})
+	return nil
+}
+
+func (u *previewUploader) Files() []*entity.PreviewFile {
+	return u.files
+}
@@ -0,0 +1,73 @@
+package repository_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-feedmaker/adapter/repository"
+	"go-feedmaker/entity"
+)
+
+func TestPreviewUploader_UploadFiles(t *testing.T) {
+	testCases := []struct {
+		name        string
+		withContent bool
+		want        []*entity.PreviewFile
+	}{
+		{
+			name:        "with content",
+			withContent: true,
+			want: []*entity.PreviewFile{
+				{
+					FileInfo: &entity.FileInfo{Name: "test_0.csv", Size: 16},
+					Content:  "id,country\n1,DE\n",
+				},
+				{
+					FileInfo: &entity.FileInfo{Name: "DE/test_0.csv", Partition: "DE", Size: 5},
+					Content:  "id\n1\n",
+				},
+			},
+		},
+		{
+			name: "without content",
+			want: []*entity.PreviewFile{
+				{FileInfo: &entity.FileInfo{Name: "test_0.csv", Size: 16}},
+				{FileInfo: &entity.FileInfo{Name: "DE/test_0.csv", Partition: "DE", Size: 5}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inStream := make(chan io.ReadCloser, 2)
+			inStream <- newArchivedFile(t, "", "id,country\n1,DE\n")
+			inStream <- newArchivedFile(t, "DE", "id\n1\n")
+			close(inStream)
+			uploader := repository.NewPreviewUploader("test", inStream, tc.withContent)
+
+			gotErr := uploader.UploadFiles(context.Background())
+
+			assert.NoError(t, gotErr)
+			got := uploader.Files()
+			// Checksums are covered by the uploader tests.
+			for _, file := range got {
+				assert.Len(t, file.SHA256, 64)
+				file.SHA256 = ""
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestPreviewUploader_UploadFiles_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	uploader := repository.NewPreviewUploader("test", make(chan io.ReadCloser), true)
+
+	gotErr := uploader.UploadFiles(ctx)
+
+	assert.ErrorIs(t, gotErr, context.Canceled)
+	assert.Empty(t, uploader.Files())
+}
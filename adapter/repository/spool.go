@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/inhies/go-bytesize"
+
+	"go-feedmaker/entity"
+)
+
+var (
+	ErrSpoolFull = errors.New("spool disk usage limit exceeded")
+)
+
+type (
+	Spool struct {
+		dir     string
+		maxSize bytesize.ByteSize
+		mu      sync.Mutex
+		used    int64
+	}
+
+	GenerationSpool struct {
+		spool *Spool
+		dir   string
+		// keep leaves files on disk once they are closed and lists them in
+		// the checkpoint, so the upload of a failed generation can be retried.
+		keep       bool
+		checkpoint spoolCheckpoint
+	}
+
+	// spoolCheckpoint lists the formatted files of a generation in the order
+	// they were uploaded; Formatted is set once formatting finished.
+	spoolCheckpoint struct {
+		Formatted bool                   `json:"formatted"`
+		Files     []*spoolCheckpointFile `json:"files"`
+	}
+
+	spoolCheckpointFile struct {
+		Name      string `json:"name"`
+		Partition string `json:"partition,omitempty"`
+		Records   uint   `json:"records"`
+	}
+
+	spoolFile struct {
+		*os.File
+		spool   *Spool
+		written int64
+		keep    bool
+	}
+)
+
+const checkpointFilename = "checkpoint.json"
+
+func NewSpool(dir string, maxSize bytesize.ByteSize) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	used, err := dirSize(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Spool{
+		dir:     dir,
+		maxSize: maxSize,
+		used:    used,
+	}, nil
+}
+
+func (s *Spool) Generation(generationID string) *GenerationSpool {
+	return &GenerationSpool{
+		spool: s,
+		dir:   path.Join(s.dir, generationID),
+	}
+}
+
+func (s *Spool) Used() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used
+}
+
+func (s *Spool) reserve(n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used+n > int64(s.maxSize) {
+		return ErrSpoolFull
+	}
+	s.used += n
+	return nil
+}
+
+func (s *Spool) release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used -= n
+}
+
+func (g *GenerationSpool) createFile() (*spoolFile, error) {
+	if err := os.MkdirAll(g.dir, 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path.Join(g.dir, uuid.NewString()+".csv"))
+	if err != nil {
+		return nil, err
+	}
+	return &spoolFile{File: file, spool: g.spool, keep: g.keep}, nil
+}
+
+// addToCheckpoint lists file as the next file of the generation.
+func (g *GenerationSpool) addToCheckpoint(file *csvFile) error {
+	if !g.keep {
+		return nil
+	}
+	g.checkpoint.Files = append(g.checkpoint.Files, &spoolCheckpointFile{
+		Name:      path.Base(file.Name()),
+		Partition: file.partition,
+		Records:   file.records,
+	})
+	return g.writeCheckpoint()
+}
+
+// finishCheckpoint marks every file of the generation as formatted.
+func (g *GenerationSpool) finishCheckpoint() error {
+	if !g.keep {
+		return nil
+	}
+	g.checkpoint.Formatted = true
+	return g.writeCheckpoint()
+}
+
+func (g *GenerationSpool) writeCheckpoint() error {
+	data, err := json.Marshal(&g.checkpoint)
+	if err != nil {
+		return err
+	}
+	tmp := path.Join(g.dir, checkpointFilename+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path.Join(g.dir, checkpointFilename))
+}
+
+func (g *GenerationSpool) readCheckpoint() (*spoolCheckpoint, error) {
+	data, err := ioutil.ReadFile(path.Join(g.dir, checkpointFilename))
+	if os.IsNotExist(err) {
+		return nil, entity.ErrNoCheckpoint
+	} else if err != nil {
+		return nil, err
+	}
+	checkpoint := new(spoolCheckpoint)
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, err
+	}
+	if !checkpoint.Formatted {
+		return nil, entity.ErrNoCheckpoint
+	}
+	return checkpoint, nil
+}
+
+// HasCheckpoint reports whether every formatted file of the generation is
+// kept in the spool.
+func (g *GenerationSpool) HasCheckpoint() bool {
+	_, err := g.readCheckpoint()
+	return err == nil
+}
+
+// openCheckpoint opens the formatted files of the generation in the order
+// they were uploaded.
+func (g *GenerationSpool) openCheckpoint() ([]*csvFile, error) {
+	checkpoint, err := g.readCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*csvFile, 0, len(checkpoint.Files))
+	for _, entry := range checkpoint.Files {
+		file, err := os.Open(path.Join(g.dir, entry.Name))
+		if err != nil {
+			closeFiles(files)
+			if os.IsNotExist(err) {
+				return nil, entity.ErrNoCheckpoint
+			}
+			return nil, err
+		}
+		files = append(files, &csvFile{
+			spoolFile: &spoolFile{File: file, spool: g.spool, keep: true},
+			partition: entry.Partition,
+			records:   entry.Records,
+		})
+	}
+	return files, nil
+}
+
+func (g *GenerationSpool) Remove() error {
+	size, err := dirSize(g.dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(g.dir); err != nil {
+		return err
+	}
+	g.spool.release(size)
+	return nil
+}
+
+func (f *spoolFile) Write(data []byte) (int, error) {
+	if err := f.spool.reserve(int64(len(data))); err != nil {
+		return 0, err
+	}
+	n, err := f.File.Write(data)
+	f.written += int64(n)
+	f.spool.release(int64(len(data) - n))
+	return n, err
+}
+
+// Close removes the file from the spool unless it is kept for a checkpoint;
+// kept files are removed with the generation spool. A file already removed
+// with the generation spool was released by it.
+func (f *spoolFile) Close() error {
+	closeErr := f.File.Close()
+	if f.keep {
+		return closeErr
+	}
+	if err := os.Remove(f.Name()); os.IsNotExist(err) {
+		f.written = 0
+		return closeErr
+	} else if err != nil {
+		return err
+	}
+	f.spool.release(f.written)
+	f.written = 0
+	return closeErr
+}
+
+// dirSize sums the formatted files under dir; checkpoints aren't written
+// through the spool, so they aren't counted either.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !isCheckpoint(info.Name()) {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func isCheckpoint(name string) bool {
+	return name == checkpointFilename || name == checkpointFilename+".tmp"
+}
+
+func closeFiles(files []*csvFile) {
+	for _, file := range files {
+		file.Close()
+	}
+}
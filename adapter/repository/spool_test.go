@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/inhies/go-bytesize"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-feedmaker/adapter/repository"
+)
+
+func TestNewSpool(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(dir, "old"), 0755))
+	require.NoError(t, ioutil.WriteFile(path.Join(dir, "old", "file.csv"), []byte("hello"), 0644))
+
+	spool, err := repository.NewSpool(path.Join(dir), bytesize.KB)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), spool.Used())
+}
+
+func TestSpool_CreateFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		maxSize  bytesize.ByteSize
+		data     []byte
+		wantUsed int64
+		wantErr  error
+	}{
+		{
+			name:     "succeed",
+			maxSize:  bytesize.KB,
+			data:     []byte("hello world"),
+			wantUsed: 11,
+		},
+		{
+			name:    "disk usage limit exceeded",
+			maxSize: 10,
+			data:    []byte("hello world"),
+			wantErr: repository.ErrSpoolFull,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spool, err := repository.NewSpool(t.TempDir(), tc.maxSize)
+			require.NoError(t, err)
+			file, err := spool.Generation("test").CreateFile()
+			require.NoError(t, err)
+
+			_, gotErr := file.Write(tc.data)
+
+			assert.Equal(t, tc.wantErr, gotErr)
+			assert.Equal(t, tc.wantUsed, spool.Used())
+			assert.NoError(t, file.Close())
+			assert.Zero(t, spool.Used())
+		})
+	}
+}
+
+func TestGenerationSpool_Remove(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := repository.NewSpool(dir, bytesize.KB)
+	require.NoError(t, err)
+	generationSpool := spool.Generation("test")
+	file, err := generationSpool.CreateFile()
+	require.NoError(t, err)
+	_, err = file.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	assert.NoError(t, generationSpool.Remove())
+
+	assert.Zero(t, spool.Used())
+	_, err = os.Stat(path.Join(dir, "test"))
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, spool.Generation("unknown").Remove())
+}
+
+func TestGenerationSpool_Remove_openFile(t *testing.T) {
+	spool, err := repository.NewSpool(t.TempDir(), bytesize.KB)
+	require.NoError(t, err)
+	generationSpool := spool.Generation("test")
+	file, err := generationSpool.CreateFile()
+	require.NoError(t, err)
+	_, err = file.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, generationSpool.Remove())
+
+	assert.NoError(t, file.Close())
+
+	assert.Zero(t, spool.Used())
+}
@@ -1,12 +1,17 @@
 package repository
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"path"
+	"time"
 
 	"github.com/rs/zerolog/log"
+
+	"go-feedmaker/entity"
 )
 
 type (
@@ -18,49 +23,153 @@ type (
 		ChangeDirToParent() error
 	}
 
+	// Renamer is implemented by gateways that can rename directories. Their
+	// uploads are staged and published at once; other gateways replace the
+	// generation directory in place.
+	Renamer interface {
+		Rename(from, to string) error
+	}
+
+	// DirChecker is implemented by gateways that can tell whether a directory
+	// exists, so a failed upload can be resumed in its directory.
+	DirChecker interface {
+		DirExists(dir string) (bool, error)
+	}
+
 	ftpUploader struct {
 		ftp              FtpGateway
+		generation       *entity.Generation
 		generationType   string
+		dir              string
 		inStream         <-chan io.ReadCloser
 		uploadedFilesNum uint
-		onUpload         func(uploadedFilesNum uint)
+		namer            *fileNamer
+		uploadedFiles    []*entity.FileInfo
+		manifest         *entity.Manifest
+		versions         *versioning
+		// resumable keeps the directory of a failed upload; uploaded files
+		// of the generation are skipped if it is still there.
+		resumable          bool
+		uploaded           uint
+		skip               uint
+		onUpload           func(uploadedFilesNum uint)
+		onManifestUploaded func(manifest *entity.Manifest)
+	}
+
+	// fileNamer numbers the files of a generation, separately within every
+	// partition.
+	fileNamer struct {
+		generationType string
+		partitionFiles map[string]uint
 	}
 )
 
-func NewFtpUploader(ftpGateway FtpGateway, generationType string, inStream <-chan io.ReadCloser) *ftpUploader {
+func NewFtpUploader(ftpGateway FtpGateway, generation *entity.Generation, inStream <-chan io.ReadCloser) *ftpUploader {
 	return &ftpUploader{
-		ftp:            ftpGateway,
-		generationType: generationType,
-		inStream:       inStream,
-		onUpload:       func(uploadedFilesNum uint) {},
+		ftp:                ftpGateway,
+		generation:         generation,
+		generationType:     generation.Type,
+		dir:                generation.Type,
+		inStream:           inStream,
+		namer:              newFileNamer(generation.Type),
+		uploadedFiles:      make([]*entity.FileInfo, 0),
+		onUpload:           func(uploadedFilesNum uint) {},
+		onManifestUploaded: func(manifest *entity.Manifest) {},
 	}
 }
 
 func (u *ftpUploader) UploadFiles(ctx context.Context) error {
-	if err := u.ftp.RemoveDir(u.generationType); err != nil {
-		log.Error().Err(err).Msgf("Cannot remove dir %s on ftp", u.generationType)
+	renamer, canRename := u.ftp.(Renamer)
+	if canRename {
+		u.dir = stagingDir(u.generation)
+	}
+	if err := u.prepareDir(canRename); err != nil {
+		return err
 	}
-	if err := u.ftp.MakeDir(u.generationType); err != nil {
+	if err := u.uploadFiles(ctx); err != nil {
+		u.discardDir(canRename)
 		return err
 	}
+	if canRename {
+		if err := u.publish(ctx, renamer); err != nil {
+			u.discardDir(canRename)
+			return err
+		}
+	}
+	u.onManifestUploaded(u.manifest)
+	return nil
+}
+
+// prepareDir creates the directory to upload into, replacing the one a
+// failed run left. A resumed upload skips the files it uploaded there
+// instead.
+func (u *ftpUploader) prepareDir(canRename bool) error {
+	exists := canRename && u.dirExists(u.dir)
+	if exists && u.resumable && u.uploaded > 0 {
+		u.skip = u.uploaded
+		return nil
+	}
+	if exists || !canRename {
+		u.removeDir(u.dir)
+	}
+	return u.ftp.MakeDir(u.dir)
+}
 
+// discardDir removes the staging directory of a failed upload unless it is
+// kept to be resumed.
+func (u *ftpUploader) discardDir(canRename bool) {
+	if canRename && !u.resumable {
+		u.removeDir(u.dir)
+	}
+}
+
+func (u *ftpUploader) dirExists(dir string) bool {
+	checker, ok := u.ftp.(DirChecker)
+	if !ok {
+		return false
+	}
+	exists, err := checker.DirExists(dir)
+	if err != nil {
+		log.Error().Err(err).Msgf("Cannot check dir %s on ftp", dir)
+		return false
+	}
+	return exists
+}
+
+func (u *ftpUploader) uploadFiles(ctx context.Context) error {
 	for {
 		select {
 		case file, isOpen := <-u.inStream:
 			if !isOpen {
-				return nil
+				return u.uploadManifest(ctx)
+			}
+
+			if u.uploadedFilesNum < u.skip {
+				fileInfo, err := skipFile(u.namer, file)
+				if err != nil {
+					return err
+				}
+				u.uploadedFiles = append(u.uploadedFiles, fileInfo)
+				u.uploadedFilesNum++
+				continue
 			}
 
-			filename := fmt.Sprintf("%s_%d.csv", u.generationType, u.uploadedFilesNum)
-			filename = path.Join(u.generationType, filename)
-			if err := u.ftp.Upload(ctx, filename, file); err != nil {
+			filename, err := u.makeFilename(file)
+			if err != nil {
 				return err
 			}
+			checksum := newChecksumReader(file)
+			if err := u.ftp.Upload(ctx, path.Join(u.dir, filename), checksum); err != nil {
+				return err
+			}
+			u.uploadedFiles = append(u.uploadedFiles, makeFileInfo(filename, file, checksum))
 
 			u.uploadedFilesNum++
-			u.onUpload(u.uploadedFilesNum)
+			if u.uploadedFilesNum > u.uploaded {
+				u.onUpload(u.uploadedFilesNum)
+			}
 			if err := file.Close(); err != nil {
-				log.Error().Err(err).Msgf("Cannot close file after uploading")
+				ctxLog(ctx).Error().Err(err).Msgf("Cannot close file after uploading")
 			}
 		case <-ctx.Done():
 			return ctx.Err()
@@ -68,6 +177,184 @@ func (u *ftpUploader) UploadFiles(ctx context.Context) error {
 	}
 }
 
+// publish swaps the staging directory with the generation directory. The
+// previous version is moved aside first and restored if the swap fails; it
+// is kept as a version when versions are enabled and removed otherwise.
+func (u *ftpUploader) publish(ctx context.Context, renamer Renamer) error {
+	var versions []*FeedVersion
+	if u.versions != nil {
+		var err error
+		if versions, err = u.versions.store.Versions(ctx, u.generationType, u.versions.destination); err != nil {
+			return err
+		}
+	}
+	previousDir := fmt.Sprintf("%s.old-%s", u.generationType, u.generation.ID)
+	if len(versions) > 0 {
+		previousDir = versions[0].Location
+	}
+	hasPrevious := renamer.Rename(u.generationType, previousDir) == nil
+	if !hasPrevious && len(versions) > 0 {
+		ctxLog(ctx).Error().Msgf("Cannot keep version %s of %s, it is missing on ftp", versions[0].GenerationID, u.generationType)
+		versions = versions[1:]
+	}
+	if err := renamer.Rename(u.dir, u.generationType); err != nil {
+		if hasPrevious {
+			if restoreErr := renamer.Rename(previousDir, u.generationType); restoreErr != nil {
+				ctxLog(ctx).Error().Err(restoreErr).Msgf("Cannot restore dir %s on ftp", u.generationType)
+			}
+		}
+		return err
+	}
+	if hasPrevious && len(versions) == 0 {
+		u.removeDir(previousDir)
+	}
+	if u.versions == nil {
+		return nil
+	}
+	kept, removed := u.versions.add(versions, &FeedVersion{
+		GenerationID: u.generation.ID,
+		Location:     versionDir(u.generation),
+	})
+	for _, version := range removed {
+		u.removeDir(version.Location)
+	}
+	return u.versions.store.SetVersions(ctx, u.generationType, u.versions.destination, kept)
+}
+
+func (u *ftpUploader) removeDir(dir string) {
+	if err := u.ftp.RemoveDir(dir); err != nil {
+		log.Error().Err(err).Msgf("Cannot remove dir %s on ftp", dir)
+	}
+}
+
+func (u *ftpUploader) uploadManifest(ctx context.Context) error {
+	manifest := newManifest(u.generation, u.uploadedFiles)
+	data, err := marshalManifest(manifest)
+	if err != nil {
+		return err
+	}
+	filename := path.Join(u.dir, ManifestFilename)
+	if err := u.ftp.Upload(ctx, filename, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	u.manifest = manifest
+	return nil
+}
+
+func (u *ftpUploader) makeFilename(file io.ReadCloser) (string, error) {
+	partition := partitionOf(file)
+	filename, isNewPartition := u.namer.next(partition)
+	if isNewPartition && partition != "" {
+		if err := u.ftp.MakeDir(path.Join(u.dir, partition)); err != nil {
+			return "", err
+		}
+	}
+	return filename, nil
+}
+
+// removeStagingDir removes the staging directory a resumed upload of
+// generation kept, if there is one.
+func removeStagingDir(ftp FtpGateway, generation *entity.Generation) error {
+	if _, ok := ftp.(Renamer); !ok {
+		return nil
+	}
+	checker, ok := ftp.(DirChecker)
+	if !ok {
+		return nil
+	}
+	dir := stagingDir(generation)
+	exists, err := checker.DirExists(dir)
+	if err != nil || !exists {
+		return err
+	}
+	return ftp.RemoveDir(dir)
+}
+
+// stagingDir is where a generation is uploaded before it is published.
+func stagingDir(generation *entity.Generation) string {
+	return fmt.Sprintf("%s.tmp-%s", generation.Type, generation.ID)
+}
+
+func newFileNamer(generationType string) *fileNamer {
+	return &fileNamer{
+		generationType: generationType,
+		partitionFiles: make(map[string]uint),
+	}
+}
+
+// next returns the name of the next file of partition relative to the
+// generation directory, and whether it is the first file of the partition.
+func (n *fileNamer) next(partition string) (string, bool) {
+	fileNum, exists := n.partitionFiles[partition]
+	n.partitionFiles[partition] = fileNum + 1
+	filename := fmt.Sprintf("%s_%d.csv", n.generationType, fileNum)
+	return path.Join(partition, filename), !exists
+}
+
+func partitionOf(file io.ReadCloser) string {
+	if partitioned, ok := file.(PartitionedFile); ok {
+		return partitioned.Partition()
+	}
+	return ""
+}
+
+// skipFile adds a file the failed run already uploaded to the manifest
+// without sending it again.
+func skipFile(namer *fileNamer, file io.ReadCloser) (*entity.FileInfo, error) {
+	filename, _ := namer.next(partitionOf(file))
+	checksum := newChecksumReader(file)
+	if _, err := io.Copy(ioutil.Discard, checksum); err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		log.Error().Err(err).Msgf("Cannot close file after skipping it")
+	}
+	return makeFileInfo(filename, file, checksum), nil
+}
+
+func newManifest(generation *entity.Generation, files []*entity.FileInfo) *entity.Manifest {
+	return &entity.Manifest{
+		GenerationID: generation.ID,
+		Type:         generation.Type,
+		StartTime:    generation.StartTime,
+		CreatedTime:  time.Now(),
+		Files:        files,
+	}
+}
+
+func makeFileInfo(filename string, file io.ReadCloser, checksum *checksumReader) *entity.FileInfo {
+	fileInfo := &entity.FileInfo{
+		Name:         filename,
+		Size:         checksum.Size(),
+		SHA256:       checksum.SHA256(),
+		Partition:    partitionOf(file),
+		UploadedTime: time.Now(),
+	}
+	if counter, ok := file.(RecordsCounter); ok {
+		fileInfo.Records = counter.Records()
+	}
+	return fileInfo
+}
+
+// KeepVersions keeps the last keep published versions next to the feed; it
+// only applies to gateways implementing Renamer.
+func (u *ftpUploader) KeepVersions(store VersionStore, destination string, keep uint) {
+	u.versions = newVersioning(store, destination, keep)
+}
+
+// Resume continues the failed upload of a retry: the first uploaded files
+// are only added to the manifest when its staging directory is still there,
+// otherwise every file is sent again. The directory is kept when the retry
+// fails too.
+func (u *ftpUploader) Resume(uploaded uint) {
+	u.resumable = true
+	u.uploaded = uploaded
+}
+
 func (u *ftpUploader) OnUpload(callback func(uploadedFilesNum uint)) {
 	u.onUpload = callback
 }
+
+func (u *ftpUploader) OnManifestUploaded(callback func(manifest *entity.Manifest)) {
+	u.onManifestUploaded = callback
+}
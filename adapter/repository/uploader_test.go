@@ -0,0 +1,376 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"go-feedmaker/adapter/repository"
+	"go-feedmaker/adapter/repository/mocks"
+	"go-feedmaker/entity"
+)
+
+type partitionedReader struct {
+	io.ReadCloser
+	partition string
+	records   uint
+}
+
+func (p *partitionedReader) Partition() string {
+	return p.partition
+}
+
+func (p *partitionedReader) Records() uint {
+	return p.records
+}
+
+func newPartitionedReader(partition string) io.ReadCloser {
+	return &partitionedReader{
+		ReadCloser: ioutil.NopCloser(strings.NewReader("data")),
+		partition:  partition,
+		records:    1,
+	}
+}
+
+func TestFtpUploader_UploadFiles(t *testing.T) {
+	const staging = "test.tmp-42"
+	testCases := []struct {
+		name       string
+		files      []io.ReadCloser
+		setupMocks func(ftp *mocks.FtpGateway)
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			files: []io.ReadCloser{
+				ioutil.NopCloser(strings.NewReader("a")),
+				ioutil.NopCloser(strings.NewReader("b")),
+			},
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", staging).Return(false, nil)
+				ftp.On("MakeDir", staging).Return(nil)
+				ftp.On("Upload", mock.Anything, staging+"/test_0.csv", mock.Anything).Return(nil).Once()
+				ftp.On("Upload", mock.Anything, staging+"/test_1.csv", mock.Anything).Return(nil).Once()
+				ftp.On("Upload", mock.Anything, staging+"/manifest.json", mock.Anything).Return(nil).Once()
+				ftp.On("Rename", "test", "test.old-42").Return(nil).Once()
+				ftp.On("Rename", staging, "test").Return(nil).Once()
+				ftp.On("RemoveDir", "test.old-42").Return(nil).Once()
+			},
+		},
+		{
+			name:  "succeed without previous version",
+			files: []io.ReadCloser{ioutil.NopCloser(strings.NewReader("a"))},
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", staging).Return(false, nil)
+				ftp.On("MakeDir", staging).Return(nil)
+				ftp.On("Upload", mock.Anything, staging+"/test_0.csv", mock.Anything).Return(nil).Once()
+				ftp.On("Upload", mock.Anything, staging+"/manifest.json", mock.Anything).Return(nil).Once()
+				ftp.On("Rename", "test", "test.old-42").Return(defaultErr).Once()
+				ftp.On("Rename", staging, "test").Return(nil).Once()
+			},
+		},
+		{
+			name: "partitions are uploaded into own directories",
+			files: []io.ReadCloser{
+				newPartitionedReader("DE"),
+				newPartitionedReader("DE"),
+				newPartitionedReader("AT"),
+			},
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", staging).Return(false, nil)
+				ftp.On("MakeDir", staging).Return(nil)
+				ftp.On("MakeDir", staging+"/DE").Return(nil).Once()
+				ftp.On("MakeDir", staging+"/AT").Return(nil).Once()
+				ftp.On("Upload", mock.Anything, staging+"/DE/test_0.csv", mock.Anything).Return(nil).Once()
+				ftp.On("Upload", mock.Anything, staging+"/DE/test_1.csv", mock.Anything).Return(nil).Once()
+				ftp.On("Upload", mock.Anything, staging+"/AT/test_0.csv", mock.Anything).Return(nil).Once()
+				ftp.On("Upload", mock.Anything, staging+"/manifest.json", mock.Anything).Return(nil).Once()
+				ftp.On("Rename", "test", "test.old-42").Return(nil).Once()
+				ftp.On("Rename", staging, "test").Return(nil).Once()
+				ftp.On("RemoveDir", "test.old-42").Return(nil).Once()
+			},
+		},
+		{
+			name:  "staging dir of a failed run replaced",
+			files: []io.ReadCloser{ioutil.NopCloser(strings.NewReader("a"))},
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", staging).Return(true, nil)
+				ftp.On("RemoveDir", staging).Return(nil).Once()
+				ftp.On("MakeDir", staging).Return(nil)
+				ftp.On("Upload", mock.Anything, staging+"/test_0.csv", mock.Anything).Return(nil).Once()
+				ftp.On("Upload", mock.Anything, staging+"/manifest.json", mock.Anything).Return(nil).Once()
+				ftp.On("Rename", "test", "test.old-42").Return(defaultErr).Once()
+				ftp.On("Rename", staging, "test").Return(nil).Once()
+			},
+		},
+		{
+			name:  "partition directory error",
+			files: []io.ReadCloser{newPartitionedReader("DE")},
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", staging).Return(false, nil)
+				ftp.On("MakeDir", staging).Return(nil)
+				ftp.On("MakeDir", staging+"/DE").Return(defaultErr)
+				ftp.On("RemoveDir", staging).Return(nil).Once()
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name:  "upload error keeps previous version",
+			files: []io.ReadCloser{ioutil.NopCloser(strings.NewReader("a"))},
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", staging).Return(false, nil)
+				ftp.On("MakeDir", staging).Return(nil)
+				ftp.On("Upload", mock.Anything, staging+"/test_0.csv", mock.Anything).Return(defaultErr)
+				ftp.On("RemoveDir", staging).Return(nil).Once()
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name:  "manifest upload error",
+			files: []io.ReadCloser{ioutil.NopCloser(strings.NewReader("a"))},
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", staging).Return(false, nil)
+				ftp.On("MakeDir", staging).Return(nil)
+				ftp.On("Upload", mock.Anything, staging+"/test_0.csv", mock.Anything).Return(nil)
+				ftp.On("Upload", mock.Anything, staging+"/manifest.json", mock.Anything).Return(defaultErr)
+				ftp.On("RemoveDir", staging).Return(nil).Once()
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name:  "swap error restores previous version",
+			files: []io.ReadCloser{ioutil.NopCloser(strings.NewReader("a"))},
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", staging).Return(false, nil)
+				ftp.On("MakeDir", staging).Return(nil)
+				ftp.On("Upload", mock.Anything, staging+"/test_0.csv", mock.Anything).Return(nil)
+				ftp.On("Upload", mock.Anything, staging+"/manifest.json", mock.Anything).Return(nil)
+				ftp.On("Rename", "test", "test.old-42").Return(nil).Once()
+				ftp.On("Rename", staging, "test").Return(defaultErr).Once()
+				ftp.On("Rename", "test.old-42", "test").Return(nil).Once()
+				ftp.On("RemoveDir", staging).Return(nil).Once()
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ftp := new(mocks.FtpGateway)
+			tc.setupMocks(ftp)
+			inStream := make(chan io.ReadCloser, len(tc.files))
+			for _, file := range tc.files {
+				inStream <- file
+			}
+			close(inStream)
+			generation := &entity.Generation{ID: "42", Type: "test", StartTime: time.Now()}
+			uploader := repository.NewFtpUploader(ftp, generation, inStream)
+			manifestUploaded := false
+			uploader.OnManifestUploaded(func(manifest *entity.Manifest) {
+				manifestUploaded = true
+			})
+
+			gotErr := uploader.UploadFiles(context.Background())
+
+			assert.Equal(t, tc.wantErr, gotErr)
+			assert.Equal(t, tc.wantErr == nil, manifestUploaded)
+			ftp.AssertExpectations(t)
+		})
+	}
+}
+
+// inPlaceFtpGateway hides Rename of the mock, like gateways that can't rename.
+type inPlaceFtpGateway struct {
+	repository.FtpGateway
+}
+
+func TestFtpUploader_UploadFiles_InPlace(t *testing.T) {
+	ftp := new(mocks.FtpGateway)
+	ftp.On("RemoveDir", "test").Return(nil).Once()
+	ftp.On("MakeDir", "test").Return(nil).Once()
+	ftp.On("Upload", mock.Anything, "test/test_0.csv", mock.Anything).Return(nil).Once()
+	ftp.On("Upload", mock.Anything, "test/manifest.json", mock.Anything).Return(nil).Once()
+	inStream := make(chan io.ReadCloser, 1)
+	inStream <- ioutil.NopCloser(strings.NewReader("a"))
+	close(inStream)
+	generation := &entity.Generation{ID: "42", Type: "test", StartTime: time.Now()}
+	uploader := repository.NewFtpUploader(&inPlaceFtpGateway{ftp}, generation, inStream)
+
+	require.NoError(t, uploader.UploadFiles(context.Background()))
+
+	ftp.AssertExpectations(t)
+}
+
+func TestFtpUploader_UploadFiles_Resumed(t *testing.T) {
+	ftp := new(mocks.FtpGateway)
+	ftp.On("DirExists", mock.Anything).Return(false, nil)
+	ftp.On("RemoveDir", mock.Anything).Return(nil)
+	ftp.On("MakeDir", mock.Anything).Return(nil)
+	ftp.On("Rename", mock.Anything, mock.Anything).Return(nil)
+	// The gateway reads part of the file, fails and resumes after the
+	// first byte.
+	ftp.On("Upload", mock.Anything, mock.MatchedBy(func(path string) bool {
+		return strings.HasSuffix(path, "test_0.csv")
+	}), mock.Anything).
+		Run(func(args mock.Arguments) {
+			r := args.Get(2).(io.Reader)
+			_, err := r.Read(make([]byte, 2))
+			require.NoError(t, err)
+			require.NoError(t, r.(interface{ ResumeAt(int64) error }).ResumeAt(1))
+			_, err = ioutil.ReadAll(r)
+			require.NoError(t, err)
+		}).
+		Return(nil)
+	var gotManifest []byte
+	ftp.On("Upload", mock.Anything, mock.MatchedBy(func(path string) bool {
+		return strings.HasSuffix(path, repository.ManifestFilename)
+	}), mock.Anything).
+		Run(func(args mock.Arguments) {
+			var err error
+			gotManifest, err = ioutil.ReadAll(args.Get(2).(io.Reader))
+			require.NoError(t, err)
+		}).
+		Return(nil)
+	inStream := make(chan io.ReadCloser, 1)
+	inStream <- &closeCountingReader{Reader: strings.NewReader("data")}
+	close(inStream)
+	generation := &entity.Generation{ID: "42", Type: "test", StartTime: time.Now()}
+	uploader := repository.NewFtpUploader(ftp, generation, inStream)
+
+	require.NoError(t, uploader.UploadFiles(context.Background()))
+
+	manifest := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(gotManifest, &manifest))
+	file := manifest["files"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, float64(4), file["size"])
+	assert.Equal(t, "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7", file["sha256"])
+}
+
+func TestFtpUploader_UploadFiles_Manifest(t *testing.T) {
+	ftp := new(mocks.FtpGateway)
+	var gotManifestJSON map[string]interface{}
+	ftp.On("DirExists", mock.Anything).Return(false, nil)
+	ftp.On("MakeDir", mock.Anything).Return(nil)
+	ftp.On("Rename", mock.Anything, mock.Anything).Return(nil)
+	ftp.On("RemoveDir", mock.Anything).Return(nil)
+	ftp.On("Upload", mock.Anything, "test.tmp-42/DE/test_0.csv", mock.Anything).
+		Run(func(args mock.Arguments) {
+			_, err := ioutil.ReadAll(args.Get(2).(io.Reader))
+			require.NoError(t, err)
+		}).
+		Return(nil)
+	ftp.On("Upload", mock.Anything, "test.tmp-42/manifest.json", mock.Anything).
+		Run(func(args mock.Arguments) {
+			require.NoError(t, json.NewDecoder(args.Get(2).(io.Reader)).Decode(&gotManifestJSON))
+		}).
+		Return(nil)
+	inStream := make(chan io.ReadCloser, 1)
+	inStream <- newPartitionedReader("DE")
+	close(inStream)
+	generation := &entity.Generation{ID: "42", Type: "test", StartTime: time.Unix(100, 0)}
+	uploader := repository.NewFtpUploader(ftp, generation, inStream)
+	var gotManifest *entity.Manifest
+	uploader.OnManifestUploaded(func(manifest *entity.Manifest) {
+		gotManifest = manifest
+	})
+
+	require.NoError(t, uploader.UploadFiles(context.Background()))
+
+	require.NotNil(t, gotManifest)
+	assert.Equal(t, "42", gotManifest.GenerationID)
+	assert.Equal(t, "test", gotManifest.Type)
+	require.Len(t, gotManifest.Files, 1)
+	assert.Equal(t, "DE/test_0.csv", gotManifest.Files[0].Name)
+	assert.Equal(t, "DE", gotManifest.Files[0].Partition)
+	assert.Equal(t, int64(4), gotManifest.Files[0].Size)
+	assert.Equal(t, uint(1), gotManifest.Files[0].Records)
+	assert.Equal(t, "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7", gotManifest.Files[0].SHA256)
+
+	assert.Equal(t, "42", gotManifestJSON["generation_id"])
+	assert.Equal(t, "test", gotManifestJSON["type"])
+	assert.Equal(t, "1970-01-01T00:01:40Z", gotManifestJSON["start_time"])
+	assert.Len(t, gotManifestJSON["files"], 1)
+}
+
+func TestFtpUploader_UploadFiles_Retried(t *testing.T) {
+	const staging = "test.tmp-42"
+	testCases := []struct {
+		name         string
+		setupMocks   func(ftp *mocks.FtpGateway)
+		wantErr      error
+		wantUploaded []uint
+	}{
+		{
+			name: "uploaded files skipped",
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", staging).Return(true, nil)
+				ftp.On("Upload", mock.Anything, staging+"/test_1.csv", mock.Anything).Return(nil).Once()
+				ftp.On("Upload", mock.Anything, staging+"/manifest.json", mock.Anything).Return(nil).Once()
+				ftp.On("Rename", mock.Anything, mock.Anything).Return(nil)
+				ftp.On("RemoveDir", mock.Anything).Return(nil)
+			},
+			wantUploaded: []uint{2},
+		},
+		{
+			name: "every file sent when staging dir is gone",
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", staging).Return(false, nil)
+				ftp.On("MakeDir", staging).Return(nil)
+				ftp.On("Upload", mock.Anything, staging+"/test_0.csv", mock.Anything).Return(nil).Once()
+				ftp.On("Upload", mock.Anything, staging+"/test_1.csv", mock.Anything).Return(nil).Once()
+				ftp.On("Upload", mock.Anything, staging+"/manifest.json", mock.Anything).Return(nil).Once()
+				ftp.On("Rename", mock.Anything, mock.Anything).Return(nil)
+				ftp.On("RemoveDir", mock.Anything).Return(nil)
+			},
+			wantUploaded: []uint{2},
+		},
+		{
+			name: "staging dir kept on failure",
+			setupMocks: func(ftp *mocks.FtpGateway) {
+				ftp.On("DirExists", staging).Return(true, nil)
+				ftp.On("Upload", mock.Anything, staging+"/test_1.csv", mock.Anything).Return(defaultErr).Once()
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ftp := new(mocks.FtpGateway)
+			tc.setupMocks(ftp)
+			inStream := make(chan io.ReadCloser, 2)
+			inStream <- &closeCountingReader{Reader: strings.NewReader("a")}
+			inStream <- &closeCountingReader{Reader: strings.NewReader("b")}
+			close(inStream)
+			generation := &entity.Generation{ID: "42", Type: "test", StartTime: time.Now()}
+			uploader := repository.NewFtpUploader(ftp, generation, inStream)
+			uploader.Resume(1)
+			var gotUploaded []uint
+			uploader.OnUpload(func(uploadedFilesNum uint) {
+				gotUploaded = append(gotUploaded, uploadedFilesNum)
+			})
+			var gotManifest *entity.Manifest
+			uploader.OnManifestUploaded(func(manifest *entity.Manifest) {
+				gotManifest = manifest
+			})
+
+			gotErr := uploader.UploadFiles(context.Background())
+
+			assert.ErrorIs(t, gotErr, tc.wantErr)
+			assert.Equal(t, tc.wantUploaded, gotUploaded)
+			if tc.wantErr == nil {
+				require.NotNil(t, gotManifest)
+				require.Len(t, gotManifest.Files, 2)
+				assert.Equal(t, "test_0.csv", gotManifest.Files[0].Name)
+				assert.Equal(t, "test_1.csv", gotManifest.Files[1].Name)
+			}
+			ftp.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rs/zerolog/log"
+
+	"go-feedmaker/entity"
+)
+
+type (
+	// FeedVersion is a published generation kept on a destination.
+	FeedVersion struct {
+		GenerationID string `json:"generation_id"`
+		// Location is where the version lives while it is not current: a
+		// directory next to the feed on file gateways, a key prefix on
+		// object storages.
+		Location string `json:"location"`
+	}
+
+	// VersionStore keeps the published versions of a feed on every
+	// destination, newest first; the first version is the current one.
+	VersionStore interface {
+		Versions(ctx context.Context, generationType, destination string) ([]*FeedVersion, error)
+		SetVersions(ctx context.Context, generationType, destination string, versions []*FeedVersion) error
+	}
+
+	// versioning keeps the last keep versions of a feed on a destination.
+	versioning struct {
+		store       VersionStore
+		destination string
+		keep        uint
+	}
+
+	currentPointer struct {
+		GenerationID string `json:"generation_id"`
+		Prefix       string `json:"prefix"`
+		Manifest     string `json:"manifest"`
+	}
+)
+
+// CurrentPointerFilename is the object that points to the current version of
+// a feed on object storages, stored under the generation type.
+const CurrentPointerFilename = "current.json"
+
+var (
+	ErrRollbackNotSupported = errors.New("destination does not support rollback")
+)
+
+func newVersioning(store VersionStore, destination string, keep uint) *versioning {
+	if store == nil || keep == 0 {
+		return nil
+	}
+	return &versioning{store: store, destination: destination, keep: keep}
+}
+
+// versionDir is where a generation is kept on file gateways once a newer
+// version replaced it.
+func versionDir(generation *entity.Generation) string {
+	return fmt.Sprintf("%s.v-%s", generation.Type, generation.ID)
+}
+
+// add puts version in front of versions and returns the kept versions and
+// the ones to remove.
+func (v *versioning) add(versions []*FeedVersion, version *FeedVersion) ([]*FeedVersion, []*FeedVersion) {
+	versions = append([]*FeedVersion{version}, versions...)
+	if uint(len(versions)) <= v.keep {
+		return versions, nil
+	}
+	return versions[:v.keep], versions[v.keep:]
+}
+
+func findVersion(versions []*FeedVersion, generationID string) (int, error) {
+	if generationID == "" {
+		if len(versions) < 2 {
+			return 0, fmt.Errorf("%w: no previous version", entity.ErrVersionNotFound)
+		}
+		return 1, nil
+	}
+	for i, version := range versions {
+		if version.GenerationID == generationID {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", entity.ErrVersionNotFound, generationID)
+}
+
+// makeCurrent moves the version at index in front of versions.
+func makeCurrent(versions []*FeedVersion, index int) []*FeedVersion {
+	res := make([]*FeedVersion, 0, len(versions))
+	res = append(res, versions[index])
+	res = append(res, versions[:index]...)
+	return append(res, versions[index+1:]...)
+}
+
+// rollbackDir moves the current directory back to its version location and
+// renames the version at index into its place.
+func rollbackDir(renamer Renamer, generationType string, versions []*FeedVersion, index int) error {
+	current, target := versions[0], versions[index]
+	if err := renamer.Rename(generationType, current.Location); err != nil {
+		return err
+	}
+	if err := renamer.Rename(target.Location, generationType); err != nil {
+		if restoreErr := renamer.Rename(current.Location, generationType); restoreErr != nil {
+			log.Error().Err(restoreErr).Msgf("Cannot restore dir %s", generationType)
+		}
+		return err
+	}
+	return nil
+}
+
+func putCurrentPointer(ctx context.Context, storage ObjectStorage, generationType string, version *FeedVersion) error {
+	data, err := json.Marshal(&currentPointer{
+		GenerationID: version.GenerationID,
+		Prefix:       version.Location,
+		Manifest:     path.Join(version.Location, ManifestFilename),
+	})
+	if err != nil {
+		return err
+	}
+	key := path.Join(generationType, CurrentPointerFilename)
+	return storage.PutObject(ctx, key, bytes.NewReader(data), int64(len(data)), contentTypeOf(key))
+}
+
+func (r *feedRepo) RollbackGeneration(ctx context.Context, generationType, generationID string) error {
+	config, ok := r.typeConfigMap[generationType]
+	if !ok {
+		return entity.ErrInvalidGenerationType
+	}
+	destinations := r.destinations(config)
+	// Check every destination before touching any of them.
+	versions := make([][]*FeedVersion, len(destinations))
+	indexes := make([]int, len(destinations))
+	for i, destination := range destinations {
+		if _, ok := destination.FtpGateway.(Renamer); !ok && destination.ObjectStorage == nil {
+			return fmt.Errorf("%w: %s", ErrRollbackNotSupported, destination.Name)
+		}
+		destinationVersions, err := r.Versions(ctx, generationType, destination.Name)
+		if err != nil {
+			return err
+		}
+		index, err := findVersion(destinationVersions, generationID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", destination.Name, err)
+		}
+		versions[i] = destinationVersions
+		indexes[i] = index
+	}
+	for i, destination := range destinations {
+		if indexes[i] == 0 {
+			continue
+		}
+		if destination.ObjectStorage != nil {
+			if err := putCurrentPointer(ctx, destination.ObjectStorage, generationType, versions[i][indexes[i]]); err != nil {
+				return err
+			}
+		} else if err := rollbackDir(destination.FtpGateway.(Renamer), generationType, versions[i], indexes[i]); err != nil {
+			return err
+		}
+		if err := r.SetVersions(ctx, generationType, destination.Name, makeCurrent(versions[i], indexes[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *feedRepo) Versions(ctx context.Context, generationType, destination string) ([]*FeedVersion, error) {
+	conn := r.client.Connection()
+	defer conn.Close()
+	data, err := redis.Bytes(conn.Do("GET", versionsKey(generationType, destination)))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]*FeedVersion, 0)
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *feedRepo) SetVersions(ctx context.Context, generationType, destination string, versions []*FeedVersion) error {
+	data, err := json.Marshal(versions)
+	if err != nil {
+		return err
+	}
+	conn := r.client.Connection()
+	defer conn.Close()
+	_, err = conn.Do("SET", versionsKey(generationType, destination), data)
+	return err
+}
+
+func (r *feedRepo) destinations(config *FeedConfig) []*Destination {
+	if len(config.Destinations) > 0 {
+		return config.Destinations
+	}
+	return []*Destination{{Name: DefaultDestination, FtpGateway: r.ftpGateway}}
+}
+
+func versionsKey(generationType, destination string) string {
+	return fmt.Sprintf("versions:%s:%s", generationType, destination)
+}
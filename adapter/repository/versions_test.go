@@ -0,0 +1,273 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"go-feedmaker/adapter/repository"
+	"go-feedmaker/adapter/repository/mocks"
+	"go-feedmaker/entity"
+)
+
+func TestFtpUploader_UploadFiles_Versions(t *testing.T) {
+	const staging = "test.tmp-42"
+	current := &repository.FeedVersion{GenerationID: "42", Location: "test.v-42"}
+	testCases := []struct {
+		name       string
+		keep       uint
+		setupMocks func(ftp *mocks.FtpGateway, store *mocks.VersionStore)
+		wantErr    error
+	}{
+		{
+			name: "first version",
+			keep: 2,
+			setupMocks: func(ftp *mocks.FtpGateway, store *mocks.VersionStore) {
+				store.On("Versions", mock.Anything, "test", "ftp").Return(nil, nil)
+				ftp.On("Rename", "test", "test.old-42").Return(defaultErr).Once()
+				ftp.On("Rename", staging, "test").Return(nil).Once()
+				store.On("SetVersions", mock.Anything, "test", "ftp", []*repository.FeedVersion{current}).Return(nil)
+			},
+		},
+		{
+			name: "previous version is kept",
+			keep: 2,
+			setupMocks: func(ftp *mocks.FtpGateway, store *mocks.VersionStore) {
+				previous := &repository.FeedVersion{GenerationID: "41", Location: "test.v-41"}
+				store.On("Versions", mock.Anything, "test", "ftp").Return([]*repository.FeedVersion{previous}, nil)
+				ftp.On("Rename", "test", "test.v-41").Return(nil).Once()
+				ftp.On("Rename", staging, "test").Return(nil).Once()
+				store.On("SetVersions", mock.Anything, "test", "ftp", []*repository.FeedVersion{current, previous}).
+					Return(nil)
+			},
+		},
+		{
+			name: "versions beyond keep are removed",
+			keep: 2,
+			setupMocks: func(ftp *mocks.FtpGateway, store *mocks.VersionStore) {
+				previous := &repository.FeedVersion{GenerationID: "41", Location: "test.v-41"}
+				oldest := &repository.FeedVersion{GenerationID: "40", Location: "test.v-40"}
+				store.On("Versions", mock.Anything, "test", "ftp").
+					Return([]*repository.FeedVersion{previous, oldest}, nil)
+				ftp.On("Rename", "test", "test.v-41").Return(nil).Once()
+				ftp.On("Rename", staging, "test").Return(nil).Once()
+				ftp.On("RemoveDir", "test.v-40").Return(nil).Once()
+				store.On("SetVersions", mock.Anything, "test", "ftp", []*repository.FeedVersion{current, previous}).
+					Return(nil)
+			},
+		},
+		{
+			name: "missing current version is forgotten",
+			keep: 2,
+			setupMocks: func(ftp *mocks.FtpGateway, store *mocks.VersionStore) {
+				previous := &repository.FeedVersion{GenerationID: "41", Location: "test.v-41"}
+				store.On("Versions", mock.Anything, "test", "ftp").Return([]*repository.FeedVersion{previous}, nil)
+				ftp.On("Rename", "test", "test.v-41").Return(defaultErr).Once()
+				ftp.On("Rename", staging, "test").Return(nil).Once()
+				store.On("SetVersions", mock.Anything, "test", "ftp", []*repository.FeedVersion{current}).Return(nil)
+			},
+		},
+		{
+			name: "versions error keeps previous version",
+			keep: 2,
+			setupMocks: func(ftp *mocks.FtpGateway, store *mocks.VersionStore) {
+				store.On("Versions", mock.Anything, "test", "ftp").Return(nil, defaultErr)
+				ftp.On("RemoveDir", staging).Return(nil).Once()
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ftp, store := new(mocks.FtpGateway), new(mocks.VersionStore)
+			ftp.On("DirExists", staging).Return(false, nil)
+			ftp.On("MakeDir", staging).Return(nil)
+			ftp.On("Upload", mock.Anything, staging+"/test_0.csv", mock.Anything).Return(nil)
+			ftp.On("Upload", mock.Anything, staging+"/manifest.json", mock.Anything).Return(nil)
+			tc.setupMocks(ftp, store)
+			inStream := make(chan io.ReadCloser, 1)
+			inStream <- ioutil.NopCloser(strings.NewReader("a"))
+			close(inStream)
+			generation := &entity.Generation{ID: "42", Type: "test", StartTime: time.Now()}
+			uploader := repository.NewFtpUploader(ftp, generation, inStream)
+			uploader.KeepVersions(store, "ftp", tc.keep)
+
+			gotErr := uploader.UploadFiles(context.Background())
+
+			assert.Equal(t, tc.wantErr, gotErr)
+			ftp.AssertExpectations(t)
+			store.AssertExpectations(t)
+		})
+	}
+}
+
+func TestObjectStorageUploader_UploadFiles_Versions(t *testing.T) {
+	storage, store := new(mocks.ObjectStorage), new(mocks.VersionStore)
+	previous := &repository.FeedVersion{GenerationID: "41", Location: "test/41"}
+	oldest := &repository.FeedVersion{GenerationID: "40", Location: "test/40"}
+	current := &repository.FeedVersion{GenerationID: "42", Location: "test/42"}
+	storage.On("PutObject", mock.Anything, "test/42/test_0.csv", mock.Anything, int64(-1), mock.Anything).Return(nil).Once()
+	storage.On("PutObject", mock.Anything, "test/42/manifest.json", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil).Once()
+	var gotPointer map[string]string
+	storage.On("PutObject", mock.Anything, "test/current.json", mock.Anything, mock.Anything, "application/json").
+		Run(func(args mock.Arguments) {
+			require.NoError(t, json.NewDecoder(args.Get(2).(io.Reader)).Decode(&gotPointer))
+		}).
+		Return(nil).Once()
+	storage.On("RemoveObjects", mock.Anything, "test/40").Return(nil).Once()
+	store.On("Versions", mock.Anything, "test", "s3").Return([]*repository.FeedVersion{previous, oldest}, nil)
+	store.On("SetVersions", mock.Anything, "test", "s3", []*repository.FeedVersion{current, previous}).Return(nil)
+	inStream := make(chan io.ReadCloser, 1)
+	inStream <- ioutil.NopCloser(strings.NewReader("a"))
+	close(inStream)
+	keyPrefix, err := repository.ParseKeyPrefix("")
+	require.NoError(t, err)
+	generation := &entity.Generation{ID: "42", Type: "test", StartTime: time.Now()}
+	uploader := repository.NewObjectStorageUploader(storage, keyPrefix, generation, inStream)
+	uploader.KeepVersions(store, "s3", 2)
+
+	require.NoError(t, uploader.UploadFiles(context.Background()))
+
+	assert.Equal(t, map[string]string{
+		"generation_id": "42",
+		"prefix":        "test/42",
+		"manifest":      "test/42/manifest.json",
+	}, gotPointer)
+	storage.AssertExpectations(t)
+	store.AssertExpectations(t)
+}
+
+func TestFeedRepo_RollbackGeneration(t *testing.T) {
+	versions := []byte(`[{"generation_id":"42","location":"test.v-42"},` +
+		`{"generation_id":"41","location":"test.v-41"},` +
+		`{"generation_id":"40","location":"test.v-40"}]`)
+	testCases := []struct {
+		name           string
+		generationType string
+		generationID   string
+		setupMocks     func(f *feedFields)
+		wantErr        error
+	}{
+		{
+			name:           "previous version",
+			generationType: "test",
+			setupMocks: func(f *feedFields) {
+				f.conn.On("Do", "GET", "versions:test:ftp").Return(versions, nil)
+				f.ftp.On("Rename", "test", "test.v-42").Return(nil).Once()
+				f.ftp.On("Rename", "test.v-41", "test").Return(nil).Once()
+				f.conn.On("Do", "SET", "versions:test:ftp", []byte(`[{"generation_id":"41","location":"test.v-41"},`+
+					`{"generation_id":"42","location":"test.v-42"},`+
+					`{"generation_id":"40","location":"test.v-40"}]`)).Return("OK", nil)
+			},
+		},
+		{
+			name:           "chosen version",
+			generationType: "test",
+			generationID:   "40",
+			setupMocks: func(f *feedFields) {
+				f.conn.On("Do", "GET", "versions:test:ftp").Return(versions, nil)
+				f.ftp.On("Rename", "test", "test.v-42").Return(nil).Once()
+				f.ftp.On("Rename", "test.v-40", "test").Return(nil).Once()
+				f.conn.On("Do", "SET", "versions:test:ftp", []byte(`[{"generation_id":"40","location":"test.v-40"},`+
+					`{"generation_id":"42","location":"test.v-42"},`+
+					`{"generation_id":"41","location":"test.v-41"}]`)).Return("OK", nil)
+			},
+		},
+		{
+			name:           "current version",
+			generationType: "test",
+			generationID:   "42",
+			setupMocks: func(f *feedFields) {
+				f.conn.On("Do", "GET", "versions:test:ftp").Return(versions, nil)
+			},
+		},
+		{
+			name:           "unknown version",
+			generationType: "test",
+			generationID:   "1",
+			setupMocks: func(f *feedFields) {
+				f.conn.On("Do", "GET", "versions:test:ftp").Return(versions, nil)
+			},
+			wantErr: entity.ErrVersionNotFound,
+		},
+		{
+			name:           "no versions",
+			generationType: "test",
+			setupMocks: func(f *feedFields) {
+				f.conn.On("Do", "GET", "versions:test:ftp").Return(nil, nil)
+			},
+			wantErr: entity.ErrVersionNotFound,
+		},
+		{
+			name:           "unknown type",
+			generationType: "unknown",
+			setupMocks:     func(f *feedFields) {},
+			wantErr:        entity.ErrInvalidGenerationType,
+		},
+		{
+			name:           "swap error restores current version",
+			generationType: "test",
+			setupMocks: func(f *feedFields) {
+				f.conn.On("Do", "GET", "versions:test:ftp").Return(versions, nil)
+				f.ftp.On("Rename", "test", "test.v-42").Return(nil).Once()
+				f.ftp.On("Rename", "test.v-41", "test").Return(defaultErr).Once()
+				f.ftp.On("Rename", "test.v-42", "test").Return(nil).Once()
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := defaultFeedFields()
+			fields.config["test"] = &repository.FeedConfig{}
+			fields.client.On("Connection").Return(fields.conn).Maybe()
+			fields.conn.On("Close").Return(nil).Maybe()
+			tc.setupMocks(fields)
+			feedRepo := repository.NewFeedRepo(fields.config, fields.client, fields.ftp)
+
+			gotErr := feedRepo.RollbackGeneration(context.Background(), tc.generationType, tc.generationID)
+
+			assert.ErrorIs(t, gotErr, tc.wantErr)
+			fields.assertExpectations(t)
+			fields.ftp.AssertExpectations(t)
+		})
+	}
+}
+
+func TestFeedRepo_RollbackGeneration_ObjectStorage(t *testing.T) {
+	fields := defaultFeedFields()
+	storage := new(mocks.ObjectStorage)
+	fields.config["test"] = &repository.FeedConfig{
+		Destinations: []*repository.Destination{{Name: "s3", ObjectStorage: storage}},
+	}
+	fields.client.On("Connection").Return(fields.conn)
+	fields.conn.On("Close").Return(nil)
+	fields.conn.On("Do", "GET", "versions:test:s3").
+		Return([]byte(`[{"generation_id":"42","location":"test/42"},{"generation_id":"41","location":"test/41"}]`), nil)
+	var gotPointer map[string]string
+	storage.On("PutObject", mock.Anything, "test/current.json", mock.Anything, mock.Anything, "application/json").
+		Run(func(args mock.Arguments) {
+			require.NoError(t, json.NewDecoder(args.Get(2).(io.Reader)).Decode(&gotPointer))
+		}).
+		Return(nil)
+	fields.conn.On("Do", "SET", "versions:test:s3",
+		[]byte(`[{"generation_id":"41","location":"test/41"},{"generation_id":"42","location":"test/42"}]`)).
+		Return("OK", nil)
+	feedRepo := repository.NewFeedRepo(fields.config, fields.client, fields.ftp)
+
+	require.NoError(t, feedRepo.RollbackGeneration(context.Background(), "test", ""))
+
+	assert.Equal(t, "41", gotPointer["generation_id"])
+	assert.Equal(t, "test/41/manifest.json", gotPointer["manifest"])
+	fields.assertExpectations(t)
+	storage.AssertExpectations(t)
+}
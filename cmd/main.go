@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/gorilla/websocket"
@@ -27,6 +32,19 @@ import (
 	"go-feedmaker/interactor"
 )
 
+var (
+	ErrInvalidCsvDialect  = errors.New("invalid csv dialect")
+	ErrUnknownDestination = errors.New("unknown destination")
+)
+
+const (
+	ftpDestination   = "ftp"
+	sftpDestination  = "sftp"
+	s3Destination    = "s3"
+	localDestination = "local"
+	httpDestination  = "http"
+)
+
 type (
 	redisDialer struct{}
 	ftpDialer   struct{}
@@ -36,8 +54,24 @@ func (r *redisDialer) Dial(network, addr string, options ...redis.DialOption) (g
 	return redis.Dial(network, addr, options...)
 }
 
-func (f *ftpDialer) DialTimeout(addr string, timeout time.Duration) (gateway.FtpConnection, error) {
-	return ftp.DialTimeout(addr, timeout)
+func (f *ftpDialer) DialTimeout(
+	addr string,
+	timeout time.Duration,
+	mode gateway.FtpTLSMode,
+	tlsConfig *tls.Config,
+) (gateway.FtpConnection, error) {
+	options := []ftp.DialOption{ftp.DialWithTimeout(timeout)}
+	switch mode {
+	case gateway.FtpTLSExplicit:
+		options = append(options, ftp.DialWithExplicitTLS(tlsConfig))
+	case gateway.FtpTLSImplicit:
+		options = append(options, ftp.DialWithTLS(tlsConfig))
+	}
+	connection, err := ftp.Dial(addr, options...)
+	if err != nil {
+		return nil, err
+	}
+	return connection, nil
 }
 
 func main() {
@@ -54,6 +88,12 @@ func main() {
 		log.Fatal().Err(err).Msg("Can't connect to Redis")
 	}
 	defer redisGateway.Disconnect()
+	if conf.Logger.GenerationLines > 0 {
+		logWriter := repository.NewLogWriter(redisGateway, conf.Logger.GenerationLines)
+		logWriter.Start()
+		defer logWriter.Stop()
+		conf.Logger.Capture(logWriter)
+	}
 
 	ftpGateway := &gateway.FtpGateway{
 		Dialer: new(ftpDialer),
@@ -64,6 +104,52 @@ func main() {
 	}
 	defer ftpGateway.Disconnect()
 
+	destinations := map[string]*repository.Destination{
+		ftpDestination: {Name: ftpDestination, FtpGateway: ftpGateway},
+	}
+	if usesDestination(conf.Feeds, sftpDestination) {
+		sftpGateway := &gateway.SftpGateway{Config: conf.Sftp}
+		if err := sftpGateway.Connect(); err != nil {
+			log.Fatal().Err(err).Msg("Can't connect to Sftp")
+		}
+		defer sftpGateway.Disconnect()
+		destinations[sftpDestination] = &repository.Destination{Name: sftpDestination, FtpGateway: sftpGateway}
+	}
+	if usesDestination(conf.Feeds, localDestination) {
+		localGateway := &gateway.LocalGateway{Config: conf.Local}
+		if err := localGateway.Connect(); err != nil {
+			log.Fatal().Err(err).Msg("Can't prepare local destination directory")
+		}
+		destinations[localDestination] = &repository.Destination{Name: localDestination, FtpGateway: localGateway}
+	}
+	if usesDestination(conf.Feeds, httpDestination) {
+		if _, err := conf.Http.UploadMethod(); err != nil {
+			log.Fatal().Err(err).Msg("Can't configure http destination")
+		}
+		httpGateway := &gateway.HttpGateway{
+			Requester: &http.Client{Timeout: conf.Http.Timeout},
+			Config:    conf.Http,
+		}
+		destinations[httpDestination] = &repository.Destination{Name: httpDestination, FtpGateway: httpGateway}
+	}
+	if usesDestination(conf.Feeds, s3Destination) {
+		s3Gateway := &gateway.S3Gateway{Config: conf.S3}
+		if err := s3Gateway.Connect(); err != nil {
+			log.Fatal().Err(err).Msg("Can't connect to S3")
+		}
+		destinations[s3Destination] = &repository.Destination{Name: s3Destination, ObjectStorage: s3Gateway}
+	}
+
+	spool, err := initSpool(conf.Spool)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Can't initialize spool directory")
+	}
+	var archive *repository.Archive
+	if conf.Archive.Dir != "" {
+		if archive, err = repository.NewArchive(conf.Archive.Dir); err != nil {
+			log.Fatal().Err(err).Msg("Can't initialize archive directory")
+		}
+	}
 	feedRepoConfig, err := initFeedRepoConfig(conf.Feeds)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Can't initialize config for feed repo")
@@ -79,12 +165,31 @@ func main() {
 			log.Fatal().Err(err).Msgf("Can't connect to database for feed %s", key)
 		}
 		feedRepoConfig[key].SqlGateway = sqlGateway.DB()
+		feedRepoConfig[key].Spool = spool
+		feedRepoConfig[key].Archive = archive
+		feedDestinations, err := getDestinations(destinations, feedDestinationNames(conf))
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Can't choose destinations for feed %s", key)
+		}
+		feedRepoConfig[key].Destinations = feedDestinations
 	}
 	defer closeSqlGateways(sqlGateways)
 
 	feedRepo := repository.NewFeedRepo(feedRepoConfig, redisGateway, ftpGateway)
+	if err := feedRepo.IndexGenerations(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("Can't index generations")
+	}
 	feedPresenter := new(presenter.Presenter)
-	feedInteractor := interactor.NewFeedInteractor(feedRepo, feedPresenter)
+	instance := conf.Instance.ID
+	if instance == "" {
+		if instance, err = os.Hostname(); err != nil {
+			log.Fatal().Err(err).Msg("Can't get hostname for instance id")
+		}
+	}
+	feedInteractor := interactor.NewFeedInteractor(feedRepo, feedPresenter, conf.State.Interval, instance)
+	if err := feedInteractor.FailInterruptedGenerations(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("Can't fail interrupted generations")
+	}
 
 	scheduleSaver := scheduler.NewScheduleSaver(redisGateway)
 	taskScheduler := scheduler.New(cron.New(), scheduleSaver)
@@ -93,6 +198,15 @@ func main() {
 	if err := taskScheduler.ScheduleAllSavedGenerations(feedInteractor); err != nil {
 		log.Fatal().Err(err).Msg("can't schedule saved generations")
 	}
+	retention := &entity.RetentionPolicy{
+		MaxAge:   conf.Retention.MaxAge,
+		MaxCount: conf.Retention.MaxCount,
+	}
+	if retention.IsEnabled() && conf.Retention.Interval > 0 {
+		janitor := scheduler.NewJanitor(feedInteractor, retention, conf.Retention.Interval)
+		janitor.Start()
+		defer janitor.Stop()
+	}
 	handler := rest.NewHandler(feedInteractor, taskScheduler)
 
 	upgrader := &websocket.Upgrader{
@@ -113,12 +227,55 @@ func main() {
 	defer apiServer.Stop()
 
 	log.Info().Msgf("Successfully started server")
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 	log.Info().Msgf("Server was stopped")
 }
 
+func usesDestination(feeds map[string]config.FeedConfig, destination string) bool {
+	for _, feed := range feeds {
+		for _, name := range feedDestinationNames(feed) {
+			if name == destination {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// feedDestinationNames prefers the destinations list over the single
+// destination key and falls back to ftp.
+func feedDestinationNames(feed config.FeedConfig) []string {
+	if len(feed.Destinations) > 0 {
+		return feed.Destinations
+	}
+	if feed.Destination != "" {
+		return []string{feed.Destination}
+	}
+	return []string{ftpDestination}
+}
+
+func getDestinations(destinations map[string]*repository.Destination, names []string) ([]*repository.Destination, error) {
+	res := make([]*repository.Destination, 0, len(names))
+	for _, name := range names {
+		destination, ok := destinations[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownDestination, name)
+		}
+		res = append(res, destination)
+	}
+	return res, nil
+}
+
+func initSpool(conf config.SpoolConfig) (*repository.Spool, error) {
+	maxSize, err := bytesize.Parse(conf.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewSpool(conf.Dir, maxSize)
+}
+
 func initFeedRepoConfig(config map[string]config.FeedConfig) (map[string]*repository.FeedConfig, error) {
 	res := make(map[string]*repository.FeedConfig, len(config))
 	for key, conf := range config {
@@ -134,17 +291,65 @@ func initFeedRepoConfig(config map[string]config.FeedConfig) (map[string]*reposi
 		if err != nil {
 			return nil, err
 		}
+		csvDialect, err := parseCsvDialect(conf.Csv)
+		if err != nil {
+			return nil, fmt.Errorf("feed %s: %w", key, err)
+		}
+		keyPrefix, err := repository.ParseKeyPrefix(conf.KeyPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("feed %s: %w", key, err)
+		}
+		destinationPolicy, err := repository.ParseDestinationPolicy(conf.DestinationPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("feed %s: %w", key, err)
+		}
 
 		res[key] = &repository.FeedConfig{
-			CountQuery:    countQuery,
-			SelectQuery:   selectQuery,
-			FileSizeLimit: fileSizeLimit,
-			FileLineLimit: conf.FileLineLimit,
+			CountQuery:        countQuery,
+			SelectQuery:       selectQuery,
+			FileSizeLimit:     fileSizeLimit,
+			FileLineLimit:     conf.FileLineLimit,
+			CsvDialect:        csvDialect,
+			PartitionBy:       conf.PartitionBy,
+			KeyPrefix:         keyPrefix,
+			DestinationPolicy: destinationPolicy,
+			KeepVersions:      conf.KeepVersions,
+			Checkpoints:       conf.Checkpoints,
 		}
 	}
 	return res, nil
 }
 
+func parseCsvDialect(conf config.CsvConfig) (repository.CsvDialect, error) {
+	dialect := repository.CsvDialect{
+		Delimiter:    ',',
+		WithBOM:      conf.Bom,
+		RepeatHeader: conf.RepeatHeader,
+	}
+	if conf.Delimiter != "" {
+		delimiter, size := utf8.DecodeRuneInString(conf.Delimiter)
+		if size != len(conf.Delimiter) || delimiter == utf8.RuneError || strings.ContainsRune("\"\r\n", delimiter) {
+			return dialect, fmt.Errorf("%w: delimiter %q", ErrInvalidCsvDialect, conf.Delimiter)
+		}
+		dialect.Delimiter = delimiter
+	}
+	switch conf.Quote {
+	case "", "minimal":
+	case "all":
+		dialect.QuoteAll = true
+	default:
+		return dialect, fmt.Errorf("%w: quote %q", ErrInvalidCsvDialect, conf.Quote)
+	}
+	switch conf.LineEnding {
+	case "", "lf":
+	case "crlf":
+		dialect.UseCRLF = true
+	default:
+		return dialect, fmt.Errorf("%w: line ending %q", ErrInvalidCsvDialect, conf.LineEnding)
+	}
+	return dialect, nil
+}
+
 func closeSqlGateways(sqlGateways []*gateway.SqlGateway) {
 	for _, sqlGateway := range sqlGateways {
 		if err := sqlGateway.Disconnect(); err != nil {
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/inhies/go-bytesize"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"go-feedmaker/adapter/repository"
+	"go-feedmaker/infrastructure/gateway"
+	helper "go-feedmaker/infrastructure/testing"
+	"go-feedmaker/interactor"
+	"go-feedmaker/interactor/mocks"
+)
+
+func TestFtpDialer_TLS(t *testing.T) {
+	ca := helper.NewTestCA(t)
+	serverTLS := ca.ServerTLSConfig(t, "127.0.0.1")
+	clientCertFile, clientKeyFile := ca.Issue(t, "client")
+	untrustedCA := helper.NewTestCA(t)
+
+	testCases := []struct {
+		name        string
+		serverTLS   *tls.Config
+		implicit    bool
+		setupConfig func(c *gateway.FtpConfig)
+		wantErr     bool
+	}{
+		{
+			name:        "plain",
+			setupConfig: func(c *gateway.FtpConfig) {},
+		},
+		{
+			name:      "explicit",
+			serverTLS: serverTLS,
+			setupConfig: func(c *gateway.FtpConfig) {
+				c.TLS = gateway.FtpTLSExplicit
+				c.CAFile = ca.CertFile
+			},
+		},
+		{
+			name:      "implicit",
+			serverTLS: serverTLS,
+			implicit:  true,
+			setupConfig: func(c *gateway.FtpConfig) {
+				c.TLS = gateway.FtpTLSImplicit
+				c.CAFile = ca.CertFile
+			},
+		},
+		{
+			name: "client certificate",
+			serverTLS: &tls.Config{
+				Certificates: serverTLS.Certificates,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    ca.Pool,
+			},
+			setupConfig: func(c *gateway.FtpConfig) {
+				c.TLS = gateway.FtpTLSExplicit
+				c.CAFile = ca.CertFile
+				c.CertFile = clientCertFile
+				c.KeyFile = clientKeyFile
+			},
+		},
+		{
+			name:      "insecure skip verify",
+			serverTLS: serverTLS,
+			setupConfig: func(c *gateway.FtpConfig) {
+				c.TLS = gateway.FtpTLSExplicit
+				c.InsecureSkipVerify = true
+			},
+		},
+		{
+			name:      "untrusted server certificate",
+			serverTLS: serverTLS,
+			setupConfig: func(c *gateway.FtpConfig) {
+				c.TLS = gateway.FtpTLSExplicit
+				c.CAFile = untrustedCA.CertFile
+			},
+			wantErr: true,
+		},
+		{
+			name:        "server requires tls",
+			serverTLS:   serverTLS,
+			setupConfig: func(c *gateway.FtpConfig) { c.TLS = gateway.FtpTLSNone },
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := helper.StartFtpServer(t, tc.serverTLS, tc.implicit)
+			config := gateway.FtpConfig{
+				Host:        server.Host,
+				Port:        server.Port,
+				ConnTimeout: time.Second,
+				Username:    server.Username,
+				Password:    server.Password,
+			}
+			tc.setupConfig(&config)
+			ftpGateway := &gateway.FtpGateway{Dialer: new(ftpDialer), Config: config}
+
+			err := ftpGateway.Connect()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer ftpGateway.Disconnect()
+
+			require.NoError(t, ftpGateway.MakeDir("criteo"))
+			content := []byte("id,name\n1,foo\n")
+			require.NoError(t, ftpGateway.Upload(context.Background(), "criteo/criteo_1.csv", bytes.NewReader(content)))
+			got, ok := server.File("criteo/criteo_1.csv")
+			require.True(t, ok)
+			assert.Equal(t, content, got)
+		})
+	}
+}
+
+func TestFtpGateway_Reconnect(t *testing.T) {
+	server := helper.StartFtpServer(t, nil, false)
+	ftpGateway := &gateway.FtpGateway{
+		Dialer: new(ftpDialer),
+		Config: gateway.FtpConfig{
+			Host:        server.Host,
+			Port:        server.Port,
+			ConnTimeout: time.Second,
+			Username:    server.Username,
+			Password:    server.Password,
+			MaxRetries:  1,
+		},
+	}
+	require.NoError(t, ftpGateway.Connect())
+	defer ftpGateway.Disconnect()
+
+	server.DropConnections()
+
+	content := []byte("id,name\n1,foo\n")
+	require.NoError(t, ftpGateway.Upload(context.Background(), "criteo_1.csv", bytes.NewReader(content)))
+	got, ok := server.File("criteo_1.csv")
+	require.True(t, ok)
+	assert.Equal(t, content, got)
+}
+
+func TestFtpGateway_ResumeUpload(t *testing.T) {
+	server := helper.StartFtpServer(t, nil, false)
+	ftpGateway := &gateway.FtpGateway{
+		Dialer: new(ftpDialer),
+		Config: gateway.FtpConfig{
+			Host:        server.Host,
+			Port:        server.Port,
+			ConnTimeout: time.Second,
+			Username:    server.Username,
+			Password:    server.Password,
+			MaxRetries:  1,
+		},
+	}
+	require.NoError(t, ftpGateway.Connect())
+	defer ftpGateway.Disconnect()
+
+	server.InterruptNextUpload(5)
+
+	content := []byte("id,name\n1,foo\n")
+	require.NoError(t, ftpGateway.Upload(context.Background(), "criteo_1.csv", bytes.NewReader(content)))
+	got, ok := server.File("criteo_1.csv")
+	require.True(t, ok)
+	assert.Equal(t, content, got)
+}
+
+func TestGenerateFeed_LocalDestination(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	sqlMock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM offers")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	sqlMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM offers")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "country"}).
+			AddRow("1", "DE").
+			AddRow("2", "AT").
+			AddRow("3", "DE"))
+
+	localGateway := &gateway.LocalGateway{Config: gateway.LocalConfig{Dir: t.TempDir()}}
+	require.NoError(t, localGateway.Connect())
+	spoolDir := t.TempDir()
+	spool, err := repository.NewSpool(spoolDir, bytesize.MB)
+	require.NoError(t, err)
+	factory, err := repository.NewDefaultFactory(&repository.FeedConfig{
+		CountQuery:    "SELECT COUNT(*) FROM offers",
+		SelectQuery:   "SELECT * FROM offers",
+		FileSizeLimit: bytesize.MB,
+		FileLineLimit: 100,
+		CsvDialect:    repository.CsvDialect{Delimiter: ','},
+		PartitionBy:   "country",
+		Spool:         spool,
+	}, db, localGateway, nil, "criteo")
+	require.NoError(t, err)
+
+	feedRepo := new(mocks.FeedRepo)
+	feedRepo.On("GetFactoryByGenerationType", "criteo").Return(factory, nil)
+	feedRepo.On("StoreGeneration", mock.Anything, mock.Anything).Return(nil)
+	feedRepo.On("UpdateGenerationState", mock.Anything, mock.Anything).Return(nil)
+	feedRepo.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	feedInteractor := interactor.NewFeedInteractor(feedRepo, new(mocks.Presenter), 0, "")
+
+	require.NoError(t, feedInteractor.GenerateFeed(context.Background(), "criteo"))
+
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.Equal(t, [][]string{{"id", "country"}, {"1", "DE"}, {"3", "DE"}},
+		readLocalCsv(t, localGateway, "criteo/DE/criteo_0.csv"))
+	assert.Equal(t, [][]string{{"id", "country"}, {"2", "AT"}},
+		readLocalCsv(t, localGateway, "criteo/AT/criteo_0.csv"))
+	_, err = os.Stat(filepath.Join(localGateway.Config.Dir, "criteo", "manifest.json"))
+	assert.NoError(t, err)
+	published, err := ioutil.ReadDir(localGateway.Config.Dir)
+	require.NoError(t, err)
+	require.Len(t, published, 1)
+	assert.Equal(t, "criteo", published[0].Name())
+	spoolEntries, err := ioutil.ReadDir(spoolDir)
+	require.NoError(t, err)
+	assert.Empty(t, spoolEntries)
+}
+
+func readLocalCsv(t *testing.T, localGateway *gateway.LocalGateway, name string) [][]string {
+	return helper.ReadCsvFromFile(t, filepath.Join(localGateway.Config.Dir, filepath.FromSlash(name)))
+}
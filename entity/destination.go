@@ -0,0 +1,20 @@
+package entity
+
+type (
+	DestinationState string
+
+	// DestinationStatus tracks the upload of a generation to one of the
+	// destinations of its feed.
+	DestinationStatus struct {
+		Name          string
+		State         DestinationState
+		FilesUploaded uint
+		Error         string
+	}
+)
+
+const (
+	DestinationUploading DestinationState = "uploading"
+	DestinationSucceeded DestinationState = "succeeded"
+	DestinationFailed    DestinationState = "failed"
+)
@@ -3,6 +3,16 @@ package entity
 import "errors"
 
 var (
-	ErrInvalidGenerationType = errors.New("this generation type is invalid")
-	ErrInvalidTimestamp      = errors.New("invalid timestamp")
+	ErrInvalidGenerationType   = errors.New("this generation type is invalid")
+	ErrGenerationNotFound      = errors.New("generation not found")
+	ErrGenerationRunning       = errors.New("generation is still running")
+	ErrGenerationSucceeded     = errors.New("generation already succeeded")
+	ErrInvalidGenerationStatus = errors.New("invalid generation status")
+	ErrInvalidCursor           = errors.New("invalid cursor")
+	ErrInvalidTimestamp        = errors.New("invalid timestamp")
+	ErrFileNotFound            = errors.New("file not found")
+	ErrVersionNotFound         = errors.New("feed version not found")
+	ErrNoCheckpoint            = errors.New("generation has no checkpoint to retry from")
+	ErrPreviewNotSupported     = errors.New("generation type can't be previewed")
+	ErrGenerationInterrupted   = errors.New("generation was interrupted by a restart")
 )
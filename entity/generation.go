@@ -1,16 +1,80 @@
 package entity
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
-type Generation struct {
-	ID            string
-	Type          string
-	Progress      uint
-	DataFetched   bool
-	FilesUploaded uint
-	IsCanceled    bool
-	StartTime     time.Time
-	EndTime       time.Time
+type (
+	GenerationStatus string
+
+	Generation struct {
+		ID            string
+		Type          string
+		Progress      uint
+		DataFetched   bool
+		FilesUploaded uint
+		IsCanceled    bool
+		StartTime     time.Time
+		EndTime       time.Time
+		Manifest      *Manifest
+		Destinations  []*DestinationStatus
+		// RecordsCount is the number of records the count query reported.
+		RecordsCount uint
+		// Parameters are the feed settings the generation was run with.
+		Parameters map[string]string
+		Stages     []*StageTiming
+		// Error is the error the generation failed with.
+		Error string
+		// Finished is set together with the outcome once the generation
+		// stopped running.
+		Finished bool
+		// Instance is the id of the process that runs the generation.
+		Instance string
+	}
+
+	// StageTiming tracks when a stage of a generation started and finished.
+	StageTiming struct {
+		Name      string
+		StartTime time.Time
+		EndTime   time.Time
+	}
+
+	// GenerationFilter selects a page of generations, newest first. Empty
+	// fields match every generation; From and To bound the start time.
+	GenerationFilter struct {
+		Types    []string
+		Statuses []GenerationStatus
+		From     time.Time
+		To       time.Time
+		// Cursor continues the page that returned it.
+		Cursor string
+		Limit  uint
+	}
+
+	// RetentionPolicy tells which finished generations are deleted: the ones
+	// started more than MaxAge ago and the ones older than the newest
+	// MaxCount of their type. Zero values disable a limit.
+	RetentionPolicy struct {
+		MaxAge   time.Duration
+		MaxCount uint
+	}
+)
+
+const (
+	GenerationRunning   GenerationStatus = "running"
+	GenerationSucceeded GenerationStatus = "succeeded"
+	GenerationFailed    GenerationStatus = "failed"
+	GenerationCanceled  GenerationStatus = "canceled"
+)
+
+func ParseGenerationStatus(status string) (GenerationStatus, error) {
+	switch GenerationStatus(status) {
+	case GenerationRunning, GenerationSucceeded, GenerationFailed, GenerationCanceled:
+		return GenerationStatus(status), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidGenerationStatus, status)
+	}
 }
 
 func (g *Generation) SetProgress(progress uint) {
@@ -22,3 +86,73 @@ func (g *Generation) SetProgress(progress uint) {
 		g.EndTime = time.Now()
 	}
 }
+
+// Status tells whether the generation is still running or how it ended.
+// Generations stored before it was tracked whether they finished count as
+// finished once they ended with an error or a cancel, fetched all the data or
+// uploaded a file; the ones interrupted before that are failed at startup.
+func (g *Generation) Status() GenerationStatus {
+	switch {
+	case !g.Finished && !g.isLegacyFinished():
+		return GenerationRunning
+	case g.IsCanceled:
+		return GenerationCanceled
+	case g.Error != "":
+		return GenerationFailed
+	}
+	return GenerationSucceeded
+}
+
+func (g *Generation) isLegacyFinished() bool {
+	if len(g.Stages) > 0 {
+		return false
+	}
+	return g.IsCanceled || g.Error != "" || g.Manifest != nil || g.Progress == 100 ||
+		!g.EndTime.IsZero() || g.FilesUploaded > 0
+}
+
+// Matches tells whether generation passes the type and status filters.
+func (f *GenerationFilter) Matches(generation *Generation) bool {
+	if len(f.Types) > 0 && !containsString(f.Types, generation.Type) {
+		return false
+	}
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	status := generation.Status()
+	for _, want := range f.Statuses {
+		if status == want {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinished tells whether the generation stopped running.
+func (g *Generation) IsFinished() bool {
+	return g.Status() != GenerationRunning
+}
+
+// IsEnabled tells whether the policy limits anything.
+func (p *RetentionPolicy) IsEnabled() bool {
+	return p.MaxAge > 0 || p.MaxCount > 0
+}
+
+// Expired tells whether generation is past the policy. position is the
+// place of generation among the finished generations of its type, newest
+// first, starting at 1.
+func (p *RetentionPolicy) Expired(generation *Generation, position uint, now time.Time) bool {
+	if p.MaxCount > 0 && position > p.MaxCount {
+		return true
+	}
+	return p.MaxAge > 0 && generation.StartTime.Before(now.Add(-p.MaxAge))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
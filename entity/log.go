@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+const (
+	// LogGenerationID and LogGenerationType are the fields tagging the log
+	// lines of a generation.
+	LogGenerationID   = "generation_id"
+	LogGenerationType = "generation_type"
+)
+
+type (
+	// LogEntry is a line of the log of a generation.
+	LogEntry struct {
+		Time    time.Time
+		Level   string
+		Message string
+		Error   string
+		// Fields holds the other fields of the line.
+		Fields map[string]interface{}
+	}
+)
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+type (
+	Manifest struct {
+		GenerationID string
+		Type         string
+		StartTime    time.Time
+		CreatedTime  time.Time
+		Files        []*FileInfo
+	}
+
+	FileInfo struct {
+		Name         string
+		Partition    string
+		Size         int64
+		Records      uint
+		SHA256       string
+		UploadedTime time.Time
+	}
+)
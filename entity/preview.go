@@ -0,0 +1,41 @@
+package entity
+
+// MaxRejectedRecords is how many invalid records a preview keeps as samples.
+const MaxRejectedRecords = 10
+
+type (
+	// FeedPreview describes what a generation of Type would upload, without
+	// uploading anything.
+	FeedPreview struct {
+		Type string
+		// DryRun is set when every record was fetched and formatted, rather
+		// than the first rows only.
+		DryRun bool
+		// RecordsCount is the result of the count query, only run for a dry
+		// run.
+		RecordsCount   uint
+		Records        uint
+		InvalidRecords uint
+		Rejected       []*RejectedRecord
+		Files          []*PreviewFile
+	}
+
+	RejectedRecord struct {
+		Record []string
+		Error  string
+	}
+
+	PreviewFile struct {
+		*FileInfo
+		// Content is only set when the preview was limited to the first rows.
+		Content string
+	}
+)
+
+// Reject counts an invalid record and keeps the first ones as samples.
+func (p *FeedPreview) Reject(record []string, err error) {
+	p.InvalidRecords++
+	if len(p.Rejected) < MaxRejectedRecords {
+		p.Rejected = append(p.Rejected, &RejectedRecord{Record: record, Error: err.Error()})
+	}
+}
@@ -13,9 +13,13 @@ require (
 	github.com/gorilla/websocket v1.4.2
 	github.com/inhies/go-bytesize v0.0.0-20201103132853-d0aed0d254f8
 	github.com/jlaffaye/ftp v0.0.0-20201112195030-9aae4d151126
+	github.com/johannesboyne/gofakes3 v0.0.0-20210217223559-02ffa763be97
+	github.com/minio/minio-go/v7 v7.0.10
 	github.com/o4eredko/configuro v0.0.3
+	github.com/pkg/sftp v1.13.0
 	github.com/robfig/cron/v3 v3.0.0
 	github.com/rs/zerolog v1.20.0
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
 	golang.org/x/tools v0.0.0-20200612220849-54c614fe050c
 )
@@ -3,6 +3,7 @@ package config
 import (
 	"path"
 	"runtime"
+	"time"
 
 	"github.com/o4eredko/configuro"
 	"golang.org/x/tools/go/types/objectpath"
@@ -18,18 +19,74 @@ type (
 		SelectQueryFilename string `config:"select_query"`
 		FileSizeLimit       string `config:"size_limit"`
 		FileLineLimit       uint   `config:"line_limit"`
+		PartitionBy         string `config:"partition_by"`
+		Destination         string
+		Destinations        []string
+		DestinationPolicy   string `config:"destination_policy"`
+		KeepVersions        uint   `config:"keep_versions"`
+		KeyPrefix           string `config:"key_prefix"`
+		Checkpoints         bool
 		Database            struct {
 			Driver string
 			Dsn    string
 		}
+		Csv CsvConfig
+	}
+
+	CsvConfig struct {
+		Delimiter    string
+		Quote        string
+		LineEnding   string `config:"line_ending"`
+		Bom          bool
+		RepeatHeader bool `config:"repeat_header"`
+	}
+
+	SpoolConfig struct {
+		Dir     string
+		MaxSize string `config:"max_size"`
+	}
+
+	// ArchiveConfig is where a copy of every uploaded file is kept; an empty
+	// Dir disables the archive.
+	ArchiveConfig struct {
+		Dir string
+	}
+
+	// RetentionConfig limits how long finished generations are kept: by age
+	// and by count per generation type. Zero disables a limit.
+	RetentionConfig struct {
+		MaxAge   time.Duration `config:"max_age"`
+		MaxCount uint          `config:"max_count"`
+		Interval time.Duration
+	}
+
+	// InstanceConfig identifies the process among the instances sharing the
+	// Redis; an empty ID falls back to the hostname.
+	InstanceConfig struct {
+		ID string
+	}
+
+	// StateConfig throttles how often the state of a running generation is
+	// stored; its final state is stored at once. Zero stores every change.
+	StateConfig struct {
+		Interval time.Duration
 	}
 
 	Config struct {
-		Logger logger.Config
-		Redis  gateway.RedisConfig
-		Ftp    gateway.FtpConfig
-		Feeds  map[string]FeedConfig
-		Api    rest.Config
+		Logger    logger.Config
+		Redis     gateway.RedisConfig
+		Ftp       gateway.FtpConfig
+		Sftp      gateway.SftpConfig
+		S3        gateway.S3Config
+		Local     gateway.LocalConfig
+		Http      gateway.HttpConfig
+		Spool     SpoolConfig
+		Archive   ArchiveConfig
+		Retention RetentionConfig
+		State     StateConfig
+		Instance  InstanceConfig
+		Feeds     map[string]FeedConfig
+		Api       rest.Config
 	}
 )
 
@@ -37,6 +37,11 @@ func TestLoadFromFile(t *testing.T) {
 				assert.NotEmpty(t, c.Ftp)
 				assert.NotEmpty(t, c.Redis)
 				assert.NotEmpty(t, c.Feeds)
+				// Retention deletes generations, so it is opt-in.
+				assert.Zero(t, c.Retention.MaxAge)
+				assert.Zero(t, c.Retention.MaxCount)
+				// So is the archive, it keeps a copy of every feed.
+				assert.Empty(t, c.Archive.Dir)
 			}
 			if tc.wantPanic {
 				assert.Panics(t, testFunc)
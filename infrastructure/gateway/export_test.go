@@ -1,9 +1,18 @@
 package gateway
 
-import "github.com/gomodule/redigo/redis"
+import (
+	"reflect"
 
+	"github.com/gomodule/redigo/redis"
+)
+
+// SetConnection puts connection in the pool as if Connect dialed it, paths
+// are left relative.
 func (f *FtpGateway) SetConnection(connection FtpConnection) {
-	f.connection = connection
+	if reflect.ValueOf(connection).IsNil() {
+		return
+	}
+	f.open("", connection)
 }
 
 func (r *RedisGateway) SetPool(pool *redis.Pool) {
@@ -2,11 +2,18 @@ package gateway
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
-	"reflect"
+	"io/ioutil"
+	"net/textproto"
+	"path"
+	"sync"
 	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
 type (
@@ -16,10 +23,31 @@ type (
 		ConnTimeout time.Duration `config:"conn_timeout"`
 		Username    string
 		Password    string
+		TLS         FtpTLSMode `config:"tls"`
+		// CAFile, CertFile and KeyFile are PEM files; the client certificate is
+		// only presented when both CertFile and KeyFile are set.
+		CAFile             string `config:"tls_ca"`
+		CertFile           string `config:"tls_cert"`
+		KeyFile            string `config:"tls_key"`
+		InsecureSkipVerify bool   `config:"tls_insecure_skip_verify"`
+		// PoolSize limits open control connections; concurrent generations
+		// wait for a free one.
+		PoolSize uint `config:"pool_size"`
+		// KeepAlive is how often idle connections send NOOP, zero disables it.
+		KeepAlive time.Duration `config:"keepalive"`
+		// MaxRetries is how many times a failed command is retried on a new
+		// connection, waiting RetryBackoff doubled on every attempt.
+		MaxRetries   uint          `config:"max_retries"`
+		RetryBackoff time.Duration `config:"retry_backoff"`
 	}
 
+	// FtpTLSMode selects how the control and data connections are secured.
+	// Explicit mode upgrades a plain connection with AUTH TLS, implicit mode
+	// speaks TLS from the first byte.
+	FtpTLSMode string
+
 	Dialer interface {
-		DialTimeout(addr string, timeout time.Duration) (FtpConnection, error)
+		DialTimeout(addr string, timeout time.Duration, mode FtpTLSMode, tlsConfig *tls.Config) (FtpConnection, error)
 	}
 
 	FtpConnection interface {
@@ -28,77 +56,415 @@ type (
 		MakeDir(path string) error
 		RemoveDirRecur(dir string) error
 		RemoveDir(dir string) error
+		Rename(from, to string) error
+		StorFrom(path string, r io.Reader, offset uint64) error
+		Append(path string, r io.Reader) error
+		FileSize(path string) (int64, error)
 		ChangeDir(path string) error
 		ChangeDirToParent() error
+		CurrentDir() (string, error)
+		NoOp() error
 		Quit() error
 	}
 
+	// Resumer is implemented by readers that can continue from an offset
+	// but have to keep state in sync, like checksums. Such readers and
+	// io.Seeker can be sent again or resumed when an upload fails.
+	Resumer interface {
+		ResumeAt(offset int64) error
+	}
+
+	// FtpGateway keeps a pool of control connections, since a connection
+	// can't be shared by concurrent generations. Every connection starts in
+	// the login directory, so paths are resolved against workDir.
 	FtpGateway struct {
-		Dialer     Dialer
-		Config     FtpConfig
-		connection FtpConnection
+		Dialer Dialer
+		Config FtpConfig
+
+		mode      FtpTLSMode
+		tlsConfig *tls.Config
+		mu        sync.Mutex
+		connected bool
+		workDir   string
+		// idle holds connections ready to use, slots holds a token for every
+		// open connection.
+		idle  chan FtpConnection
+		slots chan struct{}
+		stop  chan struct{}
+	}
+
+	// permanentError is a command error that retrying won't fix.
+	permanentError struct {
+		err error
 	}
 )
 
+const (
+	FtpTLSNone     FtpTLSMode = "none"
+	FtpTLSExplicit FtpTLSMode = "explicit"
+	FtpTLSImplicit FtpTLSMode = "implicit"
+
+	DefaultFtpPoolSize = 4
+)
+
 var (
 	ErrFtpDisconnected = errors.New("gateway is not connected to FTP")
+	ErrInvalidTLSMode  = errors.New("invalid FTP TLS mode")
+	ErrInvalidCA       = errors.New("no certificates found in CA file")
+	ErrSizeMismatch    = errors.New("remote file size doesn't match uploaded size")
 )
 
 func (c FtpConfig) Addr() string {
 	return fmt.Sprintf("%s:%s", c.Host, c.Port)
 }
 
+// TLSMode returns the configured mode, treating an empty value as FtpTLSNone.
+func (c FtpConfig) TLSMode() (FtpTLSMode, error) {
+	switch c.TLS {
+	case "", FtpTLSNone:
+		return FtpTLSNone, nil
+	case FtpTLSExplicit, FtpTLSImplicit:
+		return c.TLS, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidTLSMode, c.TLS)
+	}
+}
+
+// TLSConfig builds the client TLS config, or returns nil when TLS is disabled.
+func (c FtpConfig) TLSConfig() (*tls.Config, error) {
+	mode, err := c.TLSMode()
+	if err != nil || mode == FtpTLSNone {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         c.Host,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCA, c.CAFile)
+		}
+	}
+	if c.CertFile != "" && c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
 func (f *FtpGateway) Connect() error {
-	connection, err := f.Dialer.DialTimeout(f.Config.Addr(), f.Config.ConnTimeout)
+	mode, err := f.Config.TLSMode()
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := f.Config.TLSConfig()
+	if err != nil {
+		return err
+	}
+	f.mode, f.tlsConfig = mode, tlsConfig
+	// The first connection checks credentials and finds the login directory.
+	connection, err := f.dial()
 	if err != nil {
 		return err
 	}
-	err = connection.Login(f.Config.Username, f.Config.Password)
+	workDir, err := connection.CurrentDir()
 	if err != nil {
+		connection.Quit()
 		return err
 	}
-	f.connection = connection
+	f.open(workDir, connection)
+	if f.Config.KeepAlive > 0 {
+		go f.keepAlive(f.Config.KeepAlive, f.stop)
+	}
 	return nil
 }
 
+// Upload stores r and checks the remote size matches the sent one. Failed
+// uploads of seekable readers are resumed where the server supports it and
+// sent again from the start otherwise.
 func (f *FtpGateway) Upload(ctx context.Context, path string, r io.Reader) error {
-	if reflect.ValueOf(f.connection).IsNil() {
-		return ErrFtpDisconnected
+	_, resumer := r.(Resumer)
+	_, seeker := r.(io.Seeker)
+	upload := &ftpUpload{
+		ctx:       ctx,
+		path:      f.resolve(path),
+		r:         r,
+		seekable:  resumer || seeker,
+		resumable: resumer || seeker,
 	}
-	return f.connection.Stor(path, r)
+	return f.do(ctx, upload.send)
 }
 
 func (f *FtpGateway) MakeDir(dir string) error {
-	if reflect.ValueOf(f.connection).IsNil() {
-		return ErrFtpDisconnected
-	}
-	return f.connection.MakeDir(dir)
+	dir = f.resolve(dir)
+	return f.do(context.Background(), func(connection FtpConnection) error {
+		return connection.MakeDir(dir)
+	})
 }
 
 func (f *FtpGateway) RemoveDir(dir string) error {
-	if reflect.ValueOf(f.connection).IsNil() {
-		return ErrFtpDisconnected
+	dir = f.resolve(dir)
+	return f.do(context.Background(), func(connection FtpConnection) error {
+		connection.RemoveDirRecur(dir)
+		return connection.RemoveDir(dir)
+	})
+}
+
+func (f *FtpGateway) Rename(from, to string) error {
+	from, to = f.resolve(from), f.resolve(to)
+	return f.do(context.Background(), func(connection FtpConnection) error {
+		return connection.Rename(from, to)
+	})
+}
+
+// DirExists changes into dir on a pooled connection; paths are absolute, so
+// the working directory of the connection doesn't matter.
+func (f *FtpGateway) DirExists(dir string) (bool, error) {
+	dir = f.resolve(dir)
+	err := f.do(context.Background(), func(connection FtpConnection) error {
+		return connection.ChangeDir(dir)
+	})
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code == 550 {
+		return false, nil
 	}
-	f.connection.RemoveDirRecur(dir)
-	return f.connection.RemoveDir(dir)
+	return err == nil, err
 }
 
 func (f *FtpGateway) ChangeDir(dir string) error {
-	if reflect.ValueOf(f.connection).IsNil() {
-		return ErrFtpDisconnected
+	dir = f.resolve(dir)
+	err := f.do(context.Background(), func(connection FtpConnection) error {
+		return connection.ChangeDir(dir)
+	})
+	if err != nil {
+		return err
 	}
-	return f.connection.ChangeDir(dir)
+	f.mu.Lock()
+	f.workDir = dir
+	f.mu.Unlock()
+	return nil
 }
+
 func (f *FtpGateway) ChangeDirToParent() error {
-	if reflect.ValueOf(f.connection).IsNil() {
-		return ErrFtpDisconnected
-	}
-	return f.connection.ChangeDirToParent()
+	return f.ChangeDir("..")
 }
 
 func (f *FtpGateway) Disconnect() error {
-	if reflect.ValueOf(f.connection).IsNil() {
+	f.mu.Lock()
+	if !f.connected {
+		f.mu.Unlock()
 		return ErrFtpDisconnected
 	}
-	return f.connection.Quit()
+	f.connected = false
+	close(f.stop)
+	idle := f.drainIdle()
+	f.mu.Unlock()
+	var firstErr error
+	for _, connection := range idle {
+		if err := connection.Quit(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f *FtpGateway) open(workDir string, connection FtpConnection) {
+	size := f.Config.PoolSize
+	if size == 0 {
+		size = DefaultFtpPoolSize
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.idle = make(chan FtpConnection, size)
+	f.slots = make(chan struct{}, size)
+	f.stop = make(chan struct{})
+	f.workDir = workDir
+	f.connected = true
+	f.slots <- struct{}{}
+	f.idle <- connection
+}
+
+func (f *FtpGateway) dial() (FtpConnection, error) {
+	connection, err := f.Dialer.DialTimeout(f.Config.Addr(), f.Config.ConnTimeout, f.mode, f.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := connection.Login(f.Config.Username, f.Config.Password); err != nil {
+		connection.Quit()
+		return nil, err
+	}
+	return connection, nil
+}
+
+// do runs command on a pooled connection. Transient failures are retried
+// with backoff and broken connections are replaced by new ones.
+func (f *FtpGateway) do(ctx context.Context, command func(FtpConnection) error) error {
+	for attempt := uint(0); ; attempt++ {
+		err := f.try(ctx, command)
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if err == nil || !isTransient(err) || ctx.Err() != nil || attempt >= f.Config.MaxRetries {
+			return err
+		}
+		backoff := f.Config.RetryBackoff << attempt
+		log.Warn().Err(err).Msgf("FTP command failed, retrying in %s", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (f *FtpGateway) try(ctx context.Context, command func(FtpConnection) error) error {
+	connection, err := f.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = command(connection)
+	f.release(connection, err)
+	return err
+}
+
+// acquire takes an idle connection or dials a new one while the pool isn't
+// full, otherwise waits for a connection to be released.
+func (f *FtpGateway) acquire(ctx context.Context) (FtpConnection, error) {
+	f.mu.Lock()
+	connected, stop := f.connected, f.stop
+	f.mu.Unlock()
+	if !connected {
+		return nil, ErrFtpDisconnected
+	}
+	select {
+	case connection := <-f.idle:
+		return connection, nil
+	default:
+	}
+	select {
+	case connection := <-f.idle:
+		return connection, nil
+	case f.slots <- struct{}{}:
+		connection, err := f.dial()
+		if err != nil {
+			<-f.slots
+			return nil, err
+		}
+		return connection, nil
+	case <-stop:
+		return nil, ErrFtpDisconnected
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// release returns the connection to the pool, or closes it if err shows it
+// is no longer usable.
+func (f *FtpGateway) release(connection FtpConnection, err error) {
+	f.mu.Lock()
+	if f.connected && !isBroken(err) {
+		f.idle <- connection
+		f.mu.Unlock()
+		return
+	}
+	f.mu.Unlock()
+	connection.Quit()
+	<-f.slots
+}
+
+// drainIdle takes every idle connection out of the pool. Must be called
+// with mu held.
+func (f *FtpGateway) drainIdle() []FtpConnection {
+	var connections []FtpConnection
+	for {
+		select {
+		case connection := <-f.idle:
+			<-f.slots
+			connections = append(connections, connection)
+		default:
+			return connections
+		}
+	}
+}
+
+// keepAlive sends NOOP on idle connections so the server doesn't drop them.
+func (f *FtpGateway) keepAlive(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.pingIdle()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (f *FtpGateway) pingIdle() {
+	f.mu.Lock()
+	idle := make([]FtpConnection, len(f.idle))
+	for i := range idle {
+		idle[i] = <-f.idle
+	}
+	f.mu.Unlock()
+	for _, connection := range idle {
+		err := connection.NoOp()
+		if err != nil {
+			log.Debug().Err(err).Msg("FTP keepalive failed, closing connection")
+		}
+		f.release(connection, err)
+	}
+}
+
+func (f *FtpGateway) resolve(p string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join(f.workDir, p)
+}
+
+// isTransient reports whether a command may succeed if sent again: network
+// errors and 4xx replies are, 5xx replies are not.
+func isTransient(err error) bool {
+	if errors.Is(err, ErrFtpDisconnected) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code >= 400 && reply.Code < 500
+	}
+	return true
+}
+
+// isBroken reports whether the connection can't be used after err. A reply
+// means the connection is in sync, except 421 that closes it.
+func isBroken(err error) bool {
+	if err == nil {
+		return false
+	}
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code == 421
+	}
+	return !errors.Is(err, ErrSizeMismatch)
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
 }
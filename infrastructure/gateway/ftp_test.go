@@ -3,12 +3,20 @@ package gateway_test
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"go-feedmaker/infrastructure/gateway"
 	"go-feedmaker/infrastructure/gateway/mocks"
@@ -50,11 +58,12 @@ func TestFtpGateway_Connect(t *testing.T) {
 			fields: defaultFtpFields(),
 			setupMocks: func(f *ftpFields) {
 				f.dialer.
-					On("DialTimeout", f.config.Addr(), f.config.ConnTimeout).
+					On("DialTimeout", f.config.Addr(), f.config.ConnTimeout, gateway.FtpTLSNone, (*tls.Config)(nil)).
 					Return(f.connection, nil)
 				f.connection.
 					On("Login", f.config.Username, f.config.Password).
 					Return(nil)
+				f.connection.On("CurrentDir").Return("/", nil)
 			},
 		},
 		{
@@ -62,7 +71,7 @@ func TestFtpGateway_Connect(t *testing.T) {
 			fields: defaultFtpFields(),
 			setupMocks: func(f *ftpFields) {
 				f.dialer.
-					On("DialTimeout", f.config.Addr(), f.config.ConnTimeout).
+					On("DialTimeout", f.config.Addr(), f.config.ConnTimeout, gateway.FtpTLSNone, (*tls.Config)(nil)).
 					Return(nil, defaultErr)
 			},
 			wantErr: defaultErr,
@@ -72,11 +81,27 @@ func TestFtpGateway_Connect(t *testing.T) {
 			fields: defaultFtpFields(),
 			setupMocks: func(f *ftpFields) {
 				f.dialer.
-					On("DialTimeout", f.config.Addr(), f.config.ConnTimeout).
+					On("DialTimeout", f.config.Addr(), f.config.ConnTimeout, gateway.FtpTLSNone, (*tls.Config)(nil)).
 					Return(f.connection, nil)
 				f.connection.
 					On("Login", f.config.Username, f.config.Password).
 					Return(defaultErr)
+				f.connection.On("Quit").Return(nil)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name:   "current dir error",
+			fields: defaultFtpFields(),
+			setupMocks: func(f *ftpFields) {
+				f.dialer.
+					On("DialTimeout", f.config.Addr(), f.config.ConnTimeout, gateway.FtpTLSNone, (*tls.Config)(nil)).
+					Return(f.connection, nil)
+				f.connection.
+					On("Login", f.config.Username, f.config.Password).
+					Return(nil)
+				f.connection.On("CurrentDir").Return("", defaultErr)
+				f.connection.On("Quit").Return(nil)
 			},
 			wantErr: defaultErr,
 		},
@@ -127,7 +152,12 @@ func TestFtpGateway_Upload(t *testing.T) {
 				connection: new(mocks.FtpConnection),
 			},
 			setupMocks: func(a *args, f *ftpFields) {
-				f.connection.On("Stor", a.path, a.r).Return(nil)
+				f.connection.On("Stor", a.path, mock.Anything).
+					Run(func(args mock.Arguments) {
+						ioutil.ReadAll(args.Get(1).(io.Reader))
+					}).
+					Return(nil)
+				f.connection.On("FileSize", a.path).Return(int64(4), nil)
 			},
 		},
 		{
@@ -149,7 +179,8 @@ func TestFtpGateway_Upload(t *testing.T) {
 				connection: new(mocks.FtpConnection),
 			},
 			setupMocks: func(a *args, f *ftpFields) {
-				f.connection.On("Stor", a.path, a.r).Return(defaultErr)
+				f.connection.On("Stor", a.path, mock.Anything).Return(defaultErr)
+				f.connection.On("Quit").Return(nil)
 			},
 			wantErr: defaultErr,
 		},
@@ -174,6 +205,377 @@ func TestFtpGateway_Upload(t *testing.T) {
 	}
 }
 
+// resumableReader records offsets an upload is resumed at.
+type resumableReader struct {
+	*strings.Reader
+	resumedAt []int64
+}
+
+func (r *resumableReader) ResumeAt(offset int64) error {
+	r.resumedAt = append(r.resumedAt, offset)
+	_, err := r.Seek(offset, io.SeekStart)
+	return err
+}
+
+// ftpTransfer is how the server handles a transfer: it keeps the first keep
+// bytes, all of them when keep is negative, and replies with err. An unread
+// transfer fails before any data is sent.
+type ftpTransfer struct {
+	keep   int
+	err    error
+	unread bool
+}
+
+func TestFtpGateway_Upload_Retry(t *testing.T) {
+	transientErr := &textproto.Error{Code: 451, Msg: "local error"}
+	permanentErr := &textproto.Error{Code: 553, Msg: "file name not allowed"}
+	notImplementedErr := &textproto.Error{Code: 502, Msg: "command not implemented"}
+	resumable := func() io.Reader { return &resumableReader{Reader: strings.NewReader("test")} }
+	testCases := []struct {
+		name      string
+		r         func() io.Reader
+		transfers []ftpTransfer
+		restErr   error
+		appendErr error
+		wantErr   error
+		wantCalls []string
+		wantDials int
+		// wantResumedAt are the offsets a resumable reader was moved to.
+		wantResumedAt []int64
+	}{
+		{
+			name:          "resumes with REST after dropped connection",
+			r:             resumable,
+			transfers:     []ftpTransfer{{keep: 2, err: io.ErrUnexpectedEOF}},
+			wantCalls:     []string{"Stor", "StorFrom 2"},
+			wantDials:     2,
+			wantResumedAt: []int64{2},
+		},
+		{
+			name:          "resumes with APPE when REST is not supported",
+			r:             resumable,
+			transfers:     []ftpTransfer{{keep: 3, err: transientErr}},
+			restErr:       notImplementedErr,
+			wantCalls:     []string{"Stor", "Append"},
+			wantDials:     1,
+			wantResumedAt: []int64{3},
+		},
+		{
+			name:          "sends again when resume is not supported",
+			r:             resumable,
+			transfers:     []ftpTransfer{{keep: 3, err: transientErr}},
+			restErr:       notImplementedErr,
+			appendErr:     notImplementedErr,
+			wantCalls:     []string{"Stor", "Stor"},
+			wantDials:     1,
+			wantResumedAt: []int64{3, 0},
+		},
+		{
+			name:          "resumes after size mismatch",
+			r:             resumable,
+			transfers:     []ftpTransfer{{keep: 1}},
+			wantCalls:     []string{"Stor", "StorFrom 1"},
+			wantDials:     1,
+			wantResumedAt: []int64{1},
+		},
+		{
+			name:      "seeker is resumed",
+			r:         func() io.Reader { return strings.NewReader("test") },
+			transfers: []ftpTransfer{{keep: 2, err: transientErr}},
+			wantCalls: []string{"Stor", "StorFrom 2"},
+			wantDials: 1,
+		},
+		{
+			name:      "reconnects before sending data of a reader that can't seek",
+			r:         func() io.Reader { return bytes.NewBufferString("test") },
+			transfers: []ftpTransfer{{err: io.ErrUnexpectedEOF, unread: true}},
+			wantCalls: []string{"Stor", "Stor"},
+			wantDials: 2,
+		},
+		{
+			name:      "reader that can't seek is not sent again",
+			r:         func() io.Reader { return bytes.NewBufferString("test") },
+			transfers: []ftpTransfer{{keep: 2, err: transientErr}},
+			wantErr:   transientErr,
+			wantCalls: []string{"Stor"},
+			wantDials: 1,
+		},
+		{
+			name:      "gives up after max retries",
+			r:         resumable,
+			transfers: []ftpTransfer{{keep: 0, err: transientErr}, {keep: 0, err: transientErr}, {keep: 0, err: transientErr}},
+			wantErr:   transientErr,
+			wantCalls: []string{"Stor", "Stor", "Stor"},
+			wantDials: 1,
+			// Every failed attempt but the last one starts over.
+			wantResumedAt: []int64{0, 0},
+		},
+		{
+			name:      "permanent reply is not retried",
+			r:         resumable,
+			transfers: []ftpTransfer{{keep: 0, err: permanentErr}},
+			wantErr:   permanentErr,
+			wantCalls: []string{"Stor"},
+			wantDials: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := defaultFtpFields()
+			f.config.MaxRetries = 2
+			dials := 0
+			f.dialer.
+				On("DialTimeout", f.config.Addr(), f.config.ConnTimeout, gateway.FtpTLSNone, (*tls.Config)(nil)).
+				Run(func(mock.Arguments) { dials++ }).
+				Return(f.connection, nil)
+			f.connection.On("Login", f.config.Username, f.config.Password).Return(nil)
+			f.connection.On("CurrentDir").Return("/home/feeds", nil)
+			f.connection.On("Quit").Return(nil)
+			const path = "/home/feeds/test/test_0.csv"
+			var remote []byte
+			var gotCalls []string
+			transfers := 0
+			transfer := func(call string, r io.Reader) error {
+				gotCalls = append(gotCalls, call)
+				step := ftpTransfer{keep: -1}
+				if transfers < len(tc.transfers) {
+					step = tc.transfers[transfers]
+				}
+				transfers++
+				if step.unread {
+					return step.err
+				}
+				data, err := ioutil.ReadAll(r)
+				require.NoError(t, err)
+				if step.keep >= 0 && step.keep < len(data) {
+					data = data[:step.keep]
+				}
+				remote = append(remote, data...)
+				return step.err
+			}
+			f.connection.On("Stor", path, mock.Anything).
+				Return(func(_ string, r io.Reader) error {
+					remote = nil
+					return transfer("Stor", r)
+				})
+			f.connection.On("StorFrom", path, mock.Anything, mock.Anything).
+				Return(func(_ string, r io.Reader, offset uint64) error {
+					if tc.restErr != nil {
+						return tc.restErr
+					}
+					remote = remote[:offset]
+					return transfer(fmt.Sprintf("StorFrom %d", offset), r)
+				})
+			f.connection.On("Append", path, mock.Anything).
+				Return(func(_ string, r io.Reader) error {
+					if tc.appendErr != nil {
+						return tc.appendErr
+					}
+					return transfer("Append", r)
+				})
+			f.connection.On("FileSize", path).
+				Return(func(string) int64 { return int64(len(remote)) }, nil)
+			ftpGateway := gateway.FtpGateway{Dialer: f.dialer, Config: f.config}
+			require.NoError(t, ftpGateway.Connect())
+			r := tc.r()
+
+			gotErr := ftpGateway.Upload(context.Background(), "test/test_0.csv", r)
+
+			assert.Equal(t, tc.wantErr, gotErr)
+			if tc.wantErr == nil {
+				assert.Equal(t, "test", string(remote))
+			}
+			assert.Equal(t, tc.wantCalls, gotCalls)
+			assert.Equal(t, tc.wantDials, dials)
+			if resumable, ok := r.(*resumableReader); ok {
+				assert.Equal(t, tc.wantResumedAt, resumable.resumedAt)
+			}
+		})
+	}
+}
+
+func TestFtpGateway_Pool(t *testing.T) {
+	f := defaultFtpFields()
+	f.config.PoolSize = 1
+	f.dialer.
+		On("DialTimeout", f.config.Addr(), f.config.ConnTimeout, gateway.FtpTLSNone, (*tls.Config)(nil)).
+		Return(f.connection, nil).
+		Once()
+	f.connection.On("Login", f.config.Username, f.config.Password).Return(nil)
+	f.connection.On("CurrentDir").Return("/", nil)
+	var mu sync.Mutex
+	inUse, maxInUse := 0, 0
+	f.connection.On("MakeDir", mock.Anything).
+		Run(func(mock.Arguments) {
+			mu.Lock()
+			inUse++
+			if inUse > maxInUse {
+				maxInUse = inUse
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			inUse--
+			mu.Unlock()
+		}).
+		Return(nil)
+	ftpGateway := gateway.FtpGateway{Dialer: f.dialer, Config: f.config}
+	require.NoError(t, ftpGateway.Connect())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, ftpGateway.MakeDir("test"))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, maxInUse)
+	f.dialer.AssertExpectations(t)
+	f.connection.AssertNumberOfCalls(t, "MakeDir", 4)
+}
+
+func TestFtpGateway_KeepAlive(t *testing.T) {
+	f := defaultFtpFields()
+	f.config.KeepAlive = time.Millisecond
+	f.dialer.
+		On("DialTimeout", f.config.Addr(), f.config.ConnTimeout, gateway.FtpTLSNone, (*tls.Config)(nil)).
+		Return(f.connection, nil)
+	f.connection.On("Login", f.config.Username, f.config.Password).Return(nil)
+	f.connection.On("CurrentDir").Return("/", nil)
+	noOps := make(chan struct{}, 1)
+	f.connection.On("NoOp").
+		Run(func(mock.Arguments) {
+			select {
+			case noOps <- struct{}{}:
+			default:
+			}
+		}).
+		Return(nil)
+	f.connection.On("Quit").Return(nil)
+	ftpGateway := gateway.FtpGateway{Dialer: f.dialer, Config: f.config}
+	require.NoError(t, ftpGateway.Connect())
+
+	select {
+	case <-noOps:
+	case <-time.After(time.Second):
+		t.Fatal("no NOOP sent on idle connection")
+	}
+	assert.NoError(t, ftpGateway.Disconnect())
+}
+
+func TestFtpGateway_Rename(t *testing.T) {
+	testCases := []struct {
+		name       string
+		fields     *ftpFields
+		setupMocks func(f *ftpFields)
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			fields: &ftpFields{
+				dialer:     new(mocks.Dialer),
+				connection: new(mocks.FtpConnection),
+			},
+			setupMocks: func(f *ftpFields) {
+				f.connection.On("Rename", "test.tmp-42", "test").Return(nil)
+			},
+		},
+		{
+			name: "disconnected error",
+			fields: &ftpFields{
+				dialer: new(mocks.Dialer),
+			},
+			setupMocks: func(f *ftpFields) {},
+			wantErr:    gateway.ErrFtpDisconnected,
+		},
+		{
+			name: "Rename error",
+			fields: &ftpFields{
+				dialer:     new(mocks.Dialer),
+				connection: new(mocks.FtpConnection),
+			},
+			setupMocks: func(f *ftpFields) {
+				f.connection.On("Rename", "test.tmp-42", "test").Return(defaultErr)
+				f.connection.On("Quit").Return(nil)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setupMocks(testCase.fields)
+			ftpGateway := gateway.FtpGateway{Dialer: testCase.fields.dialer}
+			ftpGateway.SetConnection(testCase.fields.connection)
+
+			gotErr := ftpGateway.Rename("test.tmp-42", "test")
+
+			assert.Equal(t, testCase.wantErr, gotErr)
+			if testCase.fields.connection != nil {
+				testCase.fields.connection.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestFtpGateway_DirExists(t *testing.T) {
+	testCases := []struct {
+		name       string
+		fields     *ftpFields
+		setupMocks func(f *ftpFields)
+		want       bool
+		wantErr    error
+	}{
+		{
+			name: "exists",
+			fields: &ftpFields{
+				dialer:     new(mocks.Dialer),
+				connection: new(mocks.FtpConnection),
+			},
+			setupMocks: func(f *ftpFields) {
+				f.connection.On("ChangeDir", "test.tmp-42").Return(nil)
+			},
+			want: true,
+		},
+		{
+			name: "missing",
+			fields: &ftpFields{
+				dialer:     new(mocks.Dialer),
+				connection: new(mocks.FtpConnection),
+			},
+			setupMocks: func(f *ftpFields) {
+				f.connection.On("ChangeDir", "test.tmp-42").
+					Return(&textproto.Error{Code: 550, Msg: "No such file or directory"})
+			},
+		},
+		{
+			name: "disconnected error",
+			fields: &ftpFields{
+				dialer: new(mocks.Dialer),
+			},
+			setupMocks: func(f *ftpFields) {},
+			wantErr:    gateway.ErrFtpDisconnected,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setupMocks(testCase.fields)
+			ftpGateway := gateway.FtpGateway{Dialer: testCase.fields.dialer}
+			ftpGateway.SetConnection(testCase.fields.connection)
+
+			got, gotErr := ftpGateway.DirExists("test.tmp-42")
+
+			assert.Equal(t, testCase.wantErr, gotErr)
+			assert.Equal(t, testCase.want, got)
+			if testCase.fields.connection != nil {
+				testCase.fields.connection.AssertExpectations(t)
+			}
+		})
+	}
+}
+
 func TestFtpGateway_Disconnect(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -234,3 +636,52 @@ func TestFtpGateway_Disconnect(t *testing.T) {
 		})
 	}
 }
+
+func TestFtpConfig_TLSConfig(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  gateway.FtpConfig
+		wantNil bool
+		wantErr error
+	}{
+		{
+			name:    "disabled by default",
+			config:  gateway.FtpConfig{},
+			wantNil: true,
+		},
+		{
+			name:    "none",
+			config:  gateway.FtpConfig{TLS: gateway.FtpTLSNone},
+			wantNil: true,
+		},
+		{
+			name:   "explicit",
+			config: gateway.FtpConfig{Host: "ftp.example.com", TLS: gateway.FtpTLSExplicit},
+		},
+		{
+			name:    "invalid mode",
+			config:  gateway.FtpConfig{TLS: "starttls"},
+			wantNil: true,
+			wantErr: gateway.ErrInvalidTLSMode,
+		},
+		{
+			name:    "ca without certificates",
+			config:  gateway.FtpConfig{TLS: gateway.FtpTLSImplicit, CAFile: "ftp_test.go"},
+			wantNil: true,
+			wantErr: gateway.ErrInvalidCA,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.config.TLSConfig()
+
+			assert.True(t, errors.Is(err, tc.wantErr))
+			if tc.wantNil {
+				assert.Nil(t, got)
+				return
+			}
+			assert.Equal(t, tc.config.Host, got.ServerName)
+			assert.Equal(t, tc.config.InsecureSkipVerify, got.InsecureSkipVerify)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// ftpUpload is a single file upload that survives failed attempts.
+	ftpUpload struct {
+		ctx  context.Context
+		path string
+		r    io.Reader
+		// sent is the offset r was read to by the last attempt.
+		sent      int64
+		seekable  bool
+		resumable bool
+	}
+
+	// uploadReader counts sent bytes and remembers a read error, which must
+	// not be retried.
+	uploadReader struct {
+		r   io.Reader
+		n   int64
+		err error
+	}
+)
+
+var (
+	errResumeNotSupported = errors.New("server supports neither REST nor APPE")
+)
+
+func (u *ftpUpload) send(connection FtpConnection) error {
+	offset, err := u.offset(connection)
+	if err != nil {
+		return err
+	}
+	reader := &uploadReader{r: &ctxReader{ctx: u.ctx, r: u.r}}
+	if offset > 0 {
+		err = u.resume(connection, reader, offset)
+		if errors.Is(err, errResumeNotSupported) {
+			log.Debug().Msgf("Cannot resume %s, sending it again", u.path)
+			u.resumable = false
+			if err := u.seek(0); err != nil {
+				return err
+			}
+			offset = 0
+		}
+	}
+	if offset == 0 {
+		err = connection.Stor(u.path, reader)
+	}
+	u.sent = offset + reader.n
+	if err != nil {
+		if reader.err != nil {
+			return &permanentError{reader.err}
+		}
+		if u.sent > 0 && !u.seekable {
+			return &permanentError{err}
+		}
+		return err
+	}
+	return u.verify(connection)
+}
+
+// offset moves r to where the next attempt starts: the remote size when the
+// upload can be resumed, the start otherwise.
+func (u *ftpUpload) offset(connection FtpConnection) (int64, error) {
+	if u.sent == 0 {
+		return 0, nil
+	}
+	if u.resumable {
+		size, err := connection.FileSize(u.path)
+		if err == nil && size > 0 && size <= u.sent {
+			return size, u.seek(size)
+		}
+	}
+	return 0, u.seek(0)
+}
+
+// resume sends the rest of the file with REST and STOR, or with APPE when
+// the server doesn't support REST.
+func (u *ftpUpload) resume(connection FtpConnection, reader io.Reader, offset int64) error {
+	err := connection.StorFrom(u.path, reader, uint64(offset))
+	if !isNotImplemented(err) {
+		return err
+	}
+	err = connection.Append(u.path, reader)
+	if !isNotImplemented(err) {
+		return err
+	}
+	return errResumeNotSupported
+}
+
+func (u *ftpUpload) verify(connection FtpConnection) error {
+	size, err := connection.FileSize(u.path)
+	if isNotImplemented(err) {
+		log.Debug().Msgf("Cannot verify size of %s, server doesn't support SIZE", u.path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if size != u.sent {
+		return fmt.Errorf("%w: %s has %d bytes, sent %d", ErrSizeMismatch, u.path, size, u.sent)
+	}
+	return nil
+}
+
+func (u *ftpUpload) seek(offset int64) error {
+	var err error
+	switch r := u.r.(type) {
+	case Resumer:
+		err = r.ResumeAt(offset)
+	case io.Seeker:
+		_, err = r.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		return &permanentError{err}
+	}
+	return nil
+}
+
+// isNotImplemented reports whether the server rejected a command it doesn't
+// know or support.
+func isNotImplemented(err error) bool {
+	var reply *textproto.Error
+	if !errors.As(err, &reply) {
+		return false
+	}
+	return reply.Code == 500 || reply.Code == 502 || reply.Code == 504
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,132 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+type (
+	HttpConfig struct {
+		URL string
+		// Method is PUT (default) or POST.
+		Method      string
+		Headers     map[string]string
+		Username    string
+		Password    string
+		BearerToken string `config:"bearer_token"`
+		Timeout     time.Duration
+	}
+
+	Requester interface {
+		Do(req *http.Request) (*http.Response, error)
+	}
+
+	// HttpGateway sends every file in its own request to Config.URL joined
+	// with the file path. HTTP has no directories, so directory operations
+	// only track the working directory files are resolved against.
+	HttpGateway struct {
+		Requester Requester
+		Config    HttpConfig
+		workDir   string
+	}
+)
+
+var (
+	ErrUnexpectedStatus  = errors.New("unexpected response status")
+	ErrInvalidHttpMethod = errors.New("invalid HTTP upload method")
+)
+
+const maxErrorBodySize = 512
+
+func (c HttpConfig) UploadMethod() (string, error) {
+	switch strings.ToUpper(c.Method) {
+	case "", http.MethodPut:
+		return http.MethodPut, nil
+	case http.MethodPost:
+		return http.MethodPost, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidHttpMethod, c.Method)
+	}
+}
+
+func (h *HttpGateway) Upload(ctx context.Context, filePath string, r io.Reader) error {
+	method, err := h.Config.UploadMethod()
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, method, h.url(filePath), r)
+	if err != nil {
+		return err
+	}
+	for key, value := range h.Config.Headers {
+		request.Header.Set(key, value)
+	}
+	if request.Header.Get("Content-Type") == "" {
+		if contentType := mime.TypeByExtension(path.Ext(filePath)); contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		} else {
+			request.Header.Set("Content-Type", "application/octet-stream")
+		}
+	}
+	if h.Config.BearerToken != "" {
+		request.Header.Set("Authorization", "Bearer "+h.Config.BearerToken)
+	} else if h.Config.Username != "" {
+		request.SetBasicAuth(h.Config.Username, h.Config.Password)
+	}
+
+	response, err := h.Requester.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBodySize))
+		return fmt.Errorf("%w: %s %s: %s %s", ErrUnexpectedStatus, method, request.URL, response.Status, body)
+	}
+	_, err = io.Copy(ioutil.Discard, response.Body)
+	return err
+}
+
+func (h *HttpGateway) MakeDir(dir string) error {
+	return nil
+}
+
+func (h *HttpGateway) RemoveDir(dir string) error {
+	return nil
+}
+
+func (h *HttpGateway) ChangeDir(dir string) error {
+	h.workDir = h.resolve(dir)
+	return nil
+}
+
+func (h *HttpGateway) ChangeDirToParent() error {
+	return h.ChangeDir("..")
+}
+
+func (h *HttpGateway) resolve(p string) string {
+	if h.workDir == "" {
+		h.workDir = "/"
+	}
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join(h.workDir, p)
+}
+
+func (h *HttpGateway) url(filePath string) string {
+	segments := strings.Split(strings.TrimPrefix(h.resolve(filePath), "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.TrimRight(h.Config.URL, "/") + "/" + strings.Join(segments, "/")
+}
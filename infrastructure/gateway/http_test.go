@@ -0,0 +1,112 @@
+package gateway_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-feedmaker/infrastructure/gateway"
+)
+
+type receivedRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   string
+}
+
+func startHttpServer(t *testing.T, status int) (string, <-chan *receivedRequest) {
+	requests := make(chan *receivedRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests <- &receivedRequest{method: r.Method, path: r.URL.EscapedPath(), header: r.Header, body: string(body)}
+		w.WriteHeader(status)
+		w.Write([]byte("quota exceeded"))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, requests
+}
+
+func TestHttpGateway_Upload(t *testing.T) {
+	testCases := []struct {
+		name        string
+		status      int
+		setupConfig func(c *gateway.HttpConfig)
+		check       func(t *testing.T, r *receivedRequest)
+		wantErr     error
+	}{
+		{
+			name:        "put by default",
+			status:      http.StatusCreated,
+			setupConfig: func(c *gateway.HttpConfig) {},
+			check: func(t *testing.T, r *receivedRequest) {
+				assert.Equal(t, http.MethodPut, r.method)
+				assert.Equal(t, "/upload/criteo/DE%20AT/criteo_0.csv", r.path)
+				assert.Equal(t, "text/csv; charset=utf-8", r.header.Get("Content-Type"))
+				assert.Equal(t, "id\n1\n", r.body)
+			},
+		},
+		{
+			name:   "post with headers and basic auth",
+			status: http.StatusOK,
+			setupConfig: func(c *gateway.HttpConfig) {
+				c.Method = "post"
+				c.Headers = map[string]string{"x-feed-source": "feedmaker", "content-type": "text/plain"}
+				c.Username = "user"
+				c.Password = "pass"
+			},
+			check: func(t *testing.T, r *receivedRequest) {
+				assert.Equal(t, http.MethodPost, r.method)
+				assert.Equal(t, "feedmaker", r.header.Get("X-Feed-Source"))
+				assert.Equal(t, "text/plain", r.header.Get("Content-Type"))
+				assert.Equal(t, "Basic dXNlcjpwYXNz", r.header.Get("Authorization"))
+			},
+		},
+		{
+			name:        "bearer token",
+			status:      http.StatusNoContent,
+			setupConfig: func(c *gateway.HttpConfig) { c.BearerToken = "token" },
+			check: func(t *testing.T, r *receivedRequest) {
+				assert.Equal(t, "Bearer token", r.header.Get("Authorization"))
+			},
+		},
+		{
+			name:        "unexpected status",
+			status:      http.StatusInsufficientStorage,
+			setupConfig: func(c *gateway.HttpConfig) {},
+			check:       func(t *testing.T, r *receivedRequest) {},
+			wantErr:     gateway.ErrUnexpectedStatus,
+		},
+		{
+			name:        "invalid method",
+			setupConfig: func(c *gateway.HttpConfig) { c.Method = "PATCH" },
+			wantErr:     gateway.ErrInvalidHttpMethod,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			url, requests := startHttpServer(t, tc.status)
+			httpGateway := &gateway.HttpGateway{
+				Requester: http.DefaultClient,
+				Config:    gateway.HttpConfig{URL: url + "/upload/"},
+			}
+			tc.setupConfig(&httpGateway.Config)
+			require.NoError(t, httpGateway.ChangeDir("criteo"))
+
+			err := httpGateway.Upload(context.Background(), "DE AT/criteo_0.csv", bytes.NewBufferString("id\n1\n"))
+
+			assert.True(t, errors.Is(err, tc.wantErr), "got %v", err)
+			if tc.check != nil {
+				tc.check(t, <-requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+type (
+	LocalConfig struct {
+		Dir string
+	}
+
+	// LocalGateway stores feeds in a directory tree on the local filesystem.
+	// Paths are resolved inside Config.Dir and can never escape it.
+	LocalGateway struct {
+		Config  LocalConfig
+		workDir string
+	}
+)
+
+var (
+	ErrRemoveRoot = errors.New("cannot remove root directory")
+)
+
+func (l *LocalGateway) Connect() error {
+	if err := os.MkdirAll(l.Config.Dir, 0755); err != nil {
+		return err
+	}
+	l.workDir = "/"
+	return nil
+}
+
+// Upload writes into a temporary file next to the target and renames it into
+// place, so readers never see a partially written file.
+func (l *LocalGateway) Upload(ctx context.Context, filePath string, r io.Reader) error {
+	target := l.resolve(filePath)
+	file, err := ioutil.TempFile(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, &ctxReader{ctx: ctx, r: r}); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), target)
+}
+
+func (l *LocalGateway) MakeDir(dir string) error {
+	return os.Mkdir(l.resolve(dir), 0755)
+}
+
+func (l *LocalGateway) RemoveDir(dir string) error {
+	if l.virtualPath(dir) == "/" {
+		return ErrRemoveRoot
+	}
+	return os.RemoveAll(l.resolve(dir))
+}
+
+func (l *LocalGateway) Rename(from, to string) error {
+	return os.Rename(l.resolve(from), l.resolve(to))
+}
+
+func (l *LocalGateway) DirExists(dir string) (bool, error) {
+	info, err := os.Stat(l.resolve(dir))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+func (l *LocalGateway) ChangeDir(dir string) error {
+	newWorkDir := l.virtualPath(dir)
+	info, err := os.Stat(l.resolve(dir))
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &os.PathError{Op: "chdir", Path: newWorkDir, Err: ErrNotADirectory}
+	}
+	l.workDir = newWorkDir
+	return nil
+}
+
+func (l *LocalGateway) ChangeDirToParent() error {
+	return l.ChangeDir("..")
+}
+
+// virtualPath resolves p against the working directory as if Config.Dir were
+// the filesystem root; cleaning an absolute path drops any leading "..".
+func (l *LocalGateway) virtualPath(p string) string {
+	if l.workDir == "" {
+		l.workDir = "/"
+	}
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join(l.workDir, p)
+}
+
+func (l *LocalGateway) resolve(p string) string {
+	return filepath.Join(l.Config.Dir, filepath.FromSlash(l.virtualPath(p)))
+}
@@ -0,0 +1,101 @@
+package gateway_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-feedmaker/infrastructure/gateway"
+)
+
+func connectLocalGateway(t *testing.T) (*gateway.LocalGateway, string) {
+	root := filepath.Join(t.TempDir(), "feeds")
+	localGateway := &gateway.LocalGateway{Config: gateway.LocalConfig{Dir: root}}
+	require.NoError(t, localGateway.Connect())
+	return localGateway, root
+}
+
+func TestLocalGateway_Upload(t *testing.T) {
+	localGateway, root := connectLocalGateway(t)
+	require.NoError(t, localGateway.MakeDir("criteo"))
+	require.NoError(t, localGateway.MakeDir("criteo/DE"))
+
+	err := localGateway.Upload(context.Background(), "criteo/DE/criteo_0.csv", bytes.NewBufferString("id\n1\n"))
+
+	require.NoError(t, err)
+	content, err := ioutil.ReadFile(filepath.Join(root, "criteo", "DE", "criteo_0.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, "id\n1\n", string(content))
+	entries, err := ioutil.ReadDir(filepath.Join(root, "criteo", "DE"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file must be renamed")
+}
+
+func TestLocalGateway_UploadCanceled(t *testing.T) {
+	localGateway, root := connectLocalGateway(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := localGateway.Upload(ctx, "criteo_0.csv", bytes.NewBufferString("id\n1\n"))
+
+	assert.Equal(t, context.Canceled, err)
+	entries, err := ioutil.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestLocalGateway_StaysInsideRoot(t *testing.T) {
+	localGateway, root := connectLocalGateway(t)
+
+	require.NoError(t, localGateway.Upload(context.Background(), "../../escape.csv", bytes.NewBufferString("x")))
+	require.NoError(t, localGateway.ChangeDirToParent())
+
+	_, err := os.Stat(filepath.Join(root, "escape.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, gateway.ErrRemoveRoot, localGateway.RemoveDir(".."))
+}
+
+func TestLocalGateway_Dirs(t *testing.T) {
+	localGateway, root := connectLocalGateway(t)
+	require.NoError(t, localGateway.MakeDir("criteo"))
+	require.NoError(t, localGateway.Upload(context.Background(), "criteo/criteo_0.csv", bytes.NewBufferString("x")))
+
+	assert.Error(t, localGateway.MakeDir("criteo"))
+	exists, err := localGateway.DirExists("criteo")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = localGateway.DirExists("criteo/criteo_0.csv")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Error(t, localGateway.ChangeDir("criteo/criteo_0.csv"))
+	require.NoError(t, localGateway.ChangeDir("criteo"))
+	require.NoError(t, localGateway.Upload(context.Background(), "criteo_1.csv", bytes.NewBufferString("y")))
+	_, err = os.Stat(filepath.Join(root, "criteo", "criteo_1.csv"))
+	assert.NoError(t, err)
+	require.NoError(t, localGateway.ChangeDirToParent())
+	require.NoError(t, localGateway.RemoveDir("criteo"))
+	_, err = os.Stat(filepath.Join(root, "criteo"))
+	assert.True(t, os.IsNotExist(err))
+	exists, err = localGateway.DirExists("criteo")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestLocalGateway_Rename(t *testing.T) {
+	localGateway, root := connectLocalGateway(t)
+	require.NoError(t, localGateway.MakeDir("criteo.tmp-42"))
+	require.NoError(t, localGateway.Upload(context.Background(), "criteo.tmp-42/criteo_0.csv", bytes.NewBufferString("x")))
+
+	require.NoError(t, localGateway.Rename("criteo.tmp-42", "criteo"))
+
+	content, err := ioutil.ReadFile(filepath.Join(root, "criteo", "criteo_0.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(content))
+	assert.Error(t, localGateway.Rename("criteo.tmp-42", "criteo"))
+}
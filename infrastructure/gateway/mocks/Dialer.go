@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	tls "crypto/tls"
 	gateway "go-feedmaker/infrastructure/gateway"
 
 	mock "github.com/stretchr/testify/mock"
@@ -15,13 +16,13 @@ type Dialer struct {
 	mock.Mock
 }
 
-// DialTimeout provides a mock function with given fields: addr, timeout
-func (_m *Dialer) DialTimeout(addr string, timeout time.Duration) (gateway.FtpConnection, error) {
-	ret := _m.Called(addr, timeout)
+// DialTimeout provides a mock function with given fields: addr, timeout, mode, tlsConfig
+func (_m *Dialer) DialTimeout(addr string, timeout time.Duration, mode gateway.FtpTLSMode, tlsConfig *tls.Config) (gateway.FtpConnection, error) {
+	ret := _m.Called(addr, timeout, mode, tlsConfig)
 
 	var r0 gateway.FtpConnection
-	if rf, ok := ret.Get(0).(func(string, time.Duration) gateway.FtpConnection); ok {
-		r0 = rf(addr, timeout)
+	if rf, ok := ret.Get(0).(func(string, time.Duration, gateway.FtpTLSMode, *tls.Config) gateway.FtpConnection); ok {
+		r0 = rf(addr, timeout, mode, tlsConfig)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(gateway.FtpConnection)
@@ -29,8 +30,8 @@ func (_m *Dialer) DialTimeout(addr string, timeout time.Duration) (gateway.FtpCo
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, time.Duration) error); ok {
-		r1 = rf(addr, timeout)
+	if rf, ok := ret.Get(1).(func(string, time.Duration, gateway.FtpTLSMode, *tls.Config) error); ok {
+		r1 = rf(addr, timeout, mode, tlsConfig)
 	} else {
 		r1 = ret.Error(1)
 	}
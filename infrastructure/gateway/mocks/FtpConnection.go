@@ -13,6 +13,20 @@ type FtpConnection struct {
 	mock.Mock
 }
 
+// Append provides a mock function with given fields: path, r
+func (_m *FtpConnection) Append(path string, r io.Reader) error {
+	ret := _m.Called(path, r)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, io.Reader) error); ok {
+		r0 = rf(path, r)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ChangeDir provides a mock function with given fields: path
 func (_m *FtpConnection) ChangeDir(path string) error {
 	ret := _m.Called(path)
@@ -41,6 +55,48 @@ func (_m *FtpConnection) ChangeDirToParent() error {
 	return r0
 }
 
+// CurrentDir provides a mock function with given fields:
+func (_m *FtpConnection) CurrentDir() (string, error) {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FileSize provides a mock function with given fields: path
+func (_m *FtpConnection) FileSize(path string) (int64, error) {
+	ret := _m.Called(path)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(string) int64); ok {
+		r0 = rf(path)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(path)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Login provides a mock function with given fields: user, password
 func (_m *FtpConnection) Login(user string, password string) error {
 	ret := _m.Called(user, password)
@@ -69,6 +125,20 @@ func (_m *FtpConnection) MakeDir(path string) error {
 	return r0
 }
 
+// NoOp provides a mock function with given fields:
+func (_m *FtpConnection) NoOp() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Quit provides a mock function with given fields:
 func (_m *FtpConnection) Quit() error {
 	ret := _m.Called()
@@ -111,6 +181,20 @@ func (_m *FtpConnection) RemoveDirRecur(dir string) error {
 	return r0
 }
 
+// Rename provides a mock function with given fields: from, to
+func (_m *FtpConnection) Rename(from string, to string) error {
+	ret := _m.Called(from, to)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(from, to)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Stor provides a mock function with given fields: path, r
 func (_m *FtpConnection) Stor(path string, r io.Reader) error {
 	ret := _m.Called(path, r)
@@ -124,3 +208,17 @@ func (_m *FtpConnection) Stor(path string, r io.Reader) error {
 
 	return r0
 }
+
+// StorFrom provides a mock function with given fields: path, r, offset
+func (_m *FtpConnection) StorFrom(path string, r io.Reader, offset uint64) error {
+	ret := _m.Called(path, r, offset)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, io.Reader, uint64) error); ok {
+		r0 = rf(path, r, offset)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
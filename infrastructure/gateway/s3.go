@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/inhies/go-bytesize"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+type (
+	S3Config struct {
+		Endpoint  string
+		Region    string
+		Bucket    string
+		AccessKey string `config:"access_key"`
+		SecretKey string `config:"secret_key"`
+		UseSSL    bool   `config:"use_ssl"`
+		// PartSize is the size of one part of a multipart upload, e.g. "64MB".
+		// Objects of unknown size are always uploaded in parts, so it also
+		// bounds the memory one upload holds.
+		PartSize string `config:"part_size"`
+		// SSE is a server-side encryption mode: "none", "AES256" or "aws:kms".
+		SSE         string `config:"sse"`
+		SSEKmsKeyID string `config:"sse_kms_key_id"`
+	}
+
+	S3Gateway struct {
+		Config   S3Config
+		client   *minio.Client
+		partSize uint64
+		sse      encrypt.ServerSide
+	}
+)
+
+const (
+	SSENone   = "none"
+	SSEAES256 = "AES256"
+	SSEKms    = "aws:kms"
+
+	minS3PartSize = 5 * bytesize.MB
+)
+
+var (
+	ErrS3Disconnected  = errors.New("gateway is not connected to S3")
+	ErrBucketNotFound  = errors.New("bucket not found")
+	ErrInvalidSSE      = errors.New("invalid server-side encryption mode")
+	ErrInvalidPartSize = errors.New("part size must be at least 5MB")
+)
+
+func (c S3Config) ServerSideEncryption() (encrypt.ServerSide, error) {
+	switch c.SSE {
+	case "", SSENone:
+		return nil, nil
+	case SSEAES256:
+		return encrypt.NewSSE(), nil
+	case SSEKms:
+		return encrypt.NewSSEKMS(c.SSEKmsKeyID, nil)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSSE, c.SSE)
+	}
+}
+
+func (g *S3Gateway) Connect() error {
+	partSize := minS3PartSize
+	if g.Config.PartSize != "" {
+		var err error
+		if partSize, err = bytesize.Parse(g.Config.PartSize); err != nil {
+			return err
+		}
+	}
+	if partSize < minS3PartSize {
+		return fmt.Errorf("%w: %s", ErrInvalidPartSize, g.Config.PartSize)
+	}
+	sse, err := g.Config.ServerSideEncryption()
+	if err != nil {
+		return err
+	}
+	client, err := minio.New(g.Config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(g.Config.AccessKey, g.Config.SecretKey, ""),
+		Secure: g.Config.UseSSL,
+		Region: g.Config.Region,
+	})
+	if err != nil {
+		return err
+	}
+	exists, err := client.BucketExists(context.Background(), g.Config.Bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, g.Config.Bucket)
+	}
+	g.client = client
+	g.partSize = uint64(partSize)
+	g.sse = sse
+	return nil
+}
+
+// PutObject stores r under key. A negative size means the size is unknown,
+// and the object is then streamed as a multipart upload.
+func (g *S3Gateway) PutObject(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if g.client == nil {
+		return ErrS3Disconnected
+	}
+	_, err := g.client.PutObject(ctx, g.Config.Bucket, key, r, size, minio.PutObjectOptions{
+		ContentType:          contentType,
+		ServerSideEncryption: g.sse,
+		PartSize:             g.partSize,
+	})
+	return err
+}
+
+// RemoveObjects removes every object under prefix.
+func (g *S3Gateway) RemoveObjects(ctx context.Context, prefix string) error {
+	if g.client == nil {
+		return ErrS3Disconnected
+	}
+	objects := g.client.ListObjects(ctx, g.Config.Bucket, minio.ListObjectsOptions{
+		Prefix:    strings.TrimSuffix(prefix, "/") + "/",
+		Recursive: true,
+	})
+	var listErr error
+	toRemove := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(toRemove)
+		for object := range objects {
+			if object.Err != nil {
+				listErr = object.Err
+				return
+			}
+			toRemove <- object
+		}
+	}()
+	var err error
+	for removeErr := range g.client.RemoveObjects(ctx, g.Config.Bucket, toRemove, minio.RemoveObjectsOptions{}) {
+		if err == nil {
+			err = fmt.Errorf("remove %s: %w", removeErr.ObjectName, removeErr.Err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return listErr
+}
@@ -0,0 +1,162 @@
+package gateway_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-feedmaker/infrastructure/gateway"
+	helper "go-feedmaker/infrastructure/testing"
+)
+
+const testBucket = "feeds"
+
+func connectS3Gateway(t *testing.T, server *helper.S3Server, setupConfig func(c *gateway.S3Config)) *gateway.S3Gateway {
+	s3Gateway := &gateway.S3Gateway{Config: gateway.S3Config{
+		Endpoint:  server.Endpoint,
+		Region:    "us-east-1",
+		Bucket:    testBucket,
+		AccessKey: "access",
+		SecretKey: "secret",
+		PartSize:  "5MB",
+	}}
+	setupConfig(&s3Gateway.Config)
+	require.NoError(t, s3Gateway.Connect())
+	return s3Gateway
+}
+
+func TestS3Gateway_Connect(t *testing.T) {
+	server := helper.StartS3Server(t, testBucket)
+	testCases := []struct {
+		name        string
+		setupConfig func(c *gateway.S3Config)
+		wantErr     error
+	}{
+		{
+			name:        "succeed",
+			setupConfig: func(c *gateway.S3Config) {},
+		},
+		{
+			name:        "bucket not found",
+			setupConfig: func(c *gateway.S3Config) { c.Bucket = "missing" },
+			wantErr:     gateway.ErrBucketNotFound,
+		},
+		{
+			name:        "invalid sse",
+			setupConfig: func(c *gateway.S3Config) { c.SSE = "rot13" },
+			wantErr:     gateway.ErrInvalidSSE,
+		},
+		{
+			name:        "part size too small",
+			setupConfig: func(c *gateway.S3Config) { c.PartSize = "1MB" },
+			wantErr:     gateway.ErrInvalidPartSize,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s3Gateway := &gateway.S3Gateway{Config: gateway.S3Config{
+				Endpoint:  server.Endpoint,
+				Bucket:    testBucket,
+				AccessKey: "access",
+				SecretKey: "secret",
+			}}
+			tc.setupConfig(&s3Gateway.Config)
+
+			err := s3Gateway.Connect()
+
+			assert.True(t, errors.Is(err, tc.wantErr), "got %v", err)
+		})
+	}
+}
+
+func TestS3Gateway_PutObject(t *testing.T) {
+	testCases := []struct {
+		name          string
+		setupConfig   func(c *gateway.S3Config)
+		content       []byte
+		size          func(content []byte) int64
+		wantMultipart bool
+		wantHeaders   map[string]string
+	}{
+		{
+			name:        "known size",
+			setupConfig: func(c *gateway.S3Config) {},
+			content:     []byte("id,name\n1,foo\n"),
+			size:        func(content []byte) int64 { return int64(len(content)) },
+		},
+		{
+			name:          "unknown size is uploaded in parts",
+			setupConfig:   func(c *gateway.S3Config) {},
+			content:       bytes.Repeat([]byte("1,foo\n"), 2*1024*1024),
+			size:          func(content []byte) int64 { return -1 },
+			wantMultipart: true,
+		},
+		{
+			name:        "sse aes256",
+			setupConfig: func(c *gateway.S3Config) { c.SSE = gateway.SSEAES256 },
+			content:     []byte("id\n1\n"),
+			size:        func(content []byte) int64 { return int64(len(content)) },
+			wantHeaders: map[string]string{"X-Amz-Server-Side-Encryption": "AES256"},
+		},
+		{
+			name: "sse kms",
+			setupConfig: func(c *gateway.S3Config) {
+				c.SSE = gateway.SSEKms
+				c.SSEKmsKeyID = "feed-key"
+			},
+			content: []byte("id\n1\n"),
+			size:    func(content []byte) int64 { return int64(len(content)) },
+			wantHeaders: map[string]string{
+				"X-Amz-Server-Side-Encryption":                "aws:kms",
+				"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": "feed-key",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := helper.StartS3Server(t, testBucket)
+			s3Gateway := connectS3Gateway(t, server, tc.setupConfig)
+			key := "criteo/criteo_0.csv"
+
+			err := s3Gateway.PutObject(context.Background(), key, bytes.NewReader(tc.content), tc.size(tc.content), "text/csv")
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.content, server.Object(t, testBucket, key))
+			requests := server.Requests(key)
+			require.NotEmpty(t, requests)
+			_, isMultipart := requests[0].URL.Query()["uploads"]
+			assert.Equal(t, tc.wantMultipart, isMultipart)
+			assert.Equal(t, "text/csv", requests[0].Header.Get("Content-Type"))
+			for header, value := range tc.wantHeaders {
+				assert.Equal(t, value, requests[0].Header.Get(header))
+			}
+		})
+	}
+}
+
+func TestS3Gateway_PutObjectDisconnected(t *testing.T) {
+	s3Gateway := new(gateway.S3Gateway)
+
+	err := s3Gateway.PutObject(context.Background(), "key", bytes.NewReader(nil), 0, "text/csv")
+
+	assert.Equal(t, gateway.ErrS3Disconnected, err)
+	assert.Equal(t, gateway.ErrS3Disconnected, s3Gateway.RemoveObjects(context.Background(), "key"))
+}
+
+func TestS3Gateway_RemoveObjects(t *testing.T) {
+	server := helper.StartS3Server(t, testBucket)
+	s3Gateway := connectS3Gateway(t, server, func(c *gateway.S3Config) {})
+	for _, key := range []string{"criteo/1/criteo_0.csv", "criteo/1/DE/criteo_0.csv", "criteo/10/criteo_0.csv"} {
+		require.NoError(t, s3Gateway.PutObject(context.Background(), key, bytes.NewReader([]byte("a")), 1, "text/csv"))
+	}
+
+	require.NoError(t, s3Gateway.RemoveObjects(context.Background(), "criteo/1"))
+
+	assert.False(t, server.HasObject(testBucket, "criteo/1/criteo_0.csv"))
+	assert.False(t, server.HasObject(testBucket, "criteo/1/DE/criteo_0.csv"))
+	assert.True(t, server.HasObject(testBucket, "criteo/10/criteo_0.csv"))
+}
@@ -0,0 +1,209 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type (
+	SftpConfig struct {
+		Host           string
+		Port           string
+		ConnTimeout    time.Duration `config:"conn_timeout"`
+		Username       string
+		PrivateKeyPath string `config:"private_key"`
+		KnownHostsPath string `config:"known_hosts"`
+	}
+
+	SftpGateway struct {
+		Config    SftpConfig
+		sshClient *ssh.Client
+		client    *sftp.Client
+		workDir   string
+	}
+
+	ctxReader struct {
+		ctx context.Context
+		r   io.Reader
+	}
+)
+
+var (
+	ErrSftpDisconnected = errors.New("gateway is not connected to SFTP")
+	ErrNotADirectory    = errors.New("not a directory")
+)
+
+func (c SftpConfig) Addr() string {
+	return fmt.Sprintf("%s:%s", c.Host, c.Port)
+}
+
+func (c SftpConfig) ClientConfig() (*ssh.ClientConfig, error) {
+	privateKey, err := ioutil.ReadFile(c.PrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := knownhosts.New(c.KnownHostsPath)
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		User:            c.Username,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         c.ConnTimeout,
+	}, nil
+}
+
+func (s *SftpGateway) Connect() error {
+	clientConfig, err := s.Config.ClientConfig()
+	if err != nil {
+		return err
+	}
+	sshClient, err := ssh.Dial("tcp", s.Config.Addr(), clientConfig)
+	if err != nil {
+		return err
+	}
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return err
+	}
+	workDir, err := client.Getwd()
+	if err != nil {
+		client.Close()
+		sshClient.Close()
+		return err
+	}
+	s.sshClient = sshClient
+	s.client = client
+	s.workDir = workDir
+	return nil
+}
+
+func (s *SftpGateway) Upload(ctx context.Context, filePath string, r io.Reader) error {
+	if s.client == nil {
+		return ErrSftpDisconnected
+	}
+	file, err := s.client.Create(s.resolve(filePath))
+	if err != nil {
+		return err
+	}
+	if _, err := file.ReadFrom(&ctxReader{ctx: ctx, r: r}); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (s *SftpGateway) MakeDir(dir string) error {
+	if s.client == nil {
+		return ErrSftpDisconnected
+	}
+	return s.client.Mkdir(s.resolve(dir))
+}
+
+func (s *SftpGateway) RemoveDir(dir string) error {
+	if s.client == nil {
+		return ErrSftpDisconnected
+	}
+	root := s.resolve(dir)
+	dirs := make([]string, 0)
+	walker := s.client.Walk(root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		if walker.Stat().IsDir() {
+			dirs = append(dirs, walker.Path())
+		} else if err := s.client.Remove(walker.Path()); err != nil {
+			return err
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		if err := s.client.RemoveDirectory(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SftpGateway) Rename(from, to string) error {
+	if s.client == nil {
+		return ErrSftpDisconnected
+	}
+	return s.client.Rename(s.resolve(from), s.resolve(to))
+}
+
+func (s *SftpGateway) DirExists(dir string) (bool, error) {
+	if s.client == nil {
+		return false, ErrSftpDisconnected
+	}
+	info, err := s.client.Stat(s.resolve(dir))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+func (s *SftpGateway) ChangeDir(dir string) error {
+	if s.client == nil {
+		return ErrSftpDisconnected
+	}
+	newWorkDir := s.resolve(dir)
+	info, err := s.client.Stat(newWorkDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &os.PathError{Op: "chdir", Path: newWorkDir, Err: ErrNotADirectory}
+	}
+	s.workDir = newWorkDir
+	return nil
+}
+
+func (s *SftpGateway) ChangeDirToParent() error {
+	return s.ChangeDir("..")
+}
+
+func (s *SftpGateway) Disconnect() error {
+	if s.client == nil {
+		return ErrSftpDisconnected
+	}
+	if err := s.client.Close(); err != nil {
+		s.sshClient.Close()
+		return err
+	}
+	return s.sshClient.Close()
+}
+
+func (s *SftpGateway) resolve(p string) string {
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join(s.workDir, p)
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
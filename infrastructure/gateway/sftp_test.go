@@ -0,0 +1,300 @@
+package gateway_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"go-feedmaker/infrastructure/gateway"
+)
+
+type sftpServer struct {
+	config   gateway.SftpConfig
+	handlers sftp.Handlers
+}
+
+func startSftpServer(t *testing.T) *sftpServer {
+	_, hostPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivateKey)
+	require.NoError(t, err)
+	clientPublicKey, clientPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	authorizedKey, err := ssh.NewPublicKey(clientPublicKey)
+	require.NoError(t, err)
+
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "feedmaker" && bytes.Equal(key.Marshal(), authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	server := &sftpServer{handlers: sftp.InMemHandler()}
+	go server.serve(listener, serverConfig)
+
+	dir := t.TempDir()
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(clientPrivateKey)
+	require.NoError(t, err)
+	privateKeyPath := path.Join(dir, "id_ed25519")
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyBytes})
+	require.NoError(t, ioutil.WriteFile(privateKeyPath, privateKeyPEM, 0600))
+	knownHostsPath := path.Join(dir, "known_hosts")
+	addr := listener.Addr().String()
+	knownHostsLine := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostSigner.PublicKey())
+	require.NoError(t, ioutil.WriteFile(knownHostsPath, []byte(knownHostsLine+"\n"), 0600))
+
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	server.config = gateway.SftpConfig{
+		Host:           host,
+		Port:           port,
+		ConnTimeout:    time.Second,
+		Username:       "feedmaker",
+		PrivateKeyPath: privateKeyPath,
+		KnownHostsPath: knownHostsPath,
+	}
+	return server
+}
+
+func (s *sftpServer) serve(listener net.Listener, config *ssh.ServerConfig) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveConn(conn, config)
+	}
+}
+
+func (s *sftpServer) serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func(in <-chan *ssh.Request) {
+			for request := range in {
+				request.Reply(request.Type == "subsystem" && string(request.Payload[4:]) == "sftp", nil)
+			}
+		}(channelRequests)
+		go func() {
+			server := sftp.NewRequestServer(channel, s.handlers)
+			server.Serve()
+			server.Close()
+		}()
+	}
+}
+
+func (s *sftpServer) client(t *testing.T) *sftp.Client {
+	clientConfig, err := s.config.ClientConfig()
+	require.NoError(t, err)
+	sshClient, err := ssh.Dial("tcp", s.config.Addr(), clientConfig)
+	require.NoError(t, err)
+	client, err := sftp.NewClient(sshClient)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+		sshClient.Close()
+	})
+	return client
+}
+
+func (s *sftpServer) readFile(t *testing.T, filePath string) string {
+	file, err := s.client(t).Open(filePath)
+	require.NoError(t, err)
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func connectSftp(t *testing.T, config gateway.SftpConfig) *gateway.SftpGateway {
+	sftpGateway := &gateway.SftpGateway{Config: config}
+	require.NoError(t, sftpGateway.Connect())
+	t.Cleanup(func() { sftpGateway.Disconnect() })
+	return sftpGateway
+}
+
+func TestSftpGateway_Connect(t *testing.T) {
+	server := startSftpServer(t)
+	testCases := []struct {
+		name          string
+		modifyConfig  func(t *testing.T, config *gateway.SftpConfig)
+		wantErrSubstr string
+	}{
+		{
+			name:         "succeed",
+			modifyConfig: func(t *testing.T, config *gateway.SftpConfig) {},
+		},
+		{
+			name: "unknown host key",
+			modifyConfig: func(t *testing.T, config *gateway.SftpConfig) {
+				otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+				require.NoError(t, err)
+				sshPublicKey, err := ssh.NewPublicKey(otherPublicKey)
+				require.NoError(t, err)
+				line := knownhosts.Line([]string{knownhosts.Normalize(config.Addr())}, sshPublicKey)
+				config.KnownHostsPath = path.Join(t.TempDir(), "known_hosts")
+				require.NoError(t, ioutil.WriteFile(config.KnownHostsPath, []byte(line+"\n"), 0600))
+			},
+			wantErrSubstr: "key mismatch",
+		},
+		{
+			name: "unauthorized user",
+			modifyConfig: func(t *testing.T, config *gateway.SftpConfig) {
+				config.Username = "intruder"
+			},
+			wantErrSubstr: "unable to authenticate",
+		},
+		{
+			name: "missing private key",
+			modifyConfig: func(t *testing.T, config *gateway.SftpConfig) {
+				config.PrivateKeyPath = path.Join(t.TempDir(), "missing")
+			},
+			wantErrSubstr: "no such file",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := server.config
+			tc.modifyConfig(t, &config)
+			sftpGateway := &gateway.SftpGateway{Config: config}
+
+			gotErr := sftpGateway.Connect()
+
+			if tc.wantErrSubstr == "" {
+				assert.NoError(t, gotErr)
+				assert.NoError(t, sftpGateway.Disconnect())
+			} else {
+				require.Error(t, gotErr)
+				assert.Contains(t, gotErr.Error(), tc.wantErrSubstr)
+			}
+		})
+	}
+}
+
+func TestSftpGateway_Upload(t *testing.T) {
+	server := startSftpServer(t)
+	sftpGateway := connectSftp(t, server.config)
+
+	require.NoError(t, sftpGateway.MakeDir("feed"))
+	require.NoError(t, sftpGateway.MakeDir("feed/DE"))
+	require.NoError(t, sftpGateway.Upload(context.Background(), "feed/DE/feed_0.csv", strings.NewReader("a,b\n")))
+
+	assert.Equal(t, "a,b\n", server.readFile(t, "/feed/DE/feed_0.csv"))
+}
+
+func TestSftpGateway_Upload_ContextCanceled(t *testing.T) {
+	server := startSftpServer(t)
+	sftpGateway := connectSftp(t, server.config)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	gotErr := sftpGateway.Upload(ctx, "feed_0.csv", strings.NewReader("a,b\n"))
+
+	assert.ErrorIs(t, gotErr, context.Canceled)
+}
+
+func TestSftpGateway_RemoveDir(t *testing.T) {
+	server := startSftpServer(t)
+	sftpGateway := connectSftp(t, server.config)
+	require.NoError(t, sftpGateway.MakeDir("feed"))
+	require.NoError(t, sftpGateway.MakeDir("feed/DE"))
+	require.NoError(t, sftpGateway.Upload(context.Background(), "feed/feed_0.csv", strings.NewReader("a")))
+	require.NoError(t, sftpGateway.Upload(context.Background(), "feed/DE/feed_0.csv", strings.NewReader("b")))
+
+	require.NoError(t, sftpGateway.RemoveDir("feed"))
+
+	_, err := server.client(t).Stat("/feed")
+	assert.Error(t, err)
+}
+
+func TestSftpGateway_Rename(t *testing.T) {
+	server := startSftpServer(t)
+	sftpGateway := connectSftp(t, server.config)
+	require.NoError(t, sftpGateway.MakeDir("feed.tmp-42"))
+	require.NoError(t, sftpGateway.Upload(context.Background(), "feed.tmp-42/feed_0.csv", strings.NewReader("a")))
+
+	require.NoError(t, sftpGateway.Rename("feed.tmp-42", "feed"))
+
+	assert.Equal(t, "a", server.readFile(t, "/feed/feed_0.csv"))
+	assert.Error(t, sftpGateway.Rename("feed.tmp-42", "feed"))
+}
+
+func TestSftpGateway_ChangeDir(t *testing.T) {
+	server := startSftpServer(t)
+	sftpGateway := connectSftp(t, server.config)
+	require.NoError(t, sftpGateway.MakeDir("feed"))
+	require.NoError(t, sftpGateway.Upload(context.Background(), "feed/file.csv", strings.NewReader("a")))
+
+	require.NoError(t, sftpGateway.ChangeDir("feed"))
+	require.NoError(t, sftpGateway.Upload(context.Background(), "other.csv", strings.NewReader("b")))
+	assert.Error(t, sftpGateway.ChangeDir("file.csv"))
+	require.NoError(t, sftpGateway.ChangeDirToParent())
+	require.NoError(t, sftpGateway.Upload(context.Background(), "root.csv", strings.NewReader("c")))
+
+	assert.Equal(t, "b", server.readFile(t, "/feed/other.csv"))
+	assert.Equal(t, "c", server.readFile(t, "/root.csv"))
+}
+
+func TestSftpGateway_DirExists(t *testing.T) {
+	server := startSftpServer(t)
+	sftpGateway := connectSftp(t, server.config)
+	require.NoError(t, sftpGateway.MakeDir("feed.tmp-42"))
+	require.NoError(t, sftpGateway.Upload(context.Background(), "feed.tmp-42/feed_0.csv", strings.NewReader("a")))
+
+	for dir, want := range map[string]bool{
+		"feed.tmp-42":            true,
+		"feed.tmp-42/feed_0.csv": false,
+		"feed":                   false,
+	} {
+		got, err := sftpGateway.DirExists(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, dir)
+	}
+}
+
+func TestSftpGateway_Disconnected(t *testing.T) {
+	sftpGateway := new(gateway.SftpGateway)
+
+	assert.Equal(t, gateway.ErrSftpDisconnected, sftpGateway.Upload(context.Background(), "a", strings.NewReader("")))
+	assert.Equal(t, gateway.ErrSftpDisconnected, sftpGateway.MakeDir("a"))
+	assert.Equal(t, gateway.ErrSftpDisconnected, sftpGateway.RemoveDir("a"))
+	assert.Equal(t, gateway.ErrSftpDisconnected, sftpGateway.Rename("a", "b"))
+	assert.Equal(t, gateway.ErrSftpDisconnected, sftpGateway.ChangeDir("a"))
+	_, err := sftpGateway.DirExists("a")
+	assert.Equal(t, gateway.ErrSftpDisconnected, err)
+	assert.Equal(t, gateway.ErrSftpDisconnected, sftpGateway.Disconnect())
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"io"
+	"os"
 	"strings"
 
 	"github.com/rs/zerolog"
@@ -11,6 +12,9 @@ import (
 type Config struct {
 	Level      string `config:"level"`
 	JsonOutput bool   `config:"json_output"`
+	// GenerationLines is how many log lines are kept per generation, none
+	// when zero.
+	GenerationLines uint `config:"generation_lines"`
 }
 
 func (c *Config) Apply() error {
@@ -28,6 +32,15 @@ func (c *Config) Apply() error {
 	return nil
 }
 
+// Capture copies every JSON log line to w as well.
+func (c *Config) Capture(w io.Writer) {
+	var output io.Writer = os.Stderr
+	if !c.JsonOutput {
+		output = zerolog.NewConsoleWriter()
+	}
+	c.applyWriter(zerolog.MultiLevelWriter(output, w))
+}
+
 func (c *Config) parseLevel() (zerolog.Level, error) {
 	level, err := zerolog.ParseLevel(strings.ToLower(c.Level))
 	if err != nil {
@@ -1,10 +1,12 @@
 package logger_test
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
 	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 
 	"go-feedmaker/infrastructure/logger"
@@ -57,3 +59,15 @@ func TestConfig_Apply(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_Capture(t *testing.T) {
+	config := logger.Config{Level: "info", JsonOutput: true}
+	assert.NoError(t, config.Apply())
+	buf := new(bytes.Buffer)
+
+	config.Capture(buf)
+	log.Info().Str("generation_id", "42").Msg("Started")
+
+	assert.Contains(t, buf.String(), `"generation_id":"42"`)
+	assert.Contains(t, buf.String(), `"message":"Started"`)
+}
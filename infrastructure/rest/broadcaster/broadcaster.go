@@ -1,12 +1,46 @@
 package broadcaster
 
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"go-feedmaker/entity"
+)
+
 type (
 	broadcaster struct {
-		recipients map[Recipient]bool
-		register   chan Recipient
-		unregister chan Recipient
-		broadcast  chan []byte
-		stop       chan struct{}
+		// recipients maps the registered recipients to their queues.
+		recipients  map[Recipient]*queue
+		register    chan Recipient
+		unregister  chan Recipient
+		closed      chan Recipient
+		broadcast   chan []byte
+		update      chan *entity.Generation
+		resubscribe chan Subscriber
+		stop        chan struct{}
+		done        chan struct{}
+		queueSize   int
+		// history keeps the last historySize messages for the recipients
+		// resuming a stream.
+		history     []*event
+		historySize int
+		// epoch prefixes the event ids, so the ids of a previous process
+		// aren't taken for the ones of this one.
+		epoch       string
+		lastEventID uint64
+		// active keeps the running generations for the snapshots.
+		active map[string]*activeGeneration
+		// activeTTL is how long a running generation stays in the snapshots
+		// without an update.
+		activeTTL time.Duration
+	}
+
+	activeGeneration struct {
+		generation *entity.Generation
+		updated    time.Time
 	}
 
 	Recipient interface {
@@ -15,15 +49,63 @@ type (
 		Send([]byte)
 		OnCloseHook(hook CloseHook)
 	}
+
+	// EventRecipient is implemented by recipients that number the messages,
+	// so their clients can resume after the last one they got.
+	EventRecipient interface {
+		SendEvent(id string, msg []byte)
+		// LastEventID is the id of the last message the client got before it
+		// reconnected, empty for a new client.
+		LastEventID() string
+	}
+
+	// Subscriber is implemented by recipients that only get the generations
+	// they subscribed to, wrapped in typed messages, starting with a snapshot
+	// of the running ones.
+	Subscriber interface {
+		Recipient
+		Subscription() *Subscription
+		// OnSubscribeHook sets the hook called after the client changed its
+		// subscription.
+		OnSubscribeHook(hook SubscribeHook)
+	}
+
+	SubscribeHook func()
+
+	event struct {
+		seq uint64
+		id  string
+		msg []byte
+	}
+)
+
+const (
+	// historySize is how many messages a resumed stream may have missed.
+	historySize = 100
+	// queueSize is how many messages a recipient may fall behind before it
+	// is evicted. It leaves room for the history a resumed stream gets.
+	queueSize = 256
+	// activeTTL drops the generations whose instance died before it
+	// published their outcome.
+	activeTTL = time.Hour
 )
 
 func NewBroadcaster() *broadcaster {
 	return &broadcaster{
-		recipients: make(map[Recipient]bool),
-		register:   make(chan Recipient),
-		unregister: make(chan Recipient),
-		broadcast:  make(chan []byte),
-		stop:       make(chan struct{}),
+		recipients:  make(map[Recipient]*queue),
+		register:    make(chan Recipient),
+		unregister:  make(chan Recipient),
+		closed:      make(chan Recipient),
+		broadcast:   make(chan []byte),
+		update:      make(chan *entity.Generation),
+		resubscribe: make(chan Subscriber),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		queueSize:   queueSize,
+		historySize: historySize,
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 10),
+		active:      make(map[string]*activeGeneration),
+		activeTTL:   activeTTL,
 	}
 }
 
@@ -34,8 +116,14 @@ func (b *broadcaster) Start() {
 			b.pushRecipient(recipient)
 		case recipient := <-b.unregister:
 			b.stopRecipient(recipient)
+		case recipient := <-b.closed:
+			b.removeRecipient(recipient)
 		case msg := <-b.broadcast:
 			b.broadcastMsg(msg)
+		case generation := <-b.update:
+			b.broadcastGeneration(generation)
+		case subscriber := <-b.resubscribe:
+			b.sendSnapshot(subscriber)
 		case <-b.stop:
 			b.stopBroadcasting()
 			return
@@ -60,35 +148,158 @@ func (b *broadcaster) Broadcast(msg []byte) {
 }
 
 func (b *broadcaster) pushRecipient(recipient Recipient) {
-	b.recipients[recipient] = true
+	queue := newQueue(b.queueSize)
+	b.recipients[recipient] = queue
+	go queue.run()
 	hook := b.makeOnCloseHook(recipient)
 	recipient.OnCloseHook(hook)
+	subscriber, isSubscriber := recipient.(Subscriber)
+	if isSubscriber {
+		subscriber.OnSubscribeHook(b.makeOnSubscribeHook(subscriber))
+	}
 	go recipient.Start()
+	if isSubscriber {
+		b.sendSnapshot(subscriber)
+	}
+	if eventRecipient, ok := recipient.(EventRecipient); ok && eventRecipient.LastEventID() != "" {
+		b.resume(eventRecipient)
+	}
+}
+
+// resume sends the messages broadcast after the last one the recipient got.
+// When that one is unknown, comes from before a restart or is older than the
+// history, the running generations are sent instead.
+func (b *broadcaster) resume(recipient EventRecipient) {
+	seq, ok := b.parseEventID(recipient.LastEventID())
+	if !ok || seq > b.lastEventID || len(b.history) > 0 && seq+1 < b.history[0].seq {
+		b.sendState(recipient)
+		return
+	}
+	for _, event := range b.history {
+		if event.seq > seq {
+			b.sendEvent(recipient, "", event)
+		}
+	}
 }
 
+// parseEventID returns the sequence number of an event id of this process.
+func (b *broadcaster) parseEventID(id string) (uint64, bool) {
+	sep := strings.LastIndex(id, "-")
+	if sep < 0 || id[:sep] != b.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(id[sep+1:], 10, 64)
+	return seq, err == nil
+}
+
+// stopRecipient stops the recipient once it got the pending messages.
 func (b *broadcaster) stopRecipient(recipient Recipient) {
-	recipient.Stop()
+	queue, ok := b.recipients[recipient]
+	if !ok {
+		go recipient.Stop()
+		return
+	}
+	delete(b.recipients, recipient)
+	queue.finish(recipient.Stop)
+}
+
+// removeRecipient forgets a recipient that stopped, dropping its pending
+// messages.
+func (b *broadcaster) removeRecipient(recipient Recipient) {
+	if queue, ok := b.recipients[recipient]; ok {
+		delete(b.recipients, recipient)
+		queue.close()
+	}
+}
+
+// evict stops a recipient too slow to keep up with the messages.
+func (b *broadcaster) evict(recipient Recipient) {
+	log.Warn().
+		Int("queue_size", b.queueSize).
+		Msg("evict slow recipient")
+	b.removeRecipient(recipient)
+	go recipient.Stop()
+}
+
+// send queues a message for the recipient, evicting it when its queue is
+// full. Messages with the same non-empty key replace each other.
+func (b *broadcaster) send(recipient Recipient, key string, msg []byte) {
+	b.enqueue(recipient, key, func() { recipient.Send(msg) })
+}
+
+func (b *broadcaster) sendEvent(recipient EventRecipient, key string, event *event) {
+	b.enqueue(recipient.(Recipient), key, func() { recipient.SendEvent(event.id, event.msg) })
+}
+
+func (b *broadcaster) enqueue(recipient Recipient, key string, send func()) {
+	queue, ok := b.recipients[recipient]
+	if !ok {
+		return
+	}
+	if !queue.push(key, send) {
+		b.evict(recipient)
+	}
 }
 
 func (b *broadcaster) broadcastMsg(msg []byte) {
+	event := b.remember(msg)
 	for recipient := range b.recipients {
-		recipient.Send(msg)
+		if eventRecipient, ok := recipient.(EventRecipient); ok {
+			b.sendEvent(eventRecipient, "", event)
+		} else {
+			b.send(recipient, "", msg)
+		}
+	}
+}
+
+// remember numbers a message and keeps it for the resumed streams.
+func (b *broadcaster) remember(msg []byte) *event {
+	b.lastEventID++
+	event := &event{seq: b.lastEventID, id: b.eventID(), msg: msg}
+	if len(b.history) >= b.historySize {
+		b.history = b.history[1:]
 	}
+	b.history = append(b.history, event)
+	return event
+}
+
+// eventID is the id of the last message broadcast, empty before the first
+// one.
+func (b *broadcaster) eventID() string {
+	if b.lastEventID == 0 {
+		return ""
+	}
+	return b.epoch + "-" + strconv.FormatUint(b.lastEventID, 10)
 }
 
 func (b *broadcaster) stopBroadcasting() {
+	close(b.done)
 	close(b.register)
 	close(b.broadcast)
-	for recipient := range b.recipients {
+	for recipient, queue := range b.recipients {
+		queue.close()
 		recipient.Stop()
 	}
 	close(b.unregister)
 }
 
+// The hooks are called from the recipients' goroutines, so they hand the
+// recipient over to the broadcaster loop.
+
 func (b *broadcaster) makeOnCloseHook(recipient Recipient) CloseHook {
 	return func() {
-		if _, ok := b.recipients[recipient]; ok {
-			delete(b.recipients, recipient)
+		select {
+		case b.closed <- recipient:
+		case <-b.done:
+		}
+	}
+}
+
+func (b *broadcaster) makeOnSubscribeHook(subscriber Subscriber) SubscribeHook {
+	return func() {
+		select {
+		case b.resubscribe <- subscriber:
+		case <-b.done:
 		}
 	}
 }
@@ -1,8 +1,8 @@
 package broadcaster_test
 
 import (
+	"sync"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -52,6 +52,14 @@ func makeMockedRecipients(amount int) mockedRecipients {
 	return recipients
 }
 
+func asRecipients(mocked []*mocks.Recipient) []broadcaster.Recipient {
+	recipients := make([]broadcaster.Recipient, len(mocked))
+	for i, recipient := range mocked {
+		recipients[i] = recipient
+	}
+	return recipients
+}
+
 func TestNewBroadcaster(t *testing.T) {
 	b := broadcaster.NewBroadcaster()
 	assert.NotNil(t, b.GetRecipients())
@@ -64,83 +72,77 @@ func TestNewBroadcaster(t *testing.T) {
 func Test_broadcaster_Start(t *testing.T) {
 	testCases := []struct {
 		name       string
-		fields     *broadcasterFields
-		setupMocks func([]*mocks.Recipient)
-		do         func(*broadcaster.BroadcasterImpl, *broadcasterFields, []*mocks.Recipient)
+		setupMocks func(recipients []*mocks.Recipient, called *sync.WaitGroup)
+		// setup configures the broadcaster before it starts.
+		setup func(b *broadcaster.BroadcasterImpl, recipients []*mocks.Recipient)
+		do    func(b *broadcaster.BroadcasterImpl, recipients []*mocks.Recipient)
 	}{
 		{
-			name:   "register",
-			fields: defaultBroadcasterFields(),
-			setupMocks: func(recipients []*mocks.Recipient) {
+			name: "register",
+			setupMocks: func(recipients []*mocks.Recipient, called *sync.WaitGroup) {
+				called.Add(1)
 				recipient := recipients[recipientToActions]
-				recipient.On("Start")
+				recipient.On("Start").Run(func(mock.Arguments) { called.Done() })
 				recipient.On("OnCloseHook", mock.Anything)
 			},
-			do: func(b *broadcaster.BroadcasterImpl, fields *broadcasterFields, recipients []*mocks.Recipient) {
-				b.SetRegister(fields.register)
-				fields.register <- recipients[recipientToActions]
-				<-time.After(time.Millisecond)
+			do: func(b *broadcaster.BroadcasterImpl, recipients []*mocks.Recipient) {
+				b.Register(recipients[recipientToActions])
 			},
 		},
 		{
-			name:   "unregister",
-			fields: defaultBroadcasterFields(),
-			setupMocks: func(recipients []*mocks.Recipient) {
+			name: "unregister",
+			setupMocks: func(recipients []*mocks.Recipient, called *sync.WaitGroup) {
+				called.Add(1)
 				recipient := recipients[recipientToActions]
-				recipient.On("Stop")
+				recipient.On("Stop").Run(func(mock.Arguments) { called.Done() })
 			},
-			do: func(b *broadcaster.BroadcasterImpl, fields *broadcasterFields, recipients []*mocks.Recipient) {
-				b.SetUnregister(fields.unregister)
-				fields.unregister <- recipients[recipientToActions]
-				<-time.After(time.Millisecond)
+			do: func(b *broadcaster.BroadcasterImpl, recipients []*mocks.Recipient) {
+				b.Unregister(recipients[recipientToActions])
 			},
 		},
 		{
-			name:   "broadcast",
-			fields: defaultBroadcasterFields(),
-			setupMocks: func(recipients []*mocks.Recipient) {
+			name: "broadcast",
+			setupMocks: func(recipients []*mocks.Recipient, called *sync.WaitGroup) {
+				called.Add(len(recipients))
 				for _, recipient := range recipients {
-					recipient.On("Send", defaultMessage)
+					recipient.On("Send", defaultMessage).Run(func(mock.Arguments) { called.Done() })
 				}
 			},
-			do: func(b *broadcaster.BroadcasterImpl, fields *broadcasterFields, recipients []*mocks.Recipient) {
-				recipientsMap := make(map[broadcaster.Recipient]bool)
-				for _, recipient := range recipients {
-					recipientsMap[recipient] = true
-				}
-				b.SetRecipients(recipientsMap)
-				b.SetBroadcast(fields.broadcast)
-				fields.broadcast <- defaultMessage
-				<-time.After(time.Millisecond)
+			setup: func(b *broadcaster.BroadcasterImpl, recipients []*mocks.Recipient) {
+				b.SetRecipients(asRecipients(recipients))
+			},
+			do: func(b *broadcaster.BroadcasterImpl, recipients []*mocks.Recipient) {
+				b.Broadcast(defaultMessage)
 			},
 		},
 		{
-			name:   "stop",
-			fields: defaultBroadcasterFields(),
-			setupMocks: func(recipients []*mocks.Recipient) {
+			name: "stop",
+			setupMocks: func(recipients []*mocks.Recipient, called *sync.WaitGroup) {
+				called.Add(len(recipients))
 				for _, recipient := range recipients {
-					recipient.On("Stop")
+					recipient.On("Stop").Run(func(mock.Arguments) { called.Done() })
 				}
 			},
-			do: func(b *broadcaster.BroadcasterImpl, fields *broadcasterFields, recipients []*mocks.Recipient) {
-				recipientsMap := make(map[broadcaster.Recipient]bool)
-				for _, recipient := range recipients {
-					recipientsMap[recipient] = true
-				}
-				b.SetRecipients(recipientsMap)
-				b.SetStop(fields.stop)
-				fields.stop <- struct{}{}
-				<-time.After(time.Millisecond)
+			setup: func(b *broadcaster.BroadcasterImpl, recipients []*mocks.Recipient) {
+				b.SetRecipients(asRecipients(recipients))
+			},
+			do: func(b *broadcaster.BroadcasterImpl, recipients []*mocks.Recipient) {
+				b.Stop()
 			},
 		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
 			recipients := makeMockedRecipients(recipientsAmount)
-			testCase.setupMocks(recipients)
+			var called sync.WaitGroup
+			testCase.setupMocks(recipients, &called)
 			b := broadcaster.NewBroadcaster()
+			if testCase.setup != nil {
+				testCase.setup(b, recipients)
+			}
 			go b.Start()
-			testCase.do(b, testCase.fields, recipients)
+			testCase.do(b, recipients)
+			called.Wait()
 			recipients.AssertExpectations(t)
 		})
 	}
@@ -1,6 +1,7 @@
 package broadcaster_test
 
 import (
+	"errors"
 	"time"
 
 	"go-feedmaker/entity"
@@ -8,6 +9,7 @@ import (
 
 var (
 	defaultMessage    = []byte("default message")
+	defaultErr        = errors.New("test error")
 	defaultGeneration = &entity.Generation{
 		ID:            "0xDEADBEEF",
 		Type:          "degeneration",
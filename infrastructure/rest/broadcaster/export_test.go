@@ -8,8 +8,9 @@ import (
 )
 
 type (
-	RecipientImpl   = recipient
-	BroadcasterImpl = broadcaster
+	RecipientImpl    = recipient
+	SSERecipientImpl = sseRecipient
+	BroadcasterImpl  = broadcaster
 )
 
 func (r *recipient) GetConn() WSConn {
@@ -44,12 +45,42 @@ func (r *recipient) GetOnCloseHook() CloseHook {
 	return r.onCloseHook
 }
 
-func (b *broadcaster) GetRecipients() map[Recipient]bool {
+func (r *recipient) Read() {
+	r.read()
+}
+
+func (r *sseRecipient) SetTicker(ticker *time.Ticker) {
+	r.ticker = ticker
+}
+
+func (b *broadcaster) GetRecipients() map[Recipient]*queue {
 	return b.recipients
 }
 
-func (b *broadcaster) SetRecipients(recipients map[Recipient]bool) {
-	b.recipients = recipients
+// SetRecipients registers the recipients without starting them.
+func (b *broadcaster) SetRecipients(recipients []Recipient) {
+	b.recipients = make(map[Recipient]*queue)
+	for _, recipient := range recipients {
+		queue := newQueue(b.queueSize)
+		b.recipients[recipient] = queue
+		go queue.run()
+	}
+}
+
+func (b *broadcaster) SetQueueSize(size int) {
+	b.queueSize = size
+}
+
+func (b *broadcaster) SetHistorySize(size int) {
+	b.historySize = size
+}
+
+func (b *broadcaster) GetEpoch() string {
+	return b.epoch
+}
+
+func (b *broadcaster) SetActiveTTL(ttl time.Duration) {
+	b.activeTTL = ttl
 }
 
 func (b *broadcaster) GetRegister() chan Recipient {
@@ -76,6 +107,10 @@ func (b *broadcaster) SetBroadcast(broadcast chan []byte) {
 	b.broadcast = broadcast
 }
 
+func (b *broadcaster) SetUpdate(update chan *entity.Generation) {
+	b.update = update
+}
+
 func (b *broadcaster) GetStop() chan struct{} {
 	return b.stop
 }
@@ -87,3 +122,25 @@ func (b *broadcaster) SetStop(stop chan struct{}) {
 func MarshalGeneration(generation *entity.Generation, w io.Writer) error {
 	return marshalGeneration(generation, w)
 }
+
+type QueueImpl = queue
+
+func NewQueue(size int) *queue {
+	return newQueue(size)
+}
+
+func (q *queue) Push(key string, send func()) bool {
+	return q.push(key, send)
+}
+
+func (q *queue) Finish(send func()) {
+	q.finish(send)
+}
+
+func (q *queue) Close() {
+	q.close()
+}
+
+func (q *queue) Run() {
+	q.run()
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"sort"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -13,24 +14,63 @@ import (
 
 type (
 	generationOut struct {
-		ID            string  `json:"id"`
-		Type          string  `json:"type"`
-		Progress      uint    `json:"progress"`
-		DataFetched   bool    `json:"data_fetched"`
-		FilesUploaded uint    `json:"files_uploaded"`
-		IsCanceled    bool    `json:"is_canceled"`
-		StartTime     string  `json:"start_time"`
-		EndTime       *string `json:"end_time"`
+		ID            string            `json:"id"`
+		Type          string            `json:"type"`
+		Progress      uint              `json:"progress"`
+		DataFetched   bool              `json:"data_fetched"`
+		FilesUploaded uint              `json:"files_uploaded"`
+		IsCanceled    bool              `json:"is_canceled"`
+		StartTime     string            `json:"start_time"`
+		EndTime       *string           `json:"end_time"`
+		Destinations  []*destinationOut `json:"destinations,omitempty"`
 	}
+
+	// snapshotOut lists the running generations a subscriber gets when it
+	// connects or changes its subscription.
+	snapshotOut struct {
+		Type        string           `json:"type"`
+		Generations []*generationOut `json:"generations"`
+	}
+
+	// updateOut carries a generation that changed; it is removed once it
+	// finished running.
+	updateOut struct {
+		Type       string         `json:"type"`
+		Generation *generationOut `json:"generation"`
+	}
+
+	destinationOut struct {
+		Name          string `json:"name"`
+		State         string `json:"state"`
+		FilesUploaded uint   `json:"files_uploaded"`
+		Error         string `json:"error,omitempty"`
+	}
+)
+
+const (
+	messageSnapshot = "snapshot"
+	messageUpdate   = "update"
+	messageRemoved  = "removed"
 )
 
 func (b *broadcaster) BroadcastGenerationsProgress(generationsProgress <-chan *entity.Generation) {
 	for generation := range generationsProgress {
-		b.broadcastGeneration(generation)
+		b.update <- generation
 	}
 }
 
+// broadcastGeneration sends subscribers a typed message when they subscribed
+// to generation and the other recipients the generation itself. A pending
+// message about the same generation is replaced by the new one.
 func (b *broadcaster) broadcastGeneration(generation *entity.Generation) {
+	messageType := messageUpdate
+	if generation.IsFinished() {
+		delete(b.active, generation.ID)
+		messageType = messageRemoved
+	} else {
+		b.active[generation.ID] = &activeGeneration{generation: generation, updated: time.Now()}
+	}
+	b.dropStale()
 	buf := new(bytes.Buffer)
 	if err := marshalGeneration(generation, buf); err != nil {
 		log.Error().
@@ -39,7 +79,91 @@ func (b *broadcaster) broadcastGeneration(generation *entity.Generation) {
 			Msg("generation marshal")
 		return
 	}
-	b.Broadcast(buf.Bytes())
+	update, err := json.Marshal(&updateOut{Type: messageType, Generation: makeGenerationOut(generation)})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Interface("generation", generation).
+			Msg("generation marshal")
+		return
+	}
+	event := b.remember(buf.Bytes())
+	for recipient := range b.recipients {
+		switch recipient := recipient.(type) {
+		case Subscriber:
+			if recipient.Subscription().Matches(generation) {
+				b.send(recipient, generation.ID, update)
+			}
+		case EventRecipient:
+			b.sendEvent(recipient, generation.ID, event)
+		default:
+			b.send(recipient, generation.ID, event.msg)
+		}
+	}
+}
+
+// sendSnapshot sends the running generations the subscriber subscribed to,
+// oldest first.
+func (b *broadcaster) sendSnapshot(subscriber Subscriber) {
+	generations := b.running(subscriber.Subscription())
+	out := &snapshotOut{Type: messageSnapshot, Generations: make([]*generationOut, len(generations))}
+	for i, generation := range generations {
+		out.Generations[i] = makeGenerationOut(generation)
+	}
+	snapshot, err := json.Marshal(out)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("snapshot marshal")
+		return
+	}
+	b.send(subscriber, "", snapshot)
+}
+
+// sendState sends a recipient that can't resume its stream the running
+// generations, oldest first, numbered as the last message broadcast so it
+// resumes from there next time.
+func (b *broadcaster) sendState(recipient EventRecipient) {
+	id := b.eventID()
+	for _, generation := range b.running(nil) {
+		buf := new(bytes.Buffer)
+		if err := marshalGeneration(generation, buf); err != nil {
+			log.Error().
+				Err(err).
+				Interface("generation", generation).
+				Msg("generation marshal")
+			continue
+		}
+		b.sendEvent(recipient, generation.ID, &event{seq: b.lastEventID, id: id, msg: buf.Bytes()})
+	}
+}
+
+// running returns the running generations matching subscription, oldest
+// first.
+func (b *broadcaster) running(subscription *Subscription) []*entity.Generation {
+	b.dropStale()
+	generations := make([]*entity.Generation, 0, len(b.active))
+	for _, active := range b.active {
+		if subscription.Matches(active.generation) {
+			generations = append(generations, active.generation)
+		}
+	}
+	sort.Slice(generations, func(i, j int) bool {
+		if generations[i].StartTime.Equal(generations[j].StartTime) {
+			return generations[i].ID < generations[j].ID
+		}
+		return generations[i].StartTime.Before(generations[j].StartTime)
+	})
+	return generations
+}
+
+// dropStale forgets the running generations not updated within activeTTL.
+func (b *broadcaster) dropStale() {
+	for id, active := range b.active {
+		if time.Since(active.updated) > b.activeTTL {
+			delete(b.active, id)
+		}
+	}
 }
 
 func marshalGeneration(generation *entity.Generation, w io.Writer) error {
@@ -62,6 +186,14 @@ func makeGenerationOut(generation *entity.Generation) *generationOut {
 		endTime := formatTime(generation.EndTime)
 		generationOut.EndTime = &endTime
 	}
+	for _, destination := range generation.Destinations {
+		generationOut.Destinations = append(generationOut.Destinations, &destinationOut{
+			Name:          destination.Name,
+			State:         string(destination.State),
+			FilesUploaded: destination.FilesUploaded,
+			Error:         destination.Error,
+		})
+	}
 	return generationOut
 }
 
@@ -2,23 +2,162 @@ package broadcaster_test
 
 import (
 	"bytes"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 
 	"go-feedmaker/entity"
 	"go-feedmaker/infrastructure/rest/broadcaster"
+	"go-feedmaker/infrastructure/rest/mocks"
 )
 
+type (
+	// subscriber is a recipient subscribed to some generations.
+	subscriber struct {
+		*mocks.Recipient
+		subscription *broadcaster.Subscription
+		hook         broadcaster.SubscribeHook
+	}
+)
+
+func (s *subscriber) Subscription() *broadcaster.Subscription {
+	return s.subscription
+}
+
+func (s *subscriber) OnSubscribeHook(hook broadcaster.SubscribeHook) {
+	s.hook = hook
+}
+
+func newSubscriber(subscription *broadcaster.Subscription, stopped *sync.WaitGroup) *subscriber {
+	recipient := new(mocks.Recipient)
+	recipient.On("OnCloseHook", mock.Anything)
+	recipient.On("Start").Maybe()
+	recipient.On("Stop").Run(func(mock.Arguments) { stopped.Done() })
+	return &subscriber{Recipient: recipient, subscription: subscription}
+}
+
+func mustMarshalGeneration(generation *entity.Generation) string {
+	buf := new(bytes.Buffer)
+	if err := broadcaster.MarshalGeneration(generation, buf); err != nil {
+		panic(err)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
 func Test_broadcaster_BroadcastGenerationsProgress(t *testing.T) {
-	fields := defaultBroadcasterFields()
+	update := make(chan *entity.Generation)
 	b := broadcaster.NewBroadcaster()
-	b.SetBroadcast(fields.broadcast)
+	b.SetUpdate(update)
 	generationProgress := make(chan *entity.Generation)
 	go b.BroadcastGenerationsProgress(generationProgress)
 	generationProgress <- defaultGeneration
-	gotMessage := <-fields.broadcast
-	buf := new(bytes.Buffer)
-	assert.NoError(t, broadcaster.MarshalGeneration(defaultGeneration, buf))
-	assert.Equal(t, buf.Bytes(), gotMessage)
+	gotGeneration := <-update
+	assert.Equal(t, defaultGeneration, gotGeneration)
+}
+
+func Test_broadcaster_broadcastGeneration(t *testing.T) {
+	started := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	first := &entity.Generation{ID: "1", Type: "first", StartTime: started}
+	second := &entity.Generation{ID: "2", Type: "second", StartTime: started.Add(-time.Minute)}
+	firstFinished := &entity.Generation{ID: "1", Type: "first", StartTime: started, Manifest: new(entity.Manifest)}
+	update := func(messageType string, generation *entity.Generation) []byte {
+		return []byte(`{"type":"` + messageType + `","generation":` + mustMarshalGeneration(generation) + `}`)
+	}
+
+	var stopped sync.WaitGroup
+	stopped.Add(3)
+	plain := new(mocks.Recipient)
+	plain.On("OnCloseHook", mock.Anything)
+	plain.On("Start").Maybe()
+	plain.On("Stop").Run(func(mock.Arguments) { stopped.Done() })
+	for _, generation := range []*entity.Generation{first, second, firstFinished} {
+		plain.On("Send", []byte(mustMarshalGeneration(generation)+"\n")).Once()
+	}
+	// Subscribed before the updates: an empty snapshot, then its updates.
+	early := newSubscriber(&broadcaster.Subscription{Types: []string{"first"}}, &stopped)
+	early.On("Send", []byte(`{"type":"snapshot","generations":[]}`)).Once()
+	// Delivered before first finishes, so it isn't replaced by the removal.
+	firstSent := make(chan struct{}, 1)
+	early.On("Send", update("update", first)).
+		Run(func(mock.Arguments) { firstSent <- struct{}{} }).
+		Once()
+	early.On("Send", update("removed", firstFinished)).Once()
+	// Subscribed to everything after them: both running generations, oldest
+	// first, twice as it subscribes again.
+	late := newSubscriber(nil, &stopped)
+	snapshotSent := make(chan struct{}, 2)
+	late.On("Send", []byte(`{"type":"snapshot","generations":[`+
+		mustMarshalGeneration(second)+","+mustMarshalGeneration(first)+`]}`)).
+		Run(func(mock.Arguments) { snapshotSent <- struct{}{} }).
+		Twice()
+	late.On("Send", update("removed", firstFinished)).Once()
+
+	b := broadcaster.NewBroadcaster()
+	go b.Start()
+	b.Register(plain)
+	b.Register(early)
+	broadcast := func(generations ...*entity.Generation) {
+		generationProgress := make(chan *entity.Generation)
+		done := make(chan struct{})
+		go func() {
+			b.BroadcastGenerationsProgress(generationProgress)
+			close(done)
+		}()
+		for _, generation := range generations {
+			generationProgress <- generation
+		}
+		close(generationProgress)
+		<-done
+	}
+	broadcast(first, second)
+	b.Register(late)
+	<-snapshotSent
+	<-firstSent
+	late.hook()
+	broadcast(firstFinished)
+	// Unregistered recipients get their pending messages before they stop.
+	b.Unregister(plain)
+	b.Unregister(early)
+	b.Unregister(late)
+	stopped.Wait()
+	b.Stop()
+
+	plain.AssertExpectations(t)
+	early.AssertExpectations(t)
+	late.AssertExpectations(t)
+}
+
+func Test_broadcaster_sendSnapshot_stale(t *testing.T) {
+	stale := &entity.Generation{ID: "1", Type: "first"}
+
+	var stopped sync.WaitGroup
+	stopped.Add(1)
+	// The generation never finished, but it wasn't updated for longer than
+	// the TTL, so it is left out of the snapshot.
+	late := newSubscriber(nil, &stopped)
+	late.On("Send", []byte(`{"type":"snapshot","generations":[]}`)).Once()
+
+	b := broadcaster.NewBroadcaster()
+	b.SetActiveTTL(time.Millisecond)
+	go b.Start()
+	generationProgress := make(chan *entity.Generation)
+	done := make(chan struct{})
+	go func() {
+		b.BroadcastGenerationsProgress(generationProgress)
+		close(done)
+	}()
+	generationProgress <- stale
+	close(generationProgress)
+	<-done
+	time.Sleep(5 * time.Millisecond)
+	b.Register(late)
+	b.Unregister(late)
+	stopped.Wait()
+	b.Stop()
+
+	late.AssertExpectations(t)
 }
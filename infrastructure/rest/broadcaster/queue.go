@@ -0,0 +1,107 @@
+package broadcaster
+
+import "sync"
+
+type (
+	// queue delivers the messages to a recipient in its own goroutine, so a
+	// slow client doesn't hold up the broadcaster and the other clients.
+	queue struct {
+		size    int
+		mu      sync.Mutex
+		pending []*delivery
+		ready   chan struct{}
+		done    chan struct{}
+	}
+
+	// delivery is a pending message. Deliveries with the same key replace
+	// each other, so a client falling behind only gets the latest one.
+	delivery struct {
+		key   string
+		send  func()
+		final bool
+	}
+)
+
+func newQueue(size int) *queue {
+	return &queue{
+		size:  size,
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+}
+
+// push adds a delivery, dropping the pending one with the same key unless
+// the key is empty. It returns false when the queue is full.
+func (q *queue) push(key string, send func()) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if key != "" {
+		for i, pending := range q.pending {
+			if pending.key == key {
+				// The latest one goes last, so the event ids stay in order.
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+	}
+	if len(q.pending) >= q.size {
+		return false
+	}
+	q.pending = append(q.pending, &delivery{key: key, send: send})
+	q.notify()
+	return true
+}
+
+// finish adds the last delivery, run after the pending ones whatever the
+// size of the queue.
+func (q *queue) finish(send func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, &delivery{send: send, final: true})
+	q.notify()
+}
+
+// close drops the pending deliveries.
+func (q *queue) close() {
+	close(q.done)
+}
+
+func (q *queue) run() {
+	for {
+		select {
+		case <-q.ready:
+		case <-q.done:
+			return
+		}
+		for delivery := q.next(); delivery != nil; delivery = q.next() {
+			select {
+			case <-q.done:
+				return
+			default:
+			}
+			delivery.send()
+			if delivery.final {
+				return
+			}
+		}
+	}
+}
+
+func (q *queue) next() *delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return nil
+	}
+	delivery := q.pending[0]
+	q.pending[0] = nil
+	q.pending = q.pending[1:]
+	return delivery
+}
+
+func (q *queue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,226 @@
+package broadcaster_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-feedmaker/entity"
+	"go-feedmaker/infrastructure/rest/broadcaster"
+	"go-feedmaker/infrastructure/rest/mocks"
+)
+
+type (
+	// deliveries records the messages a queue delivered.
+	deliveries struct {
+		mu  sync.Mutex
+		got []string
+	}
+)
+
+func (d *deliveries) send(msg string) func() {
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.got = append(d.got, msg)
+	}
+}
+
+func (d *deliveries) get() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.got
+}
+
+// blockingRecipient returns a recipient whose first Send blocks until
+// release is closed.
+func blockingRecipient(blocked chan<- struct{}, release <-chan struct{}, stopped *sync.WaitGroup) *mocks.Recipient {
+	recipient := new(mocks.Recipient)
+	recipient.On("OnCloseHook", mock.Anything)
+	recipient.On("Start").Maybe()
+	recipient.On("Send", mock.Anything).
+		Run(func(mock.Arguments) {
+			blocked <- struct{}{}
+			<-release
+		}).
+		Once()
+	recipient.On("Stop").Run(func(mock.Arguments) { stopped.Done() })
+	return recipient
+}
+
+func Test_queue(t *testing.T) {
+	testCases := []struct {
+		name     string
+		size     int
+		do       func(q *broadcaster.QueueImpl, d *deliveries)
+		wantFull bool
+		want     []string
+	}{
+		{
+			name: "in order",
+			size: 4,
+			do: func(q *broadcaster.QueueImpl, d *deliveries) {
+				q.Push("", d.send("1"))
+				q.Push("", d.send("2"))
+				q.Push("", d.send("3"))
+			},
+			want: []string{"1", "2", "3"},
+		},
+		{
+			name: "latest of a key",
+			size: 3,
+			do: func(q *broadcaster.QueueImpl, d *deliveries) {
+				q.Push("a", d.send("a1"))
+				q.Push("b", d.send("b1"))
+				q.Push("a", d.send("a2"))
+				q.Push("", d.send("1"))
+				q.Push("", d.send("2"))
+			},
+			wantFull: true,
+			want:     []string{"b1", "a2", "1"},
+		},
+		{
+			name: "full",
+			size: 2,
+			do: func(q *broadcaster.QueueImpl, d *deliveries) {
+				q.Push("", d.send("1"))
+				q.Push("", d.send("2"))
+				q.Push("", d.send("3"))
+			},
+			wantFull: true,
+			want:     []string{"1", "2"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			q := broadcaster.NewQueue(testCase.size)
+			d := new(deliveries)
+			testCase.do(q, d)
+			gotFull := !q.Push("", d.send("last"))
+			if !gotFull {
+				testCase.want = append(testCase.want, "last")
+			}
+			q.Finish(d.send("finish"))
+
+			q.Run()
+
+			assert.Equal(t, testCase.wantFull, gotFull)
+			assert.Equal(t, append(testCase.want, "finish"), d.get())
+		})
+	}
+}
+
+func Test_queue_Close(t *testing.T) {
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	q := broadcaster.NewQueue(2)
+	d := new(deliveries)
+	q.Push("", func() {
+		blocked <- struct{}{}
+		<-release
+	})
+	q.Push("", d.send("dropped"))
+	done := make(chan struct{})
+	go func() {
+		q.Run()
+		close(done)
+	}()
+	<-blocked
+
+	q.Close()
+	close(release)
+
+	<-done
+	assert.Empty(t, d.get())
+}
+
+func Test_broadcaster_evict(t *testing.T) {
+	var stopped sync.WaitGroup
+	stopped.Add(2)
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	slow := blockingRecipient(blocked, release, &stopped)
+	fast := new(mocks.Recipient)
+	fast.On("OnCloseHook", mock.Anything)
+	fast.On("Start").Maybe()
+	fast.On("Stop").Run(func(mock.Arguments) { stopped.Done() })
+	sent := make(chan struct{})
+	msgs := make([][]byte, 4)
+	for i := range msgs {
+		msgs[i] = []byte(strconv.Itoa(i))
+		fast.On("Send", msgs[i]).Run(func(mock.Arguments) { sent <- struct{}{} }).Once()
+	}
+
+	b := broadcaster.NewBroadcaster()
+	b.SetQueueSize(2)
+	go b.Start()
+	b.Register(slow)
+	b.Register(fast)
+	b.Broadcast(msgs[0])
+	<-blocked
+	<-sent
+	// The slow recipient can't keep up with the rest, the fast one gets all.
+	for _, msg := range msgs[1:] {
+		b.Broadcast(msg)
+		<-sent
+	}
+	b.Unregister(fast)
+	stopped.Wait()
+	close(release)
+	b.Stop()
+
+	slow.AssertExpectations(t)
+	fast.AssertExpectations(t)
+}
+
+func Test_broadcaster_coalesce(t *testing.T) {
+	var stopped sync.WaitGroup
+	stopped.Add(1)
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	recipient := blockingRecipient(blocked, release, &stopped)
+	generation := func(id string, progress uint) *entity.Generation {
+		return &entity.Generation{ID: id, Type: "test", Progress: progress}
+	}
+	var mu sync.Mutex
+	var got []string
+	recipient.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, string(args.Get(0).([]byte)))
+	})
+
+	b := broadcaster.NewBroadcaster()
+	go b.Start()
+	b.Register(recipient)
+	generationProgress := make(chan *entity.Generation)
+	forwarded := make(chan struct{})
+	go func() {
+		b.BroadcastGenerationsProgress(generationProgress)
+		close(forwarded)
+	}()
+	generationProgress <- generation("1", 1)
+	<-blocked
+	// While the first one is sent, the updates of a generation replace each
+	// other.
+	generationProgress <- generation("1", 2)
+	generationProgress <- generation("1", 3)
+	generationProgress <- generation("2", 1)
+	close(generationProgress)
+	<-forwarded
+	b.Unregister(recipient)
+	close(release)
+	stopped.Wait()
+	b.Stop()
+
+	recipient.AssertExpectations(t)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		mustMarshalGeneration(generation("1", 3)) + "\n",
+		mustMarshalGeneration(generation("2", 1)) + "\n",
+	}, got)
+}
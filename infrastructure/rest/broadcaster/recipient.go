@@ -1,7 +1,9 @@
 package broadcaster
 
 import (
+	"encoding/json"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -10,32 +12,53 @@ import (
 
 type (
 	recipient struct {
-		conn        WSConn
-		send        chan []byte
-		stop        chan struct{}
-		ticker      *time.Ticker
-		onCloseHook CloseHook
+		conn            WSConn
+		send            chan []byte
+		stop            chan struct{}
+		disconnected    chan struct{}
+		done            chan struct{}
+		ticker          *time.Ticker
+		onCloseHook     CloseHook
+		onSubscribeHook SubscribeHook
+		mu              sync.Mutex
+		subscription    *Subscription
 	}
 
 	WSConn interface {
 		RemoteAddr() net.Addr
+		ReadMessage() (int, []byte, error)
 		WriteMessage(int, []byte) error
+		SetWriteDeadline(t time.Time) error
 		Close() error
 	}
 
 	CloseHook func()
+
+	// subscribeIn is the message a client changes its subscription with.
+	subscribeIn struct {
+		Action string   `json:"action"`
+		Types  []string `json:"types"`
+		IDs    []string `json:"ids"`
+	}
 )
 
 const (
 	tickInterval = time.Second
+	// writeTimeout is how long a client may take to read a message; a
+	// client that stopped reading is disconnected after it.
+	writeTimeout = 10 * time.Second
+
+	actionSubscribe = "subscribe"
 )
 
 func NewRecipient(conn WSConn) *recipient {
 	return &recipient{
-		conn:   conn,
-		send:   make(chan []byte),
-		stop:   make(chan struct{}),
-		ticker: time.NewTicker(tickInterval),
+		conn:         conn,
+		send:         make(chan []byte),
+		stop:         make(chan struct{}),
+		disconnected: make(chan struct{}),
+		done:         make(chan struct{}),
+		ticker:       time.NewTicker(tickInterval),
 	}
 }
 
@@ -43,13 +66,47 @@ func (r *recipient) OnCloseHook(hook CloseHook) {
 	r.onCloseHook = hook
 }
 
+func (r *recipient) OnSubscribeHook(hook SubscribeHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onSubscribeHook = hook
+}
+
+func (r *recipient) Subscription() *Subscription {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.subscription
+}
+
+// Subscribe replaces the subscription; once the recipient is registered
+// the client gets a snapshot for the new one.
+func (r *recipient) Subscribe(subscription *Subscription) {
+	r.mu.Lock()
+	r.subscription = subscription
+	hook := r.onSubscribeHook
+	r.mu.Unlock()
+	if hook != nil {
+		hook()
+	}
+}
+
 func (r *recipient) Start() {
+	go r.read()
 	for {
 		select {
 		case msg := <-r.send:
-			r.sendMsg(msg)
+			if err := r.sendMsg(msg); err != nil {
+				r.stopSending()
+				return
+			}
 		case <-r.ticker.C:
-			r.ping()
+			if err := r.ping(); err != nil {
+				r.stopSending()
+				return
+			}
+		case <-r.disconnected:
+			r.stopSending()
+			return
 		case <-r.stop:
 			r.stopSending()
 			return
@@ -58,40 +115,82 @@ func (r *recipient) Start() {
 }
 
 func (r *recipient) Send(msg []byte) {
-	r.send <- msg
+	select {
+	case r.send <- msg:
+	case <-r.done:
+	}
 }
 
 func (r *recipient) Stop() {
-	r.stop <- struct{}{}
+	select {
+	case r.stop <- struct{}{}:
+	case <-r.done:
+	}
 }
 
-func (r *recipient) sendMsg(msg []byte) {
-	if err := r.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+// read handles the messages of the client until the connection is closed.
+func (r *recipient) read() {
+	defer close(r.disconnected)
+	for {
+		_, msg, err := r.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		r.handleMsg(msg)
+	}
+}
+
+func (r *recipient) handleMsg(msg []byte) {
+	in := new(subscribeIn)
+	if err := json.Unmarshal(msg, in); err != nil || in.Action != actionSubscribe {
+		log.Warn().
+			Err(err).
+			Str("remote_addr", r.conn.RemoteAddr().String()).
+			Str("message", string(msg)).
+			Msg("unknown message")
+		return
+	}
+	r.Subscribe(&Subscription{Types: in.Types, IDs: in.IDs})
+}
+
+func (r *recipient) sendMsg(msg []byte) error {
+	err := r.write(websocket.TextMessage, msg)
+	if err != nil {
 		log.Error().
 			Err(err).
 			Str("remote_addr", r.conn.RemoteAddr().String()).
 			Str("message", string(msg)).
 			Msg("send message")
 	}
+	return err
 }
 
-func (r *recipient) ping() {
-	if err := r.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-		r.Stop()
+func (r *recipient) ping() error {
+	err := r.write(websocket.PingMessage, nil)
+	if err != nil {
 		log.Error().
 			Err(err).
 			Str("remote_addr", r.conn.RemoteAddr().String()).
 			Msg("ping")
 	}
+	return err
+}
+
+// write fails once the client didn't read the message in writeTimeout, so a
+// stuck client can't hold the recipient.
+func (r *recipient) write(messageType int, data []byte) error {
+	if err := r.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	return r.conn.WriteMessage(messageType, data)
 }
 
 func (r *recipient) stopSending() {
-	close(r.send)
 	r.ticker.Stop()
-	close(r.stop)
 	if r.onCloseHook != nil {
 		r.onCloseHook()
 	}
+	close(r.done)
 	if err := r.conn.Close(); err != nil {
 		log.Error().
 			Err(err).
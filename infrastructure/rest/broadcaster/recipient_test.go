@@ -1,12 +1,14 @@
 package broadcaster_test
 
 import (
+	"net"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 
 	"go-feedmaker/infrastructure/rest/broadcaster"
 	"go-feedmaker/infrastructure/rest/mocks"
@@ -144,3 +146,49 @@ func Test_recipient_Stop(t *testing.T) {
 	assert.Equal(t, struct{}{}, gotEvent)
 	fields.AssertExpectations(t)
 }
+
+func Test_recipient_Read(t *testing.T) {
+	fields := defaultRecipientFields()
+	fields.conn.On("ReadMessage").
+		Return(websocket.TextMessage, []byte(`{"action":"subscribe","types":["test"],"ids":["42"]}`), nil).Once()
+	fields.conn.On("ReadMessage").Return(websocket.TextMessage, []byte(`{"action":"dance"}`), nil).Once()
+	fields.conn.On("ReadMessage").Return(0, nil, defaultErr).Once()
+	fields.conn.On("RemoteAddr").Return(&net.TCPAddr{})
+	r := broadcaster.NewRecipient(fields.conn)
+	var subscribed int
+	r.OnSubscribeHook(func() {
+		subscribed++
+	})
+
+	r.Read()
+
+	assert.Equal(t, 1, subscribed)
+	assert.Equal(t, &broadcaster.Subscription{Types: []string{"test"}, IDs: []string{"42"}}, r.Subscription())
+	fields.AssertExpectations(t)
+}
+
+func Test_recipient_Start_writeFailed(t *testing.T) {
+	fields := defaultRecipientFields()
+	closed := make(chan struct{})
+	fields.conn.On("ReadMessage").
+		Run(func(mock.Arguments) { <-closed }).
+		Return(0, nil, defaultErr).
+		Maybe()
+	fields.conn.On("SetWriteDeadline", mock.Anything).Return(nil)
+	fields.conn.On("WriteMessage", websocket.TextMessage, defaultMessage).Return(defaultErr)
+	fields.conn.On("RemoteAddr").Return(&net.TCPAddr{})
+	fields.conn.On("Close").Run(func(mock.Arguments) { close(closed) }).Return(nil)
+	stopped := make(chan struct{})
+	fields.closeHook.On("Execute").Run(func(mock.Arguments) { close(stopped) })
+	r := broadcaster.NewRecipient(fields.conn)
+	r.OnCloseHook(fields.closeHook.Execute)
+	go r.Start()
+
+	// A client that stopped reading makes the write time out.
+	r.Send(defaultMessage)
+
+	<-stopped
+	r.Stop()
+	<-closed
+	fields.AssertExpectations(t)
+}
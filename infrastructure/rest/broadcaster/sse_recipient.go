@@ -0,0 +1,123 @@
+package broadcaster
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// sseRecipient streams the messages as server-sent events numbered by
+	// the broadcaster.
+	sseRecipient struct {
+		conn         SSEConn
+		lastEventID  string
+		send         chan *event
+		stop         chan struct{}
+		disconnected <-chan struct{}
+		done         chan struct{}
+		ticker       *time.Ticker
+		onCloseHook  CloseHook
+	}
+
+	SSEConn interface {
+		WriteEvent(id string, data []byte) error
+		WriteComment(text string) error
+	}
+)
+
+const (
+	// heartbeatInterval is how often an idle stream sends a comment, so
+	// proxies don't close it.
+	heartbeatInterval = 15 * time.Second
+)
+
+// NewSSERecipient returns a recipient writing to conn until disconnected is
+// closed or the broadcaster stops it. Messages after lastEventID are sent
+// first when the client resumes a stream.
+func NewSSERecipient(conn SSEConn, lastEventID string, disconnected <-chan struct{}) *sseRecipient {
+	return &sseRecipient{
+		conn:         conn,
+		lastEventID:  lastEventID,
+		send:         make(chan *event),
+		stop:         make(chan struct{}),
+		disconnected: disconnected,
+		done:         make(chan struct{}),
+		ticker:       time.NewTicker(heartbeatInterval),
+	}
+}
+
+func (r *sseRecipient) OnCloseHook(hook CloseHook) {
+	r.onCloseHook = hook
+}
+
+func (r *sseRecipient) LastEventID() string {
+	return r.lastEventID
+}
+
+// Done is closed once the recipient stopped writing.
+func (r *sseRecipient) Done() <-chan struct{} {
+	return r.done
+}
+
+func (r *sseRecipient) Start() {
+	for {
+		select {
+		case event := <-r.send:
+			r.sendEvent(event)
+		case <-r.ticker.C:
+			r.heartbeat()
+		case <-r.disconnected:
+			r.stopSending()
+			return
+		case <-r.stop:
+			r.stopSending()
+			return
+		}
+	}
+}
+
+// Send writes a message without an id, so it can't be resumed from.
+func (r *sseRecipient) Send(msg []byte) {
+	r.SendEvent("", msg)
+}
+
+func (r *sseRecipient) SendEvent(id string, msg []byte) {
+	select {
+	case r.send <- &event{id: id, msg: msg}:
+	case <-r.done:
+	}
+}
+
+func (r *sseRecipient) Stop() {
+	select {
+	case r.stop <- struct{}{}:
+	case <-r.done:
+	}
+}
+
+func (r *sseRecipient) sendEvent(event *event) {
+	if err := r.conn.WriteEvent(event.id, bytes.TrimSpace(event.msg)); err != nil {
+		log.Error().
+			Err(err).
+			Str("message", string(event.msg)).
+			Msg("send event")
+	}
+}
+
+func (r *sseRecipient) heartbeat() {
+	if err := r.conn.WriteComment("heartbeat"); err != nil {
+		log.Error().
+			Err(err).
+			Msg("heartbeat")
+	}
+}
+
+func (r *sseRecipient) stopSending() {
+	r.ticker.Stop()
+	if r.onCloseHook != nil {
+		r.onCloseHook()
+	}
+	close(r.done)
+}
@@ -0,0 +1,219 @@
+package broadcaster_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-feedmaker/entity"
+	"go-feedmaker/infrastructure/rest/broadcaster"
+	"go-feedmaker/infrastructure/rest/mocks"
+)
+
+type (
+	sseRecipientFields struct {
+		conn         *mocks.SSEConn
+		disconnected chan struct{}
+		ticker       chan time.Time
+		closeHook    *mocks.CloseHook
+	}
+
+	// wantEvent is a generation written with the event id of seq.
+	wantEvent struct {
+		seq        int
+		generation *entity.Generation
+	}
+)
+
+func (f *sseRecipientFields) AssertExpectations(t *testing.T) {
+	f.conn.AssertExpectations(t)
+	f.closeHook.AssertExpectations(t)
+}
+
+func defaultSSERecipientFields() *sseRecipientFields {
+	return &sseRecipientFields{
+		conn:         new(mocks.SSEConn),
+		disconnected: make(chan struct{}),
+		ticker:       make(chan time.Time),
+		closeHook:    new(mocks.CloseHook),
+	}
+}
+
+func TestNewSSERecipient(t *testing.T) {
+	fields := defaultSSERecipientFields()
+	recipient := broadcaster.NewSSERecipient(fields.conn, "42", fields.disconnected)
+	assert.Equal(t, "42", recipient.LastEventID())
+	assert.NotNil(t, recipient.Done())
+}
+
+func Test_sseRecipient_Start(t *testing.T) {
+	testCases := []struct {
+		name       string
+		setupMocks func(*sseRecipientFields)
+		do         func(*broadcaster.SSERecipientImpl, *sseRecipientFields)
+	}{
+		{
+			name: "send event",
+			setupMocks: func(fields *sseRecipientFields) {
+				fields.conn.On("WriteEvent", "7", defaultMessage).Return(nil)
+				fields.closeHook.On("Execute")
+			},
+			do: func(recipient *broadcaster.SSERecipientImpl, fields *sseRecipientFields) {
+				recipient.SendEvent("7", append(defaultMessage, '\n'))
+				recipient.Stop()
+			},
+		},
+		{
+			name: "send message without id",
+			setupMocks: func(fields *sseRecipientFields) {
+				fields.conn.On("WriteEvent", "", defaultMessage).Return(nil)
+				fields.closeHook.On("Execute")
+			},
+			do: func(recipient *broadcaster.SSERecipientImpl, fields *sseRecipientFields) {
+				recipient.Send(defaultMessage)
+				recipient.Stop()
+			},
+		},
+		{
+			name: "write error",
+			setupMocks: func(fields *sseRecipientFields) {
+				fields.conn.On("WriteEvent", "7", defaultMessage).Return(defaultErr)
+				fields.closeHook.On("Execute")
+			},
+			do: func(recipient *broadcaster.SSERecipientImpl, fields *sseRecipientFields) {
+				recipient.SendEvent("7", defaultMessage)
+				recipient.Stop()
+			},
+		},
+		{
+			name: "heartbeat",
+			setupMocks: func(fields *sseRecipientFields) {
+				fields.conn.On("WriteComment", "heartbeat").Return(nil)
+				fields.closeHook.On("Execute")
+			},
+			do: func(recipient *broadcaster.SSERecipientImpl, fields *sseRecipientFields) {
+				fields.ticker <- time.Now().UTC()
+				recipient.Stop()
+			},
+		},
+		{
+			name: "client disconnected",
+			setupMocks: func(fields *sseRecipientFields) {
+				fields.closeHook.On("Execute")
+			},
+			do: func(recipient *broadcaster.SSERecipientImpl, fields *sseRecipientFields) {
+				close(fields.disconnected)
+				<-recipient.Done()
+				// Neither blocks once the recipient stopped.
+				recipient.SendEvent("7", defaultMessage)
+				recipient.Stop()
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultSSERecipientFields()
+			testCase.setupMocks(fields)
+			r := broadcaster.NewSSERecipient(fields.conn, "", fields.disconnected)
+			r.SetTicker(&time.Ticker{C: fields.ticker})
+			r.OnCloseHook(fields.closeHook.Execute)
+			go r.Start()
+			testCase.do(r, fields)
+			<-r.Done()
+			fields.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_broadcaster_resume(t *testing.T) {
+	started := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	first := &entity.Generation{ID: "1", Type: "test", StartTime: started}
+	second := &entity.Generation{ID: "2", Type: "test", StartTime: started.Add(-time.Minute)}
+	firstProgress := &entity.Generation{ID: "1", Type: "test", StartTime: started, Progress: 50}
+	// state is what a client that can't resume gets: the running
+	// generations, numbered as the last update.
+	state := []wantEvent{{3, second}, {3, firstProgress}}
+	testCases := []struct {
+		name string
+		// lastEventID is built from the epoch of the broadcaster.
+		lastEventID func(epoch string) string
+		historySize int
+		want        []wantEvent
+	}{
+		{
+			name:        "new client",
+			lastEventID: func(string) string { return "" },
+		},
+		{
+			name:        "missed events",
+			lastEventID: func(epoch string) string { return epoch + "-1" },
+			want:        []wantEvent{{2, second}, {3, firstProgress}},
+		},
+		{
+			name:        "up to date",
+			lastEventID: func(epoch string) string { return epoch + "-3" },
+		},
+		{
+			name:        "missed only the kept events",
+			lastEventID: func(epoch string) string { return epoch + "-1" },
+			historySize: 2,
+			want:        []wantEvent{{2, second}, {3, firstProgress}},
+		},
+		{
+			name:        "fell behind the history",
+			lastEventID: func(epoch string) string { return epoch + "-1" },
+			historySize: 1,
+			want:        state,
+		},
+		{
+			name:        "id from before restart",
+			lastEventID: func(string) string { return "1634000000000000000-2" },
+			want:        state,
+		},
+		{
+			name:        "id from the future",
+			lastEventID: func(epoch string) string { return epoch + "-42" },
+			want:        state,
+		},
+		{
+			name:        "unknown id",
+			lastEventID: func(string) string { return "foo" },
+			want:        state,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultSSERecipientFields()
+			b := broadcaster.NewBroadcaster()
+			if testCase.historySize > 0 {
+				b.SetHistorySize(testCase.historySize)
+			}
+			for _, want := range testCase.want {
+				id := b.GetEpoch() + "-" + strconv.Itoa(want.seq)
+				fields.conn.On("WriteEvent", id, []byte(mustMarshalGeneration(want.generation))).Return(nil).Once()
+			}
+			go b.Start()
+			generationProgress := make(chan *entity.Generation)
+			done := make(chan struct{})
+			go func() {
+				b.BroadcastGenerationsProgress(generationProgress)
+				close(done)
+			}()
+			for _, generation := range []*entity.Generation{first, second, firstProgress} {
+				generationProgress <- generation
+			}
+			close(generationProgress)
+			<-done
+			r := broadcaster.NewSSERecipient(fields.conn, testCase.lastEventID(b.GetEpoch()), fields.disconnected)
+
+			b.Register(r)
+			b.Unregister(r)
+
+			<-r.Done()
+			b.Stop()
+			fields.conn.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package broadcaster
+
+import "go-feedmaker/entity"
+
+type (
+	// Subscription selects the generations of Types and the ones with IDs;
+	// an empty subscription selects every generation.
+	Subscription struct {
+		Types []string
+		IDs   []string
+	}
+)
+
+func (s *Subscription) Matches(generation *entity.Generation) bool {
+	if s == nil || len(s.Types) == 0 && len(s.IDs) == 0 {
+		return true
+	}
+	return contains(s.Types, generation.Type) || contains(s.IDs, generation.ID)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
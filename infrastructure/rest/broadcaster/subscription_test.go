@@ -0,0 +1,49 @@
+package broadcaster_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-feedmaker/entity"
+	"go-feedmaker/infrastructure/rest/broadcaster"
+)
+
+func TestSubscription_Matches(t *testing.T) {
+	generation := &entity.Generation{ID: "42", Type: "test"}
+	testCases := []struct {
+		name         string
+		subscription *broadcaster.Subscription
+		want         bool
+	}{
+		{
+			name: "no subscription",
+			want: true,
+		},
+		{
+			name:         "empty subscription",
+			subscription: &broadcaster.Subscription{},
+			want:         true,
+		},
+		{
+			name:         "type",
+			subscription: &broadcaster.Subscription{Types: []string{"other", "test"}},
+			want:         true,
+		},
+		{
+			name:         "id",
+			subscription: &broadcaster.Subscription{Types: []string{"other"}, IDs: []string{"42"}},
+			want:         true,
+		},
+		{
+			name:         "other generations",
+			subscription: &broadcaster.Subscription{Types: []string{"other"}, IDs: []string{"43"}},
+			want:         false,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.want, testCase.subscription.Matches(generation))
+		})
+	}
+}
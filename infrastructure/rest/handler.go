@@ -3,10 +3,14 @@ package rest
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"path"
 	"time"
 
+	"go-feedmaker/entity"
 	"go-feedmaker/infrastructure/scheduler"
 	"go-feedmaker/interactor"
 )
@@ -31,15 +35,162 @@ func NewHandler(feeds interactor.FeedInteractor, scheduler Scheduler) *handler {
 	}
 }
 
+// ListGenerations returns a page of generations, newest first. Query
+// parameters type and status may be repeated or comma separated, from and to
+// bound the start time (RFC 3339), cursor continues a previous page and limit
+// caps its size.
 func (h *handler) ListGenerations(w http.ResponseWriter, r *http.Request) {
-	generations, err := h.feeds.ListGenerations(r.Context())
+	filter, err := parseGenerationFilter(r)
 	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	generations, err := h.feeds.ListGenerations(r.Context(), filter)
+	if errors.Is(err, entity.ErrInvalidCursor) {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
 		errorResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 	jsonResponse(w, http.StatusOK, generations)
 }
 
+func (h *handler) GetGeneration(w http.ResponseWriter, r *http.Request) {
+	generationID, err := extractGenerationID(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	generation, err := h.feeds.GetGeneration(r.Context(), generationID)
+	if err != nil {
+		errorResponse(w, generationErrorCode(err), err)
+		return
+	}
+	jsonResponse(w, http.StatusOK, generation)
+}
+
+func (h *handler) ListGenerationFiles(w http.ResponseWriter, r *http.Request) {
+	generationID, err := extractGenerationID(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	files, err := h.feeds.ListGenerationFiles(r.Context(), generationID)
+	if err != nil {
+		errorResponse(w, generationErrorCode(err), err)
+		return
+	}
+	jsonResponse(w, http.StatusOK, files)
+}
+
+// GetGenerationFile streams an archived file with range support, or returns
+// its first rows as JSON when preview is set.
+func (h *handler) GetGenerationFile(w http.ResponseWriter, r *http.Request) {
+	generationID, err := extractGenerationID(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	filename, err := extractFilename(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	rows, preview, err := parsePreviewRows(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if preview {
+		out, err := h.feeds.PreviewGenerationFile(r.Context(), generationID, filename, rows)
+		if err != nil {
+			errorResponse(w, generationErrorCode(err), err)
+			return
+		}
+		jsonResponse(w, http.StatusOK, out)
+		return
+	}
+	file, err := h.feeds.OpenGenerationFile(r.Context(), generationID, filename)
+	if err != nil {
+		errorResponse(w, generationErrorCode(err), err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	name := path.Base(filename)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
+
+// GetGenerationLogs returns the log lines kept for a generation. With follow
+// set it streams them as server-sent events, followed by every new line
+// until the client disconnects.
+func (h *handler) GetGenerationLogs(w http.ResponseWriter, r *http.Request) {
+	generationID, err := extractGenerationID(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	follow, err := parseFollow(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if follow {
+		h.followGenerationLogs(w, r, generationID)
+		return
+	}
+	logs, err := h.feeds.GetGenerationLogs(r.Context(), generationID)
+	if err != nil {
+		errorResponse(w, generationErrorCode(err), err)
+		return
+	}
+	jsonResponse(w, http.StatusOK, logs)
+}
+
+func (h *handler) followGenerationLogs(w http.ResponseWriter, r *http.Request, generationID string) {
+	// Unknown generations are answered before the stream starts.
+	if _, err := h.feeds.GetGeneration(r.Context(), generationID); err != nil {
+		errorResponse(w, generationErrorCode(err), err)
+		return
+	}
+	stream, err := newEventStream(w)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	entries := make(chan interface{})
+	errStream := make(chan error, 1)
+	go func() {
+		errStream <- h.feeds.FollowGenerationLogs(ctx, generationID, entries)
+	}()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case entry := <-entries:
+			stream.send("", entry)
+		case <-keepAlive.C:
+			stream.keepAlive()
+		case err := <-errStream:
+			if err != nil && ctx.Err() == nil {
+				stream.send("error", map[string]string{"details": err.Error()})
+			}
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (h *handler) ListGenerationTypes(w http.ResponseWriter, r *http.Request) {
 	generationTypes, err := h.feeds.ListGenerationTypes(r.Context())
 	if err != nil {
@@ -62,6 +213,39 @@ func (h *handler) GenerateFeed(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 }
 
+// PreviewFeed formats the first records of a feed, or all of them for a dry
+// run, and describes the files without uploading them.
+func (h *handler) PreviewFeed(w http.ResponseWriter, r *http.Request) {
+	generationType, err := extractGenerationType(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	rows, dryRun, err := parseFeedPreview(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	var preview interface{}
+	if dryRun {
+		preview, err = h.feeds.DryRunFeed(r.Context(), generationType)
+	} else {
+		preview, err = h.feeds.PreviewFeed(r.Context(), generationType, rows)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidGenerationType):
+			errorResponse(w, http.StatusNotFound, err)
+		case errors.Is(err, entity.ErrPreviewNotSupported):
+			errorResponse(w, http.StatusNotImplemented, err)
+		default:
+			errorResponse(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	jsonResponse(w, http.StatusOK, preview)
+}
+
 func (h *handler) CancelGeneration(w http.ResponseWriter, r *http.Request) {
 	generationID, err := extractGenerationID(r)
 	if err != nil {
@@ -75,6 +259,20 @@ func (h *handler) CancelGeneration(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// PurgeGeneration deletes a finished generation and its spooled files.
+func (h *handler) PurgeGeneration(w http.ResponseWriter, r *http.Request) {
+	generationID, err := extractGenerationID(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.feeds.PurgeGeneration(r.Context(), generationID); err != nil {
+		errorResponse(w, generationErrorCode(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *handler) RestartGeneration(w http.ResponseWriter, r *http.Request) {
 	generationID, err := extractGenerationID(r)
 	if err != nil {
@@ -82,12 +280,56 @@ func (h *handler) RestartGeneration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err := h.feeds.RestartGeneration(r.Context(), generationID); err != nil {
-		errorResponse(w, http.StatusInternalServerError, err)
+		errorResponse(w, generationErrorCode(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// RetryUpload uploads the spooled files of a failed generation again, starting
+// after the last uploaded file; without a checkpoint the generation restarts.
+func (h *handler) RetryUpload(w http.ResponseWriter, r *http.Request) {
+	generationID, err := extractGenerationID(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.feeds.RetryUpload(r.Context(), generationID); err != nil {
+		errorResponse(w, generationErrorCode(err), err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
 }
 
+// RollbackGeneration makes a previously published version current again. The
+// body may name the generation to roll back to; without it the previous
+// version is used.
+func (h *handler) RollbackGeneration(w http.ResponseWriter, r *http.Request) {
+	generationType, err := extractGenerationType(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	rollback := new(rollbackIn)
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(rollback); err != nil && err != io.EOF {
+			errorResponse(w, http.StatusBadRequest, fmt.Errorf("%w: %s",
+				ErrReadingRequestBody, err.Error()))
+			return
+		}
+	}
+	if err := h.feeds.RollbackGeneration(r.Context(), generationType, rollback.GenerationID); err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidGenerationType), errors.Is(err, entity.ErrVersionNotFound):
+			errorResponse(w, http.StatusNotFound, err)
+		default:
+			errorResponse(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 func (h *handler) ScheduleGeneration(w http.ResponseWriter, r *http.Request) {
 	generationType, err := extractGenerationType(r)
 	if err != nil {
@@ -5,15 +5,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"go-feedmaker/entity"
 	"go-feedmaker/infrastructure/rest"
 	"go-feedmaker/infrastructure/scheduler"
 )
@@ -33,16 +38,756 @@ func TestNewHandler(t *testing.T) {
 }
 
 func Test_handler_ListGenerations(t *testing.T) {
+	type args struct {
+		w *httptest.ResponseRecorder
+		r *http.Request
+	}
+	defaultArgs := func(query string) *args {
+		return &args{
+			w: httptest.NewRecorder(),
+			r: httptest.NewRequest(http.MethodGet, "/generations?"+query, nil),
+		}
+	}
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 3, 2, 12, 30, 0, 0, time.UTC)
+	cursorErr := fmt.Errorf("%w: foo", entity.ErrInvalidCursor)
+	testCases := []struct {
+		name           string
+		fields         *handlerFields
+		setupMocks     func(*handlerFields, *args)
+		args           *args
+		wantStatusCode int
+		wantBody       []byte
+	}{
+		{
+			name:   "succeed",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("ListGenerations", args.r.Context(), &entity.GenerationFilter{}).
+					Return(defaultSentinel, nil)
+			},
+			args:           defaultArgs(""),
+			wantStatusCode: http.StatusOK,
+			wantBody:       mustMarshal(defaultSentinel),
+		},
+		{
+			name:   "filter parsed",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("ListGenerations", args.r.Context(), &entity.GenerationFilter{
+						Types:    []string{"foo", "bar", "baz"},
+						Statuses: []entity.GenerationStatus{entity.GenerationFailed, entity.GenerationCanceled},
+						From:     from,
+						To:       to,
+						Cursor:   "abc",
+						Limit:    20,
+					}).
+					Return(defaultSentinel, nil)
+			},
+			args: defaultArgs("type=foo,bar&type=baz&status=failed,canceled" +
+				"&from=2021-03-01T00:00:00Z&to=2021-03-02T12:30:00Z&cursor=abc&limit=20"),
+			wantStatusCode: http.StatusOK,
+			wantBody:       mustMarshal(defaultSentinel),
+		},
+		{
+			name:           "invalid status",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs("status=foo"),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("%w: status: %s", rest.ErrInvalidQuery,
+					fmt.Errorf("%w: %q", entity.ErrInvalidGenerationStatus, "foo")).Error(),
+			}),
+		},
+		{
+			name:           "invalid from",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs("from=yesterday"),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody:       mustMarshal(map[string]string{"details": fmt.Errorf("%w: from: %q", rest.ErrInvalidQuery, "yesterday").Error()}),
+		},
+		{
+			name:           "invalid limit",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs("limit=-1"),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody:       mustMarshal(map[string]string{"details": fmt.Errorf("%w: limit: %q", rest.ErrInvalidQuery, "-1").Error()}),
+		},
+		{
+			name:   "invalid cursor",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("ListGenerations", args.r.Context(), &entity.GenerationFilter{Cursor: "foo"}).
+					Return(nil, cursorErr)
+			},
+			args:           defaultArgs("cursor=foo"),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody:       mustMarshal(map[string]string{"details": cursorErr.Error()}),
+		},
+		{
+			name:   "error in feeds.ListGenerations",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("ListGenerations", args.r.Context(), &entity.GenerationFilter{}).
+					Return(nil, defaultTestErr)
+			},
+			args:           defaultArgs(""),
+			wantStatusCode: http.StatusInternalServerError,
+			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setupMocks(testCase.fields, testCase.args)
+			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
+			h.ListGenerations(testCase.args.w, testCase.args.r)
+			gotStatusCode := testCase.args.w.Code
+			gotBody := testCase.args.w.Body.Bytes()
+			assert.Equal(t, testCase.wantStatusCode, gotStatusCode)
+			assert.Equal(t, testCase.wantBody, gotBody)
+			testCase.fields.feeds.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_handler_GetGeneration(t *testing.T) {
+	type args struct {
+		w            *httptest.ResponseRecorder
+		r            *http.Request
+		generationID string
+	}
+	defaultArgs := func(generationID string) *args {
+		request := &http.Request{}
+		if generationID != "" {
+			vars := map[string]string{"generation-id": generationID}
+			request = mux.SetURLVars(request, vars)
+		}
+		return &args{
+			w:            httptest.NewRecorder(),
+			r:            request,
+			generationID: generationID,
+		}
+	}
+	notFoundErr := fmt.Errorf("%w: foobar", entity.ErrGenerationNotFound)
+	testCases := []struct {
+		name           string
+		fields         *handlerFields
+		setupMocks     func(*handlerFields, *args)
+		args           *args
+		wantStatusCode int
+		wantBody       []byte
+	}{
+		{
+			name:   "succeed",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("GetGeneration", args.r.Context(), args.generationID).
+					Return(defaultSentinel, nil)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusOK,
+			wantBody:       mustMarshal(defaultSentinel),
+		},
+		{
+			name:   "unknown generation",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("GetGeneration", args.r.Context(), args.generationID).
+					Return(nil, notFoundErr)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       mustMarshal(map[string]string{"details": notFoundErr.Error()}),
+		},
+		{
+			name:   "error in feeds.GetGeneration",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("GetGeneration", args.r.Context(), args.generationID).
+					Return(nil, defaultTestErr)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
+		},
+		{
+			name:           "empty generation id",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs(""),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("looking for generation-id: %w",
+					rest.ErrValueNotFoundInURL).Error(),
+			}),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setupMocks(testCase.fields, testCase.args)
+			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
+			h.GetGeneration(testCase.args.w, testCase.args.r)
+			gotStatusCode := testCase.args.w.Code
+			gotBody := testCase.args.w.Body.Bytes()
+			assert.Equal(t, testCase.wantStatusCode, gotStatusCode)
+			assert.Equal(t, testCase.wantBody, gotBody)
+			testCase.fields.feeds.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_handler_ListGenerationFiles(t *testing.T) {
+	type args struct {
+		w            *httptest.ResponseRecorder
+		r            *http.Request
+		generationID string
+	}
+	defaultArgs := func(generationID string) *args {
+		request := &http.Request{}
+		if generationID != "" {
+			vars := map[string]string{"generation-id": generationID}
+			request = mux.SetURLVars(request, vars)
+		}
+		return &args{
+			w:            httptest.NewRecorder(),
+			r:            request,
+			generationID: generationID,
+		}
+	}
+	notFoundErr := fmt.Errorf("%w: foobar", entity.ErrGenerationNotFound)
+	testCases := []struct {
+		name           string
+		fields         *handlerFields
+		setupMocks     func(*handlerFields, *args)
+		args           *args
+		wantStatusCode int
+		wantBody       []byte
+	}{
+		{
+			name:   "succeed",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("ListGenerationFiles", args.r.Context(), args.generationID).
+					Return(defaultSentinel, nil)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusOK,
+			wantBody:       mustMarshal(defaultSentinel),
+		},
+		{
+			name:   "unknown generation",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("ListGenerationFiles", args.r.Context(), args.generationID).
+					Return(nil, notFoundErr)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       mustMarshal(map[string]string{"details": notFoundErr.Error()}),
+		},
+		{
+			name:   "error in feeds.ListGenerationFiles",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("ListGenerationFiles", args.r.Context(), args.generationID).
+					Return(nil, defaultTestErr)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
+		},
+		{
+			name:           "empty generation id",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs(""),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("looking for generation-id: %w",
+					rest.ErrValueNotFoundInURL).Error(),
+			}),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setupMocks(testCase.fields, testCase.args)
+			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
+			h.ListGenerationFiles(testCase.args.w, testCase.args.r)
+			gotStatusCode := testCase.args.w.Code
+			gotBody := testCase.args.w.Body.Bytes()
+			assert.Equal(t, testCase.wantStatusCode, gotStatusCode)
+			assert.Equal(t, testCase.wantBody, gotBody)
+			testCase.fields.feeds.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_handler_GetGenerationFile(t *testing.T) {
+	type args struct {
+		w            *httptest.ResponseRecorder
+		r            *http.Request
+		generationID string
+		filename     string
+	}
+	defaultArgs := func(query string, header http.Header, vars map[string]string) *args {
+		request := httptest.NewRequest(http.MethodGet, "/generations/id/foobar/files/DE/test_0.csv"+query, nil)
+		for key, values := range header {
+			request.Header[key] = values
+		}
+		return &args{
+			w:            httptest.NewRecorder(),
+			r:            mux.SetURLVars(request, vars),
+			generationID: vars["generation-id"],
+			filename:     vars["filename"],
+		}
+	}
+	defaultVars := map[string]string{"generation-id": "foobar", "filename": "DE/test_0.csv"}
+	content := "id,name\n1,a\n"
+	openFile := func(t *testing.T) *os.File {
+		file, err := ioutil.TempFile(t.TempDir(), "archived")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	notFoundErr := fmt.Errorf("%w: DE/test_0.csv", entity.ErrFileNotFound)
+	testCases := []struct {
+		name           string
+		fields         *handlerFields
+		setupMocks     func(*testing.T, *handlerFields, *args)
+		args           *args
+		wantStatusCode int
+		wantHeader     http.Header
+		wantBody       []byte
+	}{
+		{
+			name:   "download",
+			fields: defaultHandlerFields(),
+			setupMocks: func(t *testing.T, fields *handlerFields, args *args) {
+				fields.feeds.
+					On("OpenGenerationFile", args.r.Context(), args.generationID, args.filename).
+					Return(openFile(t), nil)
+			},
+			args:           defaultArgs("", nil, defaultVars),
+			wantStatusCode: http.StatusOK,
+			wantHeader: http.Header{
+				"Content-Disposition": {`attachment; filename="test_0.csv"`},
+				"Content-Length":      {fmt.Sprint(len(content))},
+			},
+			wantBody: []byte(content),
+		},
+		{
+			name:   "download range",
+			fields: defaultHandlerFields(),
+			setupMocks: func(t *testing.T, fields *handlerFields, args *args) {
+				fields.feeds.
+					On("OpenGenerationFile", args.r.Context(), args.generationID, args.filename).
+					Return(openFile(t), nil)
+			},
+			args:           defaultArgs("", http.Header{"Range": {"bytes=8-"}}, defaultVars),
+			wantStatusCode: http.StatusPartialContent,
+			wantHeader: http.Header{
+				"Content-Range": {fmt.Sprintf("bytes 8-%d/%d", len(content)-1, len(content))},
+			},
+			wantBody: []byte(content[8:]),
+		},
+		{
+			name:   "preview",
+			fields: defaultHandlerFields(),
+			setupMocks: func(t *testing.T, fields *handlerFields, args *args) {
+				fields.feeds.
+					On("PreviewGenerationFile", args.r.Context(), args.generationID, args.filename, uint(5)).
+					Return(defaultSentinel, nil)
+			},
+			args:           defaultArgs("?preview=5", nil, defaultVars),
+			wantStatusCode: http.StatusOK,
+			wantBody:       mustMarshal(defaultSentinel),
+		},
+		{
+			name:   "unknown file",
+			fields: defaultHandlerFields(),
+			setupMocks: func(t *testing.T, fields *handlerFields, args *args) {
+				fields.feeds.
+					On("OpenGenerationFile", args.r.Context(), args.generationID, args.filename).
+					Return(nil, notFoundErr)
+			},
+			args:           defaultArgs("", nil, defaultVars),
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       mustMarshal(map[string]string{"details": notFoundErr.Error()}),
+		},
+		{
+			name:   "error in feeds.PreviewGenerationFile",
+			fields: defaultHandlerFields(),
+			setupMocks: func(t *testing.T, fields *handlerFields, args *args) {
+				fields.feeds.
+					On("PreviewGenerationFile", args.r.Context(), args.generationID, args.filename, uint(5)).
+					Return(nil, defaultTestErr)
+			},
+			args:           defaultArgs("?preview=5", nil, defaultVars),
+			wantStatusCode: http.StatusInternalServerError,
+			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
+		},
+		{
+			name:           "invalid preview",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(t *testing.T, fields *handlerFields, args *args) {},
+			args:           defaultArgs("?preview=5000", nil, defaultVars),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("%w: preview must be between 1 and %d: %q",
+					rest.ErrInvalidQuery, rest.MaxPreviewRows, "5000").Error(),
+			}),
+		},
+		{
+			name:           "empty filename",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(t *testing.T, fields *handlerFields, args *args) {},
+			args:           defaultArgs("", nil, map[string]string{"generation-id": "foobar"}),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("looking for filename: %w",
+					rest.ErrValueNotFoundInURL).Error(),
+			}),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setupMocks(t, testCase.fields, testCase.args)
+			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
+			h.GetGenerationFile(testCase.args.w, testCase.args.r)
+			gotStatusCode := testCase.args.w.Code
+			gotBody := testCase.args.w.Body.Bytes()
+			assert.Equal(t, testCase.wantStatusCode, gotStatusCode)
+			assert.Equal(t, testCase.wantBody, gotBody)
+			for key := range testCase.wantHeader {
+				assert.Equal(t, testCase.wantHeader.Get(key), testCase.args.w.Header().Get(key))
+			}
+			testCase.fields.feeds.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_handler_GetGenerationLogs(t *testing.T) {
+	type args struct {
+		w            *httptest.ResponseRecorder
+		r            *http.Request
+		generationID string
+	}
+	defaultArgs := func(query string, vars map[string]string) *args {
+		request := httptest.NewRequest(http.MethodGet, "/generations/id/foobar/logs"+query, nil)
+		return &args{
+			w:            httptest.NewRecorder(),
+			r:            mux.SetURLVars(request, vars),
+			generationID: vars["generation-id"],
+		}
+	}
+	defaultVars := map[string]string{"generation-id": "foobar"}
+	notFoundErr := fmt.Errorf("%w: foobar", entity.ErrGenerationNotFound)
+	followLogs := func(entries ...interface{}) func(mock.Arguments) {
+		return func(args mock.Arguments) {
+			outStream := args.Get(2).(chan<- interface{})
+			for _, entry := range entries {
+				outStream <- entry
+			}
+		}
+	}
+	testCases := []struct {
+		name           string
+		fields         *handlerFields
+		setupMocks     func(*handlerFields, *args)
+		args           *args
+		wantStatusCode int
+		wantHeader     http.Header
+		wantBody       []byte
+	}{
+		{
+			name:   "succeed",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("GetGenerationLogs", args.r.Context(), args.generationID).
+					Return(defaultSentinel, nil)
+			},
+			args:           defaultArgs("", defaultVars),
+			wantStatusCode: http.StatusOK,
+			wantBody:       mustMarshal(defaultSentinel),
+		},
+		{
+			name:   "unknown generation",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("GetGenerationLogs", args.r.Context(), args.generationID).
+					Return(nil, notFoundErr)
+			},
+			args:           defaultArgs("", defaultVars),
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       mustMarshal(map[string]string{"details": notFoundErr.Error()}),
+		},
+		{
+			name:   "error in feeds.GetGenerationLogs",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("GetGenerationLogs", args.r.Context(), args.generationID).
+					Return(nil, defaultTestErr)
+			},
+			args:           defaultArgs("", defaultVars),
+			wantStatusCode: http.StatusInternalServerError,
+			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
+		},
+		{
+			name:   "follow",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("GetGeneration", args.r.Context(), args.generationID).
+					Return(defaultSentinel, nil)
+				fields.feeds.
+					On("FollowGenerationLogs", mock.Anything, args.generationID, mock.Anything).
+					Run(followLogs("Started", "Finished")).
+					Return(nil)
+			},
+			args:           defaultArgs("?follow=true", defaultVars),
+			wantStatusCode: http.StatusOK,
+			wantHeader:     http.Header{"Content-Type": {"text/event-stream"}},
+			wantBody:       []byte("data: \"Started\"\n\ndata: \"Finished\"\n\n"),
+		},
+		{
+			name:   "follow unknown generation",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("GetGeneration", args.r.Context(), args.generationID).
+					Return(nil, notFoundErr)
+			},
+			args:           defaultArgs("?follow=true", defaultVars),
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       mustMarshal(map[string]string{"details": notFoundErr.Error()}),
+		},
+		{
+			name:   "error in feeds.FollowGenerationLogs",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("GetGeneration", args.r.Context(), args.generationID).
+					Return(defaultSentinel, nil)
+				fields.feeds.
+					On("FollowGenerationLogs", mock.Anything, args.generationID, mock.Anything).
+					Run(followLogs("Started")).
+					Return(defaultTestErr)
+			},
+			args:           defaultArgs("?follow=true", defaultVars),
+			wantStatusCode: http.StatusOK,
+			wantHeader:     http.Header{"Content-Type": {"text/event-stream"}},
+			wantBody: []byte(fmt.Sprintf("data: \"Started\"\n\nevent: error\ndata: {\"details\":%q}\n\n",
+				defaultTestErr.Error())),
+		},
+		{
+			name:           "invalid follow",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs("?follow=maybe", defaultVars),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("%w: follow: %q", rest.ErrInvalidQuery, "maybe").Error(),
+			}),
+		},
+		{
+			name:           "empty generation id",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs("", nil),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("looking for generation-id: %w",
+					rest.ErrValueNotFoundInURL).Error(),
+			}),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setupMocks(testCase.fields, testCase.args)
+			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
+			h.GetGenerationLogs(testCase.args.w, testCase.args.r)
+			assert.Equal(t, testCase.wantStatusCode, testCase.args.w.Code)
+			for key := range testCase.wantHeader {
+				assert.Equal(t, testCase.wantHeader.Get(key), testCase.args.w.Header().Get(key))
+			}
+			assert.Equal(t, string(testCase.wantBody), testCase.args.w.Body.String())
+			testCase.fields.feeds.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_handler_ListGenerationTypes(t *testing.T) {
 	type args struct {
 		w *httptest.ResponseRecorder
 		r *http.Request
 	}
 	defaultArgs := func() *args {
 		return &args{
-			w: httptest.NewRecorder(),
-			r: &http.Request{},
+			w: httptest.NewRecorder(),
+			r: &http.Request{},
+		}
+	}
+	testCases := []struct {
+		name           string
+		fields         *handlerFields
+		setupMocks     func(*handlerFields, *args)
+		args           *args
+		wantStatusCode int
+		wantBody       []byte
+	}{
+		{
+			name:   "succeed",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("ListGenerationTypes", args.r.Context()).
+					Return(defaultSentinel, nil)
+			},
+			args:           defaultArgs(),
+			wantStatusCode: http.StatusOK,
+			wantBody:       mustMarshal(defaultSentinel),
+		},
+		{
+			name:   "error in feeds.ListGenerationTypes",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("ListGenerationTypes", args.r.Context()).
+					Return(nil, defaultTestErr)
+			},
+			args:           defaultArgs(),
+			wantStatusCode: http.StatusInternalServerError,
+			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setupMocks(testCase.fields, testCase.args)
+			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
+			h.ListGenerationTypes(testCase.args.w, testCase.args.r)
+			gotStatusCode := testCase.args.w.Code
+			gotBody := testCase.args.w.Body.Bytes()
+			assert.Equal(t, testCase.wantStatusCode, gotStatusCode)
+			assert.Equal(t, testCase.wantBody, gotBody)
+			testCase.fields.feeds.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_handler_GenerateFeed(t *testing.T) {
+	type args struct {
+		w              *httptest.ResponseRecorder
+		r              *http.Request
+		generationType string
+	}
+	defaultArgs := func(generationType string) *args {
+		request := &http.Request{}
+		if generationType != "" {
+			vars := map[string]string{"generation-type": generationType}
+			request = mux.SetURLVars(request, vars)
+		}
+		return &args{
+			w:              httptest.NewRecorder(),
+			r:              request,
+			generationType: generationType,
+		}
+	}
+	testCases := []struct {
+		name           string
+		fields         *handlerFields
+		setupMocks     func(*handlerFields, *args)
+		args           *args
+		wantStatusCode int
+		wantBody       []byte
+	}{
+		{
+			name:   "succeed",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("GenerateFeed", mock.Anything, args.generationType).
+					Return(nil)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:   "error in feeds.ListGenerationTypes",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("GenerateFeed", mock.Anything, args.generationType).
+					Return(defaultTestErr)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
+		},
+		{
+			name:           "empty generation type",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs(""),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("looking for generation-type: %w",
+					rest.ErrValueNotFoundInURL).Error(),
+			}),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setupMocks(testCase.fields, testCase.args)
+			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
+			h.GenerateFeed(testCase.args.w, testCase.args.r)
+			gotStatusCode := testCase.args.w.Code
+			gotBody := testCase.args.w.Body.Bytes()
+			assert.Equal(t, testCase.wantStatusCode, gotStatusCode)
+			assert.Equal(t, testCase.wantBody, gotBody)
+			testCase.fields.feeds.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_handler_PreviewFeed(t *testing.T) {
+	type args struct {
+		w              *httptest.ResponseRecorder
+		r              *http.Request
+		generationType string
+	}
+	defaultArgs := func(generationType, query string) *args {
+		request := httptest.NewRequest(http.MethodPost, "/generations/types/foobar/preview"+query, nil)
+		if generationType != "" {
+			vars := map[string]string{"generation-type": generationType}
+			request = mux.SetURLVars(request, vars)
+		}
+		return &args{
+			w:              httptest.NewRecorder(),
+			r:              request,
+			generationType: generationType,
 		}
 	}
+	notSupportedErr := fmt.Errorf("%w: foobar", entity.ErrPreviewNotSupported)
 	testCases := []struct {
 		name           string
 		fields         *handlerFields
@@ -52,35 +797,115 @@ func Test_handler_ListGenerations(t *testing.T) {
 		wantBody       []byte
 	}{
 		{
-			name:   "succeed",
+			name:   "default rows",
 			fields: defaultHandlerFields(),
 			setupMocks: func(fields *handlerFields, args *args) {
 				fields.feeds.
-					On("ListGenerations", args.r.Context()).
+					On("PreviewFeed", args.r.Context(), args.generationType, uint(rest.DefaultPreviewRows)).
 					Return(defaultSentinel, nil)
 			},
-			args:           defaultArgs(),
+			args:           defaultArgs("foobar", ""),
 			wantStatusCode: http.StatusOK,
 			wantBody:       mustMarshal(defaultSentinel),
 		},
 		{
-			name:   "error in feeds.ListGenerations",
+			name:   "rows",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("PreviewFeed", args.r.Context(), args.generationType, uint(50)).
+					Return(defaultSentinel, nil)
+			},
+			args:           defaultArgs("foobar", "?rows=50&dry_run=false"),
+			wantStatusCode: http.StatusOK,
+			wantBody:       mustMarshal(defaultSentinel),
+		},
+		{
+			name:   "dry run",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("DryRunFeed", args.r.Context(), args.generationType).
+					Return(defaultSentinel, nil)
+			},
+			args:           defaultArgs("foobar", "?dry_run=true"),
+			wantStatusCode: http.StatusOK,
+			wantBody:       mustMarshal(defaultSentinel),
+		},
+		{
+			name:   "unknown generation type",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("PreviewFeed", args.r.Context(), args.generationType, uint(rest.DefaultPreviewRows)).
+					Return(nil, entity.ErrInvalidGenerationType)
+			},
+			args:           defaultArgs("foobar", ""),
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       mustMarshal(map[string]string{"details": entity.ErrInvalidGenerationType.Error()}),
+		},
+		{
+			name:   "preview not supported",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("DryRunFeed", args.r.Context(), args.generationType).
+					Return(nil, notSupportedErr)
+			},
+			args:           defaultArgs("foobar", "?dry_run=true"),
+			wantStatusCode: http.StatusNotImplemented,
+			wantBody:       mustMarshal(map[string]string{"details": notSupportedErr.Error()}),
+		},
+		{
+			name:   "error in feeds.PreviewFeed",
 			fields: defaultHandlerFields(),
 			setupMocks: func(fields *handlerFields, args *args) {
 				fields.feeds.
-					On("ListGenerations", args.r.Context()).
+					On("PreviewFeed", args.r.Context(), args.generationType, uint(rest.DefaultPreviewRows)).
 					Return(nil, defaultTestErr)
 			},
-			args:           defaultArgs(),
+			args:           defaultArgs("foobar", ""),
 			wantStatusCode: http.StatusInternalServerError,
 			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
 		},
+		{
+			name:           "invalid rows",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs("foobar", "?rows=0"),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("%w: rows must be between 1 and %d: %q",
+					rest.ErrInvalidQuery, rest.MaxPreviewRows, "0").Error(),
+			}),
+		},
+		{
+			name:           "invalid dry_run",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs("foobar", "?dry_run=maybe"),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("%w: dry_run: %q", rest.ErrInvalidQuery, "maybe").Error(),
+			}),
+		},
+		{
+			name:           "empty generation type",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs("", ""),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("looking for generation-type: %w",
+					rest.ErrValueNotFoundInURL).Error(),
+			}),
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
 			testCase.setupMocks(testCase.fields, testCase.args)
 			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
-			h.ListGenerations(testCase.args.w, testCase.args.r)
+			h.PreviewFeed(testCase.args.w, testCase.args.r)
 			gotStatusCode := testCase.args.w.Code
 			gotBody := testCase.args.w.Body.Bytes()
 			assert.Equal(t, testCase.wantStatusCode, gotStatusCode)
@@ -90,15 +915,22 @@ func Test_handler_ListGenerations(t *testing.T) {
 	}
 }
 
-func Test_handler_ListGenerationTypes(t *testing.T) {
+func Test_handler_CancelGeneration(t *testing.T) {
 	type args struct {
-		w *httptest.ResponseRecorder
-		r *http.Request
+		w            *httptest.ResponseRecorder
+		r            *http.Request
+		generationID string
 	}
-	defaultArgs := func() *args {
+	defaultArgs := func(generationID string) *args {
+		request := &http.Request{}
+		if generationID != "" {
+			vars := map[string]string{"generation-id": generationID}
+			request = mux.SetURLVars(request, vars)
+		}
 		return &args{
-			w: httptest.NewRecorder(),
-			r: &http.Request{},
+			w:            httptest.NewRecorder(),
+			r:            request,
+			generationID: generationID,
 		}
 	}
 	testCases := []struct {
@@ -114,31 +946,41 @@ func Test_handler_ListGenerationTypes(t *testing.T) {
 			fields: defaultHandlerFields(),
 			setupMocks: func(fields *handlerFields, args *args) {
 				fields.feeds.
-					On("ListGenerationTypes", args.r.Context()).
-					Return(defaultSentinel, nil)
+					On("CancelGeneration", args.r.Context(), args.generationID).
+					Return(nil)
 			},
-			args:           defaultArgs(),
-			wantStatusCode: http.StatusOK,
-			wantBody:       mustMarshal(defaultSentinel),
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusAccepted,
 		},
 		{
-			name:   "error in feeds.ListGenerationTypes",
+			name:   "error in feeds.CancelGeneration",
 			fields: defaultHandlerFields(),
 			setupMocks: func(fields *handlerFields, args *args) {
 				fields.feeds.
-					On("ListGenerationTypes", args.r.Context()).
-					Return(nil, defaultTestErr)
+					On("CancelGeneration", args.r.Context(), args.generationID).
+					Return(defaultTestErr)
 			},
-			args:           defaultArgs(),
+			args:           defaultArgs("foobar"),
 			wantStatusCode: http.StatusInternalServerError,
 			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
 		},
+		{
+			name:           "empty generation id",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs(""),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("looking for generation-id: %w",
+					rest.ErrValueNotFoundInURL).Error(),
+			}),
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
 			testCase.setupMocks(testCase.fields, testCase.args)
 			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
-			h.ListGenerationTypes(testCase.args.w, testCase.args.r)
+			h.CancelGeneration(testCase.args.w, testCase.args.r)
 			gotStatusCode := testCase.args.w.Code
 			gotBody := testCase.args.w.Body.Bytes()
 			assert.Equal(t, testCase.wantStatusCode, gotStatusCode)
@@ -148,14 +990,14 @@ func Test_handler_ListGenerationTypes(t *testing.T) {
 	}
 }
 
-func Test_handler_GenerateFeed(t *testing.T) {
+func Test_handler_RollbackGeneration(t *testing.T) {
 	type args struct {
 		w              *httptest.ResponseRecorder
 		r              *http.Request
 		generationType string
 	}
-	defaultArgs := func(generationType string) *args {
-		request := &http.Request{}
+	defaultArgs := func(generationType, body string) *args {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
 		if generationType != "" {
 			vars := map[string]string{"generation-type": generationType}
 			request = mux.SetURLVars(request, vars)
@@ -175,33 +1017,66 @@ func Test_handler_GenerateFeed(t *testing.T) {
 		wantBody       []byte
 	}{
 		{
-			name:   "succeed",
+			name:   "previous version",
 			fields: defaultHandlerFields(),
 			setupMocks: func(fields *handlerFields, args *args) {
 				fields.feeds.
-					On("GenerateFeed", mock.Anything, args.generationType).
+					On("RollbackGeneration", args.r.Context(), args.generationType, "").
 					Return(nil)
 			},
-			args:           defaultArgs("foobar"),
-			wantStatusCode: http.StatusCreated,
+			args:           defaultArgs("foobar", ""),
+			wantStatusCode: http.StatusOK,
 		},
 		{
-			name:   "error in feeds.ListGenerationTypes",
+			name:   "chosen version",
 			fields: defaultHandlerFields(),
 			setupMocks: func(fields *handlerFields, args *args) {
 				fields.feeds.
-					On("GenerateFeed", mock.Anything, args.generationType).
+					On("RollbackGeneration", args.r.Context(), args.generationType, "42").
+					Return(nil)
+			},
+			args:           defaultArgs("foobar", `{"generation_id":"42"}`),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "version not found",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("RollbackGeneration", args.r.Context(), args.generationType, "42").
+					Return(entity.ErrVersionNotFound)
+			},
+			args:           defaultArgs("foobar", `{"generation_id":"42"}`),
+			wantStatusCode: http.StatusNotFound,
+			wantBody:       mustMarshal(map[string]string{"details": entity.ErrVersionNotFound.Error()}),
+		},
+		{
+			name:   "error in feeds.RollbackGeneration",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("RollbackGeneration", args.r.Context(), args.generationType, "").
 					Return(defaultTestErr)
 			},
-			args:           defaultArgs("foobar"),
+			args:           defaultArgs("foobar", ""),
 			wantStatusCode: http.StatusInternalServerError,
 			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
 		},
+		{
+			name:           "invalid body",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs("foobar", "{"),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("%w: %s", rest.ErrReadingRequestBody, io.ErrUnexpectedEOF).Error(),
+			}),
+		},
 		{
 			name:           "empty generation type",
 			fields:         defaultHandlerFields(),
 			setupMocks:     func(fields *handlerFields, args *args) {},
-			args:           defaultArgs(""),
+			args:           defaultArgs("", ""),
 			wantStatusCode: http.StatusBadRequest,
 			wantBody: mustMarshal(map[string]string{
 				"details": fmt.Errorf("looking for generation-type: %w",
@@ -213,7 +1088,7 @@ func Test_handler_GenerateFeed(t *testing.T) {
 		t.Run(testCase.name, func(t *testing.T) {
 			testCase.setupMocks(testCase.fields, testCase.args)
 			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
-			h.GenerateFeed(testCase.args.w, testCase.args.r)
+			h.RollbackGeneration(testCase.args.w, testCase.args.r)
 			gotStatusCode := testCase.args.w.Code
 			gotBody := testCase.args.w.Body.Bytes()
 			assert.Equal(t, testCase.wantStatusCode, gotStatusCode)
@@ -223,7 +1098,7 @@ func Test_handler_GenerateFeed(t *testing.T) {
 	}
 }
 
-func Test_handler_CancelGeneration(t *testing.T) {
+func Test_handler_PurgeGeneration(t *testing.T) {
 	type args struct {
 		w            *httptest.ResponseRecorder
 		r            *http.Request
@@ -241,6 +1116,7 @@ func Test_handler_CancelGeneration(t *testing.T) {
 			generationID: generationID,
 		}
 	}
+	runningErr := fmt.Errorf("%w: foobar", entity.ErrGenerationRunning)
 	testCases := []struct {
 		name           string
 		fields         *handlerFields
@@ -254,24 +1130,36 @@ func Test_handler_CancelGeneration(t *testing.T) {
 			fields: defaultHandlerFields(),
 			setupMocks: func(fields *handlerFields, args *args) {
 				fields.feeds.
-					On("CancelGeneration", args.r.Context(), args.generationID).
+					On("PurgeGeneration", args.r.Context(), args.generationID).
 					Return(nil)
 			},
 			args:           defaultArgs("foobar"),
-			wantStatusCode: http.StatusAccepted,
+			wantStatusCode: http.StatusNoContent,
 		},
 		{
-			name:   "error in feeds.CancelGeneration",
+			name:   "error in feeds.PurgeGeneration",
 			fields: defaultHandlerFields(),
 			setupMocks: func(fields *handlerFields, args *args) {
 				fields.feeds.
-					On("CancelGeneration", args.r.Context(), args.generationID).
+					On("PurgeGeneration", args.r.Context(), args.generationID).
 					Return(defaultTestErr)
 			},
 			args:           defaultArgs("foobar"),
 			wantStatusCode: http.StatusInternalServerError,
 			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
 		},
+		{
+			name:   "generation running",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("PurgeGeneration", args.r.Context(), args.generationID).
+					Return(runningErr)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusConflict,
+			wantBody:       mustMarshal(map[string]string{"details": runningErr.Error()}),
+		},
 		{
 			name:           "empty generation id",
 			fields:         defaultHandlerFields(),
@@ -288,7 +1176,7 @@ func Test_handler_CancelGeneration(t *testing.T) {
 		t.Run(testCase.name, func(t *testing.T) {
 			testCase.setupMocks(testCase.fields, testCase.args)
 			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
-			h.CancelGeneration(testCase.args.w, testCase.args.r)
+			h.PurgeGeneration(testCase.args.w, testCase.args.r)
 			gotStatusCode := testCase.args.w.Code
 			gotBody := testCase.args.w.Body.Bytes()
 			assert.Equal(t, testCase.wantStatusCode, gotStatusCode)
@@ -373,6 +1261,105 @@ func Test_handler_RestartGeneration(t *testing.T) {
 	}
 }
 
+func Test_handler_RetryUpload(t *testing.T) {
+	type args struct {
+		w            *httptest.ResponseRecorder
+		r            *http.Request
+		generationID string
+	}
+	defaultArgs := func(generationID string) *args {
+		request := &http.Request{}
+		if generationID != "" {
+			vars := map[string]string{"generation-id": generationID}
+			request = mux.SetURLVars(request, vars)
+		}
+		return &args{
+			w:            httptest.NewRecorder(),
+			r:            request,
+			generationID: generationID,
+		}
+	}
+	testCases := []struct {
+		name           string
+		fields         *handlerFields
+		setupMocks     func(*handlerFields, *args)
+		args           *args
+		wantStatusCode int
+		wantBody       []byte
+	}{
+		{
+			name:   "succeed",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("RetryUpload", args.r.Context(), args.generationID).
+					Return(nil)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:   "error in feeds.RetryUpload",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("RetryUpload", args.r.Context(), args.generationID).
+					Return(defaultTestErr)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantBody:       mustMarshal(map[string]string{"details": defaultTestErr.Error()}),
+		},
+		{
+			name:   "generation running",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("RetryUpload", args.r.Context(), args.generationID).
+					Return(entity.ErrGenerationRunning)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusConflict,
+			wantBody:       mustMarshal(map[string]string{"details": entity.ErrGenerationRunning.Error()}),
+		},
+		{
+			name:   "generation succeeded",
+			fields: defaultHandlerFields(),
+			setupMocks: func(fields *handlerFields, args *args) {
+				fields.feeds.
+					On("RetryUpload", args.r.Context(), args.generationID).
+					Return(entity.ErrGenerationSucceeded)
+			},
+			args:           defaultArgs("foobar"),
+			wantStatusCode: http.StatusConflict,
+			wantBody:       mustMarshal(map[string]string{"details": entity.ErrGenerationSucceeded.Error()}),
+		},
+		{
+			name:           "empty generation id",
+			fields:         defaultHandlerFields(),
+			setupMocks:     func(fields *handlerFields, args *args) {},
+			args:           defaultArgs(""),
+			wantStatusCode: http.StatusBadRequest,
+			wantBody: mustMarshal(map[string]string{
+				"details": fmt.Errorf("looking for generation-id: %w",
+					rest.ErrValueNotFoundInURL).Error(),
+			}),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setupMocks(testCase.fields, testCase.args)
+			h := rest.NewHandler(testCase.fields.feeds, testCase.fields.scheduler)
+			h.RetryUpload(testCase.args.w, testCase.args.r)
+			gotStatusCode := testCase.args.w.Code
+			gotBody := testCase.args.w.Body.Bytes()
+			assert.Equal(t, testCase.wantStatusCode, gotStatusCode)
+			assert.Equal(t, testCase.wantBody, gotBody)
+			testCase.fields.feeds.AssertExpectations(t)
+		})
+	}
+}
+
 func Test_handler_ScheduleGeneration(t *testing.T) {
 	type args struct {
 		w              *httptest.ResponseRecorder
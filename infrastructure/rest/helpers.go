@@ -1,21 +1,48 @@
 package rest
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 
+	"go-feedmaker/entity"
+	"go-feedmaker/infrastructure/rest/broadcaster"
 	"go-feedmaker/infrastructure/scheduler"
 )
 
 var (
 	ErrValueNotFoundInURL = errors.New("not found in url")
 	ErrReadingRequestBody = errors.New("reading request body")
+	ErrInvalidQuery       = errors.New("invalid query parameter")
+	ErrStreamUnsupported  = errors.New("streaming unsupported")
+)
+
+const (
+	// MaxPreviewRows limits how many rows of a file a preview returns.
+	MaxPreviewRows = 1000
+	// DefaultPreviewRows is how many records a feed preview formats unless
+	// the request sets rows.
+	DefaultPreviewRows = 10
+	// eventStreamKeepAlive is how often an idle event stream sends a comment,
+	// so proxies don't close it.
+	eventStreamKeepAlive = 15 * time.Second
+)
+
+type (
+	// eventStream writes server-sent events.
+	eventStream struct {
+		w       http.ResponseWriter
+		flusher http.Flusher
+	}
 )
 
 func errorResponse(w http.ResponseWriter, code int, err error) {
@@ -23,6 +50,19 @@ func errorResponse(w http.ResponseWriter, code int, err error) {
 	jsonResponse(w, code, body)
 }
 
+// generationErrorCode answers requests for unknown generations and files
+// with 404.
+func generationErrorCode(err error) int {
+	switch {
+	case errors.Is(err, entity.ErrGenerationNotFound), errors.Is(err, entity.ErrFileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, entity.ErrGenerationRunning), errors.Is(err, entity.ErrGenerationSucceeded):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func jsonResponse(w http.ResponseWriter, code int, body interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -35,6 +75,73 @@ func jsonResponse(w http.ResponseWriter, code int, body interface{}) {
 	}
 }
 
+func newEventStream(w http.ResponseWriter) (*eventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, ErrStreamUnsupported
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &eventStream{w: w, flusher: flusher}, nil
+}
+
+// send writes body as JSON data of an event; an empty event is a message.
+func (s *eventStream) send(event string, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Interface("body", body).
+			Msg("send event")
+		return
+	}
+	if err := s.write("", event, data); err != nil {
+		log.Error().
+			Err(err).
+			Msg("send event")
+	}
+}
+
+func (s *eventStream) keepAlive() {
+	if err := s.WriteComment("keep-alive"); err != nil {
+		log.Error().
+			Err(err).
+			Msg("keep-alive")
+	}
+}
+
+// WriteEvent writes a message; an empty id leaves the last event id of the
+// client as it is.
+func (s *eventStream) WriteEvent(id string, data []byte) error {
+	return s.write(id, "", data)
+}
+
+func (s *eventStream) WriteComment(text string) error {
+	if _, err := fmt.Fprintf(s.w, ": %s\n\n", text); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *eventStream) write(id, event string, data []byte) error {
+	var buf bytes.Buffer
+	if id != "" {
+		fmt.Fprintf(&buf, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event)
+	}
+	fmt.Fprintf(&buf, "data: %s\n\n", data)
+	if _, err := buf.WriteTo(s.w); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
 func extractGenerationType(r *http.Request) (string, error) {
 	return extractFromURL(r, "generation-type")
 }
@@ -43,6 +150,63 @@ func extractGenerationID(r *http.Request) (string, error) {
 	return extractFromURL(r, "generation-id")
 }
 
+func extractFilename(r *http.Request) (string, error) {
+	return extractFromURL(r, "filename")
+}
+
+// parsePreviewRows reads the preview parameter; ok is false when the file
+// itself is requested.
+func parsePreviewRows(r *http.Request) (rows uint, ok bool, err error) {
+	return queryRows(r.URL.Query(), "preview")
+}
+
+// parseFeedPreview reads how many records a feed preview formats; a dry run
+// formats all of them.
+func parseFeedPreview(r *http.Request) (rows uint, dryRun bool, err error) {
+	query := r.URL.Query()
+	if value := query.Get("dry_run"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			return 0, false, fmt.Errorf("%w: dry_run: %q", ErrInvalidQuery, value)
+		}
+		if dryRun {
+			return 0, true, nil
+		}
+	}
+	rows, ok, err := queryRows(query, "rows")
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		rows = DefaultPreviewRows
+	}
+	return rows, false, nil
+}
+
+func parseFollow(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("follow")
+	if value == "" {
+		return false, nil
+	}
+	follow, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: follow: %q", ErrInvalidQuery, value)
+	}
+	return follow, nil
+}
+
+func queryRows(query url.Values, key string) (uint, bool, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, false, nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || parsed == 0 || parsed > MaxPreviewRows {
+		return 0, false, fmt.Errorf("%w: %s must be between 1 and %d: %q", ErrInvalidQuery, key, MaxPreviewRows, value)
+	}
+	return uint(parsed), true, nil
+}
+
 func extractFromURL(r *http.Request, key string) (string, error) {
 	vars := mux.Vars(r)
 	value, found := vars[key]
@@ -52,6 +216,70 @@ func extractFromURL(r *http.Request, key string) (string, error) {
 	return value, nil
 }
 
+func parseGenerationFilter(r *http.Request) (*entity.GenerationFilter, error) {
+	query := r.URL.Query()
+	filter := &entity.GenerationFilter{
+		Types:  queryList(query, "type"),
+		Cursor: query.Get("cursor"),
+	}
+	for _, value := range queryList(query, "status") {
+		status, err := entity.ParseGenerationStatus(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: status: %s", ErrInvalidQuery, err.Error())
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+	var err error
+	if filter.From, err = queryTime(query, "from"); err != nil {
+		return nil, err
+	}
+	if filter.To, err = queryTime(query, "to"); err != nil {
+		return nil, err
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: limit: %q", ErrInvalidQuery, value)
+		}
+		filter.Limit = uint(limit)
+	}
+	return filter, nil
+}
+
+// parseSubscription reads the generation types and ids a client follows.
+func parseSubscription(r *http.Request) *broadcaster.Subscription {
+	query := r.URL.Query()
+	return &broadcaster.Subscription{
+		Types: queryList(query, "type"),
+		IDs:   queryList(query, "id"),
+	}
+}
+
+// queryList collects the values of a repeated or comma separated parameter.
+func queryList(query url.Values, key string) []string {
+	var res []string
+	for _, value := range query[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				res = append(res, item)
+			}
+		}
+	}
+	return res
+}
+
+func queryTime(query url.Values, key string) (time.Time, error) {
+	value := query.Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s: %q", ErrInvalidQuery, key, value)
+	}
+	return t, nil
+}
+
 func makeSchedulesOut(schedules map[scheduler.TaskID]*scheduler.Schedule) map[scheduler.TaskID]*scheduleOut {
 	schedulesOut := make(map[scheduler.TaskID]*scheduleOut, len(schedules))
 	for taskID, schedule := range schedules {
@@ -23,6 +23,26 @@ func (_m *Handler) GenerateFeed(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
 }
 
+// GetGeneration provides a mock function with given fields: w, r
+func (_m *Handler) GetGeneration(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// GetGenerationFile provides a mock function with given fields: w, r
+func (_m *Handler) GetGenerationFile(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// GetGenerationLogs provides a mock function with given fields: w, r
+func (_m *Handler) GetGenerationLogs(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// ListGenerationFiles provides a mock function with given fields: w, r
+func (_m *Handler) ListGenerationFiles(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
 // ListGenerationTypes provides a mock function with given fields: w, r
 func (_m *Handler) ListGenerationTypes(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
@@ -38,11 +58,31 @@ func (_m *Handler) ListSchedules(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
 }
 
+// PreviewFeed provides a mock function with given fields: w, r
+func (_m *Handler) PreviewFeed(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// PurgeGeneration provides a mock function with given fields: w, r
+func (_m *Handler) PurgeGeneration(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
 // RestartGeneration provides a mock function with given fields: w, r
 func (_m *Handler) RestartGeneration(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
 }
 
+// RetryUpload provides a mock function with given fields: w, r
+func (_m *Handler) RetryUpload(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// RollbackGeneration provides a mock function with given fields: w, r
+func (_m *Handler) RollbackGeneration(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
 // ScheduleGeneration provides a mock function with given fields: w, r
 func (_m *Handler) ScheduleGeneration(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
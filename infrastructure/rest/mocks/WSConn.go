@@ -4,6 +4,7 @@ package mocks
 
 import (
 	net "net"
+	time "time"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -27,6 +28,36 @@ func (_m *WSConn) Close() error {
 	return r0
 }
 
+// ReadMessage provides a mock function with given fields:
+func (_m *WSConn) ReadMessage() (int, []byte, error) {
+	ret := _m.Called()
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 []byte
+	if rf, ok := ret.Get(1).(func() []byte); ok {
+		r1 = rf()
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func() error); ok {
+		r2 = rf()
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // RemoteAddr provides a mock function with given fields:
 func (_m *WSConn) RemoteAddr() net.Addr {
 	ret := _m.Called()
@@ -43,6 +74,20 @@ func (_m *WSConn) RemoteAddr() net.Addr {
 	return r0
 }
 
+// SetWriteDeadline provides a mock function with given fields: t
+func (_m *WSConn) SetWriteDeadline(t time.Time) error {
+	ret := _m.Called(t)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(time.Time) error); ok {
+		r0 = rf(t)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // WriteMessage provides a mock function with given fields: _a0, _a1
 func (_m *WSConn) WriteMessage(_a0 int, _a1 []byte) error {
 	ret := _m.Called(_a0, _a1)
@@ -13,6 +13,11 @@ type WSHandler struct {
 	mock.Mock
 }
 
+// ServeSSE provides a mock function with given fields: w, r
+func (_m *WSHandler) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
 // ServeWS provides a mock function with given fields: w, r
 func (_m *WSHandler) ServeWS(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
@@ -10,10 +10,18 @@ import (
 type (
 	Handler interface {
 		ListGenerations(w http.ResponseWriter, r *http.Request)
+		GetGeneration(w http.ResponseWriter, r *http.Request)
 		ListGenerationTypes(w http.ResponseWriter, r *http.Request)
 		GenerateFeed(w http.ResponseWriter, r *http.Request)
+		PreviewFeed(w http.ResponseWriter, r *http.Request)
 		CancelGeneration(w http.ResponseWriter, r *http.Request)
+		PurgeGeneration(w http.ResponseWriter, r *http.Request)
 		RestartGeneration(w http.ResponseWriter, r *http.Request)
+		RetryUpload(w http.ResponseWriter, r *http.Request)
+		ListGenerationFiles(w http.ResponseWriter, r *http.Request)
+		GetGenerationFile(w http.ResponseWriter, r *http.Request)
+		GetGenerationLogs(w http.ResponseWriter, r *http.Request)
+		RollbackGeneration(w http.ResponseWriter, r *http.Request)
 		ScheduleGeneration(w http.ResponseWriter, r *http.Request)
 		ListSchedules(w http.ResponseWriter, r *http.Request)
 		UnscheduleGeneration(w http.ResponseWriter, r *http.Request)
@@ -21,6 +29,7 @@ type (
 
 	WSHandler interface {
 		ServeWS(w http.ResponseWriter, r *http.Request)
+		ServeSSE(w http.ResponseWriter, r *http.Request)
 	}
 )
 
@@ -34,9 +43,20 @@ func NewRouter(handler Handler, wsHandler WSHandler) http.Handler {
 	generations.HandleFunc("/types", handler.ListGenerationTypes).Methods(http.MethodGet)
 
 	generations.HandleFunc("/types/{generation-type}", handler.GenerateFeed).Methods(http.MethodPost)
+	generations.HandleFunc("/types/{generation-type}/preview", handler.PreviewFeed).Methods(http.MethodPost)
+	generations.HandleFunc("/types/{generation-type}/rollback", handler.RollbackGeneration).Methods(http.MethodPost)
 
+	generations.HandleFunc("/id/{generation-id}", handler.GetGeneration).Methods(http.MethodGet)
 	generations.HandleFunc("/id/{generation-id}", handler.RestartGeneration).Methods(http.MethodPost)
+	generations.HandleFunc("/id/{generation-id}", handler.PurgeGeneration).Methods(http.MethodDelete).
+		Queries("purge", "true")
 	generations.HandleFunc("/id/{generation-id}", handler.CancelGeneration).Methods(http.MethodDelete)
+	generations.HandleFunc("/id/{generation-id}/retry", handler.RetryUpload).Methods(http.MethodPost)
+	generations.HandleFunc("/id/{generation-id}/files", handler.ListGenerationFiles).Methods(http.MethodGet)
+	generations.HandleFunc("/id/{generation-id}/files/{filename:.+}", handler.GetGenerationFile).Methods(http.MethodGet)
+	generations.HandleFunc("/id/{generation-id}/logs", handler.GetGenerationLogs).Methods(http.MethodGet)
+
+	generations.HandleFunc("/progress", wsHandler.ServeSSE).Methods(http.MethodGet)
 
 	generations.HandleFunc("/schedules", handler.ListSchedules).Methods(http.MethodGet)
 	generations.HandleFunc("/types/{generation-type}/schedules", handler.ScheduleGeneration).Methods(http.MethodPost)
@@ -45,7 +65,7 @@ func NewRouter(handler Handler, wsHandler WSHandler) http.Handler {
 	ws := router.PathPrefix("/ws").Subrouter()
 	ws.HandleFunc("/progress", wsHandler.ServeWS)
 
-	headersOK := handlers.AllowedHeaders([]string{"Accept", "Content-Type", "Authorization"})
+	headersOK := handlers.AllowedHeaders([]string{"Accept", "Content-Type", "Authorization", "Last-Event-ID"})
 	originsOK := handlers.AllowedOrigins([]string{"*"})
 	methodsOK := handlers.AllowedMethods([]string{"GET", "POST", "OPTIONS", "DELETE", "PUT"})
 
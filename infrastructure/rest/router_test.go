@@ -70,6 +70,14 @@ func TestNewRouter(t *testing.T) {
 				fields.handler.On("GenerateFeed", mock.Anything, mock.Anything)
 			},
 		},
+		{
+			name:   "GET /generations/id/foobar",
+			fields: defaultRouterFields(),
+			args:   mustMakeArgs(http.MethodGet, "/generations/id/foobar"),
+			setupMocks: func(fields *routerFields) {
+				fields.handler.On("GetGeneration", mock.Anything, mock.Anything)
+			},
+		},
 		{
 			name:   "POST /generations/id/foobar",
 			fields: defaultRouterFields(),
@@ -86,6 +94,62 @@ func TestNewRouter(t *testing.T) {
 				fields.handler.On("CancelGeneration", mock.Anything, mock.Anything)
 			},
 		},
+		{
+			name:   "DELETE /generations/id/foobar?purge=true",
+			fields: defaultRouterFields(),
+			args:   mustMakeArgs(http.MethodDelete, "/generations/id/foobar?purge=true"),
+			setupMocks: func(fields *routerFields) {
+				fields.handler.On("PurgeGeneration", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name:   "GET /generations/id/foobar/files",
+			fields: defaultRouterFields(),
+			args:   mustMakeArgs(http.MethodGet, "/generations/id/foobar/files"),
+			setupMocks: func(fields *routerFields) {
+				fields.handler.On("ListGenerationFiles", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name:   "GET /generations/id/foobar/files/DE/test_0.csv",
+			fields: defaultRouterFields(),
+			args:   mustMakeArgs(http.MethodGet, "/generations/id/foobar/files/DE/test_0.csv"),
+			setupMocks: func(fields *routerFields) {
+				fields.handler.On("GetGenerationFile", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name:   "GET /generations/id/foobar/logs",
+			fields: defaultRouterFields(),
+			args:   mustMakeArgs(http.MethodGet, "/generations/id/foobar/logs"),
+			setupMocks: func(fields *routerFields) {
+				fields.handler.On("GetGenerationLogs", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name:   "POST /generations/id/foobar/retry",
+			fields: defaultRouterFields(),
+			args:   mustMakeArgs(http.MethodPost, "/generations/id/foobar/retry"),
+			setupMocks: func(fields *routerFields) {
+				fields.handler.On("RetryUpload", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name:   "POST /generations/types/foobar/preview",
+			fields: defaultRouterFields(),
+			args:   mustMakeArgs(http.MethodPost, "/generations/types/foobar/preview?dry_run=true"),
+			setupMocks: func(fields *routerFields) {
+				fields.handler.On("PreviewFeed", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name:   "POST /generations/types/foobar/rollback",
+			fields: defaultRouterFields(),
+			args:   mustMakeArgs(http.MethodPost, "/generations/types/foobar/rollback"),
+			setupMocks: func(fields *routerFields) {
+				fields.handler.On("RollbackGeneration", mock.Anything, mock.Anything)
+			},
+		},
 		{
 			name:   "POST /generations/types/foobar/schedules",
 			fields: defaultRouterFields(),
@@ -110,6 +174,14 @@ func TestNewRouter(t *testing.T) {
 				fields.handler.On("UnscheduleGeneration", mock.Anything, mock.Anything)
 			},
 		},
+		{
+			name:   "GET /generations/progress",
+			fields: defaultRouterFields(),
+			args:   mustMakeArgs(http.MethodGet, "/generations/progress"),
+			setupMocks: func(fields *routerFields) {
+				fields.wsHandler.On("ServeSSE", mock.Anything, mock.Anything)
+			},
+		},
 		{
 			name:   "WS /ws/progress",
 			fields: defaultRouterFields(),
@@ -8,6 +8,10 @@ type (
 		DelayInterval  int       `json:"delay_interval"`
 	}
 
+	rollbackIn struct {
+		GenerationID string `json:"generation_id"`
+	}
+
 	scheduleOut struct {
 		StartTimestamp string `json:"start_timestamp"`
 		DelayInterval  int    `json:"delay_interval"`
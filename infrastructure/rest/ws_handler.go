@@ -30,12 +30,31 @@ func NewWSHandler(upgrader Upgrader, broadcaster Broadcaster) *wsHandler {
 	}
 }
 
+// ServeWS streams the progress of generations over a websocket. Query
+// parameters type and id, repeated or comma separated, subscribe to some of
+// them; a subscribe message replaces the subscription later.
 func (h *wsHandler) ServeWS(w http.ResponseWriter, r *http.Request) {
+	subscription := parseSubscription(r)
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		errorResponse(w, http.StatusBadRequest, err)
 		return
 	}
 	recipient := broadcaster.NewRecipient(conn)
+	recipient.Subscribe(subscription)
 	h.broadcaster.Register(recipient)
 }
+
+// ServeSSE streams the progress of generations as server-sent events, for
+// clients that can't use a websocket. A client reconnecting with
+// Last-Event-ID first gets the updates it missed.
+func (h *wsHandler) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	stream, err := newEventStream(w)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	recipient := broadcaster.NewSSERecipient(stream, r.Header.Get("Last-Event-ID"), r.Context().Done())
+	h.broadcaster.Register(recipient)
+	<-recipient.Done()
+}
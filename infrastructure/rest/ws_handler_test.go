@@ -1,6 +1,7 @@
 package rest_test
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"go-feedmaker/infrastructure/rest"
+	"go-feedmaker/infrastructure/rest/broadcaster"
 	"go-feedmaker/infrastructure/rest/mocks"
 )
 
@@ -48,10 +50,15 @@ func Test_wsHandler_ServeWS(t *testing.T) {
 		w *httptest.ResponseRecorder
 		r *http.Request
 	}
-	defaultArgs := func() *args {
+	defaultArgs := func(query string) *args {
 		return &args{
 			w: httptest.NewRecorder(),
-			r: &http.Request{},
+			r: httptest.NewRequest(http.MethodGet, "/ws/progress"+query, nil),
+		}
+	}
+	subscribed := func(want *broadcaster.Subscription) func(mock.Arguments) {
+		return func(args mock.Arguments) {
+			assert.Equal(t, want, args.Get(0).(broadcaster.Subscriber).Subscription())
 		}
 	}
 	testCases := []struct {
@@ -64,17 +71,30 @@ func Test_wsHandler_ServeWS(t *testing.T) {
 		{
 			name:   "succeed",
 			fields: defaultWSHandlerFields(),
-			args:   defaultArgs(),
+			args:   defaultArgs(""),
 			setupMocks: func(fields *wsHandlerFields, args *args) {
 				fields.upgrader.On("Upgrade", mock.Anything, mock.Anything, mock.Anything).Return(defaultConn, nil)
-				fields.broadcaster.On("Register", mock.Anything)
+				fields.broadcaster.On("Register", mock.Anything).Run(subscribed(&broadcaster.Subscription{}))
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "subscribe",
+			fields: defaultWSHandlerFields(),
+			args:   defaultArgs("?type=first,second&id=42"),
+			setupMocks: func(fields *wsHandlerFields, args *args) {
+				fields.upgrader.On("Upgrade", mock.Anything, mock.Anything, mock.Anything).Return(defaultConn, nil)
+				fields.broadcaster.On("Register", mock.Anything).Run(subscribed(&broadcaster.Subscription{
+					Types: []string{"first", "second"},
+					IDs:   []string{"42"},
+				}))
 			},
 			wantStatusCode: http.StatusOK,
 		},
 		{
 			name:   "upgrade error",
 			fields: defaultWSHandlerFields(),
-			args:   defaultArgs(),
+			args:   defaultArgs(""),
 			setupMocks: func(fields *wsHandlerFields, args *args) {
 				fields.upgrader.On("Upgrade", mock.Anything, mock.Anything, mock.Anything).Return(nil, defaultTestErr)
 			},
@@ -92,3 +112,71 @@ func Test_wsHandler_ServeWS(t *testing.T) {
 		})
 	}
 }
+
+func Test_wsHandler_ServeSSE(t *testing.T) {
+	type args struct {
+		w *httptest.ResponseRecorder
+		r *http.Request
+	}
+	defaultArgs := func(lastEventID string) *args {
+		// The client is gone, so the stream ends right after it started.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		request := httptest.NewRequest(http.MethodGet, "/generations/progress", nil).WithContext(ctx)
+		if lastEventID != "" {
+			request.Header.Set("Last-Event-ID", lastEventID)
+		}
+		return &args{
+			w: httptest.NewRecorder(),
+			r: request,
+		}
+	}
+	startRecipient := func(wantLastEventID string) func(mock.Arguments) {
+		return func(args mock.Arguments) {
+			recipient := args.Get(0).(broadcaster.EventRecipient)
+			assert.Equal(t, wantLastEventID, recipient.LastEventID())
+			go args.Get(0).(broadcaster.Recipient).Start()
+		}
+	}
+	testCases := []struct {
+		name           string
+		fields         *wsHandlerFields
+		args           *args
+		setupMocks     func(*wsHandlerFields, *args)
+		wantStatusCode int
+		wantHeader     http.Header
+	}{
+		{
+			name:   "succeed",
+			fields: defaultWSHandlerFields(),
+			args:   defaultArgs(""),
+			setupMocks: func(fields *wsHandlerFields, args *args) {
+				fields.broadcaster.On("Register", mock.Anything).Run(startRecipient(""))
+			},
+			wantStatusCode: http.StatusOK,
+			wantHeader:     http.Header{"Content-Type": {"text/event-stream"}},
+		},
+		{
+			name:   "resume",
+			fields: defaultWSHandlerFields(),
+			args:   defaultArgs("1634000000000000000-42"),
+			setupMocks: func(fields *wsHandlerFields, args *args) {
+				fields.broadcaster.On("Register", mock.Anything).Run(startRecipient("1634000000000000000-42"))
+			},
+			wantStatusCode: http.StatusOK,
+			wantHeader:     http.Header{"Content-Type": {"text/event-stream"}},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setupMocks(testCase.fields, testCase.args)
+			h := rest.NewWSHandler(testCase.fields.upgrader, testCase.fields.broadcaster)
+			h.ServeSSE(testCase.args.w, testCase.args.r)
+			assert.Equal(t, testCase.wantStatusCode, testCase.args.w.Code)
+			for key := range testCase.wantHeader {
+				assert.Equal(t, testCase.wantHeader.Get(key), testCase.args.w.Header().Get(key))
+			}
+			testCase.fields.AssertExpectations(t)
+		})
+	}
+}
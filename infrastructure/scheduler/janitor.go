@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"go-feedmaker/entity"
+	"go-feedmaker/interactor"
+)
+
+type (
+	// Janitor purges the generations expired by a retention policy in the
+	// background.
+	Janitor struct {
+		feeds    interactor.FeedInteractor
+		policy   *entity.RetentionPolicy
+		interval time.Duration
+		stop     chan struct{}
+		done     chan struct{}
+	}
+)
+
+func NewJanitor(feeds interactor.FeedInteractor, policy *entity.RetentionPolicy, interval time.Duration) *Janitor {
+	return &Janitor{
+		feeds:    feeds,
+		policy:   policy,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start applies the policy right away and then every interval.
+func (j *Janitor) Start() {
+	go j.run()
+}
+
+// Stop waits for a running pass to finish.
+func (j *Janitor) Stop() {
+	close(j.stop)
+	<-j.done
+}
+
+func (j *Janitor) run() {
+	defer close(j.done)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-j.stop
+		cancel()
+	}()
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		if err := j.feeds.ApplyRetention(ctx, j.policy); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Cannot apply retention policy")
+		}
+		select {
+		case <-ticker.C:
+		case <-j.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"go-feedmaker/entity"
+	"go-feedmaker/infrastructure/scheduler"
+	"go-feedmaker/interactor/mocks"
+)
+
+func TestJanitor(t *testing.T) {
+	testCases := []struct {
+		name    string
+		err     error
+		minRuns int
+	}{
+		{
+			name:    "policy applied every interval",
+			minRuns: 3,
+		},
+		{
+			name:    "errors don't stop janitor",
+			err:     defaultErr,
+			minRuns: 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := &entity.RetentionPolicy{MaxCount: 10}
+			feeds := new(mocks.FeedInteractor)
+			runs := make(chan struct{}, 100)
+			feeds.On("ApplyRetention", mock.Anything, policy).
+				Run(func(mock.Arguments) { runs <- struct{}{} }).
+				Return(tc.err)
+			janitor := scheduler.NewJanitor(feeds, policy, 10*time.Millisecond)
+
+			janitor.Start()
+			for i := 0; i < tc.minRuns; i++ {
+				select {
+				case <-runs:
+				case <-time.After(time.Second):
+					t.Fatalf("policy applied %d times, want %d", i, tc.minRuns)
+				}
+			}
+			janitor.Stop()
+
+			feeds.AssertExpectations(t)
+		})
+	}
+}
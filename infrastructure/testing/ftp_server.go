@@ -0,0 +1,349 @@
+package helper
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// FtpServer is a minimal in-process FTP stand-in. It understands the subset
+// of RFC 959, RFC 2428 (EPSV) and RFC 4217 (FTPS) that jlaffaye/ftp uses for
+// login and uploads, including REST and APPE for resumed uploads, and keeps
+// uploaded files in memory.
+//
+// When TLS is set, USER is refused until the control connection is secured,
+// so a passing test proves credentials never travel in cleartext.
+type FtpServer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	// TLS enables FTPS; Implicit makes the listener speak TLS from the first
+	// byte instead of waiting for AUTH TLS.
+	TLS      *tls.Config
+	Implicit bool
+
+	listener net.Listener
+	mu       sync.Mutex
+	files    map[string][]byte
+	dirs     map[string]bool
+	sessions map[*ftpSession]bool
+	// interruptAt aborts the next upload after that many bytes, if set.
+	interruptAt int
+}
+
+type ftpSession struct {
+	server    *FtpServer
+	conn      net.Conn
+	reader    *bufio.Reader
+	secured   bool
+	protected bool
+	loggedIn  bool
+	cwd       string
+	user      string
+	passive   net.Listener
+	restAt    int
+}
+
+func StartFtpServer(t *testing.T, tlsConfig *tls.Config, implicit bool) *FtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	server := &FtpServer{
+		Host:     host,
+		Port:     port,
+		Username: "feedmaker",
+		Password: "secret",
+		TLS:      tlsConfig,
+		Implicit: implicit,
+		listener: listener,
+		files:    make(map[string][]byte),
+		dirs:     map[string]bool{"/": true},
+		sessions: make(map[*ftpSession]bool),
+	}
+	t.Cleanup(func() { listener.Close() })
+	go server.serve()
+	return server
+}
+
+// File returns the content of an uploaded file by its absolute path.
+func (s *FtpServer) File(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.files[path.Clean("/"+name)]
+	return content, ok
+}
+
+// InterruptNextUpload makes the next upload fail with 426 after keeping the
+// first n bytes, like a transfer broken halfway.
+func (s *FtpServer) InterruptNextUpload(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interruptAt = n
+}
+
+// DropConnections closes every control connection, as servers do with idle
+// clients.
+func (s *FtpServer) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for session := range s.sessions {
+		session.conn.Close()
+	}
+}
+
+func (s *FtpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *FtpServer) handle(conn net.Conn) {
+	session := &ftpSession{server: s, cwd: "/"}
+	if s.TLS != nil && s.Implicit {
+		conn = tls.Server(conn, s.TLS)
+		session.secured = true
+	}
+	session.setConn(conn)
+	s.mu.Lock()
+	s.sessions[session] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, session)
+		s.mu.Unlock()
+		session.conn.Close()
+		if session.passive != nil {
+			session.passive.Close()
+		}
+	}()
+
+	session.reply("220 feedmaker test server ready")
+	for {
+		line, err := session.reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command, arg := splitCommand(strings.TrimRight(line, "\r\n"))
+		if !session.exec(command, arg) {
+			return
+		}
+	}
+}
+
+func (s *ftpSession) setConn(conn net.Conn) {
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+}
+
+func (s *ftpSession) reply(format string, args ...interface{}) {
+	fmt.Fprintf(s.conn, format+"\r\n", args...)
+}
+
+func (s *ftpSession) exec(command, arg string) bool {
+	switch command {
+	case "AUTH":
+		if s.server.TLS == nil || s.secured {
+			s.reply("502 AUTH not available")
+			return true
+		}
+		s.reply("234 proceed with negotiation")
+		s.setConn(tls.Server(s.conn, s.server.TLS))
+		s.secured = true
+	case "USER":
+		if s.server.TLS != nil && !s.secured {
+			s.reply("530 TLS required")
+			return true
+		}
+		s.user = arg
+		s.reply("331 password required")
+	case "PASS":
+		if s.user != s.server.Username || arg != s.server.Password {
+			s.reply("530 login incorrect")
+			return true
+		}
+		s.loggedIn = true
+		s.reply("230 logged in")
+	case "QUIT":
+		s.reply("221 bye")
+		return false
+	default:
+		if !s.loggedIn {
+			s.reply("530 not logged in")
+			return true
+		}
+		s.execLoggedIn(command, arg)
+	}
+	return true
+}
+
+func (s *ftpSession) execLoggedIn(command, arg string) {
+	fs := s.server
+	target := s.abs(arg)
+	switch command {
+	case "FEAT":
+		s.reply("211-Features:\r\n UTF8\r\n EPSV\r\n211 End")
+	case "TYPE", "OPTS", "PBSZ", "NOOP":
+		s.reply("200 ok")
+	case "PROT":
+		s.protected = arg == "P"
+		s.reply("200 ok")
+	case "PWD":
+		s.reply("257 %q", s.cwd)
+	case "CWD":
+		if !fs.hasDir(target) {
+			s.reply("550 no such directory")
+			return
+		}
+		s.cwd = target
+		s.reply("250 ok")
+	case "CDUP":
+		s.cwd = path.Dir(s.cwd)
+		s.reply("250 ok")
+	case "MKD":
+		fs.mu.Lock()
+		fs.dirs[target] = true
+		fs.mu.Unlock()
+		s.reply("257 %q created", target)
+	case "RMD":
+		fs.mu.Lock()
+		_, ok := fs.dirs[target]
+		delete(fs.dirs, target)
+		fs.mu.Unlock()
+		if !ok {
+			s.reply("550 no such directory")
+			return
+		}
+		s.reply("250 ok")
+	case "SIZE":
+		content, ok := fs.File(target)
+		if !ok {
+			s.reply("550 no such file")
+			return
+		}
+		s.reply("213 %d", len(content))
+	case "EPSV":
+		s.openPassive()
+	case "REST":
+		offset, err := strconv.Atoi(arg)
+		if err != nil || offset < 0 {
+			s.reply("501 invalid offset")
+			return
+		}
+		s.restAt = offset
+		s.reply("350 restarting at %d", offset)
+	case "STOR":
+		offset := s.restAt
+		s.restAt = 0
+		s.stor(target, func(existing []byte) ([]byte, bool) {
+			if offset == 0 {
+				return nil, true
+			}
+			if offset > len(existing) {
+				return nil, false
+			}
+			return existing[:offset], true
+		})
+	case "APPE":
+		s.stor(target, func(existing []byte) ([]byte, bool) {
+			return existing, true
+		})
+	default:
+		s.reply("502 %s not implemented", command)
+	}
+}
+
+func (s *ftpSession) openPassive() {
+	if s.passive != nil {
+		s.passive.Close()
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		s.reply("425 can't open data connection")
+		return
+	}
+	s.passive = listener
+	s.reply("229 Entering Extended Passive Mode (|||%d|)", listener.Addr().(*net.TCPAddr).Port)
+}
+
+// stor receives a file; base returns the content kept before the received
+// data.
+func (s *ftpSession) stor(target string, base func(existing []byte) ([]byte, bool)) {
+	if s.passive == nil {
+		s.reply("425 use EPSV first")
+		return
+	}
+	s.reply("150 opening data connection")
+	conn, err := s.passive.Accept()
+	s.passive.Close()
+	s.passive = nil
+	if err != nil {
+		s.reply("425 can't open data connection")
+		return
+	}
+	if s.protected {
+		conn = tls.Server(conn, s.server.TLS)
+	}
+	content, err := ioutil.ReadAll(conn)
+	conn.Close()
+	if err != nil {
+		s.reply("426 transfer aborted")
+		return
+	}
+	fs := s.server
+	fs.mu.Lock()
+	kept, ok := base(fs.files[target])
+	if !ok {
+		fs.mu.Unlock()
+		s.reply("554 invalid restart offset")
+		return
+	}
+	interrupted := fs.interruptAt > 0 && fs.interruptAt < len(content)
+	if interrupted {
+		content = content[:fs.interruptAt]
+		fs.interruptAt = 0
+	}
+	fs.files[target] = append(append([]byte(nil), kept...), content...)
+	fs.mu.Unlock()
+	if interrupted {
+		s.reply("426 transfer aborted")
+		return
+	}
+	s.reply("226 transfer complete")
+}
+
+func (s *ftpSession) abs(name string) string {
+	if path.IsAbs(name) {
+		return path.Clean(name)
+	}
+	return path.Join(s.cwd, name)
+}
+
+func (s *FtpServer) hasDir(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dirs[name]
+}
+
+func splitCommand(line string) (string, string) {
+	parts := strings.SplitN(line, " ", 2)
+	command := strings.ToUpper(parts[0])
+	if len(parts) == 1 {
+		return command, ""
+	}
+	return command, parts[1]
+}
@@ -0,0 +1,75 @@
+package helper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/stretchr/testify/require"
+)
+
+// S3Server is an in-process S3-compatible server backed by memory. It
+// records every request, because the fake backend keeps neither the content
+// type nor the server-side encryption settings of an object.
+type S3Server struct {
+	Endpoint string
+	backend  *s3mem.Backend
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func StartS3Server(t *testing.T, buckets ...string) *S3Server {
+	server := &S3Server{backend: s3mem.New()}
+	for _, bucket := range buckets {
+		require.NoError(t, server.backend.CreateBucket(bucket))
+	}
+	handler := gofakes3.New(server.backend).Server()
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		server.requests = append(server.requests, r.Clone(r.Context()))
+		server.mu.Unlock()
+		// S3 ignores an empty delimiter, the fake backend rolls up every key.
+		if query := r.URL.Query(); query.Get("delimiter") == "" {
+			query.Del("delimiter")
+			r.URL.RawQuery = query.Encode()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(httpServer.Close)
+	server.Endpoint = strings.TrimPrefix(httpServer.URL, "http://")
+	return server
+}
+
+// Object returns the content of a stored object.
+func (s *S3Server) Object(t *testing.T, bucket, key string) []byte {
+	object, err := s.backend.GetObject(bucket, key, nil)
+	require.NoError(t, err)
+	defer object.Contents.Close()
+	content, err := ioutil.ReadAll(object.Contents)
+	require.NoError(t, err)
+	return content
+}
+
+// Requests returns the requests made for key, in order.
+func (s *S3Server) Requests(key string) []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make([]*http.Request, 0)
+	for _, r := range s.requests {
+		if strings.HasSuffix(r.URL.Path, "/"+key) {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+// HasObject reports whether key is stored in bucket.
+func (s *S3Server) HasObject(bucket, key string) bool {
+	_, err := s.backend.HeadObject(bucket, key)
+	return err == nil
+}
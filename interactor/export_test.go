@@ -1,5 +1,11 @@
 package interactor
 
+import (
+	"time"
+
+	"go-feedmaker/entity"
+)
+
 type ExportedFeedInteractor feedInteractor
 
 func (i *feedInteractor) GenerationRepo() FeedRepo {
@@ -9,3 +15,9 @@ func (i *feedInteractor) GenerationRepo() FeedRepo {
 func (i *feedInteractor) Presenter() Presenter {
 	return i.presenter
 }
+
+type StateWriter = stateWriter
+
+func NewStateWriter(feeds FeedRepo, generation *entity.Generation, interval time.Duration) *stateWriter {
+	return newStateWriter(feeds, generation, interval)
+}
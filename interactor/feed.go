@@ -2,11 +2,15 @@ package interactor
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"go-feedmaker/entity"
@@ -16,10 +20,23 @@ type (
 	FeedInteractor interface {
 		GenerateFeed(ctx context.Context, generationType string) error
 		RestartGeneration(ctx context.Context, generationID string) error
-		ListGenerations(ctx context.Context) (interface{}, error)
+		RetryUpload(ctx context.Context, generationID string) error
+		GetGeneration(ctx context.Context, generationID string) (interface{}, error)
+		ListGenerations(ctx context.Context, filter *entity.GenerationFilter) (interface{}, error)
 		ListGenerationTypes(ctx context.Context) (interface{}, error)
 		CancelGeneration(ctx context.Context, id string) error
+		PurgeGeneration(ctx context.Context, id string) error
+		ApplyRetention(ctx context.Context, policy *entity.RetentionPolicy) error
+		RollbackGeneration(ctx context.Context, generationType, generationID string) error
+		ListGenerationFiles(ctx context.Context, generationID string) (interface{}, error)
+		OpenGenerationFile(ctx context.Context, generationID, name string) (ArchivedFile, error)
+		PreviewGenerationFile(ctx context.Context, generationID, name string, rows uint) (interface{}, error)
+		PreviewFeed(ctx context.Context, generationType string, rows uint) (interface{}, error)
+		DryRunFeed(ctx context.Context, generationType string) (interface{}, error)
+		GetGenerationLogs(ctx context.Context, generationID string) (interface{}, error)
+		FollowGenerationLogs(ctx context.Context, generationID string, outStream chan<- interface{}) error
 		WatchGenerationsProgress(ctx context.Context, outStream chan<- *entity.Generation) error
+		FailInterruptedGenerations(ctx context.Context) error
 	}
 
 	DataFetcher interface {
@@ -28,19 +45,97 @@ type (
 		OnProgress(func(progress uint))
 	}
 
+	// RecordsReporter is implemented by data fetchers that know how many
+	// records they are going to fetch.
+	RecordsReporter interface {
+		OnRecordsCounted(func(recordsCount uint))
+	}
+
+	// ValidationReporter is implemented by data fetchers that drop the
+	// records failing validation.
+	ValidationReporter interface {
+		OnRecordInvalid(func(record []string, err error))
+	}
+
 	FeedFactory interface {
 		CreateDataFetcher(outStream chan<- []string) DataFetcher
-		CreateFileFormatter(inStream <-chan []string, outStream chan<- io.ReadCloser) FileFormatter
-		CreateUploader(inStream <-chan io.ReadCloser) Uploader
+		CreateFileFormatter(generation *entity.Generation, inStream <-chan []string, outStream chan<- io.ReadCloser) FileFormatter
+		CreateUploader(generation *entity.Generation, inStream <-chan io.ReadCloser) Uploader
+		Cleanup(generation *entity.Generation) error
+	}
+
+	// ParametersReporter is implemented by factories that can describe the
+	// feed settings a generation is run with.
+	ParametersReporter interface {
+		Parameters() map[string]string
 	}
 
 	FileFormatter interface {
 		FormatFiles(ctx context.Context) error
 	}
 
+	// Checkpointer is implemented by factories that keep the formatted files
+	// of a failed generation, so its upload can be retried without fetching
+	// and formatting the data again.
+	Checkpointer interface {
+		// CreateCheckpointFormatter streams the files kept by the failed run
+		// instead of formatting fetched data. It returns
+		// entity.ErrNoCheckpoint when they aren't all available.
+		CreateCheckpointFormatter(generation *entity.Generation, outStream chan<- io.ReadCloser) (FileFormatter, error)
+		// CreateResumedUploader continues the failed upload after the files
+		// it already uploaded.
+		CreateResumedUploader(generation *entity.Generation, inStream <-chan io.ReadCloser) Uploader
+		// CleanupFailed is called instead of Cleanup when the generation
+		// failed; it keeps the files if formatting finished.
+		CleanupFailed(generation *entity.Generation) error
+		// RemoveStaging removes what resumed uploads kept at the
+		// destinations when the generation is purged.
+		RemoveStaging(generation *entity.Generation) error
+	}
+
+	// Archiver is implemented by factories that keep a copy of the files
+	// generations uploaded.
+	Archiver interface {
+		ListArchivedFiles(generation *entity.Generation) ([]*entity.FileInfo, error)
+		// OpenArchivedFile returns entity.ErrFileNotFound when name wasn't
+		// archived.
+		OpenArchivedFile(generation *entity.Generation, name string) (ArchivedFile, error)
+		PreviewArchivedFile(generation *entity.Generation, name string, rows uint) ([][]string, error)
+		RemoveArchive(generation *entity.Generation) error
+	}
+
+	ArchivedFile interface {
+		io.ReadSeeker
+		io.Closer
+		Stat() (os.FileInfo, error)
+	}
+
+	// Previewer is implemented by factories that can describe the files of a
+	// feed instead of uploading them.
+	Previewer interface {
+		// CreatePreviewDataFetcher fetches the first rows records only,
+		// without running the count query.
+		CreatePreviewDataFetcher(outStream chan<- []string, rows uint) DataFetcher
+		// CreatePreviewUploader reads the files in place of the uploader;
+		// withContent keeps what they contain.
+		CreatePreviewUploader(generation *entity.Generation, inStream <-chan io.ReadCloser, withContent bool) PreviewUploader
+	}
+
+	PreviewUploader interface {
+		UploadFiles(ctx context.Context) error
+		Files() []*entity.PreviewFile
+	}
+
 	Uploader interface {
 		UploadFiles(ctx context.Context) error
 		OnUpload(func(uploadedNum uint))
+		OnManifestUploaded(func(manifest *entity.Manifest))
+	}
+
+	// DestinationsReporter is implemented by uploaders that send files to
+	// several destinations and report the status of each of them.
+	DestinationsReporter interface {
+		OnDestinationsUpdated(func(destinations []*entity.DestinationStatus))
 	}
 
 	FeedRepo interface {
@@ -48,34 +143,87 @@ type (
 		StoreGeneration(ctx context.Context, generation *entity.Generation) error
 		GetGeneration(ctx context.Context, generationID string) (*entity.Generation, error)
 		UpdateGenerationState(ctx context.Context, generation *entity.Generation) error
-		ListGenerations(ctx context.Context) ([]*entity.Generation, error)
+		// ListGenerations returns a page of generations matching filter,
+		// newest first, and the cursor of the next page.
+		ListGenerations(ctx context.Context, filter *entity.GenerationFilter) ([]*entity.Generation, string, error)
 		ListAllowedTypes() []string
 		IsAllowedType(generationType string) bool
 		CancelGeneration(ctx context.Context, id string) error
+		DeleteGeneration(ctx context.Context, id string) error
 		OnGenerationCanceled(ctx context.Context, id string, callback func()) error
-		OnGenerationsUpdated(ctx context.Context, callback func(*entity.Generation)) error
+		// OnGenerationsUpdated calls onSubscribed once it listens to the
+		// updates, then callback with every generation that changed.
+		OnGenerationsUpdated(ctx context.Context, onSubscribed func() error, callback func(*entity.Generation)) error
+		// RollbackGeneration makes the published version of generationID
+		// current again, or the previous version when generationID is empty.
+		RollbackGeneration(ctx context.Context, generationType, generationID string) error
+		ListGenerationLogs(ctx context.Context, generationID string) ([]*entity.LogEntry, error)
+		// FollowGenerationLogs calls callback with the kept log lines of a
+		// generation and then with every new one until ctx is done.
+		FollowGenerationLogs(ctx context.Context, generationID string, callback func(*entity.LogEntry)) error
 	}
 
 	Presenter interface {
 		PresentGenerationTypes([]string) interface{}
 		PresentListGenerations(out *ListGenerationsOut) interface{}
+		PresentGeneration(out *GenerationsOut) interface{}
+		PresentGenerationFiles(files []*entity.FileInfo) interface{}
+		PresentFilePreview(out *FilePreviewOut) interface{}
+		PresentFeedPreview(preview *entity.FeedPreview) interface{}
+		PresentGenerationLogs(entries []*entity.LogEntry) interface{}
+		PresentLogEntry(entry *entity.LogEntry) interface{}
 		PresentErr(err error) error
 	}
 
 	feedInteractor struct {
 		feeds     FeedRepo
 		presenter Presenter
+		// stateInterval is how often the state of a running generation is
+		// stored at most.
+		stateInterval time.Duration
+		// instance tells the generations this process runs from the ones of
+		// other instances.
+		instance string
 	}
 
 	GenerationsOut entity.Generation
 
-	ListGenerationsOut []*GenerationsOut
+	ListGenerationsOut struct {
+		Generations []*GenerationsOut
+		NextCursor  string
+	}
+
+	FilePreviewOut struct {
+		Name string
+		Rows [][]string
+	}
+
+	// stage produces the files of a generation for the upload.
+	stage struct {
+		name string
+		run  func(ctx context.Context) error
+	}
 )
 
-func NewFeedInteractor(feeds FeedRepo, presenter Presenter) *feedInteractor {
+const (
+	// retentionBatchSize is how many generations ApplyRetention loads at
+	// once.
+	retentionBatchSize = 500
+	// runningBatchSize is how many running generations
+	// WatchGenerationsProgress loads at once.
+	runningBatchSize = 500
+
+	stageFetch  = "fetch"
+	stageFormat = "format"
+	stageUpload = "upload"
+)
+
+func NewFeedInteractor(feeds FeedRepo, presenter Presenter, stateInterval time.Duration, instance string) *feedInteractor {
 	return &feedInteractor{
-		feeds:     feeds,
-		presenter: presenter,
+		feeds:         feeds,
+		presenter:     presenter,
+		stateInterval: stateInterval,
+		instance:      instance,
 	}
 }
 
@@ -85,9 +233,11 @@ func (i *feedInteractor) GenerateFeed(ctx context.Context, generationType string
 		return i.presenter.PresentErr(err)
 	}
 	generation := &entity.Generation{
-		ID:        uuid.New().String(),
-		Type:      generationType,
-		StartTime: time.Now(),
+		ID:         uuid.New().String(),
+		Type:       generationType,
+		StartTime:  time.Now(),
+		Parameters: parametersOf(factory),
+		Instance:   i.instance,
 	}
 	if err := i.feeds.StoreGeneration(ctx, generation); err != nil {
 		return i.presenter.PresentErr(err)
@@ -107,138 +257,453 @@ func (i *feedInteractor) RestartGeneration(ctx context.Context, generationID str
 	if err != nil {
 		return i.presenter.PresentErr(err)
 	}
-	generation.DataFetched = false
-	generation.FilesUploaded = 0
-	generation.Progress = 0
+	if err := i.restartGeneration(ctx, factory, generation); err != nil {
+		return i.presenter.PresentErr(err)
+	}
+	return nil
+}
+
+// RetryUpload uploads the files a failed generation kept, starting after the
+// files it already uploaded. Without a checkpoint the generation is
+// restarted from scratch.
+func (i *feedInteractor) RetryUpload(ctx context.Context, generationID string) error {
+	generation, err := i.feeds.GetGeneration(ctx, generationID)
+	if err != nil {
+		return i.presenter.PresentErr(err)
+	}
+	// Only a generation that ended without publishing is retried; a
+	// running one still reads its spool and staging directory.
+	switch generation.Status() {
+	case entity.GenerationFailed, entity.GenerationCanceled:
+	case entity.GenerationRunning:
+		return i.presenter.PresentErr(fmt.Errorf("%w: %s", entity.ErrGenerationRunning, generationID))
+	default:
+		return i.presenter.PresentErr(fmt.Errorf("%w: %s", entity.ErrGenerationSucceeded, generationID))
+	}
+	factory, err := i.feeds.GetFactoryByGenerationType(generation.Type)
+	if err != nil {
+		return i.presenter.PresentErr(err)
+	}
+	fileStream := make(chan io.ReadCloser)
+	fileFormatter, err := i.createCheckpointFormatter(factory, generation, fileStream)
+	if errors.Is(err, entity.ErrNoCheckpoint) {
+		generationLog(generation).Info().Msgf("Generation %s has no checkpoint, restarting it", generation.ID)
+		if err := i.restartGeneration(ctx, factory, generation); err != nil {
+			return i.presenter.PresentErr(err)
+		}
+		return nil
+	}
+	if err != nil {
+		return i.presenter.PresentErr(err)
+	}
 	generation.IsCanceled = false
+	generation.Finished = false
+	generation.Instance = i.instance
 	if err := i.feeds.UpdateGenerationState(ctx, generation); err != nil {
 		return i.presenter.PresentErr(err)
 	}
-	if err := i.generateFeed(ctx, factory, generation); err != nil {
+	format := &stage{name: stageFormat, run: func(ctx context.Context) error {
+		defer close(fileStream)
+		return fileFormatter.FormatFiles(ctx)
+	}}
+	uploader := factory.(Checkpointer).CreateResumedUploader(generation, fileStream)
+	state := newStateWriter(i.feeds, generation, i.stateInterval)
+	if err := i.runGeneration(ctx, factory, uploader, state, format); err != nil {
 		return i.presenter.PresentErr(err)
 	}
 	return nil
 }
 
-func (i *feedInteractor) generateFeed(ctx context.Context, factory FeedFactory, generation *entity.Generation) error {
-	log.Info().Msgf("Started generation %s with id %s", generation.Type, generation.ID)
-	defer log.Info().Msgf("Finished generation %s with id %s", generation.Type, generation.ID)
+func (i *feedInteractor) createCheckpointFormatter(
+	factory FeedFactory,
+	generation *entity.Generation,
+	outStream chan<- io.ReadCloser,
+) (FileFormatter, error) {
+	checkpointer, ok := factory.(Checkpointer)
+	if !ok {
+		return nil, entity.ErrNoCheckpoint
+	}
+	return checkpointer.CreateCheckpointFormatter(generation, outStream)
+}
 
-	ctx, cancelCtx := context.WithCancel(ctx)
-	defer cancelCtx()
-	go i.onGenerationCanceled(ctx, generation, cancelCtx)
-	errStream := make(chan error)
+func (i *feedInteractor) restartGeneration(ctx context.Context, factory FeedFactory, generation *entity.Generation) error {
+	// Files kept by the failed run are formatted again.
+	i.cleanup(factory, generation, nil)
+	generation.DataFetched = false
+	generation.FilesUploaded = 0
+	generation.Progress = 0
+	generation.IsCanceled = false
+	generation.Finished = false
+	generation.Instance = i.instance
+	generation.RecordsCount = 0
+	generation.Parameters = parametersOf(factory)
+	if err := i.feeds.UpdateGenerationState(ctx, generation); err != nil {
+		return err
+	}
+	return i.generateFeed(ctx, factory, generation)
+}
+
+func (i *feedInteractor) generateFeed(ctx context.Context, factory FeedFactory, generation *entity.Generation) error {
 	recordStream := make(chan []string)
 	fileStream := make(chan io.ReadCloser)
-
 	dataFetcher := factory.CreateDataFetcher(recordStream)
-	fileFormatter := factory.CreateFileFormatter(recordStream, fileStream)
-	uploader := factory.CreateUploader(fileStream)
-	dataFetcher.OnDataFetched(i.onDataFetched(generation))
-	dataFetcher.OnProgress(i.onProgress(generation))
-	uploader.OnUpload(i.onFileUploaded(generation))
-
-	var wg sync.WaitGroup
-	wg.Add(3)
-	go func() {
-		defer wg.Done()
+	fileFormatter := factory.CreateFileFormatter(generation, recordStream, fileStream)
+	state := newStateWriter(i.feeds, generation, i.stateInterval)
+	dataFetcher.OnDataFetched(i.onDataFetched(state))
+	dataFetcher.OnProgress(i.onProgress(state))
+	if reporter, ok := dataFetcher.(RecordsReporter); ok {
+		reporter.OnRecordsCounted(i.onRecordsCounted(state))
+	}
+	fetch := &stage{name: stageFetch, run: func(ctx context.Context) error {
 		defer close(recordStream)
-		if err := dataFetcher.StreamData(ctx); err != nil {
-			errStream <- err
-		}
-	}()
-	go func() {
-		defer wg.Done()
+		return dataFetcher.StreamData(ctx)
+	}}
+	format := &stage{name: stageFormat, run: func(ctx context.Context) error {
 		defer close(fileStream)
-		if err := fileFormatter.FormatFiles(ctx); err != nil {
-			errStream <- err
-		}
-	}()
-	go func() {
-		defer wg.Done()
-		if err := uploader.UploadFiles(ctx); err != nil {
-			errStream <- err
-		}
+		return fileFormatter.FormatFiles(ctx)
+	}}
+	uploader := factory.CreateUploader(generation, fileStream)
+	return i.runGeneration(ctx, factory, uploader, state, fetch, format)
+}
+
+// runGeneration runs stages producing files into fileStream and uploads
+// them. The first error cancels every stage; it is stored with the timings
+// of the stages once all of them stopped.
+func (i *feedInteractor) runGeneration(
+	ctx context.Context,
+	factory FeedFactory,
+	uploader Uploader,
+	state *stateWriter,
+	stages ...*stage,
+) (err error) {
+	generation := state.generation
+	logger := generationLog(generation)
+	ctx = logger.WithContext(ctx)
+	logger.Info().Msgf("Started generation %s with id %s", generation.Type, generation.ID)
+	defer logger.Info().Msgf("Finished generation %s with id %s", generation.Type, generation.ID)
+
+	timings := make([]*entity.StageTiming, 0, len(stages)+1)
+	for _, stage := range stages {
+		timings = append(timings, &entity.StageTiming{Name: stage.name})
+	}
+	uploadTiming := &entity.StageTiming{Name: stageUpload}
+	timings = append(timings, uploadTiming)
+	state.Update(func(generation *entity.Generation) {
+		generation.Error = ""
+		generation.Finished = false
+		generation.Stages = timings
+	})
+	defer func() {
+		i.finishGeneration(state, err)
+		i.cleanup(factory, generation, err)
 	}()
+	ctx, cancelCtx := context.WithCancel(ctx)
+	defer cancelCtx()
+	go i.onGenerationCanceled(ctx, state, cancelCtx)
+
+	uploader.OnUpload(i.onFileUploaded(state))
+	uploader.OnManifestUploaded(i.onManifestUploaded(state))
+	if reporter, ok := uploader.(DestinationsReporter); ok {
+		reporter.OnDestinationsUpdated(i.onDestinationsUpdated(state))
+	}
+
+	runs := make([]func(ctx context.Context) error, 0, len(stages)+1)
+	for idx, s := range stages {
+		run, timing := s.run, timings[idx]
+		runs = append(runs, func(ctx context.Context) error {
+			return runStage(ctx, state, timing, run)
+		})
+	}
+	runs = append(runs, func(ctx context.Context) error {
+		return runStage(ctx, state, uploadTiming, uploader.UploadFiles)
+	})
+	return runConcurrently(ctx, runs...)
+}
+
+// runConcurrently waits for every run to return. The first error cancels
+// the others and is returned.
+func runConcurrently(ctx context.Context, runs ...func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errStream := make(chan error)
+	var wg sync.WaitGroup
+	wg.Add(len(runs))
+	for _, run := range runs {
+		go func(run func(ctx context.Context) error) {
+			defer wg.Done()
+			if err := run(ctx); err != nil {
+				errStream <- err
+			}
+		}(run)
+	}
 	go func() {
 		wg.Wait()
 		close(errStream)
 	}()
 
+	var firstErr error
 	for err := range errStream {
-		cancelCtx()
-		return err
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	return firstErr
+}
+
+// runStage times run; the timing is part of the generation, so it is set
+// through state.
+func runStage(ctx context.Context, state *stateWriter, timing *entity.StageTiming, run func(ctx context.Context) error) error {
+	state.Update(func(*entity.Generation) {
+		timing.StartTime = time.Now()
+	})
+	defer state.Update(func(*entity.Generation) {
+		timing.EndTime = time.Now()
+	})
+	return run(ctx)
+}
+
+// finishGeneration stores the final state of the generation whatever the
+// interval since the last write.
+func (i *feedInteractor) finishGeneration(state *stateWriter, err error) {
+	// The outcome is stored together with the flag, so the generation is
+	// never seen finished without it.
+	writeErr := state.Write(func(generation *entity.Generation) {
+		if err != nil {
+			generation.Error = err.Error()
+		}
+		generation.Finished = true
+	})
+	if writeErr != nil {
+		generationLog(state.generation).Error().Err(writeErr).
+			Msgf("Cannot update stages for %s", state.generation.ID)
+	}
+}
+
+// generationLog returns a logger tagging the lines with the generation, so
+// they are kept with its log.
+func generationLog(generation *entity.Generation) *zerolog.Logger {
+	logger := log.With().
+		Str(entity.LogGenerationID, generation.ID).
+		Str(entity.LogGenerationType, generation.Type).
+		Logger()
+	return &logger
+}
+
+func parametersOf(factory FeedFactory) map[string]string {
+	if reporter, ok := factory.(ParametersReporter); ok {
+		return reporter.Parameters()
 	}
 	return nil
 }
 
-func (i *feedInteractor) onProgress(generation *entity.Generation) func(uint) {
+// cleanup removes what the generation left in the spool; a failed
+// generation keeps it for a retry when the factory supports checkpoints.
+func (i *feedInteractor) cleanup(factory FeedFactory, generation *entity.Generation, err error) {
+	cleanup := factory.Cleanup
+	if checkpointer, ok := factory.(Checkpointer); ok && err != nil {
+		cleanup = checkpointer.CleanupFailed
+	}
+	if err := cleanup(generation); err != nil {
+		generationLog(generation).Error().Err(err).
+			Msgf("Cannot clean up after generation %s", generation.ID)
+	}
+}
+
+func (i *feedInteractor) onProgress(state *stateWriter) func(uint) {
 	return func(progress uint) {
-		generation.SetProgress(progress)
-		if err := i.feeds.UpdateGenerationState(context.Background(), generation); err != nil {
-			log.Error().Err(err).
-				Msgf("Cannot update progress for %s %v", generation.ID, progress)
-		}
+		state.Update(func(generation *entity.Generation) {
+			generation.SetProgress(progress)
+		})
 	}
 }
 
-func (i *feedInteractor) onFileUploaded(generation *entity.Generation) func(uint) {
+func (i *feedInteractor) onFileUploaded(state *stateWriter) func(uint) {
 	return func(uploadedNum uint) {
-		generation.FilesUploaded++
-		if err := i.feeds.UpdateGenerationState(context.Background(), generation); err != nil {
-			log.Error().Err(err).
-				Msgf("Cannot update file uploaded for %s %v", generation.ID, generation.FilesUploaded)
-		}
+		state.Update(func(generation *entity.Generation) {
+			generation.FilesUploaded++
+		})
+	}
+}
+
+func (i *feedInteractor) onManifestUploaded(state *stateWriter) func(*entity.Manifest) {
+	return func(manifest *entity.Manifest) {
+		state.Update(func(generation *entity.Generation) {
+			generation.Manifest = manifest
+		})
+	}
+}
+
+func (i *feedInteractor) onDestinationsUpdated(state *stateWriter) func([]*entity.DestinationStatus) {
+	return func(destinations []*entity.DestinationStatus) {
+		state.Update(func(generation *entity.Generation) {
+			generation.Destinations = destinations
+		})
 	}
 }
 
-func (i *feedInteractor) onDataFetched(generation *entity.Generation) func() {
+func (i *feedInteractor) onRecordsCounted(state *stateWriter) func(uint) {
+	return func(recordsCount uint) {
+		state.Update(func(generation *entity.Generation) {
+			generation.RecordsCount = recordsCount
+		})
+	}
+}
+
+func (i *feedInteractor) onDataFetched(state *stateWriter) func() {
 	return func() {
-		generation.DataFetched = true
-		if err := i.feeds.UpdateGenerationState(context.Background(), generation); err != nil {
-			log.Error().Err(err).
-				Msgf("Cannot update data fetched for %s", generation.ID)
-		}
+		state.Update(func(generation *entity.Generation) {
+			generation.DataFetched = true
+		})
 	}
 }
 
-func (i *feedInteractor) onGenerationCanceled(ctx context.Context, generation *entity.Generation, callback func()) {
+func (i *feedInteractor) onGenerationCanceled(ctx context.Context, state *stateWriter, callback func()) {
+	generation := state.generation
 	handleCancel := func() {
 		callback()
-		generation.IsCanceled = true
-		if err := i.feeds.UpdateGenerationState(context.Background(), generation); err != nil {
-			log.Error().Err(err).
+		err := state.Write(func(generation *entity.Generation) {
+			generation.IsCanceled = true
+		})
+		if err != nil {
+			generationLog(generation).Error().Err(err).
 				Msgf("Cannot update IsCanceled for %s", generation.ID)
 		}
 	}
 	err := i.feeds.OnGenerationCanceled(ctx, generation.ID, handleCancel)
 	if err != nil {
-		log.Error().Err(err).
+		generationLog(generation).Error().Err(err).
 			Msgf("Cannot check if generation with id %s canceled", generation.ID)
 	}
 }
 
-func (i *feedInteractor) ListGenerations(ctx context.Context) (interface{}, error) {
-	generations, err := i.feeds.ListGenerations(ctx)
+func (i *feedInteractor) GetGeneration(ctx context.Context, generationID string) (interface{}, error) {
+	generation, err := i.feeds.GetGeneration(ctx, generationID)
 	if err != nil {
 		return nil, i.presenter.PresentErr(err)
 	}
-	return i.presenter.PresentListGenerations(makeListGenerationsOut(generations)), nil
+	return i.presenter.PresentGeneration((*GenerationsOut)(generation)), nil
 }
 
-func makeListGenerationsOut(generations []*entity.Generation) *ListGenerationsOut {
-	out := ListGenerationsOut{}
+func (i *feedInteractor) ListGenerations(ctx context.Context, filter *entity.GenerationFilter) (interface{}, error) {
+	generations, nextCursor, err := i.feeds.ListGenerations(ctx, filter)
+	if err != nil {
+		return nil, i.presenter.PresentErr(err)
+	}
+	return i.presenter.PresentListGenerations(makeListGenerationsOut(generations, nextCursor)), nil
+}
+
+func makeListGenerationsOut(generations []*entity.Generation, nextCursor string) *ListGenerationsOut {
+	out := &ListGenerationsOut{
+		Generations: make([]*GenerationsOut, 0, len(generations)),
+		NextCursor:  nextCursor,
+	}
 	for _, generation := range generations {
-		out = append(out, (*GenerationsOut)(generation))
+		out.Generations = append(out.Generations, (*GenerationsOut)(generation))
 	}
-	return &out
+	return out
 }
 
+// WatchGenerationsProgress sends the running generations to outStream and
+// then every generation that changed. They are listed once the updates are
+// followed, so a generation finishing meanwhile is still sent finished.
 func (i *feedInteractor) WatchGenerationsProgress(ctx context.Context, outStream chan<- *entity.Generation) error {
+	onSubscribed := func() error {
+		return i.sendRunningGenerations(ctx, outStream)
+	}
 	callback := func(generation *entity.Generation) {
 		outStream <- generation
 	}
-	if err := i.feeds.OnGenerationsUpdated(ctx, callback); err != nil {
+	if err := i.feeds.OnGenerationsUpdated(ctx, onSubscribed, callback); err != nil {
+		return i.presenter.PresentErr(err)
+	}
+	return nil
+}
+
+func (i *feedInteractor) sendRunningGenerations(ctx context.Context, outStream chan<- *entity.Generation) error {
+	filter := &entity.GenerationFilter{
+		Statuses: []entity.GenerationStatus{entity.GenerationRunning},
+		Limit:    runningBatchSize,
+	}
+	for {
+		generations, nextCursor, err := i.feeds.ListGenerations(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, generation := range generations {
+			select {
+			case outStream <- generation:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if nextCursor == "" {
+			return nil
+		}
+		filter.Cursor = nextCursor
+	}
+}
+
+// FailInterruptedGenerations marks the generations this instance was running
+// when it stopped as failed; it is called at startup, before any generation
+// runs. Generations of other instances are left to them.
+func (i *feedInteractor) FailInterruptedGenerations(ctx context.Context) error {
+	filter := &entity.GenerationFilter{
+		Statuses: []entity.GenerationStatus{entity.GenerationRunning},
+		Limit:    runningBatchSize,
+	}
+	var failed uint
+	for {
+		generations, nextCursor, err := i.feeds.ListGenerations(ctx, filter)
+		if err != nil {
+			return i.presenter.PresentErr(err)
+		}
+		for _, generation := range generations {
+			if generation.Instance != i.instance {
+				continue
+			}
+			generation.Error = entity.ErrGenerationInterrupted.Error()
+			generation.Finished = true
+			if err := i.feeds.UpdateGenerationState(ctx, generation); err != nil {
+				return i.presenter.PresentErr(err)
+			}
+			failed++
+		}
+		if nextCursor == "" {
+			break
+		}
+		filter.Cursor = nextCursor
+	}
+	if failed > 0 {
+		log.Info().Msgf("Failed %d interrupted generations", failed)
+	}
+	return nil
+}
+
+func (i *feedInteractor) GetGenerationLogs(ctx context.Context, generationID string) (interface{}, error) {
+	if _, err := i.feeds.GetGeneration(ctx, generationID); err != nil {
+		return nil, i.presenter.PresentErr(err)
+	}
+	entries, err := i.feeds.ListGenerationLogs(ctx, generationID)
+	if err != nil {
+		return nil, i.presenter.PresentErr(err)
+	}
+	return i.presenter.PresentGenerationLogs(entries), nil
+}
+
+// FollowGenerationLogs sends the kept log lines of a generation to
+// outStream and then every new one until ctx is done.
+func (i *feedInteractor) FollowGenerationLogs(ctx context.Context, generationID string, outStream chan<- interface{}) error {
+	if _, err := i.feeds.GetGeneration(ctx, generationID); err != nil {
+		return i.presenter.PresentErr(err)
+	}
+	callback := func(entry *entity.LogEntry) {
+		select {
+		case outStream <- i.presenter.PresentLogEntry(entry):
+		case <-ctx.Done():
+		}
+	}
+	if err := i.feeds.FollowGenerationLogs(ctx, generationID, callback); err != nil {
 		return i.presenter.PresentErr(err)
 	}
 	return nil
@@ -254,3 +719,287 @@ func (i *feedInteractor) CancelGeneration(ctx context.Context, id string) error
 	}
 	return nil
 }
+
+// PurgeGeneration deletes a finished generation together with the files it
+// left in the spool.
+func (i *feedInteractor) PurgeGeneration(ctx context.Context, id string) error {
+	generation, err := i.feeds.GetGeneration(ctx, id)
+	if err != nil {
+		return i.presenter.PresentErr(err)
+	}
+	if !generation.IsFinished() {
+		return i.presenter.PresentErr(fmt.Errorf("%w: %s", entity.ErrGenerationRunning, id))
+	}
+	if err := i.purgeGeneration(ctx, generation); err != nil {
+		return i.presenter.PresentErr(err)
+	}
+	return nil
+}
+
+// ApplyRetention purges the finished generations expired by policy. Running
+// generations are neither purged nor counted.
+func (i *feedInteractor) ApplyRetention(ctx context.Context, policy *entity.RetentionPolicy) error {
+	if !policy.IsEnabled() {
+		return nil
+	}
+	now := time.Now()
+	finished := make(map[string]uint)
+	var purged uint
+	filter := &entity.GenerationFilter{Limit: retentionBatchSize}
+	for {
+		generations, nextCursor, err := i.feeds.ListGenerations(ctx, filter)
+		if err != nil {
+			return i.presenter.PresentErr(err)
+		}
+		for _, generation := range generations {
+			if !generation.IsFinished() {
+				continue
+			}
+			finished[generation.Type]++
+			if !policy.Expired(generation, finished[generation.Type], now) {
+				continue
+			}
+			if err := i.purgeGeneration(ctx, generation); err != nil {
+				return i.presenter.PresentErr(err)
+			}
+			purged++
+		}
+		if nextCursor == "" {
+			break
+		}
+		filter.Cursor = nextCursor
+	}
+	if purged > 0 {
+		log.Info().Msgf("Purged %d expired generations", purged)
+	}
+	return nil
+}
+
+// purgeGeneration cleans up the spool and the kept staging directories of a
+// generation whose type is still configured and deletes its record.
+func (i *feedInteractor) purgeGeneration(ctx context.Context, generation *entity.Generation) error {
+	factory, err := i.feeds.GetFactoryByGenerationType(generation.Type)
+	if err == nil {
+		if err := factory.Cleanup(generation); err != nil {
+			return err
+		}
+		if checkpointer, ok := factory.(Checkpointer); ok {
+			if err := checkpointer.RemoveStaging(generation); err != nil {
+				return err
+			}
+		}
+		if archiver, ok := factory.(Archiver); ok {
+			if err := archiver.RemoveArchive(generation); err != nil {
+				return err
+			}
+		}
+	} else if !errors.Is(err, entity.ErrInvalidGenerationType) {
+		return err
+	}
+	return i.feeds.DeleteGeneration(ctx, generation.ID)
+}
+
+// ListGenerationFiles lists the archived files of a generation with the
+// records and checksums from its manifest.
+func (i *feedInteractor) ListGenerationFiles(ctx context.Context, generationID string) (interface{}, error) {
+	generation, archiver, err := i.getArchiver(ctx, generationID)
+	if err != nil {
+		return nil, i.presenter.PresentErr(err)
+	}
+	files := make([]*entity.FileInfo, 0)
+	if archiver != nil {
+		if files, err = archiver.ListArchivedFiles(generation); err != nil {
+			return nil, i.presenter.PresentErr(err)
+		}
+	}
+	addManifestInfo(files, generation.Manifest)
+	return i.presenter.PresentGenerationFiles(files), nil
+}
+
+func (i *feedInteractor) OpenGenerationFile(ctx context.Context, generationID, name string) (ArchivedFile, error) {
+	generation, archiver, err := i.getArchiver(ctx, generationID)
+	if err != nil {
+		return nil, i.presenter.PresentErr(err)
+	}
+	if archiver == nil {
+		return nil, i.presenter.PresentErr(fmt.Errorf("%w: %s", entity.ErrFileNotFound, name))
+	}
+	file, err := archiver.OpenArchivedFile(generation, name)
+	if err != nil {
+		return nil, i.presenter.PresentErr(err)
+	}
+	return file, nil
+}
+
+func (i *feedInteractor) PreviewGenerationFile(ctx context.Context, generationID, name string, rows uint) (interface{}, error) {
+	generation, archiver, err := i.getArchiver(ctx, generationID)
+	if err != nil {
+		return nil, i.presenter.PresentErr(err)
+	}
+	if archiver == nil {
+		return nil, i.presenter.PresentErr(fmt.Errorf("%w: %s", entity.ErrFileNotFound, name))
+	}
+	records, err := archiver.PreviewArchivedFile(generation, name, rows)
+	if err != nil {
+		return nil, i.presenter.PresentErr(err)
+	}
+	return i.presenter.PresentFilePreview(&FilePreviewOut{Name: name, Rows: records}), nil
+}
+
+// getArchiver returns the archiver of the generation factory, or nil when
+// the factory keeps no files.
+func (i *feedInteractor) getArchiver(ctx context.Context, generationID string) (*entity.Generation, Archiver, error) {
+	generation, err := i.feeds.GetGeneration(ctx, generationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	factory, err := i.feeds.GetFactoryByGenerationType(generation.Type)
+	if errors.Is(err, entity.ErrInvalidGenerationType) {
+		return generation, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	archiver, _ := factory.(Archiver)
+	return generation, archiver, nil
+}
+
+func addManifestInfo(files []*entity.FileInfo, manifest *entity.Manifest) {
+	if manifest == nil {
+		return
+	}
+	uploaded := make(map[string]*entity.FileInfo, len(manifest.Files))
+	for _, file := range manifest.Files {
+		uploaded[file.Name] = file
+	}
+	for _, file := range files {
+		if info, ok := uploaded[file.Name]; ok {
+			file.Records = info.Records
+			file.SHA256 = info.SHA256
+			file.UploadedTime = info.UploadedTime
+		}
+	}
+}
+
+// PreviewFeed fetches and formats the first rows records of generationType
+// without uploading them or storing a generation.
+func (i *feedInteractor) PreviewFeed(ctx context.Context, generationType string, rows uint) (interface{}, error) {
+	preview, err := i.previewFeed(ctx, generationType, rows)
+	if err != nil {
+		return nil, i.presenter.PresentErr(err)
+	}
+	return i.presenter.PresentFeedPreview(preview), nil
+}
+
+// DryRunFeed runs the whole generation to the spool and skips the upload.
+func (i *feedInteractor) DryRunFeed(ctx context.Context, generationType string) (interface{}, error) {
+	preview, err := i.previewFeed(ctx, generationType, 0)
+	if err != nil {
+		return nil, i.presenter.PresentErr(err)
+	}
+	return i.presenter.PresentFeedPreview(preview), nil
+}
+
+// previewFeed describes the files generationType would upload; rows 0
+// formats every record.
+func (i *feedInteractor) previewFeed(ctx context.Context, generationType string, rows uint) (*entity.FeedPreview, error) {
+	factory, err := i.feeds.GetFactoryByGenerationType(generationType)
+	if err != nil {
+		return nil, err
+	}
+	previewer, ok := factory.(Previewer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", entity.ErrPreviewNotSupported, generationType)
+	}
+	generation := &entity.Generation{
+		ID:         uuid.New().String(),
+		Type:       generationType,
+		StartTime:  time.Now(),
+		Parameters: parametersOf(factory),
+	}
+	defer i.cleanup(factory, generation, nil)
+	preview := &entity.FeedPreview{Type: generationType, DryRun: rows == 0}
+
+	fetchedStream := make(chan []string)
+	recordStream := make(chan []string)
+	fileStream := make(chan io.ReadCloser)
+	var dataFetcher DataFetcher
+	if rows > 0 {
+		dataFetcher = previewer.CreatePreviewDataFetcher(fetchedStream, rows)
+	} else {
+		dataFetcher = factory.CreateDataFetcher(fetchedStream)
+	}
+	if reporter, ok := dataFetcher.(RecordsReporter); ok {
+		reporter.OnRecordsCounted(func(recordsCount uint) {
+			preview.RecordsCount = recordsCount
+		})
+	}
+	if reporter, ok := dataFetcher.(ValidationReporter); ok {
+		reporter.OnRecordInvalid(preview.Reject)
+	}
+	fileFormatter := factory.CreateFileFormatter(generation, recordStream, fileStream)
+	uploader := previewer.CreatePreviewUploader(generation, fileStream, rows > 0)
+
+	fetch := func(ctx context.Context) error {
+		defer close(recordStream)
+		return fetchRecords(ctx, dataFetcher, fetchedStream, recordStream, rows, &preview.Records)
+	}
+	format := func(ctx context.Context) error {
+		defer close(fileStream)
+		return fileFormatter.FormatFiles(ctx)
+	}
+	if err := runConcurrently(ctx, fetch, format, uploader.UploadFiles); err != nil {
+		return nil, err
+	}
+	preview.Files = uploader.Files()
+	return preview, nil
+}
+
+// fetchRecords forwards the header and the first rows records fetched to
+// outStream, then stops the fetch; rows 0 forwards every record. The number
+// of records forwarded is stored in forwarded.
+func fetchRecords(
+	ctx context.Context,
+	dataFetcher DataFetcher,
+	fetchedStream chan []string,
+	outStream chan<- []string,
+	rows uint,
+	forwarded *uint,
+) error {
+	fetchCtx, stopFetch := context.WithCancel(ctx)
+	defer stopFetch()
+	fetchErr := make(chan error, 1)
+	go func() {
+		defer close(fetchedStream)
+		fetchErr <- dataFetcher.StreamData(fetchCtx)
+	}()
+
+	var header bool
+	for record := range fetchedStream {
+		select {
+		case outStream <- record:
+		case <-ctx.Done():
+			stopFetch()
+			<-fetchErr
+			return ctx.Err()
+		}
+		if !header {
+			header = true
+			continue
+		}
+		*forwarded++
+		if rows > 0 && *forwarded == rows {
+			// Whatever the fetch returns once stopped doesn't matter.
+			stopFetch()
+			<-fetchErr
+			return nil
+		}
+	}
+	return <-fetchErr
+}
+
+func (i *feedInteractor) RollbackGeneration(ctx context.Context, generationType, generationID string) error {
+	if err := i.feeds.RollbackGeneration(ctx, generationType, generationID); err != nil {
+		return i.presenter.PresentErr(err)
+	}
+	return nil
+}
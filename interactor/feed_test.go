@@ -3,6 +3,7 @@ package interactor_test
 import (
 	"context"
 	"errors"
+	"io/ioutil"
 	"testing"
 	"time"
 
@@ -57,7 +58,7 @@ func defaultFields() *fields {
 }
 
 func (f *fields) newInteractor() interactor.FeedInteractor {
-	return interactor.NewFeedInteractor(f.feeds, f.presenter)
+	return interactor.NewFeedInteractor(f.feeds, f.presenter, 0, "")
 }
 
 func (f *fields) assertExpectations(t *testing.T) {
@@ -71,7 +72,7 @@ func (f *fields) assertExpectations(t *testing.T) {
 
 func TestNewFeedInteractor(t *testing.T) {
 	fields := defaultFields()
-	i := interactor.NewFeedInteractor(fields.feeds, fields.presenter)
+	i := interactor.NewFeedInteractor(fields.feeds, fields.presenter, 0, "")
 	assert.Equal(t, fields.feeds, i.GenerationRepo())
 	assert.Equal(t, fields.presenter, i.Presenter())
 }
@@ -108,10 +109,12 @@ func TestFeedInteractor_GenerateFeed(t *testing.T) {
 					Return(nil)
 				f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).
 					Return(nil)
+				f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).Return(nil)
 
 				f.factory.On("CreateDataFetcher", mock.Anything).Return(f.dataFetcher)
-				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything).Return(f.fileFormatter)
-				f.factory.On("CreateUploader", mock.Anything).Return(f.uploader)
+				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).Return(f.fileFormatter)
+				f.factory.On("Cleanup", mock.Anything).Return(nil)
+				f.factory.On("CreateUploader", mock.Anything, mock.Anything).Return(f.uploader)
 
 				f.dataFetcher.
 					On("StreamData", mock.Anything).Return(nil).
@@ -121,7 +124,8 @@ func TestFeedInteractor_GenerateFeed(t *testing.T) {
 					On("FormatFiles", mock.Anything).Return(nil)
 				f.uploader.
 					On("UploadFiles", mock.Anything).Return(nil).
-					On("OnUpload", mock.Anything).Return(nil)
+					On("OnUpload", mock.Anything).Return(nil).
+					On("OnManifestUploaded", mock.Anything).Return(nil)
 			},
 		},
 		{
@@ -162,10 +166,12 @@ func TestFeedInteractor_GenerateFeed(t *testing.T) {
 					Return(nil)
 				f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).
 					Return(nil)
+				f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).Return(nil)
 
 				f.factory.On("CreateDataFetcher", mock.Anything).Return(f.dataFetcher)
-				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything).Return(f.fileFormatter)
-				f.factory.On("CreateUploader", mock.Anything).Return(f.uploader)
+				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).Return(f.fileFormatter)
+				f.factory.On("Cleanup", mock.Anything).Return(nil)
+				f.factory.On("CreateUploader", mock.Anything, mock.Anything).Return(f.uploader)
 
 				f.dataFetcher.
 					On("StreamData", mock.Anything).Return(defaultErr).After(time.Millisecond*5).
@@ -175,7 +181,8 @@ func TestFeedInteractor_GenerateFeed(t *testing.T) {
 					On("FormatFiles", mock.Anything).Return(nil)
 				f.uploader.
 					On("UploadFiles", mock.Anything).Return(nil).
-					On("OnUpload", mock.Anything).Return(nil)
+					On("OnUpload", mock.Anything).Return(nil).
+					On("OnManifestUploaded", mock.Anything).Return(nil)
 
 				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
 			},
@@ -196,10 +203,12 @@ func TestFeedInteractor_GenerateFeed(t *testing.T) {
 					Return(nil)
 				f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).
 					Return(nil)
+				f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).Return(nil)
 
 				f.factory.On("CreateDataFetcher", mock.Anything).Return(f.dataFetcher)
-				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything).Return(f.fileFormatter)
-				f.factory.On("CreateUploader", mock.Anything).Return(f.uploader)
+				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).Return(f.fileFormatter)
+				f.factory.On("Cleanup", mock.Anything).Return(nil)
+				f.factory.On("CreateUploader", mock.Anything, mock.Anything).Return(f.uploader)
 
 				f.dataFetcher.
 					On("StreamData", mock.Anything).Return(nil).
@@ -209,7 +218,8 @@ func TestFeedInteractor_GenerateFeed(t *testing.T) {
 					On("FormatFiles", mock.Anything).Return(defaultErr).After(time.Millisecond * 5)
 				f.uploader.
 					On("UploadFiles", mock.Anything).Return(nil).
-					On("OnUpload", mock.Anything).Return(nil)
+					On("OnUpload", mock.Anything).Return(nil).
+					On("OnManifestUploaded", mock.Anything).Return(nil)
 
 				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
 			},
@@ -230,10 +240,12 @@ func TestFeedInteractor_GenerateFeed(t *testing.T) {
 					Return(nil)
 				f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).
 					Return(nil)
+				f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).Return(nil)
 
 				f.factory.On("CreateDataFetcher", mock.Anything).Return(f.dataFetcher)
-				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything).Return(f.fileFormatter)
-				f.factory.On("CreateUploader", mock.Anything).Return(f.uploader)
+				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).Return(f.fileFormatter)
+				f.factory.On("Cleanup", mock.Anything).Return(nil)
+				f.factory.On("CreateUploader", mock.Anything, mock.Anything).Return(f.uploader)
 
 				f.dataFetcher.
 					On("StreamData", mock.Anything).Return(nil).
@@ -243,7 +255,8 @@ func TestFeedInteractor_GenerateFeed(t *testing.T) {
 					On("FormatFiles", mock.Anything).Return(nil)
 				f.uploader.
 					On("UploadFiles", mock.Anything).Return(defaultErr).After(time.Millisecond*5).
-					On("OnUpload", mock.Anything).Return(nil)
+					On("OnUpload", mock.Anything).Return(nil).
+					On("OnManifestUploaded", mock.Anything).Return(nil)
 
 				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
 			},
@@ -307,10 +320,12 @@ func TestFeedInteractor_RestartGeneration(t *testing.T) {
 					StartTime:     time.Unix(10, 0),
 				}).Return(nil)
 				f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).Return(nil)
 
 				f.factory.On("CreateDataFetcher", mock.Anything).Return(f.dataFetcher)
-				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything).Return(f.fileFormatter)
-				f.factory.On("CreateUploader", mock.Anything).Return(f.uploader)
+				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).Return(f.fileFormatter)
+				f.factory.On("Cleanup", mock.Anything).Return(nil)
+				f.factory.On("CreateUploader", mock.Anything, mock.Anything).Return(f.uploader)
 
 				f.dataFetcher.
 					On("StreamData", mock.Anything).Return(nil).
@@ -320,7 +335,8 @@ func TestFeedInteractor_RestartGeneration(t *testing.T) {
 					On("FormatFiles", mock.Anything).Return(nil)
 				f.uploader.
 					On("UploadFiles", mock.Anything).Return(nil).
-					On("OnUpload", mock.Anything).Return(nil)
+					On("OnUpload", mock.Anything).Return(nil).
+					On("OnManifestUploaded", mock.Anything).Return(nil)
 			},
 		},
 		{
@@ -374,10 +390,12 @@ func TestFeedInteractor_RestartGeneration(t *testing.T) {
 				}).Return(nil)
 				f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).
 					Return(nil)
+				f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).Return(nil)
 
 				f.factory.On("CreateDataFetcher", mock.Anything).Return(f.dataFetcher)
-				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything).Return(f.fileFormatter)
-				f.factory.On("CreateUploader", mock.Anything).Return(f.uploader)
+				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).Return(f.fileFormatter)
+				f.factory.On("Cleanup", mock.Anything).Return(nil)
+				f.factory.On("CreateUploader", mock.Anything, mock.Anything).Return(f.uploader)
 
 				f.dataFetcher.
 					On("StreamData", mock.Anything).Return(defaultErr).After(time.Millisecond*5).
@@ -387,7 +405,8 @@ func TestFeedInteractor_RestartGeneration(t *testing.T) {
 					On("FormatFiles", mock.Anything).Return(nil)
 				f.uploader.
 					On("UploadFiles", mock.Anything).Return(nil).
-					On("OnUpload", mock.Anything).Return(nil)
+					On("OnUpload", mock.Anything).Return(nil).
+					On("OnManifestUploaded", mock.Anything).Return(nil)
 
 				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
 			},
@@ -417,10 +436,12 @@ func TestFeedInteractor_RestartGeneration(t *testing.T) {
 				}).Return(nil)
 				f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).
 					Return(nil)
+				f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).Return(nil)
 
 				f.factory.On("CreateDataFetcher", mock.Anything).Return(f.dataFetcher)
-				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything).Return(f.fileFormatter)
-				f.factory.On("CreateUploader", mock.Anything).Return(f.uploader)
+				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).Return(f.fileFormatter)
+				f.factory.On("Cleanup", mock.Anything).Return(nil)
+				f.factory.On("CreateUploader", mock.Anything, mock.Anything).Return(f.uploader)
 
 				f.dataFetcher.
 					On("StreamData", mock.Anything).Return(nil).
@@ -430,7 +451,8 @@ func TestFeedInteractor_RestartGeneration(t *testing.T) {
 					On("FormatFiles", mock.Anything).Return(defaultErr).After(time.Millisecond * 5)
 				f.uploader.
 					On("UploadFiles", mock.Anything).Return(nil).
-					On("OnUpload", mock.Anything).Return(nil)
+					On("OnUpload", mock.Anything).Return(nil).
+					On("OnManifestUploaded", mock.Anything).Return(nil)
 
 				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
 			},
@@ -460,10 +482,12 @@ func TestFeedInteractor_RestartGeneration(t *testing.T) {
 				}).Return(nil)
 				f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).
 					Return(nil)
+				f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).Return(nil)
 
 				f.factory.On("CreateDataFetcher", mock.Anything).Return(f.dataFetcher)
-				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything).Return(f.fileFormatter)
-				f.factory.On("CreateUploader", mock.Anything).Return(f.uploader)
+				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).Return(f.fileFormatter)
+				f.factory.On("Cleanup", mock.Anything).Return(nil)
+				f.factory.On("CreateUploader", mock.Anything, mock.Anything).Return(f.uploader)
 
 				f.dataFetcher.
 					On("StreamData", mock.Anything).Return(nil).
@@ -473,7 +497,8 @@ func TestFeedInteractor_RestartGeneration(t *testing.T) {
 					On("FormatFiles", mock.Anything).Return(nil)
 				f.uploader.
 					On("UploadFiles", mock.Anything).Return(defaultErr).After(time.Millisecond*5).
-					On("OnUpload", mock.Anything).Return(nil)
+					On("OnUpload", mock.Anything).Return(nil).
+					On("OnManifestUploaded", mock.Anything).Return(nil)
 
 				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
 			},
@@ -495,6 +520,186 @@ func TestFeedInteractor_RestartGeneration(t *testing.T) {
 	}
 }
 
+type checkpointFactory struct {
+	*mocks.FeedFactory
+	*mocks.Checkpointer
+}
+
+func TestFeedInteractor_RetryUpload(t *testing.T) {
+	type args struct {
+		ctx          context.Context
+		generationID string
+	}
+	defaultArgs := func() *args {
+		return &args{
+			ctx:          context.Background(),
+			generationID: uuid.NewString(),
+		}
+	}
+	failedGeneration := func(a *args) *entity.Generation {
+		return &entity.Generation{
+			ID:            a.generationID,
+			Type:          "test",
+			Progress:      100,
+			DataFetched:   true,
+			FilesUploaded: 2,
+			IsCanceled:    true,
+			StartTime:     time.Unix(10, 0),
+		}
+	}
+	testCases := []struct {
+		name       string
+		args       *args
+		setupMocks func(*args, *fields, *mocks.Checkpointer)
+		wantErr    error
+	}{
+		{
+			name: "upload retried from checkpoint",
+			args: defaultArgs(),
+			setupMocks: func(a *args, f *fields, c *mocks.Checkpointer) {
+				f.feeds.On("GetGeneration", a.ctx, a.generationID).Return(failedGeneration(a), nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&checkpointFactory{FeedFactory: f.factory, Checkpointer: c}, nil)
+				retried := failedGeneration(a)
+				retried.IsCanceled = false
+				f.feeds.On("UpdateGenerationState", a.ctx, retried).Return(nil)
+				f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).Return(nil)
+
+				c.On("CreateCheckpointFormatter", mock.Anything, mock.Anything).Return(f.fileFormatter, nil)
+				c.On("CreateResumedUploader", mock.Anything, mock.Anything).Return(f.uploader)
+				f.factory.On("Cleanup", mock.Anything).Return(nil).Once()
+
+				f.fileFormatter.
+					On("FormatFiles", mock.Anything).Return(nil)
+				f.uploader.
+					On("UploadFiles", mock.Anything).Return(nil).
+					On("OnUpload", mock.Anything).Return(nil).
+					On("OnManifestUploaded", mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "spool kept when retry fails",
+			args: defaultArgs(),
+			setupMocks: func(a *args, f *fields, c *mocks.Checkpointer) {
+				f.feeds.On("GetGeneration", a.ctx, a.generationID).Return(failedGeneration(a), nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&checkpointFactory{FeedFactory: f.factory, Checkpointer: c}, nil)
+				f.feeds.On("UpdateGenerationState", a.ctx, mock.Anything).Return(nil)
+				f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+				c.On("CreateCheckpointFormatter", mock.Anything, mock.Anything).Return(f.fileFormatter, nil)
+				c.On("CleanupFailed", mock.Anything).Return(nil).Once()
+				c.On("CreateResumedUploader", mock.Anything, mock.Anything).Return(f.uploader)
+
+				f.fileFormatter.
+					On("FormatFiles", mock.Anything).Return(nil)
+				f.uploader.
+					On("UploadFiles", mock.Anything).Return(defaultErr).
+					On("OnUpload", mock.Anything).Return(nil).
+					On("OnManifestUploaded", mock.Anything).Return(nil)
+
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "restarted without checkpoint",
+			args: defaultArgs(),
+			setupMocks: func(a *args, f *fields, c *mocks.Checkpointer) {
+				f.feeds.On("GetGeneration", a.ctx, a.generationID).Return(failedGeneration(a), nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&checkpointFactory{FeedFactory: f.factory, Checkpointer: c}, nil)
+				f.feeds.On("UpdateGenerationState", a.ctx, &entity.Generation{
+					ID:        a.generationID,
+					Type:      "test",
+					StartTime: time.Unix(10, 0),
+				}).Return(nil)
+				f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).Return(nil)
+
+				c.On("CreateCheckpointFormatter", mock.Anything, mock.Anything).Return(nil, entity.ErrNoCheckpoint)
+				f.factory.On("CreateDataFetcher", mock.Anything).Return(f.dataFetcher)
+				f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).Return(f.fileFormatter)
+				f.factory.On("CreateUploader", mock.Anything, mock.Anything).Return(f.uploader)
+				f.factory.On("Cleanup", mock.Anything).Return(nil).Twice()
+
+				f.dataFetcher.
+					On("StreamData", mock.Anything).Return(nil).
+					On("OnDataFetched", mock.Anything).Return(nil).
+					On("OnProgress", mock.Anything).Return(nil)
+				f.fileFormatter.
+					On("FormatFiles", mock.Anything).Return(nil)
+				f.uploader.
+					On("UploadFiles", mock.Anything).Return(nil).
+					On("OnUpload", mock.Anything).Return(nil).
+					On("OnManifestUploaded", mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "checkpoint error",
+			args: defaultArgs(),
+			setupMocks: func(a *args, f *fields, c *mocks.Checkpointer) {
+				f.feeds.On("GetGeneration", a.ctx, a.generationID).Return(failedGeneration(a), nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&checkpointFactory{FeedFactory: f.factory, Checkpointer: c}, nil)
+				c.On("CreateCheckpointFormatter", mock.Anything, mock.Anything).Return(nil, defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "generation running",
+			args: defaultArgs(),
+			setupMocks: func(a *args, f *fields, c *mocks.Checkpointer) {
+				running := &entity.Generation{
+					ID:     a.generationID,
+					Type:   "test",
+					Stages: []*entity.StageTiming{{Name: "upload", StartTime: time.Unix(10, 0)}},
+				}
+				f.feeds.On("GetGeneration", a.ctx, a.generationID).Return(running, nil)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrGenerationRunning,
+		},
+		{
+			name: "generation succeeded",
+			args: defaultArgs(),
+			setupMocks: func(a *args, f *fields, c *mocks.Checkpointer) {
+				succeeded := failedGeneration(a)
+				succeeded.IsCanceled = false
+				f.feeds.On("GetGeneration", a.ctx, a.generationID).Return(succeeded, nil)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrGenerationSucceeded,
+		},
+		{
+			name: "get generation error",
+			args: defaultArgs(),
+			setupMocks: func(a *args, f *fields, c *mocks.Checkpointer) {
+				f.feeds.On("GetGeneration", a.ctx, a.generationID).Return(nil, defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			checkpointer := new(mocks.Checkpointer)
+			interactor := fields.newInteractor()
+			testCase.setupMocks(testCase.args, fields, checkpointer)
+
+			gotErr := interactor.RetryUpload(testCase.args.ctx, testCase.args.generationID)
+
+			assert.ErrorIs(t, gotErr, testCase.wantErr)
+			fields.assertExpectations(t)
+			checkpointer.AssertExpectations(t)
+		})
+	}
+}
+
 func TestListGenerationTypes(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -530,12 +735,174 @@ func TestListGenerationTypes(t *testing.T) {
 	}
 }
 
+func TestFeedInteractor_GetGeneration(t *testing.T) {
+	testCases := []struct {
+		name       string
+		setupMocks func(*fields)
+		want       interface{}
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, generation1.ID).Return(&generation1, nil)
+				f.presenter.
+					On("PresentGeneration", mock.Anything).
+					Return(func(out *interactor.GenerationsOut) interface{} {
+						return out
+					})
+			},
+			want: (*interactor.GenerationsOut)(&generation1),
+		},
+		{
+			name: "not found",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, generation1.ID).Return(nil, entity.ErrGenerationNotFound)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrGenerationNotFound,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			interactor := fields.newInteractor()
+			testCase.setupMocks(fields)
+
+			got, gotErr := interactor.GetGeneration(context.Background(), generation1.ID)
+
+			assert.Equal(t, testCase.want, got)
+			assert.Equal(t, testCase.wantErr, gotErr)
+			fields.assertExpectations(t)
+		})
+	}
+}
+
+func TestFeedInteractor_GetGenerationLogs(t *testing.T) {
+	entries := []*entity.LogEntry{{Level: "info", Message: "Started"}}
+	testCases := []struct {
+		name       string
+		setupMocks func(*fields)
+		want       interface{}
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, generation1.ID).Return(&generation1, nil)
+				f.feeds.On("ListGenerationLogs", mock.Anything, generation1.ID).Return(entries, nil)
+				f.presenter.On("PresentGenerationLogs", entries).Return(entries)
+			},
+			want: entries,
+		},
+		{
+			name: "not found",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, generation1.ID).Return(nil, entity.ErrGenerationNotFound)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrGenerationNotFound,
+		},
+		{
+			name: "ListGenerationLogs error",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, generation1.ID).Return(&generation1, nil)
+				f.feeds.On("ListGenerationLogs", mock.Anything, generation1.ID).Return(nil, defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			interactor := fields.newInteractor()
+			testCase.setupMocks(fields)
+
+			got, gotErr := interactor.GetGenerationLogs(context.Background(), generation1.ID)
+
+			assert.Equal(t, testCase.want, got)
+			assert.Equal(t, testCase.wantErr, gotErr)
+			fields.assertExpectations(t)
+		})
+	}
+}
+
+func TestFeedInteractor_FollowGenerationLogs(t *testing.T) {
+	entries := []*entity.LogEntry{{Level: "info", Message: "Started"}, {Level: "info", Message: "Finished"}}
+	testCases := []struct {
+		name       string
+		setupMocks func(*fields)
+		want       []interface{}
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, generation1.ID).Return(&generation1, nil)
+				f.feeds.On("FollowGenerationLogs", mock.Anything, generation1.ID, mock.Anything).
+					Run(func(args mock.Arguments) {
+						callback := args.Get(2).(func(*entity.LogEntry))
+						for _, entry := range entries {
+							callback(entry)
+						}
+					}).
+					Return(nil)
+				f.presenter.On("PresentLogEntry", mock.Anything).Return(func(entry *entity.LogEntry) interface{} {
+					return entry.Message
+				})
+			},
+			want: []interface{}{"Started", "Finished"},
+		},
+		{
+			name: "not found",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, generation1.ID).Return(nil, entity.ErrGenerationNotFound)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrGenerationNotFound,
+		},
+		{
+			name: "FollowGenerationLogs error",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, generation1.ID).Return(&generation1, nil)
+				f.feeds.On("FollowGenerationLogs", mock.Anything, generation1.ID, mock.Anything).Return(defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			interactor := fields.newInteractor()
+			testCase.setupMocks(fields)
+			outStream := make(chan interface{}, 2)
+
+			gotErr := interactor.FollowGenerationLogs(context.Background(), generation1.ID, outStream)
+
+			close(outStream)
+			var got []interface{}
+			for out := range outStream {
+				got = append(got, out)
+			}
+			assert.Equal(t, testCase.want, got)
+			assert.Equal(t, testCase.wantErr, gotErr)
+			fields.assertExpectations(t)
+		})
+	}
+}
+
 func TestFeedInteractor_ListGenerations(t *testing.T) {
 	type args struct {
-		ctx context.Context
+		ctx    context.Context
+		filter *entity.GenerationFilter
 	}
 	defaultArgs := func() *args {
-		return &args{ctx: context.Background()}
+		return &args{
+			ctx:    context.Background(),
+			filter: &entity.GenerationFilter{Types: []string{"test"}, Limit: 2},
+		}
 	}
 	testCases := []struct {
 		name       string
@@ -549,8 +916,8 @@ func TestFeedInteractor_ListGenerations(t *testing.T) {
 			args: defaultArgs(),
 			setupMocks: func(a *args, f *fields) {
 				f.feeds.
-					On("ListGenerations", a.ctx).
-					Return([]*entity.Generation{&generation1, &generation2}, nil)
+					On("ListGenerations", a.ctx, a.filter).
+					Return([]*entity.Generation{&generation1, &generation2}, "next", nil)
 				f.presenter.
 					On("PresentListGenerations", mock.Anything).
 					Return(func(out *interactor.ListGenerationsOut) interface{} {
@@ -558,27 +925,30 @@ func TestFeedInteractor_ListGenerations(t *testing.T) {
 					})
 			},
 			want: &interactor.ListGenerationsOut{
-				{
-					ID:        generation1.ID,
-					Type:      generation1.Type,
-					Progress:  generation1.Progress,
-					StartTime: generation1.StartTime,
-					EndTime:   generation1.EndTime,
-				},
-				{
-					ID:        generation2.ID,
-					Type:      generation2.Type,
-					Progress:  generation2.Progress,
-					StartTime: generation2.StartTime,
-					EndTime:   generation2.EndTime,
+				Generations: []*interactor.GenerationsOut{
+					{
+						ID:        generation1.ID,
+						Type:      generation1.Type,
+						Progress:  generation1.Progress,
+						StartTime: generation1.StartTime,
+						EndTime:   generation1.EndTime,
+					},
+					{
+						ID:        generation2.ID,
+						Type:      generation2.Type,
+						Progress:  generation2.Progress,
+						StartTime: generation2.StartTime,
+						EndTime:   generation2.EndTime,
+					},
 				},
+				NextCursor: "next",
 			},
 		},
 		{
 			name: "feeds.ListGenerations error",
 			args: defaultArgs(),
 			setupMocks: func(a *args, f *fields) {
-				f.feeds.On("ListGenerations", a.ctx).Return(nil, defaultErr)
+				f.feeds.On("ListGenerations", a.ctx, a.filter).Return(nil, "", defaultErr)
 				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
 			},
 			wantErr: defaultErr,
@@ -590,8 +960,84 @@ func TestFeedInteractor_ListGenerations(t *testing.T) {
 			interactor := fields.newInteractor()
 			testCase.setupMocks(testCase.args, fields)
 
-			got, gotErr := interactor.ListGenerations(testCase.args.ctx)
+			got, gotErr := interactor.ListGenerations(testCase.args.ctx, testCase.args.filter)
+
+			assert.Equal(t, testCase.want, got)
+			assert.Equal(t, testCase.wantErr, gotErr)
+			fields.assertExpectations(t)
+		})
+	}
+}
+
+func TestFeedInteractor_WatchGenerationsProgress(t *testing.T) {
+	running := &entity.GenerationFilter{
+		Statuses: []entity.GenerationStatus{entity.GenerationRunning},
+		Limit:    500,
+	}
+	nextPage := &entity.GenerationFilter{
+		Statuses: running.Statuses,
+		Cursor:   "next",
+		Limit:    running.Limit,
+	}
+	updated := &entity.Generation{ID: "updated"}
+	// follow lists the running generations once subscribed and then passes
+	// the update, as the repo does.
+	follow := func(_ context.Context, onSubscribed func() error, callback func(*entity.Generation)) error {
+		if err := onSubscribed(); err != nil {
+			return err
+		}
+		callback(updated)
+		return nil
+	}
+	testCases := []struct {
+		name       string
+		setupMocks func(*fields)
+		want       []*entity.Generation
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			setupMocks: func(f *fields) {
+				f.feeds.On("ListGenerations", mock.Anything, running).
+					Return([]*entity.Generation{&generation1}, "next", nil).Once()
+				f.feeds.On("ListGenerations", mock.Anything, nextPage).
+					Return([]*entity.Generation{&generation2}, "", nil).Once()
+				f.feeds.On("OnGenerationsUpdated", mock.Anything, mock.Anything, mock.Anything).Return(follow)
+			},
+			want: []*entity.Generation{&generation1, &generation2, updated},
+		},
+		{
+			name: "ListGenerations error",
+			setupMocks: func(f *fields) {
+				f.feeds.On("ListGenerations", mock.Anything, running).Return(nil, "", defaultErr)
+				f.feeds.On("OnGenerationsUpdated", mock.Anything, mock.Anything, mock.Anything).Return(follow)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "OnGenerationsUpdated error",
+			setupMocks: func(f *fields) {
+				f.feeds.On("OnGenerationsUpdated", mock.Anything, mock.Anything, mock.Anything).Return(defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			interactor := fields.newInteractor()
+			testCase.setupMocks(fields)
+			outStream := make(chan *entity.Generation, 3)
+
+			gotErr := interactor.WatchGenerationsProgress(context.Background(), outStream)
 
+			close(outStream)
+			var got []*entity.Generation
+			for generation := range outStream {
+				got = append(got, generation)
+			}
 			assert.Equal(t, testCase.want, got)
 			assert.Equal(t, testCase.wantErr, gotErr)
 			fields.assertExpectations(t)
@@ -646,3 +1092,808 @@ func TestFeedInteractor_CancelGeneration(t *testing.T) {
 		})
 	}
 }
+
+func TestFeedInteractor_PurgeGeneration(t *testing.T) {
+	finished := &entity.Generation{ID: defaultID, Type: "test", Error: "test error"}
+	running := &entity.Generation{ID: defaultID, Type: "test"}
+	testCases := []struct {
+		name       string
+		setupMocks func(*fields)
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(finished, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").Return(f.factory, nil)
+				f.factory.On("Cleanup", finished).Return(nil)
+				f.feeds.On("DeleteGeneration", mock.Anything, defaultID).Return(nil)
+			},
+		},
+		{
+			name: "archived files removed",
+			setupMocks: func(f *fields) {
+				archiver := new(mocks.Archiver)
+				archiver.On("RemoveArchive", finished).Return(nil)
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(finished, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&archiverFactory{FeedFactory: f.factory, Archiver: archiver}, nil)
+				f.factory.On("Cleanup", finished).Return(nil)
+				f.feeds.On("DeleteGeneration", mock.Anything, defaultID).Return(nil)
+			},
+		},
+		{
+			name: "kept staging directories removed",
+			setupMocks: func(f *fields) {
+				checkpointer := new(mocks.Checkpointer)
+				checkpointer.On("RemoveStaging", finished).Return(nil).Once()
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(finished, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&checkpointFactory{FeedFactory: f.factory, Checkpointer: checkpointer}, nil)
+				f.factory.On("Cleanup", finished).Return(nil)
+				f.feeds.On("DeleteGeneration", mock.Anything, defaultID).Return(nil)
+			},
+		},
+		{
+			name: "checkpointer.RemoveStaging error",
+			setupMocks: func(f *fields) {
+				checkpointer := new(mocks.Checkpointer)
+				checkpointer.On("RemoveStaging", finished).Return(defaultErr)
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(finished, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&checkpointFactory{FeedFactory: f.factory, Checkpointer: checkpointer}, nil)
+				f.factory.On("Cleanup", finished).Return(nil)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "type no longer configured",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(finished, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").Return(nil, entity.ErrInvalidGenerationType)
+				f.feeds.On("DeleteGeneration", mock.Anything, defaultID).Return(nil)
+			},
+		},
+		{
+			name: "generation running",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(running, nil)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrGenerationRunning,
+		},
+		{
+			name: "feeds.GetGeneration error",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(nil, entity.ErrGenerationNotFound)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrGenerationNotFound,
+		},
+		{
+			name: "factory.Cleanup error",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(finished, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").Return(f.factory, nil)
+				f.factory.On("Cleanup", finished).Return(defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "feeds.DeleteGeneration error",
+			setupMocks: func(f *fields) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(finished, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").Return(f.factory, nil)
+				f.factory.On("Cleanup", finished).Return(nil)
+				f.feeds.On("DeleteGeneration", mock.Anything, defaultID).Return(defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			interactor := fields.newInteractor()
+			testCase.setupMocks(fields)
+
+			gotErr := interactor.PurgeGeneration(context.Background(), defaultID)
+
+			assert.ErrorIs(t, gotErr, testCase.wantErr)
+			fields.assertExpectations(t)
+		})
+	}
+}
+
+func TestFeedInteractor_ApplyRetention(t *testing.T) {
+	now := time.Now()
+	finished := func(id, generationType string, age time.Duration) *entity.Generation {
+		return &entity.Generation{ID: id, Type: generationType, StartTime: now.Add(-age), Error: "test error"}
+	}
+	running := &entity.Generation{ID: "running", Type: "a", StartTime: now.Add(-48 * time.Hour)}
+	firstPage := []*entity.Generation{
+		running,
+		finished("a1", "a", time.Hour),
+		finished("b1", "b", 2*time.Hour),
+		finished("a2", "a", 3*time.Hour),
+	}
+	secondPage := []*entity.Generation{
+		finished("b2", "b", 4*time.Hour),
+		finished("a3", "a", 30*time.Hour),
+	}
+	listPages := func(f *fields) {
+		f.feeds.On("ListGenerations", mock.Anything, mock.MatchedBy(func(filter *entity.GenerationFilter) bool {
+			return filter.Cursor == ""
+		})).Return(firstPage, "next", nil).Once()
+		f.feeds.On("ListGenerations", mock.Anything, mock.MatchedBy(func(filter *entity.GenerationFilter) bool {
+			return filter.Cursor == "next"
+		})).Return(secondPage, "", nil).Once()
+	}
+	expectPurged := func(f *fields, ids ...string) {
+		f.feeds.On("GetFactoryByGenerationType", mock.Anything).Return(f.factory, nil)
+		f.factory.On("Cleanup", mock.Anything).Return(nil).Times(len(ids))
+		for _, id := range ids {
+			f.feeds.On("DeleteGeneration", mock.Anything, id).Return(nil).Once()
+		}
+	}
+	testCases := []struct {
+		name       string
+		policy     *entity.RetentionPolicy
+		setupMocks func(*fields)
+		wantErr    error
+	}{
+		{
+			name:       "disabled",
+			policy:     &entity.RetentionPolicy{},
+			setupMocks: func(f *fields) {},
+		},
+		{
+			name:   "count per type",
+			policy: &entity.RetentionPolicy{MaxCount: 2},
+			setupMocks: func(f *fields) {
+				listPages(f)
+				expectPurged(f, "a3")
+			},
+		},
+		{
+			name:   "age",
+			policy: &entity.RetentionPolicy{MaxAge: 150 * time.Minute},
+			setupMocks: func(f *fields) {
+				listPages(f)
+				expectPurged(f, "a2", "b2", "a3")
+			},
+		},
+		{
+			name:   "count and age",
+			policy: &entity.RetentionPolicy{MaxAge: 24 * time.Hour, MaxCount: 1},
+			setupMocks: func(f *fields) {
+				listPages(f)
+				expectPurged(f, "a2", "b2", "a3")
+			},
+		},
+		{
+			name:   "feeds.ListGenerations error",
+			policy: &entity.RetentionPolicy{MaxCount: 2},
+			setupMocks: func(f *fields) {
+				f.feeds.On("ListGenerations", mock.Anything, mock.Anything).Return(nil, "", defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name:   "feeds.DeleteGeneration error",
+			policy: &entity.RetentionPolicy{MaxCount: 1},
+			setupMocks: func(f *fields) {
+				f.feeds.On("ListGenerations", mock.Anything, mock.Anything).Return(firstPage, "next", nil).Once()
+				f.feeds.On("GetFactoryByGenerationType", "a").Return(f.factory, nil)
+				f.factory.On("Cleanup", firstPage[3]).Return(nil)
+				f.feeds.On("DeleteGeneration", mock.Anything, "a2").Return(defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			interactor := fields.newInteractor()
+			testCase.setupMocks(fields)
+
+			gotErr := interactor.ApplyRetention(context.Background(), testCase.policy)
+
+			assert.ErrorIs(t, gotErr, testCase.wantErr)
+			fields.assertExpectations(t)
+		})
+	}
+}
+
+func TestFeedInteractor_FailInterruptedGenerations(t *testing.T) {
+	interrupted := func(id string) *entity.Generation {
+		return &entity.Generation{ID: id, Type: "test", Progress: 10, Instance: "app-1"}
+	}
+	ofOtherInstance := func(id string) *entity.Generation {
+		generation := interrupted(id)
+		generation.Instance = "app-2"
+		return generation
+	}
+	failed := func(id string) *entity.Generation {
+		generation := interrupted(id)
+		generation.Error = entity.ErrGenerationInterrupted.Error()
+		generation.Finished = true
+		return generation
+	}
+	isRunningFilter := func(cursor string) interface{} {
+		return mock.MatchedBy(func(filter *entity.GenerationFilter) bool {
+			return filter.Cursor == cursor &&
+				len(filter.Statuses) == 1 && filter.Statuses[0] == entity.GenerationRunning
+		})
+	}
+	testCases := []struct {
+		name       string
+		setupMocks func(*fields)
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			setupMocks: func(f *fields) {
+				f.feeds.On("ListGenerations", mock.Anything, isRunningFilter("")).
+					Return([]*entity.Generation{interrupted("1"), interrupted("2")}, "next", nil).Once()
+				f.feeds.On("ListGenerations", mock.Anything, isRunningFilter("next")).
+					Return([]*entity.Generation{interrupted("3"), ofOtherInstance("4")}, "", nil).Once()
+				for _, id := range []string{"1", "2", "3"} {
+					f.feeds.On("UpdateGenerationState", mock.Anything, failed(id)).Return(nil).Once()
+				}
+			},
+		},
+		{
+			name: "nothing interrupted",
+			setupMocks: func(f *fields) {
+				f.feeds.On("ListGenerations", mock.Anything, isRunningFilter("")).
+					Return([]*entity.Generation{}, "", nil).Once()
+			},
+		},
+		{
+			name: "feeds.ListGenerations error",
+			setupMocks: func(f *fields) {
+				f.feeds.On("ListGenerations", mock.Anything, mock.Anything).Return(nil, "", defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "feeds.UpdateGenerationState error",
+			setupMocks: func(f *fields) {
+				f.feeds.On("ListGenerations", mock.Anything, mock.Anything).
+					Return([]*entity.Generation{interrupted("1")}, "", nil)
+				f.feeds.On("UpdateGenerationState", mock.Anything, failed("1")).Return(defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			interactor := interactor.NewFeedInteractor(fields.feeds, fields.presenter, 0, "app-1")
+			testCase.setupMocks(fields)
+
+			gotErr := interactor.FailInterruptedGenerations(context.Background())
+
+			assert.ErrorIs(t, gotErr, testCase.wantErr)
+			fields.assertExpectations(t)
+		})
+	}
+}
+
+type archiverFactory struct {
+	*mocks.FeedFactory
+	*mocks.Archiver
+}
+
+func TestFeedInteractor_ListGenerationFiles(t *testing.T) {
+	generation := &entity.Generation{
+		ID:   defaultID,
+		Type: "test",
+		Manifest: &entity.Manifest{Files: []*entity.FileInfo{
+			{Name: "test_0.csv", Size: 10, Records: 2, SHA256: "abc"},
+		}},
+	}
+	testCases := []struct {
+		name       string
+		setupMocks func(*fields, *mocks.Archiver)
+		want       interface{}
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			setupMocks: func(f *fields, a *mocks.Archiver) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(generation, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&archiverFactory{FeedFactory: f.factory, Archiver: a}, nil)
+				a.On("ListArchivedFiles", generation).Return([]*entity.FileInfo{
+					{Name: "test_0.csv", Size: 10},
+					{Name: "test_1.csv", Size: 5},
+				}, nil)
+				f.presenter.On("PresentGenerationFiles", mock.Anything).
+					Return(func(files []*entity.FileInfo) interface{} {
+						return files
+					})
+			},
+			want: []*entity.FileInfo{
+				{Name: "test_0.csv", Size: 10, Records: 2, SHA256: "abc"},
+				{Name: "test_1.csv", Size: 5},
+			},
+		},
+		{
+			name: "factory keeps no files",
+			setupMocks: func(f *fields, a *mocks.Archiver) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(generation, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").Return(f.factory, nil)
+				f.presenter.On("PresentGenerationFiles", mock.Anything).
+					Return(func(files []*entity.FileInfo) interface{} {
+						return files
+					})
+			},
+			want: []*entity.FileInfo{},
+		},
+		{
+			name: "feeds.GetGeneration error",
+			setupMocks: func(f *fields, a *mocks.Archiver) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(nil, entity.ErrGenerationNotFound)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrGenerationNotFound,
+		},
+		{
+			name: "archiver.ListArchivedFiles error",
+			setupMocks: func(f *fields, a *mocks.Archiver) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(generation, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&archiverFactory{FeedFactory: f.factory, Archiver: a}, nil)
+				a.On("ListArchivedFiles", generation).Return(nil, defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			archiver := new(mocks.Archiver)
+			interactor := fields.newInteractor()
+			testCase.setupMocks(fields, archiver)
+
+			got, gotErr := interactor.ListGenerationFiles(context.Background(), defaultID)
+
+			assert.ErrorIs(t, gotErr, testCase.wantErr)
+			assert.Equal(t, testCase.want, got)
+			fields.assertExpectations(t)
+			archiver.AssertExpectations(t)
+		})
+	}
+}
+
+func TestFeedInteractor_OpenGenerationFile(t *testing.T) {
+	generation := &entity.Generation{ID: defaultID, Type: "test"}
+	file, err := ioutil.TempFile(t.TempDir(), "archived")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	testCases := []struct {
+		name       string
+		setupMocks func(*fields, *mocks.Archiver)
+		want       interactor.ArchivedFile
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			setupMocks: func(f *fields, a *mocks.Archiver) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(generation, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&archiverFactory{FeedFactory: f.factory, Archiver: a}, nil)
+				a.On("OpenArchivedFile", generation, "test_0.csv").Return(file, nil)
+			},
+			want: file,
+		},
+		{
+			name: "factory keeps no files",
+			setupMocks: func(f *fields, a *mocks.Archiver) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(generation, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").Return(f.factory, nil)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrFileNotFound,
+		},
+		{
+			name: "type no longer configured",
+			setupMocks: func(f *fields, a *mocks.Archiver) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(generation, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").Return(nil, entity.ErrInvalidGenerationType)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrFileNotFound,
+		},
+		{
+			name: "archiver.OpenArchivedFile error",
+			setupMocks: func(f *fields, a *mocks.Archiver) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(generation, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&archiverFactory{FeedFactory: f.factory, Archiver: a}, nil)
+				a.On("OpenArchivedFile", generation, "test_0.csv").Return(nil, entity.ErrFileNotFound)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrFileNotFound,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			archiver := new(mocks.Archiver)
+			interactor := fields.newInteractor()
+			testCase.setupMocks(fields, archiver)
+
+			got, gotErr := interactor.OpenGenerationFile(context.Background(), defaultID, "test_0.csv")
+
+			assert.ErrorIs(t, gotErr, testCase.wantErr)
+			assert.Equal(t, testCase.want, got)
+			fields.assertExpectations(t)
+			archiver.AssertExpectations(t)
+		})
+	}
+}
+
+func TestFeedInteractor_PreviewGenerationFile(t *testing.T) {
+	generation := &entity.Generation{ID: defaultID, Type: "test"}
+	rows := [][]string{{"id", "name"}, {"1", "a"}}
+	testCases := []struct {
+		name       string
+		setupMocks func(*fields, *mocks.Archiver)
+		want       interface{}
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			setupMocks: func(f *fields, a *mocks.Archiver) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(generation, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&archiverFactory{FeedFactory: f.factory, Archiver: a}, nil)
+				a.On("PreviewArchivedFile", generation, "test_0.csv", uint(2)).Return(rows, nil)
+				f.presenter.On("PresentFilePreview", mock.Anything).
+					Return(func(out *interactor.FilePreviewOut) interface{} {
+						return out
+					})
+			},
+			want: &interactor.FilePreviewOut{Name: "test_0.csv", Rows: rows},
+		},
+		{
+			name: "factory keeps no files",
+			setupMocks: func(f *fields, a *mocks.Archiver) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(generation, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").Return(f.factory, nil)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrFileNotFound,
+		},
+		{
+			name: "archiver.PreviewArchivedFile error",
+			setupMocks: func(f *fields, a *mocks.Archiver) {
+				f.feeds.On("GetGeneration", mock.Anything, defaultID).Return(generation, nil)
+				f.feeds.On("GetFactoryByGenerationType", "test").
+					Return(&archiverFactory{FeedFactory: f.factory, Archiver: a}, nil)
+				a.On("PreviewArchivedFile", generation, "test_0.csv", uint(2)).Return(nil, defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			archiver := new(mocks.Archiver)
+			interactor := fields.newInteractor()
+			testCase.setupMocks(fields, archiver)
+
+			got, gotErr := interactor.PreviewGenerationFile(context.Background(), defaultID, "test_0.csv", 2)
+
+			assert.ErrorIs(t, gotErr, testCase.wantErr)
+			assert.Equal(t, testCase.want, got)
+			fields.assertExpectations(t)
+			archiver.AssertExpectations(t)
+		})
+	}
+}
+
+type (
+	previewFactory struct {
+		*mocks.FeedFactory
+		*mocks.Previewer
+	}
+
+	// recordsFetcher sends the header and records like the SQL fetcher,
+	// rejecting the records starting with "invalid".
+	recordsFetcher struct {
+		outStream        chan<- []string
+		records          [][]string
+		err              error
+		onRecordsCounted func(uint)
+		onRecordInvalid  func([]string, error)
+	}
+)
+
+func (f *recordsFetcher) StreamData(ctx context.Context) error {
+	f.onRecordsCounted(uint(len(f.records) - 1))
+	for _, record := range f.records {
+		if record[0] == "invalid" {
+			f.onRecordInvalid(record, defaultErr)
+			continue
+		}
+		select {
+		case f.outStream <- record:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return f.err
+}
+
+func (f *recordsFetcher) OnDataFetched(func()) {}
+
+func (f *recordsFetcher) OnProgress(func(uint)) {}
+
+func (f *recordsFetcher) OnRecordsCounted(callback func(uint)) {
+	f.onRecordsCounted = callback
+}
+
+func (f *recordsFetcher) OnRecordInvalid(callback func([]string, error)) {
+	f.onRecordInvalid = callback
+}
+
+func TestFeedInteractor_PreviewFeed(t *testing.T) {
+	records := [][]string{{"id"}, {"1"}, {"invalid"}, {"2"}, {"3"}, {"4"}}
+	files := []*entity.PreviewFile{
+		{FileInfo: &entity.FileInfo{Name: "test_0.csv", Records: 2}, Content: "id\n1\n2\n"},
+	}
+	type setup struct {
+		rows      uint
+		fetcher   *recordsFetcher
+		formatted [][]string
+		previewer *mocks.Previewer
+		uploader  *mocks.PreviewUploader
+	}
+	// setupPipeline makes the formatter read every record it gets.
+	setupPipeline := func(f *fields, s *setup, formatErr error) {
+		var inStream <-chan []string
+		f.feeds.On("GetFactoryByGenerationType", "test").
+			Return(&previewFactory{FeedFactory: f.factory, Previewer: s.previewer}, nil)
+		s.previewer.On("CreatePreviewDataFetcher", mock.Anything, s.rows).
+			Run(func(args mock.Arguments) {
+				s.fetcher.outStream = args.Get(0).(chan<- []string)
+			}).
+			Return(s.fetcher)
+		f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				inStream = args.Get(1).(<-chan []string)
+			}).
+			Return(f.fileFormatter)
+		f.factory.On("Cleanup", mock.Anything).Return(nil)
+		f.fileFormatter.On("FormatFiles", mock.Anything).
+			Run(func(mock.Arguments) {
+				for record := range inStream {
+					s.formatted = append(s.formatted, record)
+				}
+			}).
+			Return(formatErr)
+	}
+	testCases := []struct {
+		name          string
+		rows          uint
+		fetchErr      error
+		setupMocks    func(*fields, *setup)
+		want          *entity.FeedPreview
+		wantFormatted [][]string
+		wantErr       error
+	}{
+		{
+			name: "first rows",
+			rows: 2,
+			setupMocks: func(f *fields, s *setup) {
+				setupPipeline(f, s, nil)
+				s.previewer.On("CreatePreviewUploader", mock.Anything, mock.Anything, true).Return(s.uploader)
+				s.uploader.On("UploadFiles", mock.Anything).Return(nil)
+				s.uploader.On("Files").Return(files)
+				f.presenter.On("PresentFeedPreview", mock.Anything).
+					Return(func(preview *entity.FeedPreview) interface{} {
+						return preview
+					})
+			},
+			want: &entity.FeedPreview{
+				Type:           "test",
+				RecordsCount:   5,
+				Records:        2,
+				InvalidRecords: 1,
+				Rejected:       []*entity.RejectedRecord{{Record: []string{"invalid"}, Error: defaultErr.Error()}},
+				Files:          files,
+			},
+			wantFormatted: [][]string{{"id"}, {"1"}, {"2"}},
+		},
+		{
+			name: "fewer records than rows",
+			rows: 10,
+			setupMocks: func(f *fields, s *setup) {
+				setupPipeline(f, s, nil)
+				s.previewer.On("CreatePreviewUploader", mock.Anything, mock.Anything, true).Return(s.uploader)
+				s.uploader.On("UploadFiles", mock.Anything).Return(nil)
+				s.uploader.On("Files").Return(files)
+				f.presenter.On("PresentFeedPreview", mock.Anything).
+					Return(func(preview *entity.FeedPreview) interface{} {
+						return preview
+					})
+			},
+			want: &entity.FeedPreview{
+				Type:           "test",
+				RecordsCount:   5,
+				Records:        4,
+				InvalidRecords: 1,
+				Rejected:       []*entity.RejectedRecord{{Record: []string{"invalid"}, Error: defaultErr.Error()}},
+				Files:          files,
+			},
+			wantFormatted: [][]string{{"id"}, {"1"}, {"2"}, {"3"}, {"4"}},
+		},
+		{
+			name:     "fetch error",
+			rows:     10,
+			fetchErr: defaultErr,
+			setupMocks: func(f *fields, s *setup) {
+				setupPipeline(f, s, nil)
+				s.previewer.On("CreatePreviewUploader", mock.Anything, mock.Anything, true).Return(s.uploader)
+				s.uploader.On("UploadFiles", mock.Anything).Return(nil)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "format error",
+			rows: 2,
+			setupMocks: func(f *fields, s *setup) {
+				setupPipeline(f, s, defaultErr)
+				s.previewer.On("CreatePreviewUploader", mock.Anything, mock.Anything, true).Return(s.uploader)
+				s.uploader.On("UploadFiles", mock.Anything).Return(nil)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+		{
+			name: "factory can't preview",
+			rows: 2,
+			setupMocks: func(f *fields, s *setup) {
+				f.feeds.On("GetFactoryByGenerationType", "test").Return(f.factory, nil)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrPreviewNotSupported,
+		},
+		{
+			name: "unknown generation type",
+			rows: 2,
+			setupMocks: func(f *fields, s *setup) {
+				f.feeds.On("GetFactoryByGenerationType", "test").Return(nil, entity.ErrInvalidGenerationType)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: entity.ErrInvalidGenerationType,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			s := &setup{
+				rows:      testCase.rows,
+				fetcher:   &recordsFetcher{records: records, err: testCase.fetchErr},
+				previewer: new(mocks.Previewer),
+				uploader:  new(mocks.PreviewUploader),
+			}
+			testCase.setupMocks(fields, s)
+
+			got, gotErr := fields.newInteractor().PreviewFeed(context.Background(), "test", testCase.rows)
+
+			assert.ErrorIs(t, gotErr, testCase.wantErr)
+			if testCase.want != nil {
+				assert.Equal(t, testCase.want, got)
+				assert.Equal(t, testCase.wantFormatted, s.formatted)
+			} else {
+				assert.Nil(t, got)
+			}
+			fields.assertExpectations(t)
+			s.previewer.AssertExpectations(t)
+			s.uploader.AssertExpectations(t)
+		})
+	}
+}
+
+func TestFeedInteractor_DryRunFeed(t *testing.T) {
+	fields := defaultFields()
+	previewer := new(mocks.Previewer)
+	uploader := new(mocks.PreviewUploader)
+	fetcher := &recordsFetcher{records: [][]string{{"id"}, {"1"}, {"2"}}}
+	files := []*entity.PreviewFile{{FileInfo: &entity.FileInfo{Name: "test_0.csv", Records: 2}}}
+	var inStream <-chan []string
+	var formatted uint
+	fields.feeds.On("GetFactoryByGenerationType", "test").
+		Return(&previewFactory{FeedFactory: fields.factory, Previewer: previewer}, nil)
+	fields.factory.On("CreateDataFetcher", mock.Anything).
+		Run(func(args mock.Arguments) {
+			fetcher.outStream = args.Get(0).(chan<- []string)
+		}).
+		Return(fetcher)
+	fields.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			inStream = args.Get(1).(<-chan []string)
+		}).
+		Return(fields.fileFormatter)
+	fields.factory.On("Cleanup", mock.Anything).Return(nil)
+	fields.fileFormatter.On("FormatFiles", mock.Anything).
+		Run(func(mock.Arguments) {
+			for range inStream {
+				formatted++
+			}
+		}).
+		Return(nil)
+	previewer.On("CreatePreviewUploader", mock.Anything, mock.Anything, false).Return(uploader)
+	uploader.On("UploadFiles", mock.Anything).Return(nil)
+	uploader.On("Files").Return(files)
+	fields.presenter.On("PresentFeedPreview", mock.Anything).
+		Return(func(preview *entity.FeedPreview) interface{} {
+			return preview
+		})
+
+	got, gotErr := fields.newInteractor().DryRunFeed(context.Background(), "test")
+
+	assert.NoError(t, gotErr)
+	assert.Equal(t, &entity.FeedPreview{
+		Type:         "test",
+		DryRun:       true,
+		RecordsCount: 2,
+		Records:      2,
+		Files:        files,
+	}, got)
+	assert.Equal(t, uint(3), formatted)
+	fields.assertExpectations(t)
+	previewer.AssertExpectations(t)
+	uploader.AssertExpectations(t)
+}
+
+func TestFeedInteractor_RollbackGeneration(t *testing.T) {
+	testCases := []struct {
+		name       string
+		setupMocks func(*fields)
+		wantErr    error
+	}{
+		{
+			name: "succeed",
+			setupMocks: func(f *fields) {
+				f.feeds.On("RollbackGeneration", mock.Anything, "test", defaultID).Return(nil)
+			},
+		},
+		{
+			name: "feeds.RollbackGeneration error",
+			setupMocks: func(f *fields) {
+				f.feeds.On("RollbackGeneration", mock.Anything, "test", defaultID).Return(defaultErr)
+				f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+			},
+			wantErr: defaultErr,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields := defaultFields()
+			interactor := fields.newInteractor()
+			testCase.setupMocks(fields)
+
+			gotErr := interactor.RollbackGeneration(context.Background(), "test", defaultID)
+
+			assert.Equal(t, testCase.wantErr, gotErr)
+			fields.assertExpectations(t)
+		})
+	}
+}
@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	entity "go-feedmaker/entity"
 	interactor "go-feedmaker/interactor"
 	io "io"
 
@@ -14,6 +15,20 @@ type FeedFactory struct {
 	mock.Mock
 }
 
+// Cleanup provides a mock function with given fields: generation
+func (_m *FeedFactory) Cleanup(generation *entity.Generation) error {
+	ret := _m.Called(generation)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*entity.Generation) error); ok {
+		r0 = rf(generation)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreateDataFetcher provides a mock function with given fields: outStream
 func (_m *FeedFactory) CreateDataFetcher(outStream chan<- []string) interactor.DataFetcher {
 	ret := _m.Called(outStream)
@@ -30,13 +45,13 @@ func (_m *FeedFactory) CreateDataFetcher(outStream chan<- []string) interactor.D
 	return r0
 }
 
-// CreateFileFormatter provides a mock function with given fields: inStream, outStream
-func (_m *FeedFactory) CreateFileFormatter(inStream <-chan []string, outStream chan<- io.ReadCloser) interactor.FileFormatter {
-	ret := _m.Called(inStream, outStream)
+// CreateFileFormatter provides a mock function with given fields: generation, inStream, outStream
+func (_m *FeedFactory) CreateFileFormatter(generation *entity.Generation, inStream <-chan []string, outStream chan<- io.ReadCloser) interactor.FileFormatter {
+	ret := _m.Called(generation, inStream, outStream)
 
 	var r0 interactor.FileFormatter
-	if rf, ok := ret.Get(0).(func(<-chan []string, chan<- io.ReadCloser) interactor.FileFormatter); ok {
-		r0 = rf(inStream, outStream)
+	if rf, ok := ret.Get(0).(func(*entity.Generation, <-chan []string, chan<- io.ReadCloser) interactor.FileFormatter); ok {
+		r0 = rf(generation, inStream, outStream)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(interactor.FileFormatter)
@@ -46,13 +61,13 @@ func (_m *FeedFactory) CreateFileFormatter(inStream <-chan []string, outStream c
 	return r0
 }
 
-// CreateUploader provides a mock function with given fields: inStream
-func (_m *FeedFactory) CreateUploader(inStream <-chan io.ReadCloser) interactor.Uploader {
-	ret := _m.Called(inStream)
+// CreateUploader provides a mock function with given fields: generation, inStream
+func (_m *FeedFactory) CreateUploader(generation *entity.Generation, inStream <-chan io.ReadCloser) interactor.Uploader {
+	ret := _m.Called(generation, inStream)
 
 	var r0 interactor.Uploader
-	if rf, ok := ret.Get(0).(func(<-chan io.ReadCloser) interactor.Uploader); ok {
-		r0 = rf(inStream)
+	if rf, ok := ret.Get(0).(func(*entity.Generation, <-chan io.ReadCloser) interactor.Uploader); ok {
+		r0 = rf(generation, inStream)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(interactor.Uploader)
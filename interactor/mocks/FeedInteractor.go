@@ -5,6 +5,7 @@ package mocks
 import (
 	context "context"
 	entity "go-feedmaker/entity"
+	interactor "go-feedmaker/interactor"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -14,6 +15,20 @@ type FeedInteractor struct {
 	mock.Mock
 }
 
+// ApplyRetention provides a mock function with given fields: ctx, policy
+func (_m *FeedInteractor) ApplyRetention(ctx context.Context, policy *entity.RetentionPolicy) error {
+	ret := _m.Called(ctx, policy)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.RetentionPolicy) error); ok {
+		r0 = rf(ctx, policy)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CancelGeneration provides a mock function with given fields: ctx, id
 func (_m *FeedInteractor) CancelGeneration(ctx context.Context, id string) error {
 	ret := _m.Called(ctx, id)
@@ -28,6 +43,57 @@ func (_m *FeedInteractor) CancelGeneration(ctx context.Context, id string) error
 	return r0
 }
 
+// DryRunFeed provides a mock function with given fields: ctx, generationType
+func (_m *FeedInteractor) DryRunFeed(ctx context.Context, generationType string) (interface{}, error) {
+	ret := _m.Called(ctx, generationType)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(context.Context, string) interface{}); ok {
+		r0 = rf(ctx, generationType)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, generationType)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FailInterruptedGenerations provides a mock function with given fields: ctx
+func (_m *FeedInteractor) FailInterruptedGenerations(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FollowGenerationLogs provides a mock function with given fields: ctx, generationID, outStream
+func (_m *FeedInteractor) FollowGenerationLogs(ctx context.Context, generationID string, outStream chan<- interface{}) error {
+	ret := _m.Called(ctx, generationID, outStream)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, chan<- interface{}) error); ok {
+		r0 = rf(ctx, generationID, outStream)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GenerateFeed provides a mock function with given fields: ctx, generationType
 func (_m *FeedInteractor) GenerateFeed(ctx context.Context, generationType string) error {
 	ret := _m.Called(ctx, generationType)
@@ -42,6 +108,75 @@ func (_m *FeedInteractor) GenerateFeed(ctx context.Context, generationType strin
 	return r0
 }
 
+// GetGeneration provides a mock function with given fields: ctx, generationID
+func (_m *FeedInteractor) GetGeneration(ctx context.Context, generationID string) (interface{}, error) {
+	ret := _m.Called(ctx, generationID)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(context.Context, string) interface{}); ok {
+		r0 = rf(ctx, generationID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, generationID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGenerationLogs provides a mock function with given fields: ctx, generationID
+func (_m *FeedInteractor) GetGenerationLogs(ctx context.Context, generationID string) (interface{}, error) {
+	ret := _m.Called(ctx, generationID)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(context.Context, string) interface{}); ok {
+		r0 = rf(ctx, generationID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, generationID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListGenerationFiles provides a mock function with given fields: ctx, generationID
+func (_m *FeedInteractor) ListGenerationFiles(ctx context.Context, generationID string) (interface{}, error) {
+	ret := _m.Called(ctx, generationID)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(context.Context, string) interface{}); ok {
+		r0 = rf(ctx, generationID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, generationID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListGenerationTypes provides a mock function with given fields: ctx
 func (_m *FeedInteractor) ListGenerationTypes(ctx context.Context) (interface{}, error) {
 	ret := _m.Called(ctx)
@@ -65,13 +200,13 @@ func (_m *FeedInteractor) ListGenerationTypes(ctx context.Context) (interface{},
 	return r0, r1
 }
 
-// ListGenerations provides a mock function with given fields: ctx
-func (_m *FeedInteractor) ListGenerations(ctx context.Context) (interface{}, error) {
-	ret := _m.Called(ctx)
+// ListGenerations provides a mock function with given fields: ctx, filter
+func (_m *FeedInteractor) ListGenerations(ctx context.Context, filter *entity.GenerationFilter) (interface{}, error) {
+	ret := _m.Called(ctx, filter)
 
 	var r0 interface{}
-	if rf, ok := ret.Get(0).(func(context.Context) interface{}); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.GenerationFilter) interface{}); ok {
+		r0 = rf(ctx, filter)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(interface{})
@@ -79,8 +214,77 @@ func (_m *FeedInteractor) ListGenerations(ctx context.Context) (interface{}, err
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
+	if rf, ok := ret.Get(1).(func(context.Context, *entity.GenerationFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// OpenGenerationFile provides a mock function with given fields: ctx, generationID, name
+func (_m *FeedInteractor) OpenGenerationFile(ctx context.Context, generationID string, name string) (interactor.ArchivedFile, error) {
+	ret := _m.Called(ctx, generationID, name)
+
+	var r0 interactor.ArchivedFile
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) interactor.ArchivedFile); ok {
+		r0 = rf(ctx, generationID, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interactor.ArchivedFile)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, generationID, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PreviewFeed provides a mock function with given fields: ctx, generationType, rows
+func (_m *FeedInteractor) PreviewFeed(ctx context.Context, generationType string, rows uint) (interface{}, error) {
+	ret := _m.Called(ctx, generationType, rows)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint) interface{}); ok {
+		r0 = rf(ctx, generationType, rows)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, uint) error); ok {
+		r1 = rf(ctx, generationType, rows)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PreviewGenerationFile provides a mock function with given fields: ctx, generationID, name, rows
+func (_m *FeedInteractor) PreviewGenerationFile(ctx context.Context, generationID string, name string, rows uint) (interface{}, error) {
+	ret := _m.Called(ctx, generationID, name, rows)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, uint) interface{}); ok {
+		r0 = rf(ctx, generationID, name, rows)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, uint) error); ok {
+		r1 = rf(ctx, generationID, name, rows)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -88,6 +292,20 @@ func (_m *FeedInteractor) ListGenerations(ctx context.Context) (interface{}, err
 	return r0, r1
 }
 
+// PurgeGeneration provides a mock function with given fields: ctx, id
+func (_m *FeedInteractor) PurgeGeneration(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // RestartGeneration provides a mock function with given fields: ctx, generationID
 func (_m *FeedInteractor) RestartGeneration(ctx context.Context, generationID string) error {
 	ret := _m.Called(ctx, generationID)
@@ -102,6 +320,34 @@ func (_m *FeedInteractor) RestartGeneration(ctx context.Context, generationID st
 	return r0
 }
 
+// RetryUpload provides a mock function with given fields: ctx, generationID
+func (_m *FeedInteractor) RetryUpload(ctx context.Context, generationID string) error {
+	ret := _m.Called(ctx, generationID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, generationID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RollbackGeneration provides a mock function with given fields: ctx, generationType, generationID
+func (_m *FeedInteractor) RollbackGeneration(ctx context.Context, generationType string, generationID string) error {
+	ret := _m.Called(ctx, generationType, generationID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, generationType, generationID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // WatchGenerationsProgress provides a mock function with given fields: ctx, outStream
 func (_m *FeedInteractor) WatchGenerationsProgress(ctx context.Context, outStream chan<- *entity.Generation) error {
 	ret := _m.Called(ctx, outStream)
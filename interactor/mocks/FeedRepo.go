@@ -30,6 +30,34 @@ func (_m *FeedRepo) CancelGeneration(ctx context.Context, id string) error {
 	return r0
 }
 
+// DeleteGeneration provides a mock function with given fields: ctx, id
+func (_m *FeedRepo) DeleteGeneration(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FollowGenerationLogs provides a mock function with given fields: ctx, generationID, callback
+func (_m *FeedRepo) FollowGenerationLogs(ctx context.Context, generationID string, callback func(*entity.LogEntry)) error {
+	ret := _m.Called(ctx, generationID, callback)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, func(*entity.LogEntry)) error); ok {
+		r0 = rf(ctx, generationID, callback)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetFactoryByGenerationType provides a mock function with given fields: generationType
 func (_m *FeedRepo) GetFactoryByGenerationType(generationType string) (interactor.FeedFactory, error) {
 	ret := _m.Called(generationType)
@@ -106,22 +134,22 @@ func (_m *FeedRepo) ListAllowedTypes() []string {
 	return r0
 }
 
-// ListGenerations provides a mock function with given fields: ctx
-func (_m *FeedRepo) ListGenerations(ctx context.Context) ([]*entity.Generation, error) {
-	ret := _m.Called(ctx)
+// ListGenerationLogs provides a mock function with given fields: ctx, generationID
+func (_m *FeedRepo) ListGenerationLogs(ctx context.Context, generationID string) ([]*entity.LogEntry, error) {
+	ret := _m.Called(ctx, generationID)
 
-	var r0 []*entity.Generation
-	if rf, ok := ret.Get(0).(func(context.Context) []*entity.Generation); ok {
-		r0 = rf(ctx)
+	var r0 []*entity.LogEntry
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*entity.LogEntry); ok {
+		r0 = rf(ctx, generationID)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*entity.Generation)
+			r0 = ret.Get(0).([]*entity.LogEntry)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, generationID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -129,6 +157,36 @@ func (_m *FeedRepo) ListGenerations(ctx context.Context) ([]*entity.Generation,
 	return r0, r1
 }
 
+// ListGenerations provides a mock function with given fields: ctx, filter
+func (_m *FeedRepo) ListGenerations(ctx context.Context, filter *entity.GenerationFilter) ([]*entity.Generation, string, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []*entity.Generation
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.GenerationFilter) []*entity.Generation); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*entity.Generation)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, *entity.GenerationFilter) string); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, *entity.GenerationFilter) error); ok {
+		r2 = rf(ctx, filter)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // OnGenerationCanceled provides a mock function with given fields: ctx, id, callback
 func (_m *FeedRepo) OnGenerationCanceled(ctx context.Context, id string, callback func()) error {
 	ret := _m.Called(ctx, id, callback)
@@ -143,13 +201,27 @@ func (_m *FeedRepo) OnGenerationCanceled(ctx context.Context, id string, callbac
 	return r0
 }
 
-// OnGenerationsUpdated provides a mock function with given fields: ctx, callback
-func (_m *FeedRepo) OnGenerationsUpdated(ctx context.Context, callback func(*entity.Generation)) error {
-	ret := _m.Called(ctx, callback)
+// OnGenerationsUpdated provides a mock function with given fields: ctx, onSubscribed, callback
+func (_m *FeedRepo) OnGenerationsUpdated(ctx context.Context, onSubscribed func() error, callback func(*entity.Generation)) error {
+	ret := _m.Called(ctx, onSubscribed, callback)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func() error, func(*entity.Generation)) error); ok {
+		r0 = rf(ctx, onSubscribed, callback)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RollbackGeneration provides a mock function with given fields: ctx, generationType, generationID
+func (_m *FeedRepo) RollbackGeneration(ctx context.Context, generationType string, generationID string) error {
+	ret := _m.Called(ctx, generationType, generationID)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, func(*entity.Generation)) error); ok {
-		r0 = rf(ctx, callback)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, generationType, generationID)
 	} else {
 		r0 = ret.Error(0)
 	}
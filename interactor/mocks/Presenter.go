@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	entity "go-feedmaker/entity"
 	interactor "go-feedmaker/interactor"
 
 	mock "github.com/stretchr/testify/mock"
@@ -27,6 +28,86 @@ func (_m *Presenter) PresentErr(err error) error {
 	return r0
 }
 
+// PresentFeedPreview provides a mock function with given fields: preview
+func (_m *Presenter) PresentFeedPreview(preview *entity.FeedPreview) interface{} {
+	ret := _m.Called(preview)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(*entity.FeedPreview) interface{}); ok {
+		r0 = rf(preview)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	return r0
+}
+
+// PresentFilePreview provides a mock function with given fields: out
+func (_m *Presenter) PresentFilePreview(out *interactor.FilePreviewOut) interface{} {
+	ret := _m.Called(out)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(*interactor.FilePreviewOut) interface{}); ok {
+		r0 = rf(out)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	return r0
+}
+
+// PresentGeneration provides a mock function with given fields: out
+func (_m *Presenter) PresentGeneration(out *interactor.GenerationsOut) interface{} {
+	ret := _m.Called(out)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(*interactor.GenerationsOut) interface{}); ok {
+		r0 = rf(out)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	return r0
+}
+
+// PresentGenerationFiles provides a mock function with given fields: files
+func (_m *Presenter) PresentGenerationFiles(files []*entity.FileInfo) interface{} {
+	ret := _m.Called(files)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func([]*entity.FileInfo) interface{}); ok {
+		r0 = rf(files)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	return r0
+}
+
+// PresentGenerationLogs provides a mock function with given fields: entries
+func (_m *Presenter) PresentGenerationLogs(entries []*entity.LogEntry) interface{} {
+	ret := _m.Called(entries)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func([]*entity.LogEntry) interface{}); ok {
+		r0 = rf(entries)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	return r0
+}
+
 // PresentGenerationTypes provides a mock function with given fields: _a0
 func (_m *Presenter) PresentGenerationTypes(_a0 []string) interface{} {
 	ret := _m.Called(_a0)
@@ -58,3 +139,19 @@ func (_m *Presenter) PresentListGenerations(out *interactor.ListGenerationsOut)
 
 	return r0
 }
+
+// PresentLogEntry provides a mock function with given fields: entry
+func (_m *Presenter) PresentLogEntry(entry *entity.LogEntry) interface{} {
+	ret := _m.Called(entry)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(*entity.LogEntry) interface{}); ok {
+		r0 = rf(entry)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	return r0
+}
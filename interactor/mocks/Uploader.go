@@ -5,6 +5,8 @@ package mocks
 import (
 	context "context"
 
+	entity "go-feedmaker/entity"
+
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -13,6 +15,11 @@ type Uploader struct {
 	mock.Mock
 }
 
+// OnManifestUploaded provides a mock function with given fields: _a0
+func (_m *Uploader) OnManifestUploaded(_a0 func(*entity.Manifest)) {
+	_m.Called(_a0)
+}
+
 // OnUpload provides a mock function with given fields: _a0
 func (_m *Uploader) OnUpload(_a0 func(uint)) {
 	_m.Called(_a0)
@@ -0,0 +1,84 @@
+package interactor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-feedmaker/entity"
+)
+
+// stateWriter stores the state of a running generation at most once per
+// interval; the changes made in between are stored together once the
+// interval passed. A pending write may run at any time, so every change to
+// the generation while it runs, stage timings included, goes through Update
+// or Write.
+type stateWriter struct {
+	feeds      FeedRepo
+	generation *entity.Generation
+	interval   time.Duration
+	mu         sync.Mutex
+	lastWrite  time.Time
+	pending    *time.Timer
+}
+
+func newStateWriter(feeds FeedRepo, generation *entity.Generation, interval time.Duration) *stateWriter {
+	return &stateWriter{
+		feeds:      feeds,
+		generation: generation,
+		interval:   interval,
+	}
+}
+
+// Update changes the generation and stores its state, at once when the
+// interval since the last write passed and later otherwise.
+func (w *stateWriter) Update(change func(generation *entity.Generation)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	change(w.generation)
+	if w.pending != nil {
+		return
+	}
+	wait := w.interval - time.Since(w.lastWrite)
+	if wait <= 0 {
+		w.logErr(w.write())
+		return
+	}
+	w.pending = time.AfterFunc(wait, w.writePending)
+}
+
+// Write changes the generation and stores its state at once, together with
+// the pending changes.
+func (w *stateWriter) Write(change func(generation *entity.Generation)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	change(w.generation)
+	if w.pending != nil {
+		w.pending.Stop()
+		w.pending = nil
+	}
+	return w.write()
+}
+
+func (w *stateWriter) writePending() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Write stored the changes in the meantime.
+	if w.pending == nil {
+		return
+	}
+	w.pending = nil
+	w.logErr(w.write())
+}
+
+func (w *stateWriter) write() error {
+	w.lastWrite = time.Now()
+	return w.feeds.UpdateGenerationState(context.Background(), w.generation)
+}
+
+func (w *stateWriter) logErr(err error) {
+	if err != nil {
+		generationLog(w.generation).Error().Err(err).
+			Msgf("Cannot update state for %s", w.generation.ID)
+	}
+}
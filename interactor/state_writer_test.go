@@ -0,0 +1,226 @@
+package interactor_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-feedmaker/entity"
+	"go-feedmaker/interactor"
+	"go-feedmaker/interactor/mocks"
+)
+
+type (
+	// storedStates records the progress of every state stored.
+	storedStates struct {
+		mu       sync.Mutex
+		progress []uint
+		stored   chan struct{}
+	}
+)
+
+func newStoredStates(feeds *mocks.FeedRepo, err error) *storedStates {
+	s := &storedStates{stored: make(chan struct{}, 10)}
+	feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			s.mu.Lock()
+			s.progress = append(s.progress, args.Get(1).(*entity.Generation).Progress)
+			s.mu.Unlock()
+			s.stored <- struct{}{}
+		}).
+		Return(err)
+	return s
+}
+
+func (s *storedStates) get() []uint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.progress
+}
+
+func setProgress(progress uint) func(*entity.Generation) {
+	return func(generation *entity.Generation) {
+		generation.Progress = progress
+	}
+}
+
+func TestStateWriter_Update(t *testing.T) {
+	testCases := []struct {
+		name     string
+		interval time.Duration
+		do       func(w *interactor.StateWriter, s *storedStates)
+		want     []uint
+	}{
+		{
+			name:     "every change without interval",
+			interval: 0,
+			do: func(w *interactor.StateWriter, s *storedStates) {
+				w.Update(setProgress(1))
+				w.Update(setProgress(2))
+				w.Update(setProgress(3))
+			},
+			want: []uint{1, 2, 3},
+		},
+		{
+			name:     "changes in the interval together",
+			interval: 20 * time.Millisecond,
+			do: func(w *interactor.StateWriter, s *storedStates) {
+				w.Update(setProgress(1))
+				w.Update(setProgress(2))
+				w.Update(setProgress(3))
+				<-s.stored
+				<-s.stored
+			},
+			want: []uint{1, 3},
+		},
+		{
+			name:     "final state at once",
+			interval: time.Hour,
+			do: func(w *interactor.StateWriter, s *storedStates) {
+				w.Update(setProgress(1))
+				w.Update(setProgress(2))
+				assert.NoError(t, w.Write(setProgress(100)))
+			},
+			want: []uint{1, 100},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			feeds := new(mocks.FeedRepo)
+			states := newStoredStates(feeds, nil)
+			w := interactor.NewStateWriter(feeds, &entity.Generation{ID: "42"}, testCase.interval)
+
+			testCase.do(w, states)
+
+			assert.Equal(t, testCase.want, states.get())
+			feeds.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStateWriter_Write(t *testing.T) {
+	feeds := new(mocks.FeedRepo)
+	states := newStoredStates(feeds, defaultErr)
+	w := interactor.NewStateWriter(feeds, &entity.Generation{ID: "42"}, 10*time.Millisecond)
+	w.Update(setProgress(1))
+	w.Update(setProgress(2))
+
+	gotErr := w.Write(setProgress(3))
+
+	// The pending write is dropped, its change is stored with the final one.
+	<-time.After(20 * time.Millisecond)
+	assert.Equal(t, defaultErr, gotErr)
+	assert.Equal(t, []uint{1, 3}, states.get())
+	feeds.AssertExpectations(t)
+}
+
+// The stages run while the pending writes of their state do, which is only
+// caught under -race with an interval.
+func TestFeedInteractor_GenerateFeed_throttledState(t *testing.T) {
+	f := defaultFields()
+	var mu sync.Mutex
+	var last []byte
+	f.feeds.On("GetFactoryByGenerationType", "test").Return(f.factory, nil)
+	f.feeds.On("StoreGeneration", mock.Anything, mock.Anything).Return(nil)
+	f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			state, err := json.Marshal(args.Get(1))
+			assert.NoError(t, err)
+			mu.Lock()
+			last = state
+			mu.Unlock()
+		}).
+		Return(nil)
+	f.factory.On("CreateDataFetcher", mock.Anything).Return(f.dataFetcher)
+	f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).Return(f.fileFormatter)
+	f.factory.On("Cleanup", mock.Anything).Return(nil)
+	f.factory.On("CreateUploader", mock.Anything, mock.Anything).Return(f.uploader)
+	var onProgress func(uint)
+	var onUpload func(uint)
+	f.dataFetcher.
+		On("OnDataFetched", mock.Anything).Return(nil).
+		On("OnProgress", mock.Anything).Run(func(args mock.Arguments) {
+		onProgress = args.Get(0).(func(uint))
+	}).Return(nil).
+		On("StreamData", mock.Anything).Run(func(mock.Arguments) {
+		for progress := uint(1); progress <= 100; progress += 11 {
+			onProgress(progress)
+			time.Sleep(time.Millisecond)
+		}
+	}).Return(nil)
+	f.fileFormatter.On("FormatFiles", mock.Anything).Return(nil)
+	f.uploader.
+		On("OnManifestUploaded", mock.Anything).Return(nil).
+		On("OnUpload", mock.Anything).Run(func(args mock.Arguments) {
+		onUpload = args.Get(0).(func(uint))
+	}).Return(nil).
+		On("UploadFiles", mock.Anything).Run(func(mock.Arguments) {
+		for uploaded := uint(1); uploaded <= 5; uploaded++ {
+			onUpload(uploaded)
+			time.Sleep(time.Millisecond)
+		}
+	}).Return(nil)
+	feedInteractor := interactor.NewFeedInteractor(f.feeds, f.presenter, time.Millisecond, "")
+
+	gotErr := feedInteractor.GenerateFeed(context.Background(), "test")
+
+	assert.NoError(t, gotErr)
+	got := new(entity.Generation)
+	mu.Lock()
+	assert.NoError(t, json.Unmarshal(last, got))
+	mu.Unlock()
+	assert.Equal(t, uint(100), got.Progress)
+	assert.Equal(t, uint(5), got.FilesUploaded)
+	for _, stage := range got.Stages {
+		assert.False(t, stage.EndTime.IsZero(), stage.Name)
+	}
+	f.assertExpectations(t)
+}
+
+// Every stage has ended before the error is stored, yet no stored state may
+// report the generation succeeded.
+func TestFeedInteractor_GenerateFeed_failedState(t *testing.T) {
+	f := defaultFields()
+	var mu sync.Mutex
+	var statuses []entity.GenerationStatus
+	f.feeds.On("GetFactoryByGenerationType", "test").Return(f.factory, nil)
+	f.feeds.On("StoreGeneration", mock.Anything, mock.Anything).Return(nil)
+	f.feeds.On("OnGenerationCanceled", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	f.feeds.On("UpdateGenerationState", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			mu.Lock()
+			defer mu.Unlock()
+			statuses = append(statuses, args.Get(1).(*entity.Generation).Status())
+		}).
+		Return(nil)
+	f.factory.On("CreateDataFetcher", mock.Anything).Return(f.dataFetcher)
+	f.factory.On("CreateFileFormatter", mock.Anything, mock.Anything, mock.Anything).Return(f.fileFormatter)
+	f.factory.On("Cleanup", mock.Anything).Return(nil)
+	f.factory.On("CreateUploader", mock.Anything, mock.Anything).Return(f.uploader)
+	f.dataFetcher.
+		On("OnDataFetched", mock.Anything).Return(nil).
+		On("OnProgress", mock.Anything).Return(nil).
+		On("StreamData", mock.Anything).Return(nil)
+	f.fileFormatter.On("FormatFiles", mock.Anything).Return(nil)
+	f.uploader.
+		On("OnManifestUploaded", mock.Anything).Return(nil).
+		On("OnUpload", mock.Anything).Return(nil).
+		On("UploadFiles", mock.Anything).Return(defaultErr)
+	f.presenter.On("PresentErr", mock.Anything).Return(errPassThrough)
+	feedInteractor := interactor.NewFeedInteractor(f.feeds, f.presenter, 0, "")
+
+	gotErr := feedInteractor.GenerateFeed(context.Background(), "test")
+
+	assert.ErrorIs(t, gotErr, defaultErr)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.NotContains(t, statuses, entity.GenerationSucceeded)
+	assert.Equal(t, entity.GenerationFailed, statuses[len(statuses)-1])
+	f.assertExpectations(t)
+}